@@ -0,0 +1,140 @@
+/*
+ * Copyright 2021 Johannes Donath <johannesd@torchmind.com>
+ * and other copyright owners as documented in the project's IP log.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package main
+
+import (
+	"archive/zip"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"github.com/dotstart/canoe/internal/classfile"
+	"github.com/google/subcommands"
+	"os"
+	"strings"
+)
+
+type analyzeCommand struct {
+	inputFile string
+	format    string
+	verbose   bool
+}
+
+func (*analyzeCommand) Name() string {
+	return "analyze"
+}
+
+func (*analyzeCommand) Synopsis() string {
+	return "identifies the runtime modules referenced by an archive"
+}
+
+func (*analyzeCommand) Usage() string {
+	return `canoegen analyze -in <file> [-format text|json] [args]
+
+Analyzes the classes within a given archive (or canoe executable) in order to identify the runtime
+modules it references:
+
+  $ canoegen analyze -in foo.jar
+
+The resulting list may be passed to jlink in order to assemble a minimal runtime image. Only direct
+references are considered - modules which are loaded reflectively or via service providers must be
+added manually.
+
+The result may additionally be recorded within generated executables via the "-record-modules" option
+of the wrap subcommand.
+
+The following configuration options are provided by this command:
+
+`
+}
+
+func (cmd *analyzeCommand) SetFlags(f *flag.FlagSet) {
+	f.StringVar(&cmd.inputFile, "in", "", "selects an input archive or executable (required)")
+	f.StringVar(&cmd.format, "format", "text", "selects an output format (text or json)")
+	f.BoolVar(&cmd.verbose, "verbose", false, "lists the packages through which each module is referenced")
+}
+
+func (cmd *analyzeCommand) Execute(context.Context, *flag.FlagSet, ...interface{}) subcommands.ExitStatus {
+	if len(cmd.inputFile) == 0 {
+		_, _ = fmt.Fprintln(os.Stderr, "invalid parameters: input file is required")
+		return subcommands.ExitUsageError
+	}
+	if cmd.format != "text" && cmd.format != "json" {
+		_, _ = fmt.Fprintf(os.Stderr, "invalid parameters: unsupported format: %s\n", cmd.format)
+		return subcommands.ExitUsageError
+	}
+
+	r, err := zip.OpenReader(cmd.inputFile)
+	if err != nil {
+		_, _ = fmt.Fprintf(os.Stderr, "failed to open archive: %s\n", err)
+		return subcommands.ExitFailure
+	}
+	defer r.Close()
+
+	analysis, err := analyzeArchive(&r.Reader)
+	if err != nil {
+		_, _ = fmt.Fprintf(os.Stderr, "failed to analyze archive: %s\n", err)
+		return subcommands.ExitFailure
+	}
+
+	if cmd.format == "json" {
+		encoded, err := json.MarshalIndent(struct {
+			Modules    []string            `json:"modules"`
+			Packages   map[string][]string `json:"packages"`
+			Unresolved []string            `json:"unresolved"`
+		}{analysis.RequiredModules(), analysis.Modules, analysis.Unresolved}, "", "  ")
+		if err != nil {
+			_, _ = fmt.Fprintf(os.Stderr, "failed to encode analysis: %s\n", err)
+			return subcommands.ExitFailure
+		}
+
+		fmt.Println(string(encoded))
+		return subcommands.ExitSuccess
+	}
+
+	for _, module := range analysis.RequiredModules() {
+		if cmd.verbose && len(analysis.Modules[module]) != 0 {
+			fmt.Printf("%s (%s)\n", module, strings.Join(analysis.Modules[module], ", "))
+		} else {
+			fmt.Println(module)
+		}
+	}
+
+	return subcommands.ExitSuccess
+}
+
+// analyzes the runtime modules referenced by a given archive
+//
+// a warning is displayed for each referenced runtime package which cannot be attributed to a known
+// module
+func analyzeArchive(r *zip.Reader) (*classfile.Analysis, error) {
+	manifest, err := readManifest(r)
+	if err != nil {
+		return nil, err
+	}
+
+	analysis, err := classfile.Analyze(r, manifest != nil && manifest.IsMultiRelease())
+	if err != nil {
+		return nil, err
+	}
+
+	for _, pkg := range analysis.Unresolved {
+		_, _ = fmt.Fprintf(os.Stderr, "warning: package %s is not exported by any known runtime module\n", pkg)
+	}
+
+	return analysis, nil
+}
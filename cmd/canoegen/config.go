@@ -0,0 +1,95 @@
+/*
+ * Copyright 2021 Johannes Donath <johannesd@torchmind.com>
+ * and other copyright owners as documented in the project's IP log.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package main
+
+import (
+	"flag"
+	"github.com/dotstart/canoe/internal/config"
+)
+
+// identifies the options which refer to files and are thus resolved relative to the configuration
+// file which declares them
+var configPathOptions = map[string]bool{
+	"in":                  true,
+	"out":                 true,
+	"wrapper":             true,
+	"sign-key":            true,
+	"authenticode-pkcs12": true,
+	"icon":                true,
+}
+
+// identifies the options which select configuration files or their sections and may thus not be
+// given within a configuration file
+var configSelectionOptions = map[string]bool{
+	"config":  true,
+	"app":     true,
+	"profile": true,
+}
+
+// applies the options declared within a given configuration file to a flag set
+//
+// options which have been explicitly passed on the command line take precedence over their
+// respective configuration file values. Each applied option is additionally passed to validate (if
+// given) in order to report semantic errors along with the location of the offending option.
+func applyConfig(f *flag.FlagSet, path string, application string, profile string, validate func(name string) error) error {
+	file, err := config.Load(path)
+	if err != nil {
+		return err
+	}
+
+	options, err := file.Resolve(application, profile)
+	if err != nil {
+		return err
+	}
+
+	isSet := visitedFlags(f)
+	for _, option := range options {
+		if configSelectionOptions[option.Name] {
+			return option.Errorf("option %q may only be given on the command line", option.Name)
+		}
+		if f.Lookup(option.Name) == nil {
+			return option.Errorf("unknown option %q", option.Name)
+		}
+		if isSet(option.Name) {
+			continue
+		}
+
+		values := []string{option.Value}
+		if option.Values != nil {
+			if _, ok := f.Lookup(option.Name).Value.(*stringList); !ok {
+				return option.Errorf("option %q does not accept multiple values", option.Name)
+			}
+
+			values = option.Values
+		} else if configPathOptions[option.Name] {
+			values[0] = option.ResolvePath()
+		}
+
+		for _, value := range values {
+			if err := f.Set(option.Name, value); err != nil {
+				return option.Errorf("invalid value for option %q: %s", option.Name, err)
+			}
+		}
+		if validate != nil {
+			if err := validate(option.Name); err != nil {
+				return option.Errorf("invalid value for option %q: %s", option.Name, err)
+			}
+		}
+	}
+
+	return nil
+}
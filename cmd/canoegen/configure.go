@@ -0,0 +1,255 @@
+/*
+ * Copyright 2021 Johannes Donath <johannesd@torchmind.com>
+ * and other copyright owners as documented in the project's IP log.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"flag"
+	"fmt"
+	"github.com/dotstart/canoe/internal"
+	"github.com/dotstart/canoe/internal/metadata"
+	"github.com/dotstart/canoe/internal/pe"
+	"github.com/google/subcommands"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+type configureCommand struct {
+	inputFile string
+
+	metadataFlags
+
+	signingKeyFile string
+	authenticodeFlags
+}
+
+func (*configureCommand) Name() string {
+	return "configure"
+}
+
+func (*configureCommand) Synopsis() string {
+	return "modifies the configuration of an existing canoe executable"
+}
+
+func (*configureCommand) Usage() string {
+	return `canoegen configure -in <file> [args]
+
+Modifies the configuration of a given canoe executable which has previously been generated using
+canoe wrap:
+
+  $ canoegen configure -in foo.exe -runtime-memory-limit 4G
+
+Only options which are explicitly given are modified while the remaining configuration is retained.
+The wrapper and embedded archive remain unchanged with the exception of the archive comment length
+which spans the configuration. Launch scripts (as generated by the "script" targets) carry a copy of
+the configuration and are thus generated again. Universal Mac OS executables carry a copy of the
+configuration within each slice which are updated and signed (ad-hoc) individually. Executables
+within application bundles are modified in place (e.g. "-in Foo.app/Contents/MacOS/Foo") while the
+bundle information remains unchanged.
+
+Signed executables must be signed again via the "-sign-key" option as their signature is otherwise
+removed. Executables which pin a signing key cannot be modified without their signing key. The same
+applies to Authenticode signatures which must be renewed via the "-authenticode-pkcs12" option.
+
+The following configuration options are provided by this command:
+
+`
+}
+
+func (cmd *configureCommand) SetFlags(f *flag.FlagSet) {
+	f.StringVar(&cmd.inputFile, "in", "", "selects an input executable (required)")
+
+	cmd.metadataFlags.SetFlags(f)
+
+	f.StringVar(&cmd.signingKeyFile, "sign-key", "", "selects a PEM encoded ed25519 private key with which the executable is signed (unset by default)")
+	cmd.authenticodeFlags.SetFlags(f)
+}
+
+func (cmd *configureCommand) Execute(_ context.Context, f *flag.FlagSet, _ ...interface{}) subcommands.ExitStatus {
+	if len(cmd.inputFile) == 0 {
+		_, _ = fmt.Fprintln(os.Stderr, "invalid parameters: input file is required")
+		return subcommands.ExitUsageError
+	}
+
+	var signingKey ed25519.PrivateKey
+	if len(cmd.signingKeyFile) != 0 {
+		var err error
+		signingKey, err = internal.ReadPrivateKey(cmd.signingKeyFile)
+		if err != nil {
+			_, _ = fmt.Fprintf(os.Stderr, "invalid signing key: %s\n", err)
+			return subcommands.ExitUsageError
+		}
+	}
+
+	authenticode, err := cmd.authenticodeFlags.load()
+	if err != nil {
+		_, _ = fmt.Fprintf(os.Stderr, "invalid Authenticode certificate: %s\n", err)
+		return subcommands.ExitUsageError
+	}
+
+	footer, meta, err := internal.ReadExecutableContainer(cmd.inputFile)
+	if err != nil {
+		_, _ = fmt.Fprintf(os.Stderr, "failed to read executable: %s\n", err)
+		return subcommands.ExitFailure
+	}
+
+	if footer.FormatVersion < internal.FooterFormatVersion {
+		_, _ = fmt.Fprintf(os.Stderr, "cannot configure executable: wrappers of format version %d cannot decode newer footers (please use the upgrade subcommand instead)\n", footer.FormatVersion)
+		return subcommands.ExitFailure
+	}
+
+	isSet := visitedFlags(f)
+	if err := cmd.metadataFlags.apply(meta, isSet); err != nil {
+		_, _ = fmt.Fprintf(os.Stderr, "%s\n", err)
+		return subcommands.ExitUsageError
+	}
+
+	if isSet("main-class") || isSet("runtime-version") {
+		if err := validatePayload(cmd.inputFile, footer, meta); err != nil {
+			_, _ = fmt.Fprintf(os.Stderr, "%s\n", err)
+			return subcommands.ExitFailure
+		}
+	}
+
+	if err := configureExecutable(cmd.inputFile, footer, meta, signingKey, authenticode); err != nil {
+		_, _ = fmt.Fprintf(os.Stderr, "failed to configure executable: %s\n", err)
+		return subcommands.ExitFailure
+	}
+
+	return subcommands.ExitSuccess
+}
+
+// ensures that the configured main class and runtime version are compatible with the payload of a
+// given executable
+func validatePayload(path string, footer *internal.Footer, meta *metadata.ApplicationContainer) error {
+	in, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to open executable: %w", err)
+	}
+	defer in.Close()
+
+	stat, err := in.Stat()
+	if err != nil {
+		return fmt.Errorf("failed to stat executable: %w", err)
+	}
+
+	// payloads which are embedded within a Mach-O or ELF section are stored as standalone archives while
+	// appended payloads span the end of the executable
+	var r *zip.Reader
+	if _, _, ok := internal.PayloadSection(in, stat.Size()); ok {
+		r, err = zip.NewReader(io.NewSectionReader(in, int64(footer.PayloadOffset), int64(footer.PayloadLength)), int64(footer.PayloadLength))
+	} else {
+		r, err = zip.NewReader(in, stat.Size())
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read embedded archive: %w", err)
+	}
+
+	manifest, err := readManifest(r)
+	if err != nil {
+		return fmt.Errorf("failed to read embedded archive: %w", err)
+	}
+
+	resolveRuntimeVersion(r, manifest, meta, true)
+	return resolveMainClass(r, manifest, meta)
+}
+
+// replaces the container metadata of a given executable while retaining its wrapper and payload
+//
+// the payload is only modified in order to update the length of its comment which spans the
+// container metadata
+func configureExecutable(path string, footer *internal.Footer, meta *metadata.ApplicationContainer, signingKey ed25519.PrivateKey, authenticode *pe.Signer) error {
+	in, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to open executable: %w", err)
+	}
+	defer in.Close()
+
+	stat, err := in.Stat()
+	if err != nil {
+		return fmt.Errorf("failed to stat executable: %w", err)
+	}
+
+	// existing digests are verified in order to avoid signing corrupted executables
+	if err := internal.VerifyDigests(in, footer, meta); err != nil {
+		return err
+	}
+
+	// executables which embed their payload within a dedicated section retain this layout
+	_, _, embedSection := internal.PayloadSection(in, stat.Size())
+
+	wrapper, err := readWrapper(in, stat.Size(), footer.PayloadOffset)
+	if err != nil {
+		return fmt.Errorf("failed to read wrapper: %w", err)
+	}
+
+	payload := make([]byte, footer.PayloadLength)
+	if _, err := in.ReadAt(payload, int64(footer.PayloadOffset)); err != nil {
+		return fmt.Errorf("failed to read payload: %w", err)
+	}
+
+	// launch scripts may change in length thus requiring the payload to be relocated
+	script, err := regenerateScript(wrapper, meta)
+	if err != nil {
+		return err
+	}
+	if script != nil {
+		wrapper = script
+		payload, err = relocatePayload(payload, len(wrapper))
+		if err != nil {
+			return err
+		}
+	}
+
+	pinnedKey := internal.FindPinnedPublicKey(wrapper)
+	if signingKey == nil {
+		if pinnedKey != nil {
+			return fmt.Errorf("executable pins signing key %x: signing key is required", pinnedKey)
+		}
+
+		if meta.Signature != nil {
+			_, _ = fmt.Fprintln(os.Stderr, "warning: executable signature has been removed (specify -sign-key in order to sign it again)")
+			meta.Signature = nil
+		}
+	} else if pinnedKey != nil && !bytes.Equal(pinnedKey, signingKey.Public().(ed25519.PublicKey)) {
+		return fmt.Errorf("executable pins signing key %x: signing key mismatch", pinnedKey)
+	}
+
+	out, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*")
+	if err != nil {
+		return fmt.Errorf("failed to create temporary file: %w", err)
+	}
+	defer os.Remove(out.Name())
+	defer out.Close()
+
+	if err := writeExecutable(out, wrapper, payload, meta, footer, signingKey, authenticode, embedSection); err != nil {
+		return fmt.Errorf("failed to write executable: %w", err)
+	}
+
+	if err := out.Chmod(stat.Mode()); err != nil {
+		return fmt.Errorf("failed to update file permissions: %w", err)
+	}
+	if err := out.Close(); err != nil {
+		return fmt.Errorf("failed to write executable: %w", err)
+	}
+
+	return os.Rename(out.Name(), path)
+}
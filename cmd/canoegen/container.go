@@ -0,0 +1,424 @@
+/*
+ * Copyright 2021 Johannes Donath <johannesd@torchmind.com>
+ * and other copyright owners as documented in the project's IP log.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package main
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"github.com/dotstart/canoe/internal"
+	"github.com/dotstart/canoe/internal/archive"
+	"github.com/dotstart/canoe/internal/elf"
+	"github.com/dotstart/canoe/internal/macho"
+	"github.com/dotstart/canoe/internal/metadata"
+	"github.com/dotstart/canoe/internal/pe"
+	"io"
+	"os"
+)
+
+// defines the maximum amount of attempts at computing a stable trailer length
+//
+// the trailer length typically stabilizes after the second attempt as digests and signatures are
+// of constant size (with the exception of ECDSA based Authenticode signatures which may vary by a
+// few bytes)
+const maxTrailerAttempts = 8
+
+// creates a copy of a given archive which has been relocated to the specified offset
+func relocatePayload(data []byte, offset int) ([]byte, error) {
+	payload := make([]byte, len(data))
+	copy(payload, data)
+
+	if err := archive.Relocate(payload, int64(offset)); err != nil {
+		return nil, fmt.Errorf("failed to relocate archive: %w", err)
+	}
+
+	return payload, nil
+}
+
+// writes an executable consisting of a given wrapper, payload and set of container metadata
+//
+// The minimum wrapper version of the passed footer is replaced with the smallest revision which is
+// capable of launching the application within the resulting layout.
+//
+// signed Mach-O wrappers receive their payload within a dedicated section (see
+// writeMachOExecutable) while universal wrappers receive a copy within each of their slices (see
+// writeUniversalExecutable). The same applies to unsigned Mach-O and ELF wrappers when embedSection is
+// set (see writeELFExecutable). Otherwise, the payload comment is adjusted in place in order to
+// include the trailing container metadata and footer while the digests within the passed metadata
+// are replaced. When an Authenticode signer is given and the wrapper is a PE image, a certificate
+// table is appended to the executable (and covered by the payload comment). Existing certificate
+// tables are discarded otherwise.
+func writeExecutable(w io.Writer, wrapper []byte, payload []byte, meta *metadata.ApplicationContainer, footer *internal.Footer, signingKey ed25519.PrivateKey, authenticode *pe.Signer, embedSection bool) error {
+	if macho.IsFat(wrapper) {
+		return writeUniversalExecutable(w, wrapper, payload, meta, footer, signingKey)
+	}
+	if image, err := macho.Parse(append([]byte{}, wrapper...)); err == nil && (image.IsSigned() || embedSection) {
+		return writeMachOExecutable(w, image, payload, meta, footer, signingKey)
+	}
+	if embedSection {
+		if image, err := elf.Parse(append([]byte{}, wrapper...)); err == nil {
+			return writeELFExecutable(w, image, payload, meta, footer, signingKey)
+		}
+	}
+
+	footer.MinimumWrapperVersion = internal.RequiredWrapperVersion(meta, false)
+	footer.PayloadOffset = uint64(len(wrapper))
+	footer.PayloadLength = uint64(len(payload))
+
+	wrapper = append([]byte{}, wrapper...)
+	image, err := pe.Parse(wrapper)
+	if err != nil {
+		if !errors.Is(err, pe.ErrMalformed) {
+			return err
+		}
+
+		image = nil
+		authenticode = nil
+	}
+	if image != nil && authenticode == nil {
+		if offset, _ := image.CertificateTable(); offset != 0 {
+			_, _ = fmt.Fprintln(os.Stderr, "warning: Authenticode signature has been removed (specify -authenticode-pkcs12 in order to sign it again)")
+			if err := image.SetCertificateTable(0, 0); err != nil {
+				return err
+			}
+		}
+	}
+
+	if comment, err := archive.Comment(payload); err == nil && len(comment) != 0 {
+		_, _ = fmt.Fprintln(os.Stderr, "warning: archive comment is followed by the container metadata within the executable (the original comment is restored upon extraction)")
+	}
+
+	// the trailer is covered by the archive comment unless it exceeds the maximum comment length
+	covered := true
+
+	trailer := &bytes.Buffer{}
+	var padding []byte
+	var certificateTable []byte
+	for attempt, trailerLength, certificateLength := 0, 0, 0; ; attempt++ {
+		if attempt == maxTrailerAttempts {
+			return errors.New("failed to compute stable trailer length")
+		}
+
+		// certificate tables are required to be aligned to eight bytes thus requiring us to pad
+		// the payload accordingly
+		padding = nil
+		if authenticode != nil {
+			unaligned := len(wrapper) + len(payload) + trailerLength
+			padding = make([]byte, (8-unaligned%8)%8)
+
+			if err := image.SetCertificateTable(uint32(unaligned+len(padding)), uint32(certificateLength)); err != nil {
+				return err
+			}
+		}
+
+		commentLength := 0
+		if covered {
+			commentLength = len(padding) + trailerLength + certificateLength
+		}
+
+		if err := archive.SetTrailerLength(payload, commentLength); err != nil {
+			if !errors.Is(err, archive.ErrCommentOverflow) {
+				return fmt.Errorf("failed to adjust archive comment: %w", err)
+			}
+
+			covered = false
+			continue
+		}
+
+		wrapperDigest := sha256.Sum256(wrapper)
+		payloadDigest := sha256.Sum256(payload)
+		if err := encodeTrailer(trailer, footer, meta, signingKey, wrapperDigest[:], payloadDigest[:]); err != nil {
+			return err
+		}
+
+		if authenticode != nil {
+			signed := make([]byte, 0, len(wrapper)+len(payload)+len(padding)+trailer.Len())
+			signed = append(signed, wrapper...)
+			signed = append(signed, payload...)
+			signed = append(signed, padding...)
+			signed = append(signed, trailer.Bytes()...)
+
+			identity := meta.GetIdentity()
+			certificateTable, err = authenticode.Sign(signed, identity.GetName(), identity.GetHomepage())
+			if err != nil {
+				return fmt.Errorf("failed to compute Authenticode signature: %w", err)
+			}
+		}
+
+		if trailer.Len() == trailerLength && len(certificateTable) == certificateLength {
+			break
+		}
+		if !covered && authenticode == nil {
+			break
+		}
+
+		trailerLength = trailer.Len()
+		certificateLength = len(certificateTable)
+	}
+
+	if !covered {
+		_, _ = fmt.Fprintln(os.Stderr, "warning: container metadata exceeds 64 KiB - the runtime may be unable to open the embedded archive")
+	}
+
+	for _, section := range [][]byte{wrapper, payload, padding, trailer.Bytes(), certificateTable} {
+		if _, err := w.Write(section); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// writes an executable which embeds its payload and container metadata within a dedicated section
+// of a given Mach-O wrapper
+//
+// Mach-O code signatures reside at the end of the file and cover the entire image thus preventing
+// us from appending the payload. Instead, a new segment is inserted in front of the __LINKEDIT
+// segment and the image is signed again using an ad-hoc signature. As the payload is no longer
+// located at the end of the file, it is stored without relocation and extracted by the runtime
+// prior to launching the application.
+func writeMachOExecutable(w io.Writer, wrapper *macho.Image, payload []byte, meta *metadata.ApplicationContainer, footer *internal.Footer, signingKey ed25519.PrivateKey) error {
+	offset, err := wrapper.SegmentOffset()
+	if err != nil {
+		return err
+	}
+
+	payload, err = relocatePayload(payload, 0)
+	if err != nil {
+		return err
+	}
+	if err := archive.SetTrailerLength(payload, 0); err != nil {
+		return fmt.Errorf("failed to adjust archive comment: %w", err)
+	}
+
+	// offsets within the footer are relative to the beginning of the payload section
+	footer.PayloadOffset = 0
+	footer.PayloadLength = uint64(len(payload))
+	footer.MinimumWrapperVersion = internal.RequiredWrapperVersion(meta, true)
+
+	identifier := meta.GetIdentity().GetIdentifier()
+	if len(identifier) == 0 {
+		identifier = meta.GetIdentity().GetName()
+	}
+	if len(identifier) == 0 {
+		identifier = wrapper.Identifier()
+	}
+	if len(identifier) == 0 {
+		identifier = "canoew"
+	}
+
+	payloadDigest := sha256.Sum256(payload)
+	trailer := &bytes.Buffer{}
+	var image *macho.Image
+	for attempt := 0; ; attempt++ {
+		if attempt == maxTrailerAttempts {
+			return errors.New("failed to compute stable trailer length")
+		}
+
+		contents := make([]byte, 0, len(payload)+trailer.Len())
+		contents = append(contents, payload...)
+		contents = append(contents, trailer.Bytes()...)
+
+		image, err = macho.Parse(append([]byte{}, wrapper.Bytes()...))
+		if err != nil {
+			return err
+		}
+		if _, err := image.AddSegment(internal.PayloadSegmentName, internal.PayloadSectionName, contents); err != nil {
+			return fmt.Errorf("failed to embed payload: %w", err)
+		}
+		if err := image.Sign(identifier); err != nil {
+			return fmt.Errorf("failed to sign executable: %w", err)
+		}
+
+		wrapperDigest := sha256.Sum256(image.Bytes()[:offset])
+		if err := encodeTrailer(trailer, footer, meta, signingKey, wrapperDigest[:], payloadDigest[:]); err != nil {
+			return err
+		}
+		if trailer.Len() == len(contents)-len(payload) {
+			break
+		}
+	}
+
+	// the embedded trailer merely differs in its digests at this point thus permitting us to replace
+	// it without affecting the image layout
+	copy(image.Bytes()[offset+uint64(len(payload)):], trailer.Bytes())
+	if err := image.Sign(identifier); err != nil {
+		return fmt.Errorf("failed to sign executable: %w", err)
+	}
+
+	_, err = w.Write(image.Bytes())
+	return err
+}
+
+// writes a universal executable which embeds its payload and container metadata within each slice
+// of a given universal Mach-O wrapper
+//
+// slices are assembled individually (see writeMachOExecutable) and combined in their original
+// order. As every slice carries an identical payload and configuration, the passed footer reflects
+// the layout of the final slice.
+func writeUniversalExecutable(w io.Writer, wrapper []byte, payload []byte, meta *metadata.ApplicationContainer, footer *internal.Footer, signingKey ed25519.PrivateKey) error {
+	slices, err := macho.Slices(wrapper)
+	if err != nil {
+		return err
+	}
+
+	images := make([]*macho.Image, len(slices))
+	for i, slice := range slices {
+		buf := &bytes.Buffer{}
+		if err := writeMachOExecutable(buf, slice, payload, meta, footer, signingKey); err != nil {
+			return err
+		}
+
+		images[i], err = macho.Parse(buf.Bytes())
+		if err != nil {
+			return err
+		}
+	}
+
+	executable, err := macho.Fat(images...)
+	if err != nil {
+		return fmt.Errorf("failed to generate universal executable: %w", err)
+	}
+
+	_, err = w.Write(executable)
+	return err
+}
+
+// writes an executable which embeds its payload and container metadata within a dedicated
+// non-allocated section of a given ELF wrapper
+//
+// Non-allocated sections are not mapped into memory and may thus be relocated freely by tools such
+// as strip or objcopy. As a result, the footer describes the section contents as if they were a
+// standalone file and the payload is extracted by the runtime prior to launching the application.
+// The wrapper digest is omitted as these tools rewrite the wrapper in the process.
+func writeELFExecutable(w io.Writer, wrapper *elf.Image, payload []byte, meta *metadata.ApplicationContainer, footer *internal.Footer, signingKey ed25519.PrivateKey) error {
+	payload, err := relocatePayload(payload, 0)
+	if err != nil {
+		return err
+	}
+	if err := archive.SetTrailerLength(payload, 0); err != nil {
+		return fmt.Errorf("failed to adjust archive comment: %w", err)
+	}
+
+	footer.MinimumWrapperVersion = internal.RequiredWrapperVersion(meta, true)
+	footer.PayloadOffset = 0
+	footer.PayloadLength = uint64(len(payload))
+
+	payloadDigest := sha256.Sum256(payload)
+	trailer := &bytes.Buffer{}
+	if err := encodeTrailer(trailer, footer, meta, signingKey, nil, payloadDigest[:]); err != nil {
+		return err
+	}
+
+	contents := make([]byte, 0, len(payload)+trailer.Len())
+	contents = append(contents, payload...)
+	contents = append(contents, trailer.Bytes()...)
+
+	if _, err := wrapper.AddSection(internal.ELFPayloadSectionName, contents); err != nil {
+		return fmt.Errorf("failed to embed payload: %w", err)
+	}
+
+	_, err = w.Write(wrapper.Bytes())
+	return err
+}
+
+// encodes the container metadata and footer of an executable along with a given set of digests
+//
+// digests which are not given are omitted from the metadata.
+func encodeTrailer(trailer *bytes.Buffer, footer *internal.Footer, meta *metadata.ApplicationContainer, signingKey ed25519.PrivateKey, wrapperDigest []byte, payloadDigest []byte) error {
+	meta.Integrity = &metadata.IntegrityConfiguration{
+		WrapperSha256: wrapperDigest,
+		PayloadSha256: payloadDigest,
+	}
+
+	trailer.Reset()
+	if signingKey != nil {
+		_, err := internal.WriteSignedExecutableFooter(trailer, footer, meta, signingKey)
+		return err
+	}
+
+	_, err := internal.WriteExecutableFooter(trailer, footer, meta)
+	return err
+}
+
+// reads the wrapper of a given executable
+//
+// wrappers of ELF executables are restored by removing the section which embeds the payload while
+// wrappers of signed Mach-O executables additionally remove the respective segment and are signed
+// again. Universal executables are restored one slice at a time and combined into a universal
+// wrapper.
+func readWrapper(r io.ReaderAt, size int64, payloadOffset uint64) ([]byte, error) {
+	if _, _, ok := internal.PayloadSection(r, size); !ok {
+		wrapper := make([]byte, payloadOffset)
+		if _, err := r.ReadAt(wrapper, 0); err != nil {
+			return nil, err
+		}
+
+		return wrapper, nil
+	}
+
+	data := make([]byte, size)
+	if _, err := r.ReadAt(data, 0); err != nil {
+		return nil, err
+	}
+	if macho.IsFat(data) {
+		images, err := macho.Slices(data)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, image := range images {
+			if err := removePayloadSegment(image); err != nil {
+				return nil, err
+			}
+		}
+
+		return macho.Fat(images...)
+	}
+
+	if image, err := elf.Parse(data); err == nil {
+		if err := image.RemoveSection(internal.ELFPayloadSectionName); err != nil {
+			return nil, err
+		}
+
+		return image.Bytes(), nil
+	}
+
+	image, err := macho.Parse(data)
+	if err != nil {
+		return nil, err
+	}
+	if err := removePayloadSegment(image); err != nil {
+		return nil, err
+	}
+
+	return image.Bytes(), nil
+}
+
+// removes the segment which embeds the payload of a given Mach-O image and signs it again using
+// its original identifier
+func removePayloadSegment(image *macho.Image) error {
+	identifier := image.Identifier()
+
+	if err := image.RemoveSegment(internal.PayloadSegmentName); err != nil {
+		return err
+	}
+
+	return image.Sign(identifier)
+}
@@ -0,0 +1,262 @@
+/*
+ * Copyright 2021 Johannes Donath <johannesd@torchmind.com>
+ * and other copyright owners as documented in the project's IP log.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"debug/pe"
+	"encoding/binary"
+	"github.com/dotstart/canoe/internal"
+	"github.com/dotstart/canoe/internal/metadata"
+	canoepe "github.com/dotstart/canoe/internal/pe"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// assembles a minimal PE32+ image consisting of a single code section
+func testPEWrapper() []byte {
+	buf := &bytes.Buffer{}
+
+	dos := make([]byte, 0x40)
+	copy(dos, "MZ")
+	binary.LittleEndian.PutUint32(dos[0x3C:], 0x40)
+	buf.Write(dos)
+
+	buf.WriteString("PE\x00\x00")
+	_ = binary.Write(buf, binary.LittleEndian, pe.FileHeader{
+		Machine:              pe.IMAGE_FILE_MACHINE_AMD64,
+		NumberOfSections:     1,
+		SizeOfOptionalHeader: 240,
+		Characteristics:      pe.IMAGE_FILE_EXECUTABLE_IMAGE | pe.IMAGE_FILE_LARGE_ADDRESS_AWARE,
+	})
+	_ = binary.Write(buf, binary.LittleEndian, pe.OptionalHeader64{
+		Magic:               0x20B,
+		SizeOfCode:          0x200,
+		AddressOfEntryPoint: 0x1000,
+		BaseOfCode:          0x1000,
+		ImageBase:           0x140000000,
+		SectionAlignment:    0x1000,
+		FileAlignment:       0x200,
+		SizeOfImage:         0x2000,
+		SizeOfHeaders:       0x200,
+		Subsystem:           pe.IMAGE_SUBSYSTEM_WINDOWS_CUI,
+		NumberOfRvaAndSizes: 16,
+	})
+	_ = binary.Write(buf, binary.LittleEndian, pe.SectionHeader32{
+		Name:             [8]uint8{'.', 't', 'e', 'x', 't'},
+		VirtualSize:      0x10,
+		VirtualAddress:   0x1000,
+		SizeOfRawData:    0x200,
+		PointerToRawData: 0x200,
+		Characteristics:  pe.IMAGE_SCN_CNT_CODE | pe.IMAGE_SCN_MEM_EXECUTE | pe.IMAGE_SCN_MEM_READ,
+	})
+
+	buf.Write(make([]byte, 0x200-buf.Len()))
+	code := make([]byte, 0x200)
+	code[0] = 0xC3
+	buf.Write(code)
+
+	return buf.Bytes()
+}
+
+// assembles an archive which consists of a manifest and a single class
+func testArchive(t *testing.T) []byte {
+	buf := &bytes.Buffer{}
+	w := zip.NewWriter(buf)
+
+	for name, contents := range map[string]string{
+		"META-INF/MANIFEST.MF": "Manifest-Version: 1.0\r\nMain-Class: foo.Main\r\n\r\n",
+		"foo/Main.class":       "\xCA\xFE\xBA\xBE",
+	} {
+		entry, err := w.Create(name)
+		if err != nil {
+			t.Fatalf("failed to create entry %s: %s", name, err)
+		}
+		_, _ = entry.Write([]byte(contents))
+	}
+
+	if err := w.Close(); err != nil {
+		t.Fatalf("failed to finalize archive: %s", err)
+	}
+
+	return buf.Bytes()
+}
+
+// generates a self-signed ECDSA code signing certificate (ECDSA signatures vary in length thus
+// exercising the trailer length computation)
+func testAuthenticodeSigner(t *testing.T) *canoepe.Signer {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate key: %s", err)
+	}
+
+	now := time.Now()
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(0x1337),
+		Subject:      pkix.Name{CommonName: "canoe Test Publisher"},
+		NotBefore:    now.Add(-time.Hour),
+		NotAfter:     now.Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageCodeSigning},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, key.Public(), key)
+	if err != nil {
+		t.Fatalf("failed to create certificate: %s", err)
+	}
+	certificate, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatalf("failed to parse certificate: %s", err)
+	}
+
+	return &canoepe.Signer{Certificate: certificate, Key: key}
+}
+
+func TestWriteExecutable(t *testing.T) {
+	_, signingKey, _ := ed25519.GenerateKey(nil)
+	authenticode := testAuthenticodeSigner(t)
+	archive := testArchive(t)
+
+	tests := []struct {
+		name         string
+		wrapper      []byte
+		signingKey   ed25519.PrivateKey
+		authenticode *canoepe.Signer
+		arguments    int
+	}{
+		{"plain", []byte("#!/bin/sh\nexit 1\n"), nil, nil, 0},
+		{"pe", testPEWrapper(), nil, nil, 0},
+		{"signed", testPEWrapper(), signingKey, nil, 0},
+		{"authenticode", testPEWrapper(), nil, authenticode, 0},
+		{"signed-authenticode", testPEWrapper(), signingKey, authenticode, 0},
+		{"authenticode-uncovered", testPEWrapper(), signingKey, authenticode, 100 * 1024},
+	}
+
+	for _, test := range tests {
+		// the application name alters the trailer length thus covering all amounts of padding
+		for padding := 0; padding < 8; padding++ {
+			meta := &metadata.ApplicationContainer{
+				Identity: &metadata.ApplicationIdentity{
+					Name: strings.Repeat("a", padding+1),
+				},
+				Application: &metadata.ApplicationConfiguration{
+					MainClass: "foo.Main",
+				},
+				Runtime: &metadata.RuntimeConfiguration{
+					AdditionalArguments: strings.Repeat("a", test.arguments),
+				},
+			}
+
+			payload, err := relocatePayload(archive, len(test.wrapper))
+			if err != nil {
+				t.Fatalf("%s: failed to relocate payload: %s", test.name, err)
+			}
+
+			buf := &bytes.Buffer{}
+			if err := writeExecutable(buf, test.wrapper, payload, meta, &internal.Footer{}, test.signingKey, test.authenticode, false); err != nil {
+				t.Fatalf("%s: failed to write executable: %s", test.name, err)
+			}
+			executable := buf.Bytes()
+
+			path := filepath.Join(t.TempDir(), "executable")
+			if err := os.WriteFile(path, executable, 0755); err != nil {
+				t.Fatalf("%s: failed to write executable: %s", test.name, err)
+			}
+
+			footer, decoded, err := internal.ReadExecutableContainer(path)
+			if err != nil {
+				t.Fatalf("%s: failed to read executable: %s", test.name, err)
+			}
+			if decoded.GetIdentity().GetName() != meta.Identity.Name {
+				t.Errorf("%s: expected name %q but got %q", test.name, meta.Identity.Name, decoded.GetIdentity().GetName())
+			}
+			if footer.PayloadOffset != uint64(len(test.wrapper)) || footer.PayloadLength != uint64(len(payload)) {
+				t.Errorf("%s: unexpected payload location %d+%d", test.name, footer.PayloadOffset, footer.PayloadLength)
+			}
+			if err := internal.VerifyDigests(bytes.NewReader(executable), footer, decoded); err != nil {
+				t.Errorf("%s: expected valid digests but got %s", test.name, err)
+			}
+			if test.signingKey != nil {
+				if err := internal.VerifySignature(bytes.NewReader(executable), footer, decoded, test.signingKey.Public().(ed25519.PublicKey)); err != nil {
+					t.Errorf("%s: expected valid signature but got %s", test.name, err)
+				}
+			}
+
+			// the runtime requires the archive comment to reach the end of the file unless the
+			// trailer exceeds the maximum comment length in which case the comment is retained
+			covered := test.arguments == 0
+			archiveEnd := len(executable)
+			if !covered {
+				archiveEnd = int(footer.PayloadOffset + footer.PayloadLength)
+			}
+
+			r, err := zip.NewReader(bytes.NewReader(executable[:archiveEnd]), int64(archiveEnd))
+			if err != nil {
+				t.Fatalf("%s: failed to open embedded archive: %s", test.name, err)
+			}
+			if len(r.File) != 2 {
+				t.Errorf("%s: expected 2 entries but got %d", test.name, len(r.File))
+			}
+			if covered && (!bytes.HasSuffix(executable, []byte(r.Comment)) || len(r.Comment) < len(executable)-int(footer.MetadataOffset)) {
+				t.Errorf("%s: expected archive comment to cover the trailer", test.name)
+			}
+			if !covered && len(r.Comment) != 0 {
+				t.Errorf("%s: expected archive comment to be retained but got %d bytes", test.name, len(r.Comment))
+			}
+
+			if test.authenticode == nil {
+				continue
+			}
+
+			f, err := pe.NewFile(bytes.NewReader(executable))
+			if err != nil {
+				t.Fatalf("%s: failed to parse signed executable: %s", test.name, err)
+			}
+			directory := f.OptionalHeader.(*pe.OptionalHeader64).DataDirectory[pe.IMAGE_DIRECTORY_ENTRY_SECURITY]
+			if directory.VirtualAddress%8 != 0 || int(directory.VirtualAddress)+int(directory.Size) != len(executable) {
+				t.Fatalf("%s: unexpected certificate table at 0x%X (%d bytes)", test.name, directory.VirtualAddress, directory.Size)
+			}
+
+			table := executable[directory.VirtualAddress:]
+			// the certificate length excludes the padding which aligns the certificate table
+			if length := binary.LittleEndian.Uint32(table); length > directory.Size || (length+7)/8*8 != directory.Size {
+				t.Errorf("%s: unexpected certificate length %d within table of %d bytes", test.name, length, directory.Size)
+			}
+			if revision, typ := binary.LittleEndian.Uint16(table[4:]), binary.LittleEndian.Uint16(table[6:]); revision != 0x0200 || typ != 0x0002 {
+				t.Errorf("%s: unexpected certificate revision 0x%04X and type 0x%04X", test.name, revision, typ)
+			}
+
+			digest, err := canoepe.AuthenticodeDigest(executable)
+			if err != nil {
+				t.Fatalf("%s: failed to compute Authenticode digest: %s", test.name, err)
+			}
+			if !bytes.Contains(table, digest) {
+				t.Errorf("%s: expected certificate table to carry the Authenticode digest of the executable", test.name)
+			}
+		}
+	}
+}
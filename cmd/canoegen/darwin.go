@@ -0,0 +1,224 @@
+/*
+ * Copyright 2021 Johannes Donath <johannesd@torchmind.com>
+ * and other copyright owners as documented in the project's IP log.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/dotstart/canoe/build"
+	"github.com/dotstart/canoe/internal"
+	"github.com/dotstart/canoe/internal/icon"
+	"github.com/dotstart/canoe/internal/macho"
+	"github.com/dotstart/canoe/internal/metadata"
+	"github.com/dotstart/canoe/internal/plist"
+	"image/png"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+const (
+	executableFormat = "executable"
+	appFormat        = "app"
+)
+
+// identifies the pseudo target which combines all embedded Mac OS wrappers within a single
+// universal executable
+const universalTarget = "darwin-" + internal.UniversalArch
+
+// defines the oldest Mac OS release on which generated application bundles may be launched
+const minimumSystemVersion = "10.13"
+
+var bundleIdentifierPattern = regexp.MustCompile("[^A-Za-z0-9.-]+")
+
+// loads all embedded Mac OS wrappers for inclusion within a universal executable
+func loadUniversalWrappers() ([][]byte, error) {
+	targets, err := build.GetFilesystem().ReadDir("wrappers")
+	if err != nil {
+		return nil, fmt.Errorf("failed to load target list: %w", err)
+	}
+
+	var wrappers [][]byte
+	for _, target := range targets {
+		if !target.IsDir() || !strings.HasPrefix(target.Name(), "darwin-") {
+			continue
+		}
+
+		wrapper, err := loadTargetWrapper(target.Name())
+		if err != nil {
+			return nil, err
+		}
+
+		wrappers = append(wrappers, wrapper)
+	}
+
+	if len(wrappers) == 0 {
+		return nil, fmt.Errorf("invalid target: %s (no Mac OS wrappers are available)", universalTarget)
+	}
+
+	return wrappers, nil
+}
+
+// writes a Mac OS application bundle which contains a given executable to the specified path
+//
+// existing bundles at the output path are replaced while other files are left untouched
+func (cmd *wrapCommand) writeBundle(meta *metadata.ApplicationContainer, executable []byte, output string) error {
+	if _, err := macho.Parse(append([]byte{}, executable...)); err != nil && !macho.IsFat(executable) {
+		return fmt.Errorf("application bundles require a Mac OS wrapper: %w", err)
+	}
+
+	if !strings.HasSuffix(output, ".app") {
+		output += ".app"
+	}
+	name := strings.TrimSuffix(filepath.Base(output), ".app")
+
+	if _, err := os.Stat(output); err == nil {
+		if _, err := os.Stat(filepath.Join(output, "Contents", "Info.plist")); err != nil {
+			return fmt.Errorf("refusing to replace %s: not an application bundle", output)
+		}
+
+		if err := os.RemoveAll(output); err != nil {
+			return fmt.Errorf("failed to remove existing bundle %s: %w", output, err)
+		}
+	}
+
+	contents := filepath.Join(output, "Contents")
+	for _, dir := range []string{"MacOS", "Resources"} {
+		if err := os.MkdirAll(filepath.Join(contents, dir), 0755); err != nil {
+			return fmt.Errorf("failed to create directory structure at %s: %w", output, err)
+		}
+	}
+
+	if err := writeOutputFile(filepath.Join(contents, "MacOS", name), executable, 0755); err != nil {
+		return err
+	}
+
+	iconFile := ""
+	if len(cmd.icon) != 0 {
+		if icon.IsICO(cmd.icon) {
+			_, _ = fmt.Fprintln(os.Stderr, "warning: ICO icons are not embedded within Mac OS application bundles (please specify a PNG icon instead)")
+		} else {
+			img, err := png.Decode(bytes.NewReader(cmd.icon))
+			if err != nil {
+				return fmt.Errorf("failed to decode icon: %w", err)
+			}
+
+			encoded, err := icon.EncodeICNS(img)
+			if err != nil {
+				return fmt.Errorf("failed to encode icon: %w", err)
+			}
+
+			iconFile = name + ".icns"
+			if err := os.WriteFile(filepath.Join(contents, "Resources", iconFile), encoded, 0644); err != nil {
+				return fmt.Errorf("failed to write icon: %w", err)
+			}
+		}
+	}
+
+	info, err := plist.Encode(infoPlist(meta, name, iconFile))
+	if err != nil {
+		return fmt.Errorf("failed to encode bundle information: %w", err)
+	}
+	if err := os.WriteFile(filepath.Join(contents, "Info.plist"), info, 0644); err != nil {
+		return fmt.Errorf("failed to write bundle information: %w", err)
+	}
+
+	if err := os.WriteFile(filepath.Join(contents, "PkgInfo"), []byte("APPL????"), 0644); err != nil {
+		return fmt.Errorf("failed to write bundle information: %w", err)
+	}
+
+	return nil
+}
+
+// generates the bundle information of a given application
+func infoPlist(meta *metadata.ApplicationContainer, executable string, iconFile string) plist.Dict {
+	identity := meta.GetIdentity()
+
+	identifier := identity.GetIdentifier()
+	if len(identifier) == 0 {
+		identifier = strings.Trim(bundleIdentifierPattern.ReplaceAllString(executable, "-"), "-.")
+		_, _ = fmt.Fprintf(os.Stderr, "warning: application identifier is unset (defaulting to %q; specify -app-id in order to select a reverse DNS identifier)\n", identifier)
+	}
+
+	name := identity.GetName()
+	if len(name) == 0 {
+		name = executable
+	}
+
+	info := plist.Dict{
+		{Key: "CFBundleDevelopmentRegion", Value: "en"},
+		{Key: "CFBundleExecutable", Value: executable},
+		{Key: "CFBundleIdentifier", Value: identifier},
+		{Key: "CFBundleInfoDictionaryVersion", Value: "6.0"},
+		{Key: "CFBundleName", Value: name},
+		{Key: "CFBundleDisplayName", Value: name},
+		{Key: "CFBundlePackageType", Value: "APPL"},
+		{Key: "CFBundleSignature", Value: "????"},
+	}
+	if version := identity.GetVersion(); len(version) != 0 {
+		info = append(info,
+			plist.Entry{Key: "CFBundleShortVersionString", Value: version},
+			plist.Entry{Key: "CFBundleVersion", Value: version},
+		)
+	}
+	if len(iconFile) != 0 {
+		info = append(info, plist.Entry{Key: "CFBundleIconFile", Value: iconFile})
+	}
+	if copyright := identity.GetCopyright(); len(copyright) != 0 {
+		info = append(info, plist.Entry{Key: "NSHumanReadableCopyright", Value: copyright})
+	}
+	info = append(info,
+		plist.Entry{Key: "LSMinimumSystemVersion", Value: minimumSystemVersion},
+		plist.Entry{Key: "NSHighResolutionCapable", Value: true},
+	)
+
+	documentTypes := meta.GetDesktop().GetDocumentTypes()
+	if len(documentTypes) != 0 {
+		types := make([]plist.Dict, len(documentTypes))
+		for i, documentType := range documentTypes {
+			entry := plist.Dict{
+				{Key: "CFBundleTypeName", Value: documentType.Name},
+				{Key: "CFBundleTypeRole", Value: "Editor"},
+			}
+			if len(documentType.Extensions) != 0 {
+				entry = append(entry, plist.Entry{Key: "CFBundleTypeExtensions", Value: documentType.Extensions})
+			}
+			if len(documentType.MimeTypes) != 0 {
+				entry = append(entry, plist.Entry{Key: "CFBundleTypeMIMETypes", Value: documentType.MimeTypes})
+			}
+			if len(iconFile) != 0 {
+				entry = append(entry, plist.Entry{Key: "CFBundleTypeIconFile", Value: iconFile})
+			}
+
+			types[i] = entry
+		}
+
+		info = append(info, plist.Entry{Key: "CFBundleDocumentTypes", Value: types})
+	}
+
+	if schemes := meta.GetDesktop().GetUrlSchemes(); len(schemes) != 0 {
+		info = append(info, plist.Entry{Key: "CFBundleURLTypes", Value: []plist.Dict{
+			{
+				{Key: "CFBundleURLName", Value: identifier},
+				{Key: "CFBundleURLSchemes", Value: schemes},
+			},
+		}})
+	}
+
+	return info
+}
@@ -0,0 +1,162 @@
+/*
+ * Copyright 2021 Johannes Donath <johannesd@torchmind.com>
+ * and other copyright owners as documented in the project's IP log.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"github.com/dotstart/canoe/internal"
+	"github.com/dotstart/canoe/internal/archive"
+	"github.com/google/subcommands"
+	"google.golang.org/protobuf/encoding/protojson"
+	"io"
+	"os"
+)
+
+type extractCommand struct {
+	inputFile    string
+	archiveFile  string
+	wrapperFile  string
+	metadataFile string
+}
+
+func (*extractCommand) Name() string {
+	return "extract"
+}
+
+func (*extractCommand) Synopsis() string {
+	return "extracts the embedded archive, wrapper and metadata from a canoe executable"
+}
+
+func (*extractCommand) Usage() string {
+	return `canoegen extract -in <file> -jar <file> [-wrapper <file>] [-metadata <file>] [args]
+
+Splits a given canoe executable which has previously been generated using canoe wrap back into its
+individual components:
+
+  $ canoegen extract -in foo.exe -jar foo.jar -wrapper canoew.exe -metadata foo.json
+
+Executables generated by older versions of canoe do not record the location of their embedded
+archive. In this case, its location is derived from the archive's central directory instead. The
+offsets within the extracted archive are restored to their original values.
+
+The following configuration options are provided by this command:
+
+`
+}
+
+func (cmd *extractCommand) SetFlags(f *flag.FlagSet) {
+	f.StringVar(&cmd.inputFile, "in", "", "selects an input executable (required)")
+	f.StringVar(&cmd.archiveFile, "jar", "", "selects an output file for the embedded archive (required)")
+	f.StringVar(&cmd.wrapperFile, "wrapper", "", "selects an output file for the wrapper executable")
+	f.StringVar(&cmd.metadataFile, "metadata", "", "selects an output file for the container metadata (encoded as JSON)")
+}
+
+func (cmd *extractCommand) Execute(context.Context, *flag.FlagSet, ...interface{}) subcommands.ExitStatus {
+	if len(cmd.inputFile) == 0 {
+		_, _ = fmt.Fprintln(os.Stderr, "invalid parameters: input file is required")
+		return subcommands.ExitUsageError
+	}
+	if len(cmd.archiveFile) == 0 {
+		_, _ = fmt.Fprintln(os.Stderr, "invalid parameters: archive output file is required")
+		return subcommands.ExitUsageError
+	}
+
+	footer, meta, err := internal.ReadExecutableContainer(cmd.inputFile)
+	if err != nil {
+		_, _ = fmt.Fprintf(os.Stderr, "failed to read executable: %s\n", err)
+		return subcommands.ExitFailure
+	}
+
+	f, err := os.Open(cmd.inputFile)
+	if err != nil {
+		_, _ = fmt.Fprintf(os.Stderr, "failed to read executable: %s\n", err)
+		return subcommands.ExitFailure
+	}
+	defer f.Close()
+
+	payloadOffset, payloadLength, err := internal.LocatePayload(f, footer)
+	if err != nil {
+		_, _ = fmt.Fprintf(os.Stderr, "failed to extract archive: %s\n", err)
+		return subcommands.ExitFailure
+	}
+
+	if err := extractArchive(f, payloadOffset, payloadLength, cmd.archiveFile); err != nil {
+		_, _ = fmt.Fprintf(os.Stderr, "failed to extract archive: %s\n", err)
+		return subcommands.ExitFailure
+	}
+
+	if len(cmd.wrapperFile) != 0 {
+		if err := extractWrapper(f, payloadOffset, cmd.wrapperFile); err != nil {
+			_, _ = fmt.Fprintf(os.Stderr, "failed to extract wrapper: %s\n", err)
+			return subcommands.ExitFailure
+		}
+	}
+
+	if len(cmd.metadataFile) != 0 {
+		encoded, err := protojson.MarshalOptions{Multiline: true}.Marshal(meta)
+		if err != nil {
+			_, _ = fmt.Fprintf(os.Stderr, "failed to encode metadata: %s\n", err)
+			return subcommands.ExitFailure
+		}
+
+		if err := os.WriteFile(cmd.metadataFile, encoded, 0644); err != nil {
+			_, _ = fmt.Fprintf(os.Stderr, "failed to write metadata: %s\n", err)
+			return subcommands.ExitFailure
+		}
+	}
+
+	return subcommands.ExitSuccess
+}
+
+// extracts the embedded archive from an executable while restoring its original offsets and
+// comment length
+func extractArchive(r io.ReaderAt, offset uint64, length uint64, output string) error {
+	data := make([]byte, length)
+	if _, err := r.ReadAt(data, int64(offset)); err != nil {
+		return fmt.Errorf("failed to read archive: %w", err)
+	}
+
+	if err := archive.Relocate(data, 0); err != nil {
+		return fmt.Errorf("failed to relocate archive: %w", err)
+	}
+	if err := archive.SetTrailerLength(data, 0); err != nil {
+		return fmt.Errorf("failed to restore archive comment: %w", err)
+	}
+
+	return os.WriteFile(output, data, 0644)
+}
+
+// extracts the wrapper of a given executable to a separate file
+func extractWrapper(f *os.File, payloadOffset uint64, output string) error {
+	stat, err := f.Stat()
+	if err != nil {
+		return fmt.Errorf("failed to stat executable: %w", err)
+	}
+
+	wrapper, err := readWrapper(f, stat.Size(), payloadOffset)
+	if err != nil {
+		return fmt.Errorf("failed to read wrapper: %w", err)
+	}
+
+	if err := os.WriteFile(output, wrapper, 0644); err != nil {
+		return fmt.Errorf("failed to write output file %s: %w", output, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,319 @@
+/*
+ * Copyright 2021 Johannes Donath <johannesd@torchmind.com>
+ * and other copyright owners as documented in the project's IP log.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package main
+
+import (
+	"flag"
+	"fmt"
+	"github.com/dotstart/canoe/internal/metadata"
+	"regexp"
+	"strings"
+)
+
+// matches application identifiers in reverse domain name notation
+var identifierPattern = regexp.MustCompile(`^[A-Za-z0-9-]+(\.[A-Za-z0-9-]+)+$`)
+
+// matches URL schemes (e.g. "foo" for "foo://bar")
+var urlSchemePattern = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9+.-]*$`)
+
+// matches freedesktop menu categories (e.g. "Development")
+var categoryPattern = regexp.MustCompile(`^[A-Za-z0-9-]+$`)
+
+// matches MIME types (e.g. "application/x-foo")
+var mimeTypePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9!#$&^_.+-]*/[a-z0-9][a-z0-9!#$&^_.+-]*$`)
+
+// encapsulates the command line options which define the container metadata of an executable
+type metadataFlags struct {
+	mainClass string
+
+	runtimeMinimumVersion uint
+	runtimeMaximumVersion uint
+	runtimeInitialMemory  string
+	runtimeMemoryLimit    string
+	runtimeArguments      string
+
+	applicationName        string
+	applicationVersion     string
+	applicationVendor      string
+	applicationCopyright   string
+	applicationDescription string
+	applicationHomepage    string
+	applicationIdentifier  string
+
+	documentTypes     stringList
+	urlSchemes        stringList
+	desktopCategories string
+
+	supportURL         string
+	runtimeDownloadURL string
+
+	messageConfiguration      string
+	messageRuntimeNotFound    string
+	messageRuntimeUnsupported string
+	messageRuntimeInvalid     string
+	messageLaunch             string
+	messageIntegrity          string
+}
+
+func (m *metadataFlags) SetFlags(f *flag.FlagSet) {
+	f.StringVar(&m.mainClass, "main-class", "", "selects a specific main class to launch (defaults to the Main-Class attribute within the archive manifest)")
+
+	f.UintVar(&m.runtimeMinimumVersion, "runtime-version", 0, fmt.Sprintf("defines the minimum required runtime version (inferred from the archive bytecode by default; falls back to %d)", defaultRuntimeVersion))
+	f.UintVar(&m.runtimeMaximumVersion, "runtime-max-version", 0, "defines the maximum permitted runtime version (unset by default)")
+	f.StringVar(&m.runtimeInitialMemory, "runtime-initial-memory", "", "defines the initial runtime memory (unset by default)")
+	f.StringVar(&m.runtimeMemoryLimit, "runtime-memory-limit", "", "defines the runtime memory limit (unset by default)")
+	f.StringVar(&m.runtimeArguments, "runtime-args", "", "supplies additional arguments to be passed to the runtime upon application startup")
+
+	f.StringVar(&m.applicationName, "name", "", "defines the application name as displayed to users (defaults to the Implementation-Title attribute within the archive manifest)")
+	f.StringVar(&m.applicationVersion, "app-version", "", "defines the application version (defaults to the Implementation-Version attribute within the archive manifest)")
+	f.StringVar(&m.applicationVendor, "vendor", "", "defines the application vendor (defaults to the Implementation-Vendor attribute within the archive manifest)")
+	f.StringVar(&m.applicationCopyright, "copyright", "", "defines the application copyright notice (unset by default)")
+	f.StringVar(&m.applicationDescription, "description", "", "defines a short application description (unset by default)")
+	f.StringVar(&m.applicationHomepage, "homepage", "", "defines the application homepage (defaults to the Implementation-URL attribute within the archive manifest)")
+	f.StringVar(&m.applicationIdentifier, "app-id", "", "defines the application identifier in reverse domain name notation (defaults to the Automatic-Module-Name attribute within the archive manifest or the main class)")
+	f.Var(&m.urlSchemes, "url-scheme", "declares a URL scheme (such as \"foo\" for foo://bar) which is handled by the application (may be given multiple times)")
+	f.StringVar(&m.desktopCategories, "desktop-categories", "", "selects a comma separated list of freedesktop menu categories in which the application is listed on Linux (defaults to Utility)")
+	f.Var(&m.documentTypes, "document-type", "declares a type of document which may be opened by the application in the form of name:extensions[:mime types] (may be given multiple times)")
+	f.StringVar(&m.supportURL, "support-url", "", "defines a URL at which users may request help with the application (unset by default)")
+	f.StringVar(&m.runtimeDownloadURL, "runtime-download-url", "", "defines a URL from which users may obtain a compatible runtime (unset by default)")
+
+	f.StringVar(&m.messageConfiguration, "message-configuration", "", "replaces the message displayed when the application configuration cannot be loaded")
+	f.StringVar(&m.messageRuntimeNotFound, "message-runtime-not-found", "", "replaces the message displayed when no runtime can be located")
+	f.StringVar(&m.messageRuntimeUnsupported, "message-runtime-unsupported", "", "replaces the message displayed when no compatible runtime version can be located")
+	f.StringVar(&m.messageRuntimeInvalid, "message-runtime-invalid", "", "replaces the message displayed when a damaged runtime installation is encountered")
+	f.StringVar(&m.messageLaunch, "message-launch", "", "replaces the message displayed when the runtime fails to launch the application")
+	f.StringVar(&m.messageIntegrity, "message-integrity", "", "replaces the message displayed when the executable is found to be corrupted")
+}
+
+// applies the selected options to a given set of container metadata
+//
+// only options for which isSet returns true are applied thus permitting callers to selectively
+// update existing metadata
+func (m *metadataFlags) apply(meta *metadata.ApplicationContainer, isSet func(name string) bool) error {
+	if meta.Runtime == nil {
+		meta.Runtime = &metadata.RuntimeConfiguration{}
+	}
+	if meta.Application == nil {
+		meta.Application = &metadata.ApplicationConfiguration{}
+	}
+	if meta.Identity == nil {
+		meta.Identity = &metadata.ApplicationIdentity{}
+	}
+	if meta.Support == nil {
+		meta.Support = &metadata.SupportConfiguration{}
+	}
+	if meta.Desktop == nil {
+		meta.Desktop = &metadata.DesktopConfiguration{}
+	}
+	if meta.Support.Messages == nil {
+		meta.Support.Messages = &metadata.ErrorMessages{}
+	}
+
+	if isSet("main-class") {
+		meta.Application.MainClass = m.mainClass
+	}
+
+	if isSet("runtime-version") {
+		meta.Runtime.MinimumVersion = uint64(m.runtimeMinimumVersion)
+	}
+	if isSet("runtime-max-version") {
+		meta.Runtime.MaximumVersion = uint64(m.runtimeMaximumVersion)
+	}
+	if isSet("runtime-initial-memory") {
+		size, err := parseOptionalByteSuffix(m.runtimeInitialMemory)
+		if err != nil {
+			return fmt.Errorf("invalid initial memory size: %w", err)
+		}
+
+		meta.Runtime.InitialMemory = size
+	}
+	if isSet("runtime-memory-limit") {
+		size, err := parseOptionalByteSuffix(m.runtimeMemoryLimit)
+		if err != nil {
+			return fmt.Errorf("invalid memory limit: %w", err)
+		}
+
+		meta.Runtime.MemoryLimit = size
+	}
+	if isSet("runtime-args") {
+		meta.Runtime.AdditionalArguments = m.runtimeArguments
+	}
+
+	if isSet("name") {
+		meta.Identity.Name = m.applicationName
+	}
+	if isSet("app-version") {
+		meta.Identity.Version = m.applicationVersion
+	}
+	if isSet("vendor") {
+		meta.Identity.Vendor = m.applicationVendor
+	}
+	if isSet("copyright") {
+		meta.Identity.Copyright = m.applicationCopyright
+	}
+	if isSet("description") {
+		meta.Identity.Description = m.applicationDescription
+	}
+	if isSet("homepage") {
+		meta.Identity.Homepage = m.applicationHomepage
+	}
+	if isSet("app-id") {
+		if len(m.applicationIdentifier) != 0 && !identifierPattern.MatchString(m.applicationIdentifier) {
+			return fmt.Errorf("invalid application identifier: %s", m.applicationIdentifier)
+		}
+
+		meta.Identity.Identifier = m.applicationIdentifier
+	}
+	if isSet("document-type") {
+		meta.Desktop.DocumentTypes = nil
+		for _, declaration := range m.documentTypes {
+			documentType, err := parseDocumentType(declaration)
+			if err != nil {
+				return err
+			}
+
+			meta.Desktop.DocumentTypes = append(meta.Desktop.DocumentTypes, documentType)
+		}
+	}
+	if isSet("url-scheme") {
+		meta.Desktop.UrlSchemes = nil
+		for _, scheme := range m.urlSchemes {
+			scheme = strings.TrimSuffix(scheme, "://")
+			if !urlSchemePattern.MatchString(scheme) {
+				return fmt.Errorf("invalid URL scheme: %s", scheme)
+			}
+
+			meta.Desktop.UrlSchemes = append(meta.Desktop.UrlSchemes, strings.ToLower(scheme))
+		}
+	}
+	if isSet("desktop-categories") {
+		meta.Desktop.Categories = splitList(m.desktopCategories)
+		for _, category := range meta.Desktop.Categories {
+			if !categoryPattern.MatchString(category) {
+				return fmt.Errorf("invalid desktop category: %s", category)
+			}
+		}
+	}
+	if isSet("support-url") {
+		meta.Support.SupportUrl = m.supportURL
+	}
+	if isSet("runtime-download-url") {
+		meta.Support.RuntimeDownloadUrl = m.runtimeDownloadURL
+	}
+
+	messages := meta.Support.Messages
+	if isSet("message-configuration") {
+		messages.Configuration = m.messageConfiguration
+	}
+	if isSet("message-runtime-not-found") {
+		messages.RuntimeNotFound = m.messageRuntimeNotFound
+	}
+	if isSet("message-runtime-unsupported") {
+		messages.RuntimeUnsupported = m.messageRuntimeUnsupported
+	}
+	if isSet("message-runtime-invalid") {
+		messages.RuntimeInvalid = m.messageRuntimeInvalid
+	}
+	if isSet("message-launch") {
+		messages.Launch = m.messageLaunch
+	}
+	if isSet("message-integrity") {
+		messages.Integrity = m.messageIntegrity
+	}
+
+	return nil
+}
+
+// parses a document type declaration in the form of name:extensions[:mime types] where extensions
+// and MIME types are separated by commas
+func parseDocumentType(declaration string) (*metadata.DocumentType, error) {
+	parts := strings.Split(declaration, ":")
+	if len(parts) < 2 || len(parts) > 3 || len(strings.TrimSpace(parts[0])) == 0 {
+		return nil, fmt.Errorf("invalid document type %q: expected name:extensions[:mime types]", declaration)
+	}
+
+	documentType := &metadata.DocumentType{
+		Name: strings.TrimSpace(parts[0]),
+	}
+	for _, extension := range splitList(parts[1]) {
+		documentType.Extensions = append(documentType.Extensions, strings.TrimPrefix(extension, "."))
+	}
+	if len(parts) == 3 {
+		documentType.MimeTypes = splitList(parts[2])
+	}
+
+	if len(documentType.Extensions) == 0 && len(documentType.MimeTypes) == 0 {
+		return nil, fmt.Errorf("invalid document type %q: at least one extension or MIME type is required", declaration)
+	}
+	for _, mimeType := range documentType.MimeTypes {
+		if !mimeTypePattern.MatchString(mimeType) {
+			return nil, fmt.Errorf("invalid document type %q: malformed MIME type %s", declaration, mimeType)
+		}
+	}
+
+	return documentType, nil
+}
+
+// splits a comma separated list while omitting empty elements
+func splitList(input string) []string {
+	elements := make([]string, 0)
+	for _, element := range strings.Split(input, ",") {
+		if element = strings.TrimSpace(element); len(element) != 0 {
+			elements = append(elements, element)
+		}
+	}
+
+	return elements
+}
+
+// parses a given byte size while permitting empty values (which evaluate to zero)
+func parseOptionalByteSuffix(input string) (uint64, error) {
+	if len(input) == 0 {
+		return 0, nil
+	}
+
+	return metadata.ParseByteSuffix(input)
+}
+
+// stringList collects the values of flags which may be given multiple times.
+type stringList []string
+
+func (l *stringList) String() string {
+	return strings.Join(*l, ", ")
+}
+
+func (l *stringList) Set(value string) error {
+	*l = append(*l, value)
+	return nil
+}
+
+// produces a function which identifies whether a given flag has been explicitly set
+func visitedFlags(f *flag.FlagSet) func(name string) bool {
+	visited := make(map[string]bool)
+	f.Visit(func(f *flag.Flag) {
+		visited[f.Name] = true
+	})
+
+	return func(name string) bool {
+		return visited[name]
+	}
+}
+
+// evaluates to true for all flags
+func allFlags(string) bool {
+	return true
+}
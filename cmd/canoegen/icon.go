@@ -0,0 +1,55 @@
+/*
+ * Copyright 2021 Johannes Donath <johannesd@torchmind.com>
+ * and other copyright owners as documented in the project's IP log.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/dotstart/canoe/internal/icon"
+	"image/png"
+	"os"
+)
+
+// reads and validates an application icon
+//
+// icons are expected to be square PNG images (or ICO files for Windows targets). Larger images
+// (256x256 or more) are recommended as they are scaled down to the sizes required by the respective
+// target platforms.
+func readIcon(path string) ([]byte, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read icon: %w", err)
+	}
+
+	if icon.IsICO(data) {
+		if _, err := icon.ParseICO(data); err != nil {
+			return nil, fmt.Errorf("invalid icon %s: %w", path, err)
+		}
+
+		return data, nil
+	}
+
+	cfg, err := png.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("invalid icon %s: expected PNG image: %w", path, err)
+	}
+	if cfg.Width != cfg.Height {
+		return nil, fmt.Errorf("invalid icon %s: expected square image but got %dx%d", path, cfg.Width, cfg.Height)
+	}
+
+	return data, nil
+}
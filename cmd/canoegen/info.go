@@ -17,17 +17,57 @@
 package main
 
 import (
+	"bytes"
 	"context"
+	"encoding/hex"
+	"encoding/json"
 	"flag"
 	"fmt"
 	"github.com/dotstart/canoe/internal"
 	"github.com/dotstart/canoe/internal/metadata"
 	"github.com/google/subcommands"
+	"google.golang.org/protobuf/encoding/protojson"
+	"gopkg.in/yaml.v3"
 	"os"
+	"strings"
 )
 
 type infoCommand struct {
 	inputFile string
+	format    string
+}
+
+// containerInfo describes the machine readable representation of a canoe executable.
+type containerInfo struct {
+	File     string          `json:"file"`
+	Footer   footerInfo      `json:"footer"`
+	Target   *targetInfo     `json:"target,omitempty"`
+	Digests  digestInfo      `json:"digests"`
+	Metadata json.RawMessage `json:"metadata"`
+}
+
+type footerInfo struct {
+	FormatVersion         uint8  `json:"formatVersion"`
+	MinimumWrapperVersion uint16 `json:"minimumWrapperVersion"`
+	PayloadOffset         uint64 `json:"payloadOffset"`
+	PayloadLength         uint64 `json:"payloadLength"`
+	MetadataOffset        uint64 `json:"metadataOffset"`
+	MetadataLength        uint64 `json:"metadataLength"`
+}
+
+type targetInfo struct {
+	Name string `json:"name"`
+	OS   string `json:"os"`
+	Arch string `json:"arch"`
+	Gui  bool   `json:"gui"`
+}
+
+type digestInfo struct {
+	WrapperSha256 string `json:"wrapperSha256"`
+	PayloadSha256 string `json:"payloadSha256"`
+
+	// one of "verified", "mismatch" or "unrecorded"
+	Status string `json:"status"`
 }
 
 func (*infoCommand) Name() string {
@@ -39,13 +79,20 @@ func (*infoCommand) Synopsis() string {
 }
 
 func (*infoCommand) Usage() string {
-	return `canoegen info -in <file> [args]
+	return `canoegen info -in <file> [-format text|json|yaml] [args]
 
 Displays the configuration information stored within a given canoe executable which has previously
 been generated using canoe wrap:
 
   $ canoegen info -in foo.exe
 
+Machine readable output may be requested using the -format flag. In this mode, the container
+metadata is included verbatim (as encoded by protojson) along with the location of each section
+within the executable, the digests of the wrapper and payload as well as the target platform
+detected from the wrapper header:
+
+  $ canoegen info -in foo.exe -format json
+
 The following configuration options are provided by this command:
 
 `
@@ -53,6 +100,7 @@ The following configuration options are provided by this command:
 
 func (cmd *infoCommand) SetFlags(f *flag.FlagSet) {
 	f.StringVar(&cmd.inputFile, "in", "", "selects an input executable")
+	f.StringVar(&cmd.format, "format", "text", "selects an output format (text, json or yaml)")
 }
 
 func (cmd *infoCommand) Execute(context.Context, *flag.FlagSet, ...interface{}) subcommands.ExitStatus {
@@ -60,14 +108,155 @@ func (cmd *infoCommand) Execute(context.Context, *flag.FlagSet, ...interface{})
 		_, _ = fmt.Fprintln(os.Stderr, "invalid parameters: input file is required")
 		return subcommands.ExitUsageError
 	}
+	if cmd.format != "text" && cmd.format != "json" && cmd.format != "yaml" {
+		_, _ = fmt.Fprintf(os.Stderr, "invalid parameters: unsupported format: %s\n", cmd.format)
+		return subcommands.ExitUsageError
+	}
 
-	meta, err := internal.ReadExecutableFooter(cmd.inputFile)
+	info, meta, err := readContainerInfo(cmd.inputFile)
 	if err != nil {
 		_, _ = fmt.Fprintf(os.Stderr, "failed to read executable: %s\n", err)
 		return subcommands.ExitFailure
 	}
 
-	fmt.Printf("==== configuration of %s ====\n\n", cmd.inputFile)
+	switch cmd.format {
+	case "json":
+		encoded, err := json.MarshalIndent(info, "", "  ")
+		if err != nil {
+			_, _ = fmt.Fprintf(os.Stderr, "failed to encode container information: %s\n", err)
+			return subcommands.ExitFailure
+		}
+
+		fmt.Println(string(encoded))
+	case "yaml":
+		encoded, err := encodeYaml(info)
+		if err != nil {
+			_, _ = fmt.Fprintf(os.Stderr, "failed to encode container information: %s\n", err)
+			return subcommands.ExitFailure
+		}
+
+		fmt.Print(string(encoded))
+	default:
+		printContainerInfo(info, meta)
+	}
+
+	return subcommands.ExitSuccess
+}
+
+// reads the footer, metadata and section digests of a given executable
+func readContainerInfo(path string) (*containerInfo, *metadata.ApplicationContainer, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer f.Close()
+
+	stat, err := f.Stat()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	footer, meta, err := internal.DecodeExecutableFooter(f, stat.Size())
+	if err != nil {
+		return nil, nil, err
+	}
+
+	payloadOffset, payloadLength, err := internal.LocatePayload(f, footer)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	encoded, err := protojson.Marshal(meta)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to encode container metadata: %w", err)
+	}
+
+	info := &containerInfo{
+		File: path,
+		Footer: footerInfo{
+			FormatVersion:         footer.FormatVersion,
+			MinimumWrapperVersion: footer.MinimumWrapperVersion,
+			PayloadOffset:         payloadOffset,
+			PayloadLength:         payloadLength,
+			MetadataOffset:        footer.MetadataOffset,
+			MetadataLength:        footer.MetadataLength,
+		},
+		Metadata: encoded,
+	}
+
+	if target, err := internal.DetectTarget(f); err == nil {
+		info.Target = &targetInfo{
+			Name: target.Name(),
+			OS:   target.OS,
+			Arch: target.Arch,
+			Gui:  target.Gui,
+		}
+	}
+
+	wrapperDigest, err := internal.DigestSection(f, footer.WrapperOffset, payloadOffset-footer.WrapperOffset)
+	if err != nil {
+		return nil, nil, err
+	}
+	payloadDigest, err := internal.DigestSection(f, payloadOffset, payloadLength)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	info.Digests = digestInfo{
+		WrapperSha256: hex.EncodeToString(wrapperDigest),
+		PayloadSha256: hex.EncodeToString(payloadDigest),
+		Status:        "unrecorded",
+	}
+	if integrity := meta.GetIntegrity(); integrity != nil {
+		// executables which embed their payload within an ELF section do not record a wrapper digest
+		// as their wrapper may be rewritten by tools such as strip
+		info.Digests.Status = "verified"
+		if (len(integrity.WrapperSha256) != 0 && !bytes.Equal(integrity.WrapperSha256, wrapperDigest)) || !bytes.Equal(integrity.PayloadSha256, payloadDigest) {
+			info.Digests.Status = "mismatch"
+		}
+	}
+
+	return info, meta, nil
+}
+
+// encodes container information as YAML while retaining the field order of its JSON encoding
+func encodeYaml(info *containerInfo) ([]byte, error) {
+	encoded, err := json.Marshal(info)
+	if err != nil {
+		return nil, err
+	}
+
+	node := &yaml.Node{}
+	if err := yaml.Unmarshal(encoded, node); err != nil {
+		return nil, err
+	}
+	resetYamlStyle(node)
+
+	buf := &bytes.Buffer{}
+	enc := yaml.NewEncoder(buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(node); err != nil {
+		return nil, err
+	}
+	if err := enc.Close(); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// resets the style of a given node and its children in order to replace the flow style inherited
+// from JSON with regular block style
+func resetYamlStyle(node *yaml.Node) {
+	node.Style = 0
+	for _, child := range node.Content {
+		resetYamlStyle(child)
+	}
+}
+
+// prints a human readable description of a given container
+func printContainerInfo(info *containerInfo, meta *metadata.ApplicationContainer) {
+	fmt.Printf("==== configuration of %s ====\n\n", info.File)
 
 	fmt.Println("==> canoe metadata")
 	fmt.Println()
@@ -75,23 +264,89 @@ func (cmd *infoCommand) Execute(context.Context, *flag.FlagSet, ...interface{})
 	fmt.Printf(" custom generator: %v\n", meta.CustomWrapper)
 	fmt.Println()
 
+	fmt.Println("==> container layout")
+	fmt.Println()
+	fmt.Printf("          format version: %d\n", info.Footer.FormatVersion)
+	fmt.Printf(" minimum wrapper version: %d\n", info.Footer.MinimumWrapperVersion)
+	fmt.Printf("          payload offset: %d\n", info.Footer.PayloadOffset)
+	fmt.Printf("            payload size: %d\n", info.Footer.PayloadLength)
+	fmt.Printf("         metadata offset: %d\n", info.Footer.MetadataOffset)
+	fmt.Printf("           metadata size: %d\n", info.Footer.MetadataLength)
+	if info.Target != nil {
+		fmt.Printf("                  target: %s (gui: %v)\n", info.Target.Name, info.Target.Gui)
+	} else {
+		fmt.Println("                  target: unknown")
+	}
+	fmt.Println()
+
 	fmt.Println("==> runtime configuration")
 	fmt.Println()
-	fmt.Printf("      minimum version: %d\n", meta.Runtime.MinimumVersion)
-	fmt.Printf("      maximum version: %d\n", meta.Runtime.MaximumVersion)
+	fmt.Printf("      minimum version: %d\n", meta.GetRuntime().GetMinimumVersion())
+	fmt.Printf("      maximum version: %d\n", meta.GetRuntime().GetMaximumVersion())
 	fmt.Println()
 
-	fmt.Printf("       initial memory: %s\n", metadata.AppendByteSuffix(meta.Runtime.InitialMemory))
-	fmt.Printf("         memory limit: %s\n", metadata.AppendByteSuffix(meta.Runtime.MemoryLimit))
-	fmt.Printf(" additional arguments: \"%s\"\n", meta.Runtime.AdditionalArguments)
+	fmt.Printf("       initial memory: %s\n", metadata.AppendByteSuffix(meta.GetRuntime().GetInitialMemory()))
+	fmt.Printf("         memory limit: %s\n", metadata.AppendByteSuffix(meta.GetRuntime().GetMemoryLimit()))
+	fmt.Printf(" additional arguments: \"%s\"\n", meta.GetRuntime().GetAdditionalArguments())
+	fmt.Printf("     required modules: %s\n", strings.Join(meta.GetRuntime().GetRequiredModules(), ", "))
 	fmt.Println()
 
 	fmt.Println("==> application configuration")
 	fmt.Println()
-	fmt.Printf(" main class: %s\n", meta.Application.MainClass)
+	identity := meta.GetIdentity()
+	fmt.Printf("                 name: %s\n", identity.GetName())
+	fmt.Printf("              version: %s\n", identity.GetVersion())
+	fmt.Printf("               vendor: %s\n", identity.GetVendor())
+	fmt.Printf("            copyright: %s\n", identity.GetCopyright())
+	fmt.Printf("          description: %s\n", identity.GetDescription())
+	fmt.Printf("             homepage: %s\n", identity.GetHomepage())
+	fmt.Printf("           identifier: %s\n", identity.GetIdentifier())
+	fmt.Printf("           main class: %s\n", meta.GetApplication().GetMainClass())
+	fmt.Printf("            add opens: %s\n", strings.Join(meta.GetApplication().GetAddOpens(), " "))
+	fmt.Printf("          add exports: %s\n", strings.Join(meta.GetApplication().GetAddExports(), " "))
+	fmt.Printf(" enable native access: %v\n", meta.GetApplication().GetEnableNativeAccess())
+	fmt.Printf(" launcher agent class: %s\n", meta.GetApplication().GetLauncherAgentClass())
+	fmt.Printf("           class path: %s\n", strings.Join(meta.GetApplication().GetClassPath(), " "))
 	fmt.Println()
 
-	fmt.Println("-- end of readout --")
+	fmt.Println("==> desktop configuration")
+	fmt.Println()
+	for _, documentType := range meta.GetDesktop().GetDocumentTypes() {
+		fmt.Printf("        document type: %s (extensions: %s; mime types: %s)\n", documentType.GetName(), strings.Join(documentType.GetExtensions(), ", "), strings.Join(documentType.GetMimeTypes(), ", "))
+	}
+	if len(meta.GetDesktop().GetDocumentTypes()) == 0 {
+		fmt.Println("       document types: none")
+	}
+	fmt.Printf("          url schemes: %s\n", strings.Join(meta.GetDesktop().GetUrlSchemes(), ", "))
+	fmt.Printf("           categories: %s\n", strings.Join(meta.GetDesktop().GetCategories(), ", "))
+	if iconData := meta.GetDesktop().GetIcon(); len(iconData) != 0 {
+		fmt.Printf("                 icon: %d bytes\n", len(iconData))
+	} else {
+		fmt.Println("                 icon: none")
+	}
+	fmt.Println()
 
-	return subcommands.ExitSuccess
+	fmt.Println("==> support configuration")
+	fmt.Println()
+	fmt.Printf("          support url: %s\n", meta.GetSupport().GetSupportUrl())
+	fmt.Printf(" runtime download url: %s\n", meta.GetSupport().GetRuntimeDownloadUrl())
+	fmt.Println()
+
+	messages := meta.GetSupport().GetMessages()
+	fmt.Printf("        configuration message: %q\n", messages.GetConfiguration())
+	fmt.Printf("    runtime not found message: %q\n", messages.GetRuntimeNotFound())
+	fmt.Printf("  runtime unsupported message: %q\n", messages.GetRuntimeUnsupported())
+	fmt.Printf("      runtime invalid message: %q\n", messages.GetRuntimeInvalid())
+	fmt.Printf("               launch message: %q\n", messages.GetLaunch())
+	fmt.Printf("            integrity message: %q\n", messages.GetIntegrity())
+	fmt.Println()
+
+	fmt.Println("==> integrity")
+	fmt.Println()
+	fmt.Printf(" wrapper sha256: %s\n", info.Digests.WrapperSha256)
+	fmt.Printf(" payload sha256: %s\n", info.Digests.PayloadSha256)
+	fmt.Printf("         status: %s\n", info.Digests.Status)
+	fmt.Println()
+
+	fmt.Println("-- end of readout --")
 }
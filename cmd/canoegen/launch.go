@@ -39,12 +39,16 @@ func (*launchCommand) Synopsis() string {
 }
 
 func (*launchCommand) Usage() string {
-	return `canoegen launch -in <file> [args]
+	return `canoegen launch -in <file> [args] [application args]
 
 Launches the archive contained within a given canoe wrapped executable:
 
   $ canoegen launch -in foo.exe
 
+Any arguments following the options of this command are passed to the application:
+
+  $ canoegen launch -in foo.exe -- --foo bar.txt
+
 This command is primarily provided for development purposes.
 
 The following configuration options are provided by this command:
@@ -56,11 +60,11 @@ func (cmd *launchCommand) SetFlags(f *flag.FlagSet) {
 	f.StringVar(&cmd.inputFile, "in", "", "selects an input executable")
 }
 
-func (cmd *launchCommand) Execute(context.Context, *flag.FlagSet, ...interface{}) subcommands.ExitStatus {
+func (cmd *launchCommand) Execute(_ context.Context, f *flag.FlagSet, _ ...interface{}) subcommands.ExitStatus {
 	if len(cmd.inputFile) == 0 {
 		_, _ = fmt.Fprintln(os.Stderr, "invalid parameters: input file is required")
 		return subcommands.ExitUsageError
 	}
 
-	return subcommands.ExitStatus(internal.LaunchApplication(cmd.inputFile, runtime.CliExecutableName))
+	return subcommands.ExitStatus(internal.LaunchApplication(cmd.inputFile, runtime.CliExecutableName, f.Args(), internal.CliReporter{}))
 }
@@ -27,8 +27,13 @@ func main() {
 	subcommands.Register(subcommands.HelpCommand(), "")
 	subcommands.Register(subcommands.FlagsCommand(), "")
 	subcommands.Register(subcommands.CommandsCommand(), "")
+	subcommands.Register(&analyzeCommand{}, "")
+	subcommands.Register(&configureCommand{}, "")
+	subcommands.Register(&extractCommand{}, "")
 	subcommands.Register(&infoCommand{}, "")
 	subcommands.Register(&launchCommand{}, "")
+	subcommands.Register(&upgradeCommand{}, "")
+	subcommands.Register(&verifyCommand{}, "")
 	subcommands.Register(&wrapCommand{}, "")
 
 	flag.Parse()
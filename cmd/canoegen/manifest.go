@@ -0,0 +1,204 @@
+/*
+ * Copyright 2021 Johannes Donath <johannesd@torchmind.com>
+ * and other copyright owners as documented in the project's IP log.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package main
+
+import (
+	"archive/zip"
+	"errors"
+	"fmt"
+	"github.com/dotstart/canoe/internal/archive"
+	"github.com/dotstart/canoe/internal/metadata"
+	"net/url"
+	"os"
+	"strings"
+)
+
+// reads the manifest of a given archive
+//
+// returns nil when the archive does not contain a manifest
+func readManifest(r *zip.Reader) (*archive.Manifest, error) {
+	manifest, err := archive.ReadManifest(r)
+	if err != nil {
+		if errors.Is(err, archive.ErrMissingManifest) {
+			return nil, nil
+		}
+
+		return nil, err
+	}
+
+	return manifest, nil
+}
+
+// resolves the main class of an application and ensures that it is present within its archive
+//
+// when no main class has been selected explicitly, the Main-Class attribute of the archive
+// manifest is used instead
+func resolveMainClass(r *zip.Reader, manifest *archive.Manifest, meta *metadata.ApplicationContainer) error {
+	mainClass := meta.GetApplication().GetMainClass()
+	if len(mainClass) == 0 {
+		if manifest == nil {
+			return fmt.Errorf("no main class selected: archive does not contain a manifest (please specify -main-class)")
+		}
+
+		mainClass = strings.TrimSpace(manifest.Get("Main-Class"))
+		if len(mainClass) == 0 {
+			return fmt.Errorf("no main class selected: manifest does not declare a Main-Class attribute (please specify -main-class)")
+		}
+	}
+
+	multiRelease := manifest != nil && manifest.IsMultiRelease()
+	versions, err := archive.FindClass(r, mainClass, multiRelease)
+	if err != nil {
+		return fmt.Errorf("invalid main class: %w", err)
+	}
+
+	minimumVersion := meta.GetRuntime().GetMinimumVersion()
+	if uint64(versions[0]) > minimumVersion {
+		return fmt.Errorf("invalid main class: %s is only available on runtime version %d or newer (please raise -runtime-version)", mainClass, versions[0])
+	}
+
+	meta.Application.MainClass = mainClass
+	return nil
+}
+
+// resolves the minimum runtime version of an application based on the bytecode within its archive
+//
+// when a minimum version has been selected explicitly, it is retained but a warning is displayed
+// if it is lower than the version required by the bytecode
+func resolveRuntimeVersion(r *zip.Reader, manifest *archive.Manifest, meta *metadata.ApplicationContainer, explicit bool) {
+	multiRelease := manifest != nil && manifest.IsMultiRelease()
+	requirement, err := archive.InferRuntimeRequirement(r, multiRelease)
+	if err != nil {
+		_, _ = fmt.Fprintf(os.Stderr, "warning: failed to infer runtime version: %s\n", err)
+		requirement = &archive.RuntimeRequirement{}
+	}
+
+	for _, mismatch := range requirement.Mismatches {
+		_, _ = fmt.Fprintf(os.Stderr, "warning: %s requires runtime version %d but is selected by runtime version %d and newer\n", mismatch.Class, mismatch.Version, mismatch.Release)
+	}
+
+	if explicit {
+		if uint64(requirement.Version) > meta.Runtime.MinimumVersion {
+			_, _ = fmt.Fprintf(os.Stderr, "warning: %s requires runtime version %d but -runtime-version is set to %d (the application may fail with UnsupportedClassVersionError)\n", requirement.Class, requirement.Version, meta.Runtime.MinimumVersion)
+		}
+
+		return
+	}
+
+	if requirement.Version == 0 {
+		meta.Runtime.MinimumVersion = defaultRuntimeVersion
+		return
+	}
+
+	meta.Runtime.MinimumVersion = uint64(requirement.Version)
+}
+
+// translates the launch attributes of an archive manifest into their respective metadata
+//
+// the runtime solely evaluates these attributes when launching applications via -jar and they are
+// thus passed via their command line equivalents instead
+func resolveLaunchAttributes(manifest *archive.Manifest, meta *metadata.ApplicationContainer) {
+	if manifest == nil {
+		return
+	}
+
+	application := meta.Application
+	application.AddOpens = strings.Fields(manifest.Get("Add-Opens"))
+	application.AddExports = strings.Fields(manifest.Get("Add-Exports"))
+
+	nativeAccess := strings.TrimSpace(manifest.Get("Enable-Native-Access"))
+	if len(nativeAccess) != 0 {
+		if nativeAccess == "ALL-UNNAMED" {
+			application.EnableNativeAccess = true
+		} else {
+			_, _ = fmt.Fprintf(os.Stderr, "warning: ignoring unsupported Enable-Native-Access value %q\n", nativeAccess)
+		}
+	}
+
+	// agents are loaded via -javaagent which invokes the premain method of the Premain-Class rather
+	// than the agentmain method of the Launcher-Agent-Class (as the runtime only evaluates the latter
+	// when launching via -jar)
+	agentClass := strings.TrimSpace(manifest.Get("Launcher-Agent-Class"))
+	premainClass := strings.TrimSpace(manifest.Get("Premain-Class"))
+	application.LauncherAgentClass = ""
+	if len(agentClass) != 0 {
+		if len(premainClass) == 0 {
+			_, _ = fmt.Fprintf(os.Stderr, "warning: Launcher-Agent-Class %s will not be started as the manifest does not declare a Premain-Class attribute\n", agentClass)
+		} else if premainClass != agentClass {
+			_, _ = fmt.Fprintf(os.Stderr, "warning: Launcher-Agent-Class %s will not be started as the manifest declares a different Premain-Class %s\n", agentClass, premainClass)
+		} else {
+			application.LauncherAgentClass = agentClass
+		}
+	}
+
+	application.ClassPath = nil
+	for _, entry := range strings.Fields(manifest.Get("Class-Path")) {
+		parsed, err := url.Parse(entry)
+		if err != nil || (len(parsed.Scheme) != 0 && parsed.Scheme != "file") {
+			_, _ = fmt.Fprintf(os.Stderr, "warning: ignoring unsupported Class-Path entry %s\n", entry)
+			continue
+		}
+
+		application.ClassPath = append(application.ClassPath, parsed.Path)
+	}
+}
+
+// populates the application identity from the Implementation-* and Automatic-Module-Name attributes
+// of an archive manifest
+//
+// only values which have not been selected explicitly are replaced
+func resolveIdentity(manifest *archive.Manifest, meta *metadata.ApplicationContainer, isSet func(name string) bool) {
+	if manifest == nil {
+		return
+	}
+
+	identity := meta.Identity
+	if !isSet("name") {
+		identity.Name = strings.TrimSpace(manifest.Get("Implementation-Title"))
+	}
+	if !isSet("app-version") {
+		identity.Version = strings.TrimSpace(manifest.Get("Implementation-Version"))
+	}
+	if !isSet("vendor") {
+		identity.Vendor = strings.TrimSpace(manifest.Get("Implementation-Vendor"))
+	}
+	if !isSet("homepage") {
+		identity.Homepage = strings.TrimSpace(manifest.Get("Implementation-URL"))
+	}
+	if !isSet("app-id") {
+		identity.Identifier = strings.TrimSpace(manifest.Get("Automatic-Module-Name"))
+	}
+}
+
+// derives the application identifier from the main class when it has neither been given explicitly
+// nor via the archive manifest
+//
+// main classes which reside within the unnamed package do not produce a valid identifier and are
+// thus ignored.
+func resolveIdentifier(meta *metadata.ApplicationContainer) {
+	identity := meta.Identity
+	if len(identity.Identifier) != 0 && identifierPattern.MatchString(identity.Identifier) {
+		return
+	}
+
+	identifier := strings.NewReplacer("_", "-", "$", "-").Replace(meta.Application.MainClass)
+	if !identifierPattern.MatchString(identifier) {
+		identifier = ""
+	}
+
+	identity.Identifier = identifier
+}
@@ -0,0 +1,342 @@
+/*
+ * Copyright 2021 Johannes Donath <johannesd@torchmind.com>
+ * and other copyright owners as documented in the project's IP log.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"github.com/dotstart/canoe/internal"
+	"github.com/dotstart/canoe/internal/deb"
+	"github.com/dotstart/canoe/internal/desktop"
+	"github.com/dotstart/canoe/internal/icon"
+	"github.com/dotstart/canoe/internal/metadata"
+	"github.com/dotstart/canoe/internal/rpm"
+	"github.com/golang/protobuf/proto"
+	"image"
+	"image/png"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+)
+
+const (
+	debPackageFormat = "deb"
+	rpmPackageFormat = "rpm"
+)
+
+// identifies the release of generated RPM packages
+const rpmRelease = "1"
+
+var packageNamePattern = regexp.MustCompile("^[a-z0-9][a-z0-9.+-]+$")
+var packageVersionPattern = regexp.MustCompile("^[0-9][A-Za-z0-9.+~]*$")
+var packageMaintainerPattern = regexp.MustCompile(`^[^<>\s][^<>]*\s<[^<>\s@]+@[^<>\s]+>$`)
+
+// encapsulates the command line options which control the generation of Linux packages
+type packageFlags struct {
+	packageFormats string
+	packageName    string
+	maintainer     string
+	debDepends     stringList
+	rpmRequires    stringList
+	desktopFiles   bool
+}
+
+func (p *packageFlags) SetFlags(f *flag.FlagSet) {
+	f.StringVar(&p.packageFormats, "package", "", "generates Linux packages of the given comma separated formats (deb or rpm) for each Linux target (unset by default)")
+	f.StringVar(&p.packageName, "package-name", "", "selects the name of generated packages and their installed executable (defaults to the application name)")
+	f.StringVar(&p.maintainer, "maintainer", "", "declares the maintainer of generated Debian packages as \"Name <email>\" (defaults to the application vendor when given in this format)")
+	f.Var(&p.debDepends, "deb-depends", "declares a dependency of generated Debian packages (may be repeated; {minimum} is replaced with the minimum runtime version)")
+	f.Var(&p.rpmRequires, "rpm-requires", "declares a requirement of generated RPM packages (may be repeated; {minimum} is replaced with the minimum runtime version)")
+	f.BoolVar(&p.desktopFiles, "desktop-files", false, "generates a freedesktop desktop entry, icons and MIME type declarations for Linux targets which are also included within packages")
+}
+
+// identifies whether any Linux specific files are to be generated
+func (p *packageFlags) enabled() bool {
+	return len(p.packageFormats) != 0 || p.desktopFiles
+}
+
+// parses the selected package formats
+func (p *packageFlags) formats() ([]string, error) {
+	if len(p.packageFormats) == 0 {
+		return nil, nil
+	}
+
+	var formats []string
+	for _, format := range splitList(p.packageFormats) {
+		if format != debPackageFormat && format != rpmPackageFormat {
+			return nil, fmt.Errorf("unknown package format: %s", format)
+		}
+
+		formats = append(formats, format)
+	}
+
+	return formats, nil
+}
+
+// resolves the package name from the application identity unless given explicitly
+func (p *packageFlags) resolveName(meta *metadata.ApplicationContainer, fallback string) error {
+	if len(p.packageName) == 0 {
+		name := meta.GetIdentity().GetName()
+		if len(name) == 0 {
+			name = fallback
+		}
+
+		p.packageName = desktop.SanitizeName(name)
+	}
+
+	if !packageNamePattern.MatchString(p.packageName) {
+		return fmt.Errorf("invalid package name %q: expected at least two lower case letters, digits, dots, plus or minus signs", p.packageName)
+	}
+
+	return nil
+}
+
+// resolves the maintainer of Debian packages from the application vendor unless given explicitly
+//
+// Debian requires a maintainer consisting of a name and email address thus rejecting vendors which
+// do not follow this format
+func (p *packageFlags) resolveMaintainer(meta *metadata.ApplicationContainer) error {
+	if len(p.maintainer) == 0 {
+		vendor := meta.GetIdentity().GetVendor()
+		if len(vendor) == 0 {
+			return fmt.Errorf("Debian packages require a maintainer: specify -maintainer \"Name <email>\"")
+		}
+		if !packageMaintainerPattern.MatchString(vendor) {
+			return fmt.Errorf("Debian packages require a maintainer: specify -maintainer \"Name <email>\" (application vendor %q is not a valid maintainer)", vendor)
+		}
+
+		p.maintainer = vendor
+	}
+
+	if !packageMaintainerPattern.MatchString(p.maintainer) {
+		return fmt.Errorf("invalid maintainer %q: expected \"Name <email>\"", p.maintainer)
+	}
+
+	return nil
+}
+
+// writes the desktop integration files and selected packages for a given Linux executable to the
+// specified directory
+//
+// desktop integration files are written to the "share" directory (which mirrors the layout of
+// /usr/share) in order to permit their manual installation
+func (p *packageFlags) writePackages(meta *metadata.ApplicationContainer, executable []byte, iconData []byte, dir string) error {
+	formats, err := p.formats()
+	if err != nil {
+		return err
+	}
+
+	target, err := internal.DetectTarget(bytes.NewReader(executable))
+	if err != nil {
+		return fmt.Errorf("failed to detect package architecture: %w", err)
+	}
+	if target.OS != "linux" {
+		return fmt.Errorf("packages and desktop files cannot be generated for target %s", target.Name())
+	}
+
+	files := []packageFile{
+		{path: "/usr/bin/" + p.packageName, mode: 0755, data: executable},
+	}
+	if p.desktopFiles {
+		desktopFiles, err := p.desktopIntegration(meta, iconData)
+		if err != nil {
+			return err
+		}
+
+		for _, file := range desktopFiles {
+			if err := writeOutputFile(filepath.Join(dir, "share", filepath.FromSlash(file.Path)), file.Data, file.Mode); err != nil {
+				return err
+			}
+
+			files = append(files, packageFile{path: "/usr/share/" + file.Path, mode: file.Mode, data: file.Data})
+		}
+	}
+	if len(formats) == 0 {
+		return nil
+	}
+
+	version, err := packageVersion(meta)
+	if err != nil {
+		return err
+	}
+
+	identity := meta.GetIdentity()
+	summary := identity.GetDescription()
+	if len(summary) == 0 {
+		summary = identity.GetName()
+	}
+	if len(summary) == 0 {
+		summary = p.packageName
+	}
+
+	now := time.Now()
+	for _, format := range formats {
+		var fileName string
+		encoded := &bytes.Buffer{}
+
+		switch format {
+		case debPackageFormat:
+			if err := p.resolveMaintainer(meta); err != nil {
+				return err
+			}
+
+			pkg := &deb.Package{
+				Name:         p.packageName,
+				Version:      version,
+				Architecture: deb.Architecture(target.Arch),
+				Maintainer:   p.maintainer,
+				Homepage:     identity.GetHomepage(),
+				Summary:      summary,
+				Depends:      expandDependencies(p.debDepends, meta),
+				ModTime:      now,
+			}
+			for _, file := range files {
+				pkg.Files = append(pkg.Files, deb.File{Path: file.path, Mode: file.mode, Data: file.data})
+			}
+			if len(pkg.Architecture) == 0 {
+				return fmt.Errorf("architecture %s is not supported by Debian packages", target.Arch)
+			}
+
+			fileName = pkg.FileName()
+			err = pkg.Write(encoded)
+		case rpmPackageFormat:
+			pkg := &rpm.Package{
+				Name:         p.packageName,
+				Version:      version,
+				Release:      rpmRelease,
+				Architecture: rpm.Architecture(target.Arch),
+				Summary:      summary,
+				Vendor:       identity.GetVendor(),
+				URL:          identity.GetHomepage(),
+				Requires:     expandDependencies(p.rpmRequires, meta),
+				BuildTime:    now,
+			}
+			for _, file := range files {
+				pkg.Files = append(pkg.Files, rpm.File{Path: file.path, Mode: file.mode, Data: file.data})
+			}
+			if len(pkg.Architecture) == 0 {
+				return fmt.Errorf("architecture %s is not supported by RPM packages", target.Arch)
+			}
+
+			fileName = pkg.FileName()
+			err = pkg.Write(encoded)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to encode %s package: %w", format, err)
+		}
+
+		if err := writeOutputFile(filepath.Join(dir, fileName), encoded.Bytes(), 0644); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// generates the desktop entry, icons and MIME type declarations for the application
+//
+// the desktop entry refers to the installed executable via the search path
+func (p *packageFlags) desktopIntegration(meta *metadata.ApplicationContainer, iconData []byte) ([]desktop.File, error) {
+	var img image.Image
+	if len(iconData) != 0 {
+		if icon.IsICO(iconData) {
+			_, _ = fmt.Fprintln(os.Stderr, "warning: ICO icons are not included within Linux desktop entries (please specify a PNG icon instead)")
+		} else {
+			var err error
+			img, err = png.Decode(bytes.NewReader(iconData))
+			if err != nil {
+				return nil, fmt.Errorf("failed to decode icon: %w", err)
+			}
+		}
+	}
+
+	return desktop.Files(meta, desktop.ID(meta, p.packageName), p.packageName, img)
+}
+
+// defines the maximum size of icons which are embedded within the metadata of Linux executables
+const embeddedIconSize = 256
+
+// embeds a given PNG icon within a copy of the passed metadata in order to permit Linux executables
+// to install it along with their desktop entry
+//
+// ICO icons are not embedded
+func embedDesktopIcon(meta *metadata.ApplicationContainer, iconData []byte) (*metadata.ApplicationContainer, error) {
+	if len(iconData) == 0 || icon.IsICO(iconData) {
+		return meta, nil
+	}
+
+	img, err := png.Decode(bytes.NewReader(iconData))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode icon: %w", err)
+	}
+
+	size := img.Bounds().Dx()
+	if size > embeddedIconSize {
+		size = embeddedIconSize
+	}
+
+	encoded, err := icon.EncodePNG(img, size)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode icon: %w", err)
+	}
+
+	meta = proto.Clone(meta).(*metadata.ApplicationContainer)
+	if meta.Desktop == nil {
+		meta.Desktop = &metadata.DesktopConfiguration{}
+	}
+	meta.Desktop.Icon = encoded
+
+	return meta, nil
+}
+
+// describes a single file which is installed by generated packages
+type packageFile struct {
+	path string
+	mode os.FileMode
+	data []byte
+}
+
+// computes the package version from the application version
+//
+// pre-release suffixes (such as "-SNAPSHOT") are separated by a tilde as both formats consider
+// these versions to precede their respective release
+func packageVersion(meta *metadata.ApplicationContainer) (string, error) {
+	version := meta.GetIdentity().GetVersion()
+	if len(version) == 0 {
+		return "", fmt.Errorf("packages require an application version (specify -app-version)")
+	}
+
+	version = strings.ReplaceAll(version, "-", "~")
+	if !packageVersionPattern.MatchString(version) {
+		return "", fmt.Errorf("application version %q cannot be represented within packages", meta.GetIdentity().GetVersion())
+	}
+
+	return version, nil
+}
+
+// replaces the runtime version placeholders within a given list of dependencies
+func expandDependencies(dependencies []string, meta *metadata.ApplicationContainer) []string {
+	expanded := make([]string, len(dependencies))
+	for i, dependency := range dependencies {
+		expanded[i] = strings.ReplaceAll(dependency, "{minimum}", fmt.Sprint(meta.GetRuntime().GetMinimumVersion()))
+	}
+
+	return expanded
+}
@@ -0,0 +1,60 @@
+/*
+ * Copyright 2021 Johannes Donath <johannesd@torchmind.com>
+ * and other copyright owners as documented in the project's IP log.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package main
+
+import (
+	"github.com/dotstart/canoe/internal/metadata"
+	"testing"
+)
+
+func TestResolveMaintainer(t *testing.T) {
+	tests := []struct {
+		maintainer string
+		vendor     string
+		expected   string
+		valid      bool
+	}{
+		{"Jane Doe <jane@example.org>", "Example Inc.", "Jane Doe <jane@example.org>", true},
+		{"", "Example Inc. <foo@example.org>", "Example Inc. <foo@example.org>", true},
+		{"", "Example Inc.", "", false},
+		{"", "", "", false},
+		{"Jane Doe", "Example Inc. <foo@example.org>", "", false},
+		{"<jane@example.org>", "", "", false},
+		{"Jane Doe <jane>", "", "", false},
+	}
+
+	for _, test := range tests {
+		p := &packageFlags{maintainer: test.maintainer}
+		meta := &metadata.ApplicationContainer{
+			Identity: &metadata.ApplicationIdentity{Vendor: test.vendor},
+		}
+
+		err := p.resolveMaintainer(meta)
+		if !test.valid {
+			if err == nil {
+				t.Errorf("%q/%q: expected error but resolved %q", test.maintainer, test.vendor, p.maintainer)
+			}
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("%q/%q: expected maintainer to resolve but got %s", test.maintainer, test.vendor, err)
+		} else if p.maintainer != test.expected {
+			t.Errorf("%q/%q: expected maintainer %q but got %q", test.maintainer, test.vendor, test.expected, p.maintainer)
+		}
+	}
+}
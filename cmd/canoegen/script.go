@@ -0,0 +1,68 @@
+/*
+ * Copyright 2021 Johannes Donath <johannesd@torchmind.com>
+ * and other copyright owners as documented in the project's IP log.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/dotstart/canoe/internal"
+	"github.com/dotstart/canoe/internal/metadata"
+	"github.com/dotstart/canoe/internal/script"
+)
+
+// identify the pseudo targets which generate launch scripts in place of wrapper executables
+const scriptTarget = internal.ScriptOS
+const batchScriptTarget = internal.ScriptOS + "-" + internal.BatchScriptArch
+
+// identifies whether a given target generates a launch script
+func isScriptTarget(target string) bool {
+	return target == scriptTarget || target == batchScriptTarget
+}
+
+// generates the launch script for a given script target
+//
+// unlike wrapper executables, launch scripts carry a copy of the configuration and are thus
+// generated for each executable
+func generateScript(target string, meta *metadata.ApplicationContainer) ([]byte, error) {
+	var generated []byte
+	var err error
+	switch target {
+	case scriptTarget:
+		generated, err = script.Shell(meta)
+	case batchScriptTarget:
+		generated, err = script.Batch(meta)
+	default:
+		return nil, fmt.Errorf("invalid target: %s", target)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate launch script: %w", err)
+	}
+
+	return generated, nil
+}
+
+// generates a given launch script again in order to reflect the passed configuration
+//
+// returns nil if the passed wrapper is not a launch script
+func regenerateScript(wrapper []byte, meta *metadata.ApplicationContainer) ([]byte, error) {
+	target, err := internal.DetectTarget(bytes.NewReader(wrapper))
+	if err != nil || target.OS != internal.ScriptOS {
+		return nil, nil
+	}
+
+	return generateScript(target.Name(), meta)
+}
@@ -0,0 +1,271 @@
+/*
+ * Copyright 2021 Johannes Donath <johannesd@torchmind.com>
+ * and other copyright owners as documented in the project's IP log.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"flag"
+	"fmt"
+	"github.com/dotstart/canoe/internal"
+	"github.com/dotstart/canoe/internal/pe"
+	"github.com/google/subcommands"
+	"io"
+	"os"
+)
+
+const autoTarget = "auto"
+
+type upgradeCommand struct {
+	inputFile  string
+	outputFile string
+	target     string
+
+	useGuiWrapper bool
+
+	signingKeyFile string
+	authenticodeFlags
+}
+
+func (*upgradeCommand) Name() string {
+	return "upgrade"
+}
+
+func (*upgradeCommand) Synopsis() string {
+	return "replaces the wrapper of an existing canoe executable with the current revision"
+}
+
+func (*upgradeCommand) Usage() string {
+	return `canoegen upgrade -in <file> [-out <file>] [-target <name>] [args]
+
+Replaces the wrapper of a given canoe executable which has previously been generated using canoe
+wrap (or an older version of this tool) with the wrapper embedded within this version:
+
+  $ canoegen upgrade -in foo.exe
+
+The embedded archive and configuration are retained. By default, the target platform is detected
+from the existing wrapper. Alternatively, a target may be selected explicitly via the "-target"
+option. Windows executables retain the subsystem of their existing wrapper unless the "-gui" option
+is given explicitly (use "-gui=false" in order to select the console subsystem).
+
+Universal Mac OS executables (as generated by the "darwin-universal" target or within application
+bundles) receive all built-in Mac OS wrappers with each slice carrying a copy of the archive and
+configuration. Executables within application bundles are upgraded in place (e.g. "-in
+Foo.app/Contents/MacOS/Foo") while the remaining bundle contents are left untouched.
+
+Executables which have been generated using a custom wrapper will have their wrapper replaced with
+the respective built-in wrapper.
+
+Signed executables must be signed again via the "-sign-key" option as their signature is otherwise
+removed. Executables which pin a signing key cannot be upgraded without their signing key. The same
+applies to Authenticode signatures which must be renewed via the "-authenticode-pkcs12" option.
+
+The icon, version information and manifest of Windows executables are carried over from the
+existing wrapper. Executables which do not contain any resources receive the default version
+information and manifest generated by canoe wrap.
+
+The following configuration options are provided by this command:
+
+`
+}
+
+func (cmd *upgradeCommand) SetFlags(f *flag.FlagSet) {
+	f.StringVar(&cmd.inputFile, "in", "", "selects an input executable (required)")
+	f.StringVar(&cmd.outputFile, "out", "", "selects an output file (defaults to the input file)")
+	f.StringVar(&cmd.target, "target", autoTarget, "selects a target platform (defaults to the platform of the existing wrapper)")
+	f.BoolVar(&cmd.useGuiWrapper, "gui", false, "selects the GUI subsystem for Windows wrapper executables (defaults to the subsystem of the existing wrapper)")
+
+	f.StringVar(&cmd.signingKeyFile, "sign-key", "", "selects a PEM encoded ed25519 private key with which the executable is signed (unset by default)")
+	cmd.authenticodeFlags.SetFlags(f)
+}
+
+func (cmd *upgradeCommand) Execute(_ context.Context, f *flag.FlagSet, _ ...interface{}) subcommands.ExitStatus {
+	if len(cmd.inputFile) == 0 {
+		_, _ = fmt.Fprintln(os.Stderr, "invalid parameters: input file is required")
+		return subcommands.ExitUsageError
+	}
+
+	output := cmd.outputFile
+	if len(output) == 0 {
+		output = cmd.inputFile
+	}
+
+	var signingKey ed25519.PrivateKey
+	if len(cmd.signingKeyFile) != 0 {
+		var err error
+		signingKey, err = internal.ReadPrivateKey(cmd.signingKeyFile)
+		if err != nil {
+			_, _ = fmt.Fprintf(os.Stderr, "invalid signing key: %s\n", err)
+			return subcommands.ExitUsageError
+		}
+	}
+
+	authenticode, err := cmd.authenticodeFlags.load()
+	if err != nil {
+		_, _ = fmt.Fprintf(os.Stderr, "invalid Authenticode certificate: %s\n", err)
+		return subcommands.ExitUsageError
+	}
+
+	footer, meta, err := internal.ReadExecutableContainer(cmd.inputFile)
+	if err != nil {
+		_, _ = fmt.Fprintf(os.Stderr, "failed to read executable: %s\n", err)
+		return subcommands.ExitFailure
+	}
+
+	in, err := os.Open(cmd.inputFile)
+	if err != nil {
+		_, _ = fmt.Fprintf(os.Stderr, "failed to read executable: %s\n", err)
+		return subcommands.ExitFailure
+	}
+	defer in.Close()
+
+	payloadOffset, payloadLength, err := internal.LocatePayload(in, footer)
+	if err != nil {
+		_, _ = fmt.Fprintf(os.Stderr, "failed to read executable: %s\n", err)
+		return subcommands.ExitFailure
+	}
+
+	// digests are only available for executables of format version 2 or newer
+	if err := internal.VerifyDigests(in, footer, meta); err != nil {
+		_, _ = fmt.Fprintf(os.Stderr, "failed to read executable: %s\n", err)
+		return subcommands.ExitFailure
+	}
+
+	stat, err := in.Stat()
+	if err != nil {
+		_, _ = fmt.Fprintf(os.Stderr, "failed to read executable: %s\n", err)
+		return subcommands.ExitFailure
+	}
+
+	// executables which embed their payload within a dedicated section retain this layout
+	_, _, embedSection := internal.PayloadSection(in, stat.Size())
+
+	wrapper, err := readWrapper(in, stat.Size(), payloadOffset)
+	if err != nil {
+		_, _ = fmt.Fprintf(os.Stderr, "failed to read wrapper: %s\n", err)
+		return subcommands.ExitFailure
+	}
+
+	archive := make([]byte, payloadLength)
+	if _, err := in.ReadAt(archive, int64(payloadOffset)); err != nil && err != io.EOF {
+		_, _ = fmt.Fprintf(os.Stderr, "failed to read archive: %s\n", err)
+		return subcommands.ExitFailure
+	}
+
+	target := cmd.target
+	detected, detectErr := internal.DetectTarget(bytes.NewReader(wrapper))
+	if target == autoTarget {
+		if detectErr != nil {
+			_, _ = fmt.Fprintf(os.Stderr, "failed to detect target (please specify -target explicitly): %s\n", detectErr)
+			return subcommands.ExitFailure
+		}
+
+		target = detected.Name()
+	} else if !targetPattern.MatchString(target) {
+		_, _ = fmt.Fprintf(os.Stderr, "invalid target: %s\n", target)
+		return subcommands.ExitUsageError
+	}
+
+	// the subsystem of the existing wrapper is retained regardless of the selected target unless
+	// selected explicitly
+	useGuiWrapper := detectErr == nil && detected.Gui
+	if visitedFlags(f)("gui") {
+		useGuiWrapper = cmd.useGuiWrapper
+	}
+
+	// universal executables receive a fresh copy of every embedded Mac OS wrapper
+	var replacement []byte
+	var replacements [][]byte
+	if target == universalTarget {
+		replacements, err = loadUniversalWrappers()
+	} else if isScriptTarget(target) {
+		replacement, err = generateScript(target, meta)
+	} else {
+		replacement, err = loadTargetWrapper(target)
+	}
+	if err != nil {
+		_, _ = fmt.Fprintf(os.Stderr, "failed to load wrapper: %s\n", err)
+		return subcommands.ExitFailure
+	}
+
+	pinnedKey := internal.FindPinnedPublicKey(wrapper)
+	if pinnedKey != nil {
+		if signingKey == nil {
+			_, _ = fmt.Fprintf(os.Stderr, "executable pins signing key %x: signing key is required\n", pinnedKey)
+			return subcommands.ExitUsageError
+		}
+		if !bytes.Equal(pinnedKey, signingKey.Public().(ed25519.PublicKey)) {
+			_, _ = fmt.Fprintf(os.Stderr, "executable pins signing key %x: signing key mismatch\n", pinnedKey)
+			return subcommands.ExitUsageError
+		}
+	}
+	if signingKey == nil && meta.Signature != nil {
+		_, _ = fmt.Fprintln(os.Stderr, "warning: executable signature has been removed (specify -sign-key in order to sign it again)")
+	}
+
+	// the icon, version information and manifest of Windows executables are carried over as their
+	// original sources are not retained within the executable
+	var resources []*pe.Resource
+	if image, err := pe.Parse(wrapper); err == nil {
+		if offset, _ := image.CertificateTable(); offset != 0 && authenticode == nil {
+			_, _ = fmt.Fprintln(os.Stderr, "warning: Authenticode signature has been removed (specify -authenticode-pkcs12 in order to sign it again)")
+		}
+
+		resources, err = image.Resources()
+		if err != nil {
+			_, _ = fmt.Fprintf(os.Stderr, "failed to read wrapper resources: %s\n", err)
+			return subcommands.ExitFailure
+		}
+	}
+
+	if meta.CustomWrapper {
+		_, _ = fmt.Fprintf(os.Stderr, "warning: executable has been generated using a custom wrapper which will be replaced by the built-in %s wrapper\n", target)
+	}
+
+	meta.CanoeVersion = internal.Version()
+	meta.CustomWrapper = false
+	meta.Signature = nil
+
+	// the input file is fully loaded into memory at this point thus permitting us to overwrite it
+	_ = in.Close()
+
+	generator := &wrapCommand{
+		useGuiWrapper: useGuiWrapper,
+		signingKey:    signingKey,
+		pinKey:        pinnedKey != nil,
+		authenticode:  authenticode,
+		elfSection:    embedSection,
+		windowsFlags: windowsFlags{
+			executionLevel: pe.ExecutionLevelAsInvoker,
+			dpiAwareness:   pe.DpiAwarenessPerMonitorV2,
+			longPaths:      true,
+			resources:      resources,
+		},
+	}
+	if replacements != nil {
+		err = generator.generateUniversal(meta, replacements, archive, output, false)
+	} else {
+		err = generator.generate(meta, replacement, archive, output, false)
+	}
+	if err != nil {
+		_, _ = fmt.Fprintf(os.Stderr, "failed to generate executable: %s\n", err)
+		return subcommands.ExitFailure
+	}
+
+	return subcommands.ExitSuccess
+}
@@ -0,0 +1,136 @@
+/*
+ * Copyright 2021 Johannes Donath <johannesd@torchmind.com>
+ * and other copyright owners as documented in the project's IP log.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package main
+
+import (
+	"context"
+	"crypto/ed25519"
+	"flag"
+	"fmt"
+	"github.com/dotstart/canoe/internal"
+	"github.com/google/subcommands"
+	"io"
+	"os"
+)
+
+type verifyCommand struct {
+	inputFile     string
+	publicKeyFile string
+}
+
+func (*verifyCommand) Name() string {
+	return "verify"
+}
+
+func (*verifyCommand) Synopsis() string {
+	return "verifies the integrity and signature of a canoe executable"
+}
+
+func (*verifyCommand) Usage() string {
+	return `canoegen verify -in <file> [-pubkey <file>] [args]
+
+Verifies the digests and signature of a given canoe executable which has previously been generated
+using canoe wrap:
+
+  $ canoegen verify -in foo.exe -pubkey key.pub
+
+When no public key is given, the signature is verified against the key pinned within the wrapper
+(if any). Otherwise, only the consistency of the signature is verified. Executables which have been
+generated without a signing key will fail verification.
+
+The following configuration options are provided by this command:
+
+`
+}
+
+func (cmd *verifyCommand) SetFlags(f *flag.FlagSet) {
+	f.StringVar(&cmd.inputFile, "in", "", "selects an input executable")
+	f.StringVar(&cmd.publicKeyFile, "pubkey", "", "selects a PEM encoded ed25519 public key against which the signature is verified")
+}
+
+func (cmd *verifyCommand) Execute(context.Context, *flag.FlagSet, ...interface{}) subcommands.ExitStatus {
+	if len(cmd.inputFile) == 0 {
+		_, _ = fmt.Fprintln(os.Stderr, "invalid parameters: input file is required")
+		return subcommands.ExitUsageError
+	}
+
+	var publicKey ed25519.PublicKey
+	if len(cmd.publicKeyFile) != 0 {
+		var err error
+		publicKey, err = internal.ReadPublicKey(cmd.publicKeyFile)
+		if err != nil {
+			_, _ = fmt.Fprintf(os.Stderr, "invalid public key: %s\n", err)
+			return subcommands.ExitUsageError
+		}
+	}
+
+	footer, meta, err := internal.ReadExecutableContainer(cmd.inputFile)
+	if err != nil {
+		_, _ = fmt.Fprintf(os.Stderr, "failed to read executable: %s\n", err)
+		return subcommands.ExitFailure
+	}
+
+	f, err := os.Open(cmd.inputFile)
+	if err != nil {
+		_, _ = fmt.Fprintf(os.Stderr, "failed to read executable: %s\n", err)
+		return subcommands.ExitFailure
+	}
+	defer f.Close()
+
+	if footer.FormatVersion < internal.FooterFormatVersion {
+		_, _ = fmt.Fprintf(os.Stderr, "verification failed: format version %d does not support signatures\n", footer.FormatVersion)
+		return subcommands.ExitFailure
+	}
+
+	// wrapper digests are omitted by executables which embed their payload within an ELF section
+	if len(meta.GetIntegrity().GetPayloadSha256()) == 0 {
+		_, _ = fmt.Fprintln(os.Stderr, "verification failed: executable lacks digests")
+		return subcommands.ExitFailure
+	}
+
+	if err := internal.VerifyDigests(f, footer, meta); err != nil {
+		_, _ = fmt.Fprintf(os.Stderr, "verification failed: %s\n", err)
+		return subcommands.ExitFailure
+	}
+
+	wrapper := make([]byte, footer.PayloadOffset)
+	if _, err := f.ReadAt(wrapper, 0); err != nil && err != io.EOF {
+		_, _ = fmt.Fprintf(os.Stderr, "failed to read wrapper: %s\n", err)
+		return subcommands.ExitFailure
+	}
+
+	pinnedKey := internal.FindPinnedPublicKey(wrapper)
+	if publicKey == nil {
+		publicKey = pinnedKey
+	}
+
+	if err := internal.VerifySignature(f, footer, meta, publicKey); err != nil {
+		_, _ = fmt.Fprintf(os.Stderr, "verification failed: %s\n", err)
+		return subcommands.ExitFailure
+	}
+
+	fmt.Printf("  signed by: %x\n", meta.Signature.PublicKey)
+	if pinnedKey != nil {
+		fmt.Printf(" pinned key: %x\n", pinnedKey)
+	} else {
+		fmt.Println(" pinned key: none")
+	}
+	fmt.Println()
+	fmt.Println("verification successful")
+
+	return subcommands.ExitSuccess
+}
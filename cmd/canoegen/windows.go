@@ -0,0 +1,200 @@
+/*
+ * Copyright 2021 Johannes Donath <johannesd@torchmind.com>
+ * and other copyright owners as documented in the project's IP log.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"github.com/dotstart/canoe/internal/icon"
+	"github.com/dotstart/canoe/internal/metadata"
+	"github.com/dotstart/canoe/internal/pe"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// encapsulates the command line options which define the platform resources of Windows executables
+type windowsFlags struct {
+	executionLevel string
+	dpiAwareness   string
+	longPaths      bool
+
+	// resources which are embedded in place of the generated resources (e.g. in order to carry
+	// over the resources of an existing executable)
+	resources []*pe.Resource
+}
+
+func (w *windowsFlags) SetFlags(f *flag.FlagSet) {
+	f.StringVar(&w.executionLevel, "windows-execution-level", pe.ExecutionLevelAsInvoker, "selects the execution level requested by Windows executables (asInvoker, highestAvailable or requireAdministrator)")
+	f.StringVar(&w.dpiAwareness, "windows-dpi-awareness", pe.DpiAwarenessPerMonitorV2, "selects the DPI awareness declared by Windows executables (unaware, system or permonitorv2)")
+	f.BoolVar(&w.longPaths, "windows-long-paths", true, "declares support for paths exceeding MAX_PATH within Windows executables")
+}
+
+// defines the environment variable from which the PKCS#12 password is read when not given explicitly
+const authenticodePasswordEnvironmentVariable = "CANOE_AUTHENTICODE_PASSWORD"
+
+// encapsulates the command line options which select the Authenticode certificate with which
+// Windows executables are signed
+type authenticodeFlags struct {
+	authenticodeFile     string
+	authenticodePassword string
+}
+
+func (a *authenticodeFlags) SetFlags(f *flag.FlagSet) {
+	f.StringVar(&a.authenticodeFile, "authenticode-pkcs12", "", "selects a PKCS#12 bundle containing the code signing certificate and private key with which Windows executables are signed via Authenticode (unset by default)")
+	f.StringVar(&a.authenticodePassword, "authenticode-password", "", "defines the password of the PKCS#12 bundle (defaults to the value of the "+authenticodePasswordEnvironmentVariable+" environment variable)")
+}
+
+// loads the selected Authenticode signer
+//
+// returns nil if no PKCS#12 bundle has been selected
+func (a *authenticodeFlags) load() (*pe.Signer, error) {
+	if len(a.authenticodeFile) == 0 {
+		return nil, nil
+	}
+
+	password := a.authenticodePassword
+	if len(password) == 0 {
+		password = os.Getenv(authenticodePasswordEnvironmentVariable)
+	}
+
+	data, err := ioutil.ReadFile(a.authenticodeFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read PKCS#12 bundle: %w", err)
+	}
+
+	return pe.ReadPKCS12(data, password)
+}
+
+// embeds the icon, version information and manifest of an application (or the selected set of
+// resources) within a given Windows wrapper executable and selects its subsystem
+//
+// returns the modified wrapper or the original wrapper if it is not a PE image
+func (w *windowsFlags) patchImage(wrapper []byte, meta *metadata.ApplicationContainer, iconData []byte, output string, gui bool) ([]byte, error) {
+	image, err := pe.Parse(append([]byte{}, wrapper...))
+	if err != nil {
+		if errors.Is(err, pe.ErrMalformed) {
+			return wrapper, nil
+		}
+
+		return nil, err
+	}
+
+	resources := w.resources
+	if len(resources) == 0 {
+		resources, err = w.generateResources(meta, iconData, output)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if err := image.SetResources(resources); err != nil {
+		return nil, fmt.Errorf("failed to embed resources: %w", err)
+	}
+
+	subsystem := uint16(pe.SubsystemWindowsCUI)
+	if gui {
+		subsystem = pe.SubsystemWindowsGUI
+	}
+	if err := image.SetSubsystem(subsystem); err != nil {
+		return nil, err
+	}
+
+	// the checksum would have to cover the payload, container metadata and certificate table which
+	// are appended later on while the container metadata records a digest of the wrapper (including
+	// its checksum) thus preventing us from computing it
+	image.ClearChecksum()
+
+	return image.Bytes(), nil
+}
+
+// produces the icon, version information and manifest resources of a given application
+func (w *windowsFlags) generateResources(meta *metadata.ApplicationContainer, iconData []byte, output string) ([]*pe.Resource, error) {
+	manifest := &pe.Manifest{
+		ExecutionLevel: w.executionLevel,
+		DpiAwareness:   w.dpiAwareness,
+		LongPathAware:  w.longPaths,
+	}
+	encodedManifest, err := manifest.Encode()
+	if err != nil {
+		return nil, err
+	}
+
+	resources := []*pe.Resource{
+		{Type: pe.TypeVersion, ID: 1, Language: pe.LanguageEnglishUS, Data: versionInfo(meta, output).Encode()},
+		{Type: pe.TypeManifest, ID: 1, Language: pe.LanguageEnglishUS, Data: encodedManifest},
+	}
+
+	if len(iconData) != 0 {
+		images, err := windowsIconImages(iconData)
+		if err != nil {
+			return nil, err
+		}
+
+		resources = append(resources, pe.IconResources(images)...)
+	}
+
+	return resources, nil
+}
+
+// converts a given icon into the set of images embedded within Windows executables
+func windowsIconImages(data []byte) ([]*icon.Image, error) {
+	if icon.IsICO(data) {
+		return icon.ParseICO(data)
+	}
+
+	img, err := icon.Decode(data)
+	if err != nil {
+		return nil, err
+	}
+
+	return icon.EncodeImages(img, icon.WindowsSizes)
+}
+
+// produces the version information resource for a given application
+func versionInfo(meta *metadata.ApplicationContainer, output string) *pe.VersionInfo {
+	identity := meta.GetIdentity()
+	version := pe.ParseVersion(identity.GetVersion())
+
+	description := identity.GetDescription()
+	if len(description) == 0 {
+		description = identity.GetName()
+	}
+
+	info := &pe.VersionInfo{
+		FileVersion:    version,
+		ProductVersion: version,
+	}
+
+	for _, str := range []pe.VersionString{
+		{Key: "CompanyName", Value: identity.GetVendor()},
+		{Key: "FileDescription", Value: description},
+		{Key: "FileVersion", Value: identity.GetVersion()},
+		{Key: "InternalName", Value: identity.GetName()},
+		{Key: "LegalCopyright", Value: identity.GetCopyright()},
+		{Key: "OriginalFilename", Value: filepath.Base(output)},
+		{Key: "ProductName", Value: identity.GetName()},
+		{Key: "ProductVersion", Value: identity.GetVersion()},
+	} {
+		if len(str.Value) != 0 {
+			info.Strings = append(info.Strings, str)
+		}
+	}
+
+	return info
+}
@@ -17,13 +17,20 @@
 package main
 
 import (
+	"archive/zip"
+	"bytes"
 	"context"
+	"crypto/ed25519"
+	"debug/elf"
 	"errors"
 	"flag"
 	"fmt"
 	"github.com/dotstart/canoe/build"
 	"github.com/dotstart/canoe/internal"
+	"github.com/dotstart/canoe/internal/macho"
 	"github.com/dotstart/canoe/internal/metadata"
+	"github.com/dotstart/canoe/internal/pe"
+	"github.com/golang/protobuf/proto"
 	"github.com/google/subcommands"
 	"io/fs"
 	"io/ioutil"
@@ -38,19 +45,34 @@ const defaultRuntimeVersion = 16
 var targetPattern = regexp.MustCompile("^[a-z0-9_-]+$")
 
 type wrapCommand struct {
+	configFile  string
+	application string
+	profile     string
+
 	inputFile  string
 	outputFile string
-	mainClass  string
 
 	target        string
 	wrapperFile   string
 	useGuiWrapper bool
+	elfSection    bool
+	format        string
+
+	metadataFlags
+	recordModules bool
+
+	iconFile string
+	icon     []byte
 
-	runtimeMinimumVersion uint
-	runtimeMaximumVersion uint
-	runtimeInitialMemory  string
-	runtimeMemoryLimit    string
-	runtimeArguments      string
+	windowsFlags
+	authenticodeFlags
+	authenticode *pe.Signer
+
+	packageFlags
+
+	signingKeyFile string
+	pinKey         bool
+	signingKey     ed25519.PrivateKey
 
 	verbose bool
 }
@@ -67,13 +89,20 @@ func (*wrapCommand) Usage() string {
 	wrappers, _ := build.GetFilesystem().ReadDir("wrappers")
 
 	targets := ""
+	universal := false
 	for _, target := range wrappers {
 		if target.IsDir() {
 			targets += "  - " + target.Name() + "\n"
+			universal = universal || strings.HasPrefix(target.Name(), "darwin-")
 		}
 	}
+	if universal {
+		targets += "  - " + universalTarget + " (combines all Mac OS targets)\n"
+	}
+	targets += "  - " + scriptTarget + " (POSIX shell script for platforms without a prebuilt wrapper)\n"
+	targets += "  - " + batchScriptTarget + " (Windows batch script)\n"
 
-	return `canoegen wrap -in <file> [-out <file>] [-target <name>] [args]
+	return `canoegen wrap -in <file> [-out <file>] [-target <name>] [-config <file>] [args]
 
 Generates a canoe self contained executable which automatically locates compatible runtime 
 installations to launch a given embedded Java application.
@@ -85,6 +114,30 @@ In order to generate a simple self contained executable the following command ma
 Where "foo.jar" contains the application code which shall be wrapped. By default, Linux, Mac OS and
 Windows versions of the executable will be placed in the current working directory.
 
+The application main class is read from the Main-Class attribute of the archive manifest unless
+given via the "-main-class" option. Generation fails when the selected class is not present within
+the archive (or one of its multi-release directories).
+
+Unless given via the "-runtime-version" option, the minimum runtime version is inferred from the
+class file versions within the archive. Version specific classes within multi-release archives do not
+affect the minimum version. A warning is displayed when the selected version is lower than the
+version required by the archive bytecode.
+
+The Add-Opens, Add-Exports, Enable-Native-Access, Launcher-Agent-Class and Class-Path attributes of
+the archive manifest are translated into their respective runtime options. Options which are not
+supported by the located runtime version are omitted at launch time. Launcher agents are started via
+"-javaagent" which invokes the premain method of the agent (rather than its agentmain method) and
+thus requires a matching Premain-Class attribute.
+
+When "-record-modules" is given, the runtime modules referenced by the archive are recorded within
+the executable metadata (refer to the analyze subcommand for details).
+
+Archives which are appended to their wrapper are relocated (equivalent to "zip -A") while their
+comment is extended in order to cover the trailing configuration. Within the executable, archive
+comments are thus followed by the configuration and the recorded archive digest refers to the
+relocated archive rather than the input file. The extract subcommand restores the original archive
+including its comment.
+
 Alternatively, a target directory may be specified via the "-out" parameter:
 
   $ canoegen wrap -in foo.jar -out ./target
@@ -104,81 +157,364 @@ If desired, the built-in targets may be replaced by custom executables via the "
 
   $ canoegen wrap -in foo.jar -wrapper mywrapper.exe -out foo.exe
 
-Custom wrappers are expected to rely on the implementations provided by the canoew-cli or canoew-gui
-packages respectively and will _NOT_ be validated by this tool. Please ensure that passed wrapper
+Custom wrappers are expected to rely on the implementation provided by the canoew-cli package and
+will _NOT_ be validated by this tool. Please ensure that passed wrapper
 executables are actually compatible with this revision of the tool as wrapped executables may 
 otherwise fail to launch or produce other undesired side effects.
 
+Platforms for which no wrapper is available (such as FreeBSD or Linux on riscv64) are covered by
+the "script" target which generates a POSIX shell script. A Windows batch script may be generated
+via the "script-cmd" target (which receives the ".cmd" extension):
+
+  $ canoegen wrap -in foo.jar -target script -out foo
+
+Launch scripts locate a compatible runtime via JAVA_HOME or the search path and honor the runtime
+version constraints, memory settings, launch attributes, runtime arguments and error messages of
+the application. As the archive and configuration are appended to the script, the runtime loads
+the application directly from the script file. Launch scripts carry a copy of the configuration
+which is generated again when the executable is configured or upgraded. They do not verify digests
+or signatures at launch time (use the verify subcommand instead) and do not support key pinning.
+Launch scripts are only generated when selected explicitly via the "-target" option.
+
+Code signed Mac OS wrappers (such as the built-in darwin-arm64 wrapper) receive their archive and
+configuration within a dedicated "__CANOE" segment and are signed again using an ad-hoc signature
+as Apple Silicon systems refuse to launch executables with invalid signatures. The archive is
+extracted to the user cache directory when the application is first launched.
+
+When "-elf-section" is given, Linux executables receive their archive and configuration within a
+dedicated non-allocated ".canoe" section instead of appending them to the wrapper:
+
+  $ canoegen wrap -in foo.jar -target linux-amd64 -elf-section
+
+These executables may be processed using strip, objcopy and similar tools without corrupting the
+embedded archive. As the wrapper itself may be rewritten by these tools, only the archive digest is
+recorded. The archive is extracted to the user cache directory when the application is first
+launched.
+
+Mac OS applications may alternatively be generated as application bundles via "-format app":
+
+  $ canoegen wrap -in foo.jar -format app -app-id org.example.foo -icon foo.png
+
+When no target is given, a single bundle containing a universal executable (which combines all Mac OS
+targets) is generated in place of the individual Mac OS executables. The "darwin-universal" target
+generates a universal executable explicitly. Each slice of a universal executable carries its own
+copy of the archive and configuration. Bundles receive an Info.plist (populated from the application
+identity), an icon (when given as a PNG file) and the declared document types. The bundle identifier
+is read from the "-app-id" option, the Automatic-Module-Name attribute or the main class (in this
+order). Bundles are not sealed by this tool and must thus be signed via codesign (and notarized)
+prior to their distribution.
+
+Document types which may be opened by the application are declared via the repeatable
+"-document-type" option using the format "name:extensions[:mime types]" where extensions and mime
+types are separated by commas:
+
+  $ canoegen wrap -in foo.jar -format app -document-type "Foo Document:foo,foox:application/x-foo"
+
+Linux executables may additionally be distributed as Debian (deb) or RPM (rpm) packages which install
+the executable to /usr/bin:
+
+  $ canoegen wrap -in foo.jar -package deb,rpm -app-version 1.2.0 \
+      -maintainer "Jane Doe <jane@example.org>" \
+      -deb-depends "openjdk-{minimum}-jre-headless | java{minimum}-runtime-headless" \
+      -rpm-requires "java-{minimum}-openjdk-headless"
+
+Packages are placed next to their respective executables. Their name is derived from the application
+name unless given via "-package-name" while their version, vendor, homepage and summary (description)
+are read from the application identity. Debian packages additionally require a maintainer of the form
+"Name <email>" which is given via "-maintainer" (or taken from the vendor when it follows this
+format). Pre-release suffixes such as "-SNAPSHOT" are
+separated by a tilde within package versions. Dependencies are declared per package format via the
+repeatable "-deb-depends" and "-rpm-requires" options using the syntax of their respective format
+while "{minimum}" is replaced with the minimum runtime version. Packages are not signed. As only the
+executable is packaged, applications which reference class path entries relative to the executable
+cannot be packaged.
+
+When "-desktop-files" is given, a freedesktop desktop entry, icons (in the sizes of the hicolor theme
+when given as a PNG file) and a shared-mime-info declaration of the document types are generated
+within the "share" directory next to the Linux executables and included within packages:
+
+  $ canoegen wrap -in foo.jar -desktop-files -package deb -app-id org.example.foo -icon foo.png \
+      -document-type "Foo Document:foo" -url-scheme foo -desktop-categories Development
+
+The desktop entry launches the executable via the search path and passes opened documents and URLs
+as application arguments. Document types which do not declare a MIME type receive a type derived
+from their name (e.g. "application/x-foo-document"). URL schemes are declared as
+"x-scheme-handler" types on Linux and within the Info.plist of Mac OS application bundles.
+
+Linux executables may additionally install themselves along with their desktop entry, icon and MIME
+declarations when launched with "--canoe-install" (into ~/.local by default or /usr/local when
+"--system" is given). Installed files are recorded and removed again via "--canoe-uninstall".
+
+Windows executables target the console subsystem by default. When "-gui" is given, the subsystem of
+the wrapper is switched to the GUI subsystem instead thus suppressing the console window and
+reporting errors via dialogs.
+
+Executables may be signed using an ed25519 private key in order to permit verification of their
+origin via the "verify" subcommand:
+
+  $ canoegen wrap -in foo.jar -sign-key key.pem
+
+When "-pin-key" is given, the public key is additionally embedded within the wrapper which will
+subsequently refuse to launch unsigned or modified executables.
+
+Windows executables may additionally be signed via Authenticode using a code signing certificate
+and private key (RSA or ECDSA) stored within a PKCS#12 bundle:
+
+  $ CANOE_AUTHENTICODE_PASSWORD=secret canoegen wrap -in foo.jar -authenticode-pkcs12 cert.p12
+
+The signature covers the entire executable including its payload and metadata. Signatures are not
+timestamped and will thus be considered invalid once the certificate expires. Other targets ignore
+this option.
+
+The application identity (as displayed within error messages and reported by the info subcommand)
+is read from the Implementation-Title, Implementation-Version, Implementation-Vendor and
+Implementation-URL attributes of the archive manifest unless given explicitly:
+
+  $ canoegen wrap -in foo.jar -name Foo -app-version 1.2.0 -vendor "Example Inc." \
+      -copyright "Copyright (c) 2021 Example Inc." -description "Does foo" \
+      -homepage https://example.org -icon foo.png
+
+Icons are embedded within the platform specific resources of supported targets. Linux executables
+carry no such resources and thus store PNG icons (scaled down to 256x256 when necessary) within
+their metadata in order to install them along with their desktop entry. Windows executables
+additionally receive a version information resource (populated from the application identity) as
+well as an application manifest which may be customized via the "-windows-*" options. Icons may be
+given as PNG (which is scaled to the sizes requested by Windows) or ICO files.
+
+Error messages displayed by the wrapper may be branded and extended with links to further
+assistance:
+
+  $ canoegen wrap -in foo.jar -name Foo -support-url https://example.org/help \
+      -runtime-download-url https://adoptium.net/
+
+Each category of error message may additionally be replaced via its respective "-message-*" option.
+Message templates may reference the following placeholders:
+
+  - {name}: application name
+  - {version}, {vendor}, {homepage}: application version, vendor and homepage respectively
+  - {required}: human readable representation of the required runtime version range
+  - {minimum}, {maximum}: minimum and maximum runtime version respectively
+  - {found}: runtime version which has been located (if any)
+  - {error}: detailed error description
+  - {support_url}, {runtime_download_url}: configured support and download URLs respectively
+
+Alternatively, options may be declared within a configuration file:
+
+  $ canoegen wrap -config canoe.yaml -app foo -profile release
+
+Configuration files declare options using the names of their respective command line flags:
+
+  version: 1
+  extends: ../base.yaml
+  defaults:
+    runtime-version: 17
+    support-url: https://example.org/help
+  applications:
+    foo:
+      in: build/foo.jar
+      out: dist
+      name: Foo
+  profiles:
+    release:
+      sign-key: release.pem
+      pin-key: true
+    debug:
+      runtime-args: -Xdebug
+
+Repeatable options (such as "document-type") may be given as a list of values:
+
+  defaults:
+    document-type:
+      - "Foo Document:foo:application/x-foo"
+      - "Bar Document:bar"
+
+Options are applied in order of precedence: defaults, application and profile. Files given via
+"extends" are loaded first and may be overridden by the extending file. Relative paths are resolved
+relative to the file which declares them. Options passed on the command line take precedence over
+all configuration file values. When a file declares a single application, "-app" may be omitted.
+
 The following configuration options are provided by this command:
 
 `
 }
 
 func (cmd *wrapCommand) SetFlags(f *flag.FlagSet) {
+	f.StringVar(&cmd.configFile, "config", "", "selects a configuration file from which options are read (unset by default)")
+	f.StringVar(&cmd.application, "app", "", "selects an application within the configuration file (required when multiple applications are declared)")
+	f.StringVar(&cmd.profile, "profile", "", "selects a profile within the configuration file (unset by default)")
+
 	f.StringVar(&cmd.inputFile, "in", "", "selects an input archive (required)")
 	f.StringVar(&cmd.outputFile, "out", ".", "selects an output file or directory")
-	f.StringVar(&cmd.mainClass, "main-class", "", "selects a specific main class to launch (defaults to the Main-Class attribute within the archive manifest)")
 
 	f.StringVar(&cmd.target, "target", "", "selects a target platform (defaults to all)")
 	f.StringVar(&cmd.wrapperFile, "wrapper", "", "selects an alternative wrapper executable (defaults to embedded executables)")
-	f.BoolVar(&cmd.useGuiWrapper, "gui", false, "selects a GUI focused wrapper executable on supported platforms (only applies to Windows targets; ignored otherwise)")
+	f.BoolVar(&cmd.useGuiWrapper, "gui", false, "selects the GUI subsystem for the wrapper executable thus suppressing its console window (only applies to Windows targets; ignored otherwise)")
+	f.StringVar(&cmd.format, "format", executableFormat, "selects the output format (executable or app; app generates Mac OS application bundles and only applies to Mac OS targets)")
+	f.BoolVar(&cmd.elfSection, "elf-section", false, "embeds the archive and configuration within a dedicated ELF section which survives strip and objcopy (only applies to Linux targets; ignored otherwise)")
+
+	cmd.metadataFlags.SetFlags(f)
+	f.StringVar(&cmd.iconFile, "icon", "", "selects a square PNG icon which is embedded within the platform resources of supported targets (unset by default)")
+	cmd.windowsFlags.SetFlags(f)
+	cmd.authenticodeFlags.SetFlags(f)
+	cmd.packageFlags.SetFlags(f)
+	f.BoolVar(&cmd.recordModules, "record-modules", false, "records the runtime modules referenced by the archive within the executable metadata")
 
-	f.UintVar(&cmd.runtimeMinimumVersion, "runtime-version", defaultRuntimeVersion, fmt.Sprintf("defines the minimum required runtime version (defaults to %d)", defaultRuntimeVersion))
-	f.UintVar(&cmd.runtimeMaximumVersion, "runtime-max-version", 0, "defines the maximum permitted runtime version (unset by default)")
-	f.StringVar(&cmd.runtimeInitialMemory, "runtime-initial-memory", "", "defines the initial runtime memory (unset by default)")
-	f.StringVar(&cmd.runtimeMemoryLimit, "runtime-memory-limit", "", "defines the runtime memory limit (unset by default)")
-	f.StringVar(&cmd.runtimeArguments, "runtime-args", "", "supplies additional arguments to be passed to the runtime upon application startup")
+	f.StringVar(&cmd.signingKeyFile, "sign-key", "", "selects a PEM encoded ed25519 private key with which executables are signed (unset by default)")
+	f.BoolVar(&cmd.pinKey, "pin-key", false, "pins the signing key into the wrapper thus refusing to launch unsigned or modified executables (requires -sign-key)")
 
 	f.BoolVar(&cmd.verbose, "verbose", false, "prints additional information when generating executables")
 }
 
-func (cmd *wrapCommand) Execute(context.Context, *flag.FlagSet, ...interface{}) subcommands.ExitStatus {
+func (cmd *wrapCommand) Execute(_ context.Context, f *flag.FlagSet, _ ...interface{}) subcommands.ExitStatus {
+	if len(cmd.configFile) != 0 {
+		validate := func(name string) error {
+			return cmd.metadataFlags.apply(&metadata.ApplicationContainer{}, func(candidate string) bool {
+				return candidate == name
+			})
+		}
+
+		if err := applyConfig(f, cmd.configFile, cmd.application, cmd.profile, validate); err != nil {
+			_, _ = fmt.Fprintf(os.Stderr, "invalid configuration: %s\n", err)
+			return subcommands.ExitUsageError
+		}
+	} else if len(cmd.application) != 0 || len(cmd.profile) != 0 {
+		_, _ = fmt.Fprintln(os.Stderr, "invalid parameters: application and profile selection requires a configuration file")
+		return subcommands.ExitUsageError
+	}
+
 	if len(cmd.inputFile) == 0 {
 		_, _ = fmt.Fprintln(os.Stderr, "invalid parameters: input file is required")
 		return subcommands.ExitUsageError
 	}
 
+	if cmd.format != executableFormat && cmd.format != appFormat {
+		_, _ = fmt.Fprintf(os.Stderr, "invalid parameters: unknown output format: %s\n", cmd.format)
+		return subcommands.ExitUsageError
+	}
+	if cmd.format == appFormat && len(cmd.target) != 0 && len(cmd.wrapperFile) == 0 && !strings.HasPrefix(cmd.target, "darwin-") {
+		_, _ = fmt.Fprintf(os.Stderr, "invalid parameters: application bundles cannot be generated for target %s\n", cmd.target)
+		return subcommands.ExitUsageError
+	}
+
+	packageFormats, err := cmd.packageFlags.formats()
+	if err != nil {
+		_, _ = fmt.Fprintf(os.Stderr, "invalid parameters: %s\n", err)
+		return subcommands.ExitUsageError
+	}
+	if cmd.packageFlags.enabled() && len(cmd.target) != 0 && len(cmd.wrapperFile) == 0 && !strings.HasPrefix(cmd.target, "linux-") {
+		_, _ = fmt.Fprintf(os.Stderr, "invalid parameters: packages and desktop files cannot be generated for target %s\n", cmd.target)
+		return subcommands.ExitUsageError
+	}
+
 	archive, err := ioutil.ReadFile(cmd.inputFile)
 	if err != nil {
 		_, _ = fmt.Fprintf(os.Stderr, "failed to read input file: %s\n", err)
 		return subcommands.ExitFailure
 	}
 
-	inputBase := filepath.Base(cmd.inputFile)
-	extensionOffset := strings.LastIndex(inputBase, ".")
-	inferredOutputName := inputBase[:extensionOffset]
-
-	runtimeInitialMemory := uint64(0)
-	if len(cmd.runtimeInitialMemory) != 0 {
-		runtimeInitialMemory, err = metadata.ParseByteSuffix(cmd.runtimeInitialMemory)
+	if len(cmd.iconFile) != 0 {
+		cmd.icon, err = readIcon(cmd.iconFile)
 		if err != nil {
-			_, _ = fmt.Fprintf(os.Stderr, "invalid initial memory size: %s\n", err)
+			_, _ = fmt.Fprintf(os.Stderr, "%s\n", err)
 			return subcommands.ExitUsageError
 		}
 	}
 
-	runtimeMemoryLimit := uint64(0)
-	if len(cmd.runtimeMemoryLimit) != 0 {
-		runtimeMemoryLimit, err = metadata.ParseByteSuffix(cmd.runtimeMemoryLimit)
+	if len(cmd.signingKeyFile) != 0 {
+		cmd.signingKey, err = internal.ReadPrivateKey(cmd.signingKeyFile)
 		if err != nil {
-			_, _ = fmt.Fprintf(os.Stderr, "invalid memory limit: %s\n", err)
+			_, _ = fmt.Fprintf(os.Stderr, "invalid signing key: %s\n", err)
 			return subcommands.ExitUsageError
 		}
+	} else if cmd.pinKey {
+		_, _ = fmt.Fprintln(os.Stderr, "invalid parameters: key pinning requires a signing key")
+		return subcommands.ExitUsageError
 	}
 
+	cmd.authenticode, err = cmd.authenticodeFlags.load()
+	if err != nil {
+		_, _ = fmt.Fprintf(os.Stderr, "invalid Authenticode certificate: %s\n", err)
+		return subcommands.ExitUsageError
+	}
+
+	inputBase := filepath.Base(cmd.inputFile)
+	extensionOffset := strings.LastIndex(inputBase, ".")
+	inferredOutputName := inputBase[:extensionOffset]
+
 	meta := &metadata.ApplicationContainer{
 		CanoeVersion:  internal.Version(),
 		CustomWrapper: len(cmd.wrapperFile) != 0,
-		Runtime: &metadata.RuntimeConfiguration{
-			MinimumVersion:      uint64(cmd.runtimeMinimumVersion),
-			MaximumVersion:      uint64(cmd.runtimeMaximumVersion),
-			InitialMemory:       runtimeInitialMemory,
-			MemoryLimit:         runtimeMemoryLimit,
-			AdditionalArguments: cmd.runtimeArguments,
-		},
-		Application: &metadata.ApplicationConfiguration{
-			MainClass: cmd.mainClass,
-		},
+	}
+	if err := cmd.metadataFlags.apply(meta, allFlags); err != nil {
+		_, _ = fmt.Fprintf(os.Stderr, "%s\n", err)
+		return subcommands.ExitUsageError
+	}
+
+	archiveReader, err := zip.NewReader(bytes.NewReader(archive), int64(len(archive)))
+	if err != nil {
+		_, _ = fmt.Fprintf(os.Stderr, "invalid input file: %s\n", err)
+		return subcommands.ExitFailure
+	}
+	manifest, err := readManifest(archiveReader)
+	if err != nil {
+		_, _ = fmt.Fprintf(os.Stderr, "invalid input file: %s\n", err)
+		return subcommands.ExitFailure
+	}
+
+	isSet := visitedFlags(f)
+	resolveIdentity(manifest, meta, isSet)
+	resolveRuntimeVersion(archiveReader, manifest, meta, isSet("runtime-version"))
+	if cmd.verbose {
+		fmt.Printf("requiring runtime version %d or newer\n", meta.Runtime.MinimumVersion)
+	}
+
+	if err := resolveMainClass(archiveReader, manifest, meta); err != nil {
+		_, _ = fmt.Fprintf(os.Stderr, "%s\n", err)
+		return subcommands.ExitFailure
+	}
+	resolveIdentifier(meta)
+	resolveLaunchAttributes(manifest, meta)
+
+	if cmd.packageFlags.enabled() {
+		if err := cmd.packageFlags.resolveName(meta, inferredOutputName); err != nil {
+			_, _ = fmt.Fprintf(os.Stderr, "invalid parameters: %s\n", err)
+			return subcommands.ExitUsageError
+		}
+	}
+	if len(packageFormats) != 0 {
+		if _, err := packageVersion(meta); err != nil {
+			_, _ = fmt.Fprintf(os.Stderr, "invalid parameters: %s\n", err)
+			return subcommands.ExitUsageError
+		}
+		for _, format := range packageFormats {
+			if format != debPackageFormat {
+				continue
+			}
+
+			if err := cmd.packageFlags.resolveMaintainer(meta); err != nil {
+				_, _ = fmt.Fprintf(os.Stderr, "invalid parameters: %s\n", err)
+				return subcommands.ExitUsageError
+			}
+		}
+
+		// packages only install the executable itself thus breaking references to files next to it
+		if entries := internal.RelativeClassPath(meta); len(entries) != 0 {
+			_, _ = fmt.Fprintf(os.Stderr, "invalid parameters: packages cannot be generated for applications with class path entries relative to the executable: %s\n", strings.Join(entries, ", "))
+			return subcommands.ExitUsageError
+		}
+	}
+
+	if cmd.recordModules {
+		analysis, err := analyzeArchive(archiveReader)
+		if err != nil {
+			_, _ = fmt.Fprintf(os.Stderr, "failed to analyze archive: %s\n", err)
+			return subcommands.ExitFailure
+		}
+
+		meta.Runtime.RequiredModules = analysis.RequiredModules()
+		if cmd.verbose {
+			fmt.Printf("requiring runtime modules %s\n", strings.Join(meta.Runtime.RequiredModules, ", "))
+		}
 	}
 
 	if len(cmd.target) == 0 && len(cmd.wrapperFile) == 0 {
@@ -187,8 +523,10 @@ func (cmd *wrapCommand) Execute(context.Context, *flag.FlagSet, ...interface{})
 			_, _ = fmt.Fprintf(os.Stderr, "failed to load target list: %s\n", err)
 		}
 
+		// application bundles combine all Mac OS targets within a single universal executable
+		bundle := cmd.format == appFormat
 		for _, target := range targets {
-			if !target.IsDir() {
+			if !target.IsDir() || (bundle && strings.HasPrefix(target.Name(), "darwin-")) {
 				continue
 			}
 
@@ -204,6 +542,22 @@ func (cmd *wrapCommand) Execute(context.Context, *flag.FlagSet, ...interface{})
 			}
 		}
 
+		if bundle {
+			if cmd.verbose {
+				fmt.Printf("generating target %s\n", universalTarget)
+			}
+
+			bundleName := inferredOutputName
+			if name := meta.GetIdentity().GetName(); len(name) != 0 {
+				bundleName = strings.ReplaceAll(name, string(filepath.Separator), "-")
+			}
+			output := filepath.Join(cmd.outputFile, bundleName)
+
+			if err := cmd.generateFromTarget(meta, universalTarget, archive, output); err != nil {
+				_, _ = fmt.Fprintf(os.Stderr, "failed to generate target %s: %s\n", universalTarget, err)
+			}
+		}
+
 		return subcommands.ExitSuccess
 	}
 
@@ -237,56 +591,182 @@ func (cmd *wrapCommand) generateFromExecutable(meta *metadata.ApplicationContain
 		return fmt.Errorf("failed to open wrapper %s: %w", input, err)
 	}
 
-	return cmd.generate(meta, inFile, archive, output)
+	return cmd.generate(meta, inFile, archive, output, cmd.format == appFormat)
 }
 
 func (cmd *wrapCommand) generateFromTarget(meta *metadata.ApplicationContainer, target string, archive []byte, output string) error {
-	filename := "canoew"
-	if strings.Contains(target, "windows") {
-		if cmd.useGuiWrapper {
-			filename = "canoew-gui"
+	bundle := cmd.format == appFormat && strings.HasPrefix(target, "darwin-")
+	if target == universalTarget {
+		wrappers, err := loadUniversalWrappers()
+		if err != nil {
+			return err
 		}
 
-		filename += ".exe"
+		return cmd.generateUniversal(meta, wrappers, archive, output, bundle)
+	}
+
+	if isScriptTarget(target) {
+		if target == batchScriptTarget {
+			output += ".cmd"
+		}
+
+		wrapper, err := generateScript(target, meta)
+		if err != nil {
+			return err
+		}
+
+		return cmd.generate(meta, wrapper, archive, output, false)
+	}
+
+	if strings.Contains(target, "windows") {
 		output += ".exe"
 	}
 
+	inFile, err := loadTargetWrapper(target)
+	if err != nil {
+		return err
+	}
+
+	return cmd.generate(meta, inFile, archive, output, bundle)
+}
+
+// loads the embedded wrapper executable for a given target
+func loadTargetWrapper(target string) ([]byte, error) {
+	filename := "canoew"
+	if strings.Contains(target, "windows") {
+		filename += ".exe"
+	}
+
 	inFile, err := build.GetFilesystem().ReadFile("wrappers/" + target + "/" + filename)
 	if err != nil {
 		if errors.Is(err, fs.ErrNotExist) {
-			return fmt.Errorf("invalid target: %s", target)
+			return nil, fmt.Errorf("invalid target: %s", target)
 		}
 
-		return fmt.Errorf("failed to open wrapper for target %s: %w", target, err)
+		return nil, fmt.Errorf("failed to open wrapper for target %s: %w", target, err)
 	}
 
-	return cmd.generate(meta, inFile, archive, output)
+	return inFile, nil
 }
 
-func (cmd *wrapCommand) generate(meta *metadata.ApplicationContainer, wrapper []byte, archive []byte, output string) error {
-	parent := filepath.Dir(output)
-	if _, err := os.Stat(parent); err != nil && os.IsNotExist(err) {
-		if err := os.MkdirAll(parent, 0755); err != nil {
-			return fmt.Errorf("failed to create directory structure at %s: %w", parent, err)
+// generates an executable (or an application bundle containing the executable when bundle is set)
+func (cmd *wrapCommand) generate(meta *metadata.ApplicationContainer, wrapper []byte, archive []byte, output string, bundle bool) error {
+	isELF := bytes.HasPrefix(wrapper, []byte(elf.ELFMAG))
+	if isELF {
+		var err error
+		meta, err = embedDesktopIcon(meta, cmd.icon)
+		if err != nil {
+			return err
 		}
+	} else if len(meta.GetDesktop().GetIcon()) != 0 {
+		// icons embedded within the metadata are retained when upgrading Linux executables but are
+		// of no use to other platforms
+		meta = proto.Clone(meta).(*metadata.ApplicationContainer)
+		meta.Desktop.Icon = nil
 	}
 
-	outFile, err := os.OpenFile(output, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0755)
+	// ELF sections are only requested for Linux wrappers as Mach-O wrappers select their layout
+	// based on their signature
+	executable, err := cmd.assemble(meta, wrapper, archive, output, cmd.elfSection && isELF)
 	if err != nil {
-		return fmt.Errorf("failed to open output file %s: %w", output, err)
+		return err
 	}
 
-	if _, err := outFile.Write(wrapper); err != nil {
-		return fmt.Errorf("failed to create output file %s: %w", output, err)
+	if bundle {
+		return cmd.writeBundle(meta, executable, output)
 	}
 
-	if _, err := outFile.Write(archive); err != nil {
-		return fmt.Errorf("failed to write to output file: %s: %w", output, err)
+	if err := writeOutputFile(output, executable, 0755); err != nil {
+		return err
 	}
 
-	if _, err := internal.WriteExecutableFooter(outFile, meta); err != nil {
-		return fmt.Errorf("failed to finalize output file %s: %w", output, err)
+	// packages are generated for Linux executables (whether selected explicitly or as part of all
+	// targets) as well as for executables which are generated from custom wrappers
+	if cmd.packageFlags.enabled() && (len(cmd.wrapperFile) != 0 || isELF) {
+		return cmd.packageFlags.writePackages(meta, executable, cmd.icon, filepath.Dir(output))
 	}
 
 	return nil
 }
+
+// generates a universal Mac OS executable which combines the passed wrappers
+//
+// each slice receives its own copy of the archive and configuration within its "__CANOE" segment
+func (cmd *wrapCommand) generateUniversal(meta *metadata.ApplicationContainer, wrappers [][]byte, archive []byte, output string, bundle bool) error {
+	images := make([]*macho.Image, len(wrappers))
+	for i, wrapper := range wrappers {
+		executable, err := cmd.assemble(meta, wrapper, archive, output, true)
+		if err != nil {
+			return err
+		}
+
+		images[i], err = macho.Parse(executable)
+		if err != nil {
+			return fmt.Errorf("invalid Mac OS wrapper: %w", err)
+		}
+	}
+
+	executable, err := macho.Fat(images...)
+	if err != nil {
+		return fmt.Errorf("failed to generate universal executable: %w", err)
+	}
+
+	if bundle {
+		return cmd.writeBundle(meta, executable, output)
+	}
+
+	return writeOutputFile(output, executable, 0755)
+}
+
+// assembles an executable from a given wrapper and archive
+func (cmd *wrapCommand) assemble(meta *metadata.ApplicationContainer, wrapper []byte, archive []byte, output string, embedSection bool) ([]byte, error) {
+	if cmd.pinKey {
+		var err error
+		wrapper, err = internal.PinPublicKey(wrapper, cmd.signingKey.Public().(ed25519.PublicKey))
+		if err != nil {
+			return nil, fmt.Errorf("failed to pin signing key: %w", err)
+		}
+	}
+
+	wrapper, err := cmd.windowsFlags.patchImage(wrapper, meta, cmd.icon, output, cmd.useGuiWrapper)
+	if err != nil {
+		return nil, err
+	}
+
+	payload, err := relocatePayload(archive, len(wrapper))
+	if err != nil {
+		return nil, err
+	}
+
+	meta = proto.Clone(meta).(*metadata.ApplicationContainer)
+	footer := &internal.Footer{}
+
+	executable := &bytes.Buffer{}
+	if err := writeExecutable(executable, wrapper, payload, meta, footer, cmd.signingKey, cmd.authenticode, embedSection); err != nil {
+		return nil, fmt.Errorf("failed to write output file %s: %w", output, err)
+	}
+
+	return executable.Bytes(), nil
+}
+
+// writes a given file to the specified path while creating its parent directories as necessary
+func writeOutputFile(output string, data []byte, mode os.FileMode) error {
+	parent := filepath.Dir(output)
+	if _, err := os.Stat(parent); err != nil && os.IsNotExist(err) {
+		if err := os.MkdirAll(parent, 0755); err != nil {
+			return fmt.Errorf("failed to create directory structure at %s: %w", parent, err)
+		}
+	}
+
+	outFile, err := os.OpenFile(output, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, mode)
+	if err != nil {
+		return fmt.Errorf("failed to open output file %s: %w", output, err)
+	}
+	defer outFile.Close()
+
+	if _, err := outFile.Write(data); err != nil {
+		return fmt.Errorf("failed to write output file %s: %w", output, err)
+	}
+
+	return outFile.Close()
+}
@@ -23,5 +23,9 @@ import (
 )
 
 func main() {
-	os.Exit(internal.Launch(runtime.CliExecutableName))
+	if internal.IsGuiExecutable() {
+		os.Exit(internal.Launch(runtime.GuiExecutableName, internal.GuiReporter{}))
+	}
+
+	os.Exit(internal.Launch(runtime.CliExecutableName, internal.CliReporter{}))
 }
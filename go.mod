@@ -8,6 +8,11 @@ require (
 	github.com/google/subcommands v1.2.0
 	golang.org/x/sys v0.0.0-20211004093028-2c5d950f24ef
 	google.golang.org/protobuf v1.27.1
+	gopkg.in/yaml.v3 v3.0.1
+	software.sslmate.com/src/go-pkcs12 v0.2.0
 )
 
-require github.com/gopherjs/gopherjs v0.0.0-20211004101933-6b77bd30416d // indirect
+require (
+	github.com/gopherjs/gopherjs v0.0.0-20211004101933-6b77bd30416d // indirect
+	golang.org/x/crypto v0.0.0-20220331220935-ae2d96664a29 // indirect
+)
@@ -0,0 +1,151 @@
+/*
+ * Copyright 2021 Johannes Donath <johannesd@torchmind.com>
+ * and other copyright owners as documented in the project's IP log.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package archive
+
+import (
+	"archive/zip"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+const classFileMagic = 0xCAFEBABE
+
+// identifies the class file major version which corresponds to runtime version 1.0 (versions are
+// incremented by one for each runtime release starting with Java 5)
+const classFileVersionOffset = 44
+
+var ErrMalformedClass = errors.New("malformed class file")
+
+// RuntimeRequirement describes the minimum runtime version required by the bytecode within an
+// archive.
+type RuntimeRequirement struct {
+	// Version identifies the minimum runtime version required to load all classes within the
+	// archive root (zero if the archive contains no classes)
+	Version int
+	// Class identifies the path of the class which requires the minimum runtime version
+	Class string
+
+	// Mismatches lists version specific classes which require a newer runtime version than the
+	// release directory in which they are stored
+	Mismatches []VersionMismatch
+}
+
+// VersionMismatch describes a version specific class which cannot be loaded by all runtime versions
+// which select it.
+type VersionMismatch struct {
+	Class   string
+	Release int
+	Version int
+}
+
+// ClassRuntimeVersion reads the header of a given class file and returns the minimum runtime
+// version which is capable of loading it.
+func ClassRuntimeVersion(r io.Reader) (int, error) {
+	var header struct {
+		Magic        uint32
+		MinorVersion uint16
+		MajorVersion uint16
+	}
+	if err := binary.Read(r, binary.BigEndian, &header); err != nil {
+		return 0, fmt.Errorf("%w: %s", ErrMalformedClass, err)
+	}
+	if header.Magic != classFileMagic {
+		return 0, fmt.Errorf("%w: invalid magic 0x%08X", ErrMalformedClass, header.Magic)
+	}
+
+	version := int(header.MajorVersion) - classFileVersionOffset
+	if version < 1 {
+		version = 1
+	}
+
+	return version, nil
+}
+
+// InferRuntimeRequirement scans the class files within a given archive in order to identify the
+// minimum runtime version required to load them.
+//
+// Version specific classes (as stored within META-INF/versions in multi-release archives) are only
+// selected by runtimes of the respective version and thus do not affect the minimum version.
+// Module descriptors are ignored as they have no effect when archives are loaded via the class
+// path.
+func InferRuntimeRequirement(r *zip.Reader, multiRelease bool) (*RuntimeRequirement, error) {
+	requirement := &RuntimeRequirement{}
+
+	for _, f := range r.File {
+		if !strings.HasSuffix(f.Name, ".class") || strings.HasSuffix(f.Name, "module-info.class") {
+			continue
+		}
+
+		release := 0
+		if strings.HasPrefix(f.Name, "META-INF/") {
+			if !multiRelease || !strings.HasPrefix(f.Name, VersionsPath) {
+				continue
+			}
+
+			remainder := f.Name[len(VersionsPath):]
+			separator := strings.IndexByte(remainder, '/')
+			if separator == -1 {
+				continue
+			}
+
+			var err error
+			release, err = strconv.Atoi(remainder[:separator])
+			if err != nil || release < 9 {
+				continue
+			}
+		}
+
+		version, err := readClassRuntimeVersion(f)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read class %s: %w", f.Name, err)
+		}
+
+		if release != 0 {
+			if version > release {
+				requirement.Mismatches = append(requirement.Mismatches, VersionMismatch{
+					Class:   f.Name,
+					Release: release,
+					Version: version,
+				})
+			}
+
+			continue
+		}
+
+		if version > requirement.Version {
+			requirement.Version = version
+			requirement.Class = f.Name
+		}
+	}
+
+	return requirement, nil
+}
+
+// reads the runtime version required by a given class file entry
+func readClassRuntimeVersion(f *zip.File) (int, error) {
+	rc, err := f.Open()
+	if err != nil {
+		return 0, err
+	}
+	defer rc.Close()
+
+	return ClassRuntimeVersion(rc)
+}
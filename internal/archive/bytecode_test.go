@@ -0,0 +1,76 @@
+/*
+ * Copyright 2021 Johannes Donath <johannesd@torchmind.com>
+ * and other copyright owners as documented in the project's IP log.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package archive
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+// produces a class file header for a given runtime version
+func testClass(version int) string {
+	major := version + classFileVersionOffset
+	return string([]byte{0xCA, 0xFE, 0xBA, 0xBE, 0x00, 0x00, byte(major >> 8), byte(major)})
+}
+
+func TestClassRuntimeVersion(t *testing.T) {
+	version, err := ClassRuntimeVersion(bytes.NewReader([]byte(testClass(21))))
+	if err != nil {
+		t.Fatalf("failed to read class version: %s", err)
+	}
+	if version != 21 {
+		t.Errorf("expected version 21 but got %d", version)
+	}
+
+	if _, err := ClassRuntimeVersion(bytes.NewReader([]byte("foo/Main.class"))); !errors.Is(err, ErrMalformedClass) {
+		t.Errorf("expected malformed class but got %v", err)
+	}
+	if _, err := ClassRuntimeVersion(bytes.NewReader([]byte{0xCA, 0xFE})); !errors.Is(err, ErrMalformedClass) {
+		t.Errorf("expected malformed class but got %v", err)
+	}
+}
+
+func TestInferRuntimeRequirement(t *testing.T) {
+	r := testJar(t, map[string]string{
+		"foo/Main.class":                       testClass(8),
+		"foo/Util.class":                       testClass(11),
+		"module-info.class":                    testClass(17),
+		"META-INF/versions/17/foo/Util.class":  testClass(17),
+		"META-INF/versions/17/foo/Other.class": testClass(21),
+		"foo/resource.txt":                     "not a class",
+	})
+
+	requirement, err := InferRuntimeRequirement(r, true)
+	if err != nil {
+		t.Fatalf("failed to infer runtime requirement: %s", err)
+	}
+	if requirement.Version != 11 || requirement.Class != "foo/Util.class" {
+		t.Errorf("expected foo/Util.class to require version 11 but got %s (%d)", requirement.Class, requirement.Version)
+	}
+	if len(requirement.Mismatches) != 1 || requirement.Mismatches[0].Class != "META-INF/versions/17/foo/Other.class" || requirement.Mismatches[0].Version != 21 {
+		t.Errorf("expected a single mismatch for foo/Other.class but got %v", requirement.Mismatches)
+	}
+
+	requirement, err = InferRuntimeRequirement(r, false)
+	if err != nil {
+		t.Fatalf("failed to infer runtime requirement: %s", err)
+	}
+	if requirement.Version != 11 || len(requirement.Mismatches) != 0 {
+		t.Errorf("expected version specific classes to be ignored in regular archives but got %+v", requirement)
+	}
+}
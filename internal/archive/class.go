@@ -0,0 +1,93 @@
+/*
+ * Copyright 2021 Johannes Donath <johannesd@torchmind.com>
+ * and other copyright owners as documented in the project's IP log.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package archive
+
+import (
+	"archive/zip"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// VersionsPath identifies the directory in which multi-release archives store version specific
+// classes.
+const VersionsPath = "META-INF/versions/"
+
+var ErrInvalidClassName = errors.New("invalid class name")
+var ErrClassNotFound = errors.New("class not found")
+
+// ClassPath converts a given binary class name (e.g. "foo.Main") into the path of its respective
+// class file within an archive.
+func ClassPath(name string) (string, error) {
+	name = strings.ReplaceAll(name, "/", ".")
+
+	segments := strings.Split(name, ".")
+	for _, segment := range segments {
+		if len(segment) == 0 || strings.ContainsAny(segment, "[;<>") {
+			return "", fmt.Errorf("%w: %q", ErrInvalidClassName, name)
+		}
+	}
+
+	return strings.Join(segments, "/") + ".class", nil
+}
+
+// FindClass identifies the runtime versions on which a given class is available within an archive.
+//
+// A version of zero indicates that the class is available within the archive root (and thus on
+// all runtime versions). When multiRelease is set, version specific directories are considered as
+// well. The returned versions are sorted in ascending order.
+func FindClass(r *zip.Reader, name string, multiRelease bool) ([]int, error) {
+	path, err := ClassPath(name)
+	if err != nil {
+		return nil, err
+	}
+
+	versions := make([]int, 0)
+	for _, f := range r.File {
+		if f.Name == path {
+			versions = append(versions, 0)
+			continue
+		}
+
+		if !multiRelease || !strings.HasPrefix(f.Name, VersionsPath) {
+			continue
+		}
+
+		remainder := f.Name[len(VersionsPath):]
+		separator := strings.IndexByte(remainder, '/')
+		if separator == -1 || remainder[separator+1:] != path {
+			continue
+		}
+
+		version, err := strconv.Atoi(remainder[:separator])
+		if err != nil || version < 9 {
+			// the runtime ignores directories which do not refer to a supported version
+			continue
+		}
+
+		versions = append(versions, version)
+	}
+
+	if len(versions) == 0 {
+		return nil, fmt.Errorf("%w: %s", ErrClassNotFound, name)
+	}
+
+	sort.Ints(versions)
+	return versions, nil
+}
@@ -0,0 +1,54 @@
+/*
+ * Copyright 2021 Johannes Donath <johannesd@torchmind.com>
+ * and other copyright owners as documented in the project's IP log.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package archive
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestFindClass(t *testing.T) {
+	r := testJar(t, map[string]string{
+		"foo/Main.class":                       "",
+		"META-INF/versions/11/foo/Main.class":  "",
+		"META-INF/versions/17/foo/Other.class": "",
+		"META-INF/versions/8/foo/Other.class":  "",
+	})
+
+	versions, err := FindClass(r, "foo.Main", true)
+	if err != nil {
+		t.Fatalf("failed to locate class: %s", err)
+	}
+	if len(versions) != 2 || versions[0] != 0 || versions[1] != 11 {
+		t.Errorf("expected versions [0 11] but got %v", versions)
+	}
+
+	versions, err = FindClass(r, "foo/Other", true)
+	if err != nil {
+		t.Fatalf("failed to locate class: %s", err)
+	}
+	if len(versions) != 1 || versions[0] != 17 {
+		t.Errorf("expected versions [17] but got %v", versions)
+	}
+
+	if _, err := FindClass(r, "foo.Other", false); !errors.Is(err, ErrClassNotFound) {
+		t.Errorf("expected versioned classes to be ignored in regular archives but got %v", err)
+	}
+	if _, err := FindClass(r, "foo..Main", true); !errors.Is(err, ErrInvalidClassName) {
+		t.Errorf("expected invalid class name but got %v", err)
+	}
+}
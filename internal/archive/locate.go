@@ -0,0 +1,126 @@
+/*
+ * Copyright 2021 Johannes Donath <johannesd@torchmind.com>
+ * and other copyright owners as documented in the project's IP log.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package archive
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+const localFileHeaderSignature = 0x04034b50
+const centralDirectorySignature = 0x02014b50
+const directoryEndSignature = 0x06054b50
+const directoryEndSize = 22
+const maxCommentLength = 0xFFFF
+
+var ErrNotFound = errors.New("no zip archive found")
+
+// Locate identifies the offset at which a zip archive ending at a given offset within a file
+// begins.
+//
+// The archive start is derived from the location of its central directory and thus only applies
+// to archives which have not been adjusted to account for prepended data (e.g. as is the case with
+// "zip -A").
+func Locate(r io.ReaderAt, end int64) (int64, error) {
+	directoryEnd, err := findDirectoryEnd(r, end)
+	if err != nil {
+		return 0, err
+	}
+
+	var record struct {
+		Signature           uint32
+		DiskNumber          uint16
+		DirectoryDiskNumber uint16
+		DiskRecordCount     uint16
+		RecordCount         uint16
+		DirectorySize       uint32
+		DirectoryOffset     uint32
+		CommentLength       uint16
+	}
+	if err := binary.Read(io.NewSectionReader(r, directoryEnd, directoryEndSize), binary.LittleEndian, &record); err != nil {
+		return 0, fmt.Errorf("failed to decode end of central directory: %w", err)
+	}
+
+	if record.DirectorySize == 0xFFFFFFFF || record.DirectoryOffset == 0xFFFFFFFF {
+		return 0, fmt.Errorf("%w: zip64 archives are not supported", ErrNotFound)
+	}
+
+	directoryStart := directoryEnd - int64(record.DirectorySize)
+	start := directoryStart - int64(record.DirectoryOffset)
+	if directoryStart < 0 || start < 0 {
+		return 0, fmt.Errorf("%w: central directory exceeds file bounds", ErrNotFound)
+	}
+
+	if err := expectSignature(r, directoryStart, centralDirectorySignature); err != nil {
+		return 0, err
+	}
+	if err := expectSignature(r, start, localFileHeaderSignature); err != nil {
+		return 0, err
+	}
+
+	return start, nil
+}
+
+// locates the end of central directory record of an archive ending at a given offset
+func findDirectoryEnd(r io.ReaderAt, end int64) (int64, error) {
+	windowStart := end - directoryEndSize - maxCommentLength
+	if windowStart < 0 {
+		windowStart = 0
+	}
+
+	window := make([]byte, end-windowStart)
+	if _, err := r.ReadAt(window, windowStart); err != nil && err != io.EOF {
+		return 0, fmt.Errorf("failed to read archive: %w", err)
+	}
+
+	for i := len(window) - directoryEndSize; i >= 0; i-- {
+		if binary.LittleEndian.Uint32(window[i:]) != directoryEndSignature {
+			continue
+		}
+
+		commentLength := int(binary.LittleEndian.Uint16(window[i+directoryEndSize-2:]))
+		if i+directoryEndSize+commentLength <= len(window) {
+			return windowStart + int64(i), nil
+		}
+
+		// the comment may extend beyond the end of the archive when the archive has been extracted
+		// from an executable in which case the presence of the central directory is verified instead
+		directorySize := int64(binary.LittleEndian.Uint32(window[i+12:]))
+		directoryStart := windowStart + int64(i) - directorySize
+		if directoryStart >= 0 && expectSignature(r, directoryStart, centralDirectorySignature) == nil {
+			return windowStart + int64(i), nil
+		}
+	}
+
+	return 0, fmt.Errorf("%w: missing end of central directory", ErrNotFound)
+}
+
+// verifies whether a given signature is present at the specified offset
+func expectSignature(r io.ReaderAt, offset int64, expected uint32) error {
+	var signature uint32
+	if err := binary.Read(io.NewSectionReader(r, offset, 4), binary.LittleEndian, &signature); err != nil {
+		return fmt.Errorf("failed to read signature: %w", err)
+	}
+
+	if signature != expected {
+		return fmt.Errorf("%w: signature mismatch at offset %d", ErrNotFound, offset)
+	}
+
+	return nil
+}
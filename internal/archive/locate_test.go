@@ -0,0 +1,67 @@
+/*
+ * Copyright 2021 Johannes Donath <johannesd@torchmind.com>
+ * and other copyright owners as documented in the project's IP log.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package archive
+
+import (
+	"archive/zip"
+	"bytes"
+	"errors"
+	"testing"
+)
+
+// creates a simple zip archive for testing purposes
+func testArchive(t *testing.T) []byte {
+	buf := &bytes.Buffer{}
+	w := zip.NewWriter(buf)
+
+	for _, name := range []string{"META-INF/MANIFEST.MF", "foo/Main.class"} {
+		entry, err := w.Create(name)
+		if err != nil {
+			t.Fatalf("failed to create entry %s: %s", name, err)
+		}
+		_, _ = entry.Write([]byte(name))
+	}
+
+	if err := w.Close(); err != nil {
+		t.Fatalf("failed to finalize archive: %s", err)
+	}
+
+	return buf.Bytes()
+}
+
+func TestLocate(t *testing.T) {
+	prefix := []byte("some wrapper executable")
+	suffix := []byte("some trailing metadata")
+	archive := testArchive(t)
+
+	data := append(append(append([]byte{}, prefix...), archive...), suffix...)
+
+	start, err := Locate(bytes.NewReader(data), int64(len(prefix)+len(archive)))
+	if err != nil {
+		t.Fatalf("failed to locate archive: %s", err)
+	}
+	if start != int64(len(prefix)) {
+		t.Errorf("expected archive to start at %d but got %d", len(prefix), start)
+	}
+}
+
+func TestLocateMissing(t *testing.T) {
+	data := []byte("this is not a zip archive")
+	if _, err := Locate(bytes.NewReader(data), int64(len(data))); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected not found error but got %v", err)
+	}
+}
@@ -0,0 +1,163 @@
+/*
+ * Copyright 2021 Johannes Donath <johannesd@torchmind.com>
+ * and other copyright owners as documented in the project's IP log.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package archive
+
+import (
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// ManifestPath identifies the location of the manifest within Java archives.
+const ManifestPath = "META-INF/MANIFEST.MF"
+
+var ErrMissingManifest = errors.New("archive does not contain a manifest")
+var ErrMalformedManifest = errors.New("malformed manifest")
+
+// Manifest represents the attributes declared within the manifest of a Java archive.
+//
+// Attribute names are case-insensitive and are thus normalized to lower case.
+type Manifest struct {
+	Attributes map[string]string
+	Sections   map[string]map[string]string
+}
+
+// Get retrieves the value of a given main attribute.
+func (m *Manifest) Get(name string) string {
+	return m.Attributes[strings.ToLower(name)]
+}
+
+// IsMultiRelease identifies whether the archive provides version specific classes within
+// META-INF/versions.
+func (m *Manifest) IsMultiRelease() bool {
+	return strings.EqualFold(strings.TrimSpace(m.Get("Multi-Release")), "true")
+}
+
+// ReadManifest decodes the manifest of a given Java archive.
+func ReadManifest(r *zip.Reader) (*Manifest, error) {
+	f, err := r.Open(ManifestPath)
+	if err != nil {
+		return nil, ErrMissingManifest
+	}
+	defer f.Close()
+
+	data, err := io.ReadAll(f)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read manifest: %w", err)
+	}
+
+	return ParseManifest(data)
+}
+
+// ParseManifest decodes a given manifest.
+//
+// Manifests consist of a main section followed by an arbitrary number of named sections which are
+// separated by empty lines. Values which exceed the maximum line length are continued on the
+// following line(s) with a leading space.
+func ParseManifest(data []byte) (*Manifest, error) {
+	manifest := &Manifest{
+		Attributes: make(map[string]string),
+		Sections:   make(map[string]map[string]string),
+	}
+
+	// strip the byte order mark which is occasionally written by non-JDK tooling
+	data = bytes.TrimPrefix(data, []byte{0xEF, 0xBB, 0xBF})
+
+	section := manifest.Attributes
+	name := ""
+	lineNumber := 0
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Split(scanManifestLines)
+	for scanner.Scan() {
+		line := scanner.Text()
+		lineNumber++
+
+		if len(line) == 0 {
+			section = nil
+			name = ""
+			continue
+		}
+
+		if line[0] == ' ' {
+			if len(name) == 0 {
+				return nil, fmt.Errorf("%w: unexpected continuation on line %d", ErrMalformedManifest, lineNumber)
+			}
+
+			section[name] += line[1:]
+			continue
+		}
+
+		separator := strings.Index(line, ": ")
+		if separator <= 0 {
+			return nil, fmt.Errorf("%w: invalid header on line %d", ErrMalformedManifest, lineNumber)
+		}
+
+		name = strings.ToLower(line[:separator])
+		value := line[separator+2:]
+
+		if section == nil {
+			if name != "name" {
+				return nil, fmt.Errorf("%w: expected section name on line %d", ErrMalformedManifest, lineNumber)
+			}
+
+			section = make(map[string]string)
+			manifest.Sections[value] = section
+		}
+
+		section[name] = value
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrMalformedManifest, err)
+	}
+
+	return manifest, nil
+}
+
+// splits manifest contents into lines terminated by CR LF, LF or CR
+func scanManifestLines(data []byte, atEOF bool) (int, []byte, error) {
+	for i, b := range data {
+		if b == '\n' {
+			return i + 1, data[:i], nil
+		}
+		if b == '\r' {
+			if i+1 < len(data) {
+				if data[i+1] == '\n' {
+					return i + 2, data[:i], nil
+				}
+
+				return i + 1, data[:i], nil
+			}
+			if atEOF {
+				return i + 1, data[:i], nil
+			}
+
+			// request more data in order to check for a trailing LF
+			return 0, nil, nil
+		}
+	}
+
+	if atEOF && len(data) != 0 {
+		return len(data), data, nil
+	}
+
+	return 0, nil, nil
+}
@@ -0,0 +1,100 @@
+/*
+ * Copyright 2021 Johannes Donath <johannesd@torchmind.com>
+ * and other copyright owners as documented in the project's IP log.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package archive
+
+import (
+	"archive/zip"
+	"bytes"
+	"errors"
+	"testing"
+)
+
+// creates an archive containing a given set of files
+func testJar(t *testing.T, files map[string]string) *zip.Reader {
+	buf := &bytes.Buffer{}
+	w := zip.NewWriter(buf)
+
+	for name, contents := range files {
+		entry, err := w.Create(name)
+		if err != nil {
+			t.Fatalf("failed to create entry %s: %s", name, err)
+		}
+		_, _ = entry.Write([]byte(contents))
+	}
+
+	if err := w.Close(); err != nil {
+		t.Fatalf("failed to finalize archive: %s", err)
+	}
+
+	r, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatalf("failed to open archive: %s", err)
+	}
+
+	return r
+}
+
+func TestParseManifest(t *testing.T) {
+	manifest, err := ParseManifest([]byte("Manifest-Version: 1.0\r\n" +
+		"Main-Class: foo.bar.baz.SomeVeryLongApplicationClassNameWhichExceedsTheMaximumLineLen\r\n" +
+		" gth\r\n" +
+		"multi-release: true\r\n" +
+		"\r\n" +
+		"Name: foo/bar/\r\n" +
+		"Sealed: true\r\n"))
+	if err != nil {
+		t.Fatalf("failed to parse manifest: %s", err)
+	}
+
+	if mainClass := manifest.Get("Main-Class"); mainClass != "foo.bar.baz.SomeVeryLongApplicationClassNameWhichExceedsTheMaximumLineLength" {
+		t.Errorf("expected continued main class but got %q", mainClass)
+	}
+	if !manifest.IsMultiRelease() {
+		t.Errorf("expected case-insensitive Multi-Release attribute to be recognized")
+	}
+	if manifest.Get("Sealed") != "" {
+		t.Errorf("expected section attributes to be omitted from main attributes")
+	}
+	if manifest.Sections["foo/bar/"]["sealed"] != "true" {
+		t.Errorf("expected section attributes to be decoded but got %v", manifest.Sections)
+	}
+
+	if _, err := ParseManifest([]byte(" continuation\n")); !errors.Is(err, ErrMalformedManifest) {
+		t.Errorf("expected malformed manifest but got %v", err)
+	}
+	if _, err := ParseManifest([]byte("Manifest-Version: 1.0\n\nSealed: true\n")); !errors.Is(err, ErrMalformedManifest) {
+		t.Errorf("expected malformed manifest but got %v", err)
+	}
+}
+
+func TestReadManifest(t *testing.T) {
+	r := testJar(t, map[string]string{
+		ManifestPath: "Manifest-Version: 1.0\nMain-Class: foo.Main\n",
+	})
+
+	manifest, err := ReadManifest(r)
+	if err != nil {
+		t.Fatalf("failed to read manifest: %s", err)
+	}
+	if manifest.Get("main-class") != "foo.Main" {
+		t.Errorf("expected main class foo.Main but got %q", manifest.Get("main-class"))
+	}
+
+	if _, err := ReadManifest(testJar(t, map[string]string{})); !errors.Is(err, ErrMissingManifest) {
+		t.Errorf("expected missing manifest but got %v", err)
+	}
+}
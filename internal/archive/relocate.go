@@ -0,0 +1,243 @@
+/*
+ * Copyright 2021 Johannes Donath <johannesd@torchmind.com>
+ * and other copyright owners as documented in the project's IP log.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package archive
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+)
+
+const directory64LocatorSignature = 0x07064b50
+const directory64EndSignature = 0x06064b50
+const directory64LocatorSize = 20
+const directory64EndSize = 56
+const centralDirectoryHeaderSize = 46
+const zip64ExtraID = 0x0001
+
+var ErrOffsetOverflow = errors.New("archive offset exceeds format limits")
+var ErrCommentOverflow = errors.New("archive comment exceeds format limits")
+
+var byteOrder = binary.LittleEndian
+
+// describes the location of the central directory within an archive
+type directory struct {
+	end     int64 // end of central directory record
+	end64   int64 // zip64 end of central directory record (-1 if not present)
+	locator int64 // zip64 end of central directory locator (-1 if not present)
+	start   int64 // start of central directory
+	size    int64 // size of central directory
+	offset  int64 // recorded offset of central directory
+	entries int64 // total amount of central directory entries
+	isZip64 bool
+}
+
+// locates the central directory of a given archive
+func findDirectory(data []byte) (*directory, error) {
+	end, err := findDirectoryEnd(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, err
+	}
+
+	d := &directory{
+		end:     end,
+		end64:   -1,
+		locator: -1,
+		entries: int64(byteOrder.Uint16(data[end+10:])),
+		size:    int64(byteOrder.Uint32(data[end+12:])),
+		offset:  int64(byteOrder.Uint32(data[end+16:])),
+	}
+
+	locator := end - directory64LocatorSize
+	if locator >= 0 && byteOrder.Uint32(data[locator:]) == directory64LocatorSignature {
+		// zip64 records typically do not carry extensible data thus directly preceding the locator
+		end64 := locator - directory64EndSize
+		if end64 < 0 || byteOrder.Uint32(data[end64:]) != directory64EndSignature {
+			return nil, fmt.Errorf("%w: unsupported zip64 end of central directory", ErrNotFound)
+		}
+
+		d.isZip64 = true
+		d.locator = locator
+		d.end64 = end64
+		d.entries = int64(byteOrder.Uint64(data[end64+32:]))
+		d.size = int64(byteOrder.Uint64(data[end64+40:]))
+		d.offset = int64(byteOrder.Uint64(data[end64+48:]))
+	}
+
+	recordStart := end
+	if d.isZip64 {
+		recordStart = d.end64
+	}
+
+	d.start = recordStart - d.size
+	if d.start < 0 || d.size < 0 {
+		return nil, fmt.Errorf("%w: central directory exceeds archive bounds", ErrNotFound)
+	}
+
+	return d, nil
+}
+
+// Relocate adjusts the offsets within a given archive in place in order to permit its placement at
+// a given offset within another file (equivalent to "zip -A").
+//
+// Archives which have previously been relocated are adjusted relative to their current offset thus
+// permitting archives to be restored to their original state by relocating them to offset zero.
+func Relocate(data []byte, offset int64) error {
+	d, err := findDirectory(data)
+	if err != nil {
+		return err
+	}
+
+	delta := offset - (d.offset - d.start)
+	if delta == 0 {
+		return nil
+	}
+
+	position := d.start
+	for i := int64(0); i < d.entries; i++ {
+		if position+centralDirectoryHeaderSize > d.start+d.size || byteOrder.Uint32(data[position:]) != centralDirectorySignature {
+			return fmt.Errorf("%w: malformed central directory entry %d", ErrNotFound, i)
+		}
+
+		nameLength := int64(byteOrder.Uint16(data[position+28:]))
+		extraLength := int64(byteOrder.Uint16(data[position+30:]))
+		commentLength := int64(byteOrder.Uint16(data[position+32:]))
+		if position+centralDirectoryHeaderSize+nameLength+extraLength+commentLength > d.start+d.size {
+			return fmt.Errorf("%w: central directory entry %d exceeds directory bounds", ErrNotFound, i)
+		}
+
+		if err := relocateEntry(data[position:position+centralDirectoryHeaderSize+nameLength+extraLength], delta); err != nil {
+			return fmt.Errorf("failed to relocate central directory entry %d: %w", i, err)
+		}
+
+		position += centralDirectoryHeaderSize + nameLength + extraLength + commentLength
+	}
+
+	if d.isZip64 {
+		if err := adjustUint64(data[d.end64+48:], delta); err != nil {
+			return err
+		}
+		if err := adjustUint64(data[d.locator+8:], delta); err != nil {
+			return err
+		}
+	}
+
+	if offset := byteOrder.Uint32(data[d.end+16:]); offset != math.MaxUint32 {
+		return adjustUint32(data[d.end+16:], delta)
+	}
+
+	return nil
+}
+
+// relocates a given central directory entry
+func relocateEntry(entry []byte, delta int64) error {
+	if byteOrder.Uint32(entry[42:]) != math.MaxUint32 {
+		return adjustUint32(entry[42:], delta)
+	}
+
+	// the local header offset has been moved to the zip64 extended information field which
+	// additionally carries the uncompressed and compressed sizes if they overflow
+	fieldOffset := 0
+	if byteOrder.Uint32(entry[24:]) == math.MaxUint32 {
+		fieldOffset += 8
+	}
+	if byteOrder.Uint32(entry[20:]) == math.MaxUint32 {
+		fieldOffset += 8
+	}
+
+	nameLength := int(byteOrder.Uint16(entry[28:]))
+	extra := entry[centralDirectoryHeaderSize+nameLength:]
+
+	for len(extra) >= 4 {
+		id := byteOrder.Uint16(extra)
+		size := int(byteOrder.Uint16(extra[2:]))
+		if 4+size > len(extra) {
+			break
+		}
+
+		if id == zip64ExtraID {
+			if fieldOffset+8 > size {
+				break
+			}
+
+			return adjustUint64(extra[4+fieldOffset:], delta)
+		}
+
+		extra = extra[4+size:]
+	}
+
+	return fmt.Errorf("%w: missing zip64 extended information", ErrNotFound)
+}
+
+// adjusts a 32-bit little endian offset
+func adjustUint32(field []byte, delta int64) error {
+	value := int64(byteOrder.Uint32(field)) + delta
+	if value < 0 || value >= math.MaxUint32 {
+		return ErrOffsetOverflow
+	}
+
+	byteOrder.PutUint32(field, uint32(value))
+	return nil
+}
+
+// adjusts a 64-bit little endian offset
+func adjustUint64(field []byte, delta int64) error {
+	value := int64(byteOrder.Uint64(field)) + delta
+	if value < 0 {
+		return ErrOffsetOverflow
+	}
+
+	byteOrder.PutUint64(field, uint64(value))
+	return nil
+}
+
+// Comment retrieves the comment which is stored at the end of a given archive.
+//
+// Comments which have been extended in order to include trailing data (refer to SetTrailerLength)
+// are truncated to the end of the passed data.
+func Comment(data []byte) ([]byte, error) {
+	d, err := findDirectory(data)
+	if err != nil {
+		return nil, err
+	}
+
+	return data[d.end+directoryEndSize:], nil
+}
+
+// SetTrailerLength adjusts the comment length of a given archive in place in order to include the
+// specified amount of data which is appended to the archive.
+//
+// Readers which require the archive comment to reach the end of the file (such as the JVM when
+// reading archives with relocated offsets) will otherwise reject archives followed by trailing
+// data. The original comment is retained in front of the trailing data (thus being reported with
+// the trailing data appended to it) and is restored by resetting the trailer length to zero.
+func SetTrailerLength(data []byte, length int) error {
+	d, err := findDirectory(data)
+	if err != nil {
+		return err
+	}
+
+	commentLength := len(data) - int(d.end) - directoryEndSize + length
+	if commentLength > maxCommentLength {
+		return fmt.Errorf("%w: %d bytes exceed limit of %d bytes", ErrCommentOverflow, commentLength, maxCommentLength)
+	}
+
+	byteOrder.PutUint16(data[d.end+20:], uint16(commentLength))
+	return nil
+}
@@ -0,0 +1,271 @@
+/*
+ * Copyright 2021 Johannes Donath <johannesd@torchmind.com>
+ * and other copyright owners as documented in the project's IP log.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package archive
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"testing"
+)
+
+// assembles a test executable consisting of a prefix, a relocated archive and a trailer
+func relocatedExecutable(t *testing.T, archive []byte) []byte {
+	prefix := bytes.Repeat([]byte{0xCC}, 4096)
+	trailer := []byte("some trailing metadata")
+
+	payload := make([]byte, len(archive))
+	copy(payload, archive)
+
+	if err := Relocate(payload, int64(len(prefix))); err != nil {
+		t.Fatalf("failed to relocate archive: %s", err)
+	}
+	if err := SetTrailerLength(payload, len(trailer)); err != nil {
+		t.Fatalf("failed to set trailer length: %s", err)
+	}
+
+	return append(append(prefix, payload...), trailer...)
+}
+
+// reads all entries from a given archive using the algorithm employed by the JDK's ZipFile
+// implementation (which is used when archives are passed via the runtime classpath)
+func readLikeRuntime(data []byte) (map[string]bool, error) {
+	end := -1
+	for i := len(data) - directoryEndSize; i >= 0 && i >= len(data)-directoryEndSize-maxCommentLength; i-- {
+		if binary.LittleEndian.Uint32(data[i:]) != directoryEndSignature {
+			continue
+		}
+
+		directorySize := int(binary.LittleEndian.Uint32(data[i+12:]))
+		directoryOffset := int(binary.LittleEndian.Uint32(data[i+16:]))
+		commentLength := int(binary.LittleEndian.Uint16(data[i+20:]))
+
+		if i+directoryEndSize+commentLength != len(data) {
+			// trailing data is only permitted when the derived archive start is valid
+			directoryStart := i - directorySize
+			start := directoryStart - directoryOffset
+			if directoryStart < 0 || start < 0 ||
+				binary.LittleEndian.Uint32(data[directoryStart:]) != centralDirectorySignature ||
+				binary.LittleEndian.Uint32(data[start:]) != localFileHeaderSignature {
+				continue
+			}
+		}
+
+		end = i
+		break
+	}
+	if end == -1 {
+		return nil, fmt.Errorf("zip END header not found")
+	}
+
+	directorySize := int(binary.LittleEndian.Uint32(data[end+12:]))
+	directoryOffset := int(binary.LittleEndian.Uint32(data[end+16:]))
+	directoryStart := end - directorySize
+	base := directoryStart - directoryOffset
+
+	entries := make(map[string]bool)
+	for position := directoryStart; position < end; {
+		if binary.LittleEndian.Uint32(data[position:]) != centralDirectorySignature {
+			return nil, fmt.Errorf("invalid CEN header (bad signature)")
+		}
+
+		nameLength := int(binary.LittleEndian.Uint16(data[position+28:]))
+		extraLength := int(binary.LittleEndian.Uint16(data[position+30:]))
+		commentLength := int(binary.LittleEndian.Uint16(data[position+32:]))
+		localOffset := base + int(binary.LittleEndian.Uint32(data[position+42:]))
+		name := string(data[position+centralDirectoryHeaderSize : position+centralDirectoryHeaderSize+nameLength])
+
+		if binary.LittleEndian.Uint32(data[localOffset:]) != localFileHeaderSignature {
+			return nil, fmt.Errorf("invalid LOC header (bad signature) for %s", name)
+		}
+		localNameLength := int(binary.LittleEndian.Uint16(data[localOffset+26:]))
+		if string(data[localOffset+30:localOffset+30+localNameLength]) != name {
+			return nil, fmt.Errorf("LOC header name mismatch for %s", name)
+		}
+
+		entries[name] = true
+		position += centralDirectoryHeaderSize + nameLength + extraLength + commentLength
+	}
+
+	return entries, nil
+}
+
+func TestRelocate(t *testing.T) {
+	executable := relocatedExecutable(t, testArchive(t))
+
+	r, err := zip.NewReader(bytes.NewReader(executable), int64(len(executable)))
+	if err != nil {
+		t.Fatalf("failed to open relocated archive: %s", err)
+	}
+
+	for _, f := range r.File {
+		offset, err := f.DataOffset()
+		if err != nil {
+			t.Fatalf("failed to locate entry %s: %s", f.Name, err)
+		}
+		if offset < 4096 {
+			t.Errorf("expected entry %s to be located after prefix but got offset %d", f.Name, offset)
+		}
+
+		rc, err := f.Open()
+		if err != nil {
+			t.Fatalf("failed to open entry %s: %s", f.Name, err)
+		}
+		contents, err := io.ReadAll(rc)
+		_ = rc.Close()
+		if err != nil {
+			t.Fatalf("failed to read entry %s: %s", f.Name, err)
+		}
+
+		if string(contents) != f.Name {
+			t.Errorf("expected entry %s to contain its name but got %q", f.Name, contents)
+		}
+	}
+
+	entries, err := readLikeRuntime(executable)
+	if err != nil {
+		t.Fatalf("failed to read relocated archive like the runtime: %s", err)
+	}
+	if !entries["META-INF/MANIFEST.MF"] || !entries["foo/Main.class"] {
+		t.Errorf("expected runtime to locate all entries but got %v", entries)
+	}
+}
+
+func TestRelocateWithoutTrailerLength(t *testing.T) {
+	archive := testArchive(t)
+	prefix := bytes.Repeat([]byte{0xCC}, 4096)
+
+	payload := make([]byte, len(archive))
+	copy(payload, archive)
+	if err := Relocate(payload, int64(len(prefix))); err != nil {
+		t.Fatalf("failed to relocate archive: %s", err)
+	}
+
+	// relocated archives followed by data which is not covered by their comment are rejected by the
+	// runtime
+	executable := append(append(prefix, payload...), []byte("trailer")...)
+	if _, err := readLikeRuntime(executable); err == nil {
+		t.Errorf("expected runtime to reject uncovered trailing data")
+	}
+}
+
+func TestRelocateRestore(t *testing.T) {
+	archive := testArchive(t)
+
+	payload := make([]byte, len(archive))
+	copy(payload, archive)
+
+	if err := Relocate(payload, 1234); err != nil {
+		t.Fatalf("failed to relocate archive: %s", err)
+	}
+	if err := SetTrailerLength(payload, 42); err != nil {
+		t.Fatalf("failed to set trailer length: %s", err)
+	}
+	if bytes.Equal(payload, archive) {
+		t.Fatalf("expected relocated archive to differ from original")
+	}
+
+	if err := Relocate(payload, 0); err != nil {
+		t.Fatalf("failed to restore archive: %s", err)
+	}
+	if err := SetTrailerLength(payload, 0); err != nil {
+		t.Fatalf("failed to restore trailer length: %s", err)
+	}
+	if !bytes.Equal(payload, archive) {
+		t.Errorf("expected restored archive to match original")
+	}
+}
+
+func TestRelocateComment(t *testing.T) {
+	buf := &bytes.Buffer{}
+	w := zip.NewWriter(buf)
+	if _, err := w.Create("foo/Main.class"); err != nil {
+		t.Fatalf("failed to create entry: %s", err)
+	}
+	if err := w.SetComment("some comment"); err != nil {
+		t.Fatalf("failed to set comment: %s", err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("failed to finalize archive: %s", err)
+	}
+	archive := buf.Bytes()
+
+	if comment, err := Comment(archive); err != nil || string(comment) != "some comment" {
+		t.Fatalf("expected comment %q but got %q (%v)", "some comment", comment, err)
+	}
+
+	executable := relocatedExecutable(t, archive)
+	r, err := zip.NewReader(bytes.NewReader(executable), int64(len(executable)))
+	if err != nil {
+		t.Fatalf("failed to open relocated archive: %s", err)
+	}
+	if r.Comment != "some commentsome trailing metadata" {
+		t.Errorf("expected comment to be followed by the trailer but got %q", r.Comment)
+	}
+
+	payload := executable[4096 : len(executable)-len("some trailing metadata")]
+	if err := Relocate(payload, 0); err != nil {
+		t.Fatalf("failed to restore archive: %s", err)
+	}
+	if err := SetTrailerLength(payload, 0); err != nil {
+		t.Fatalf("failed to restore trailer length: %s", err)
+	}
+	if !bytes.Equal(payload, archive) {
+		t.Errorf("expected restored archive to retain its comment")
+	}
+}
+
+func TestRelocateZip64(t *testing.T) {
+	buf := &bytes.Buffer{}
+	w := zip.NewWriter(buf)
+
+	// archives with more than 65535 entries rely on zip64 end of central directory records
+	for i := 0; i < 0x10000+1; i++ {
+		if _, err := w.CreateHeader(&zip.FileHeader{Name: fmt.Sprintf("%d", i), Method: zip.Store}); err != nil {
+			t.Fatalf("failed to create entry %d: %s", i, err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("failed to finalize archive: %s", err)
+	}
+
+	executable := relocatedExecutable(t, buf.Bytes())
+
+	r, err := zip.NewReader(bytes.NewReader(executable), int64(len(executable)))
+	if err != nil {
+		t.Fatalf("failed to open relocated archive: %s", err)
+	}
+	if len(r.File) != 0x10000+1 {
+		t.Fatalf("expected %d entries but got %d", 0x10000+1, len(r.File))
+	}
+
+	last := r.File[len(r.File)-1]
+	rc, err := last.Open()
+	if err != nil {
+		t.Fatalf("failed to open entry %s: %s", last.Name, err)
+	}
+	_ = rc.Close()
+}
+
+func TestSetTrailerLengthOverflow(t *testing.T) {
+	payload := testArchive(t)
+	if err := SetTrailerLength(payload, maxCommentLength+1); err == nil {
+		t.Errorf("expected overflow error")
+	}
+}
@@ -0,0 +1,116 @@
+/*
+ * Copyright 2021 Johannes Donath <johannesd@torchmind.com>
+ * and other copyright owners as documented in the project's IP log.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package classfile
+
+import (
+	"archive/zip"
+	"fmt"
+	"github.com/dotstart/canoe/internal/archive"
+	"sort"
+	"strings"
+)
+
+// Analysis describes the runtime modules referenced by the classes within an archive.
+type Analysis struct {
+	// Modules maps the names of all referenced runtime modules to the packages through which they
+	// are referenced
+	Modules map[string][]string
+	// Unresolved lists referenced packages within runtime namespaces which are not exported by any
+	// known module
+	Unresolved []string
+}
+
+// RequiredModules retrieves the sorted names of all modules referenced by the analyzed archive
+// (including the base module).
+func (a *Analysis) RequiredModules() []string {
+	modules := []string{BaseModule}
+	for module := range a.Modules {
+		if module != BaseModule {
+			modules = append(modules, module)
+		}
+	}
+	sort.Strings(modules[1:])
+
+	return modules
+}
+
+// Analyze identifies the runtime modules referenced by the classes within a given archive.
+//
+// Packages which are declared within the archive itself are never attributed to runtime modules.
+// When multiRelease is set, version specific classes are considered as well.
+func Analyze(r *zip.Reader, multiRelease bool) (*Analysis, error) {
+	declared := make(map[string]bool)
+	referenced := make(map[string]bool)
+
+	for _, f := range r.File {
+		if !strings.HasSuffix(f.Name, ".class") || strings.HasSuffix(f.Name, "module-info.class") {
+			continue
+		}
+		if strings.HasPrefix(f.Name, "META-INF/") && (!multiRelease || !strings.HasPrefix(f.Name, archive.VersionsPath)) {
+			continue
+		}
+
+		class, err := readClass(f)
+		if err != nil {
+			return nil, fmt.Errorf("failed to analyze class %s: %w", f.Name, err)
+		}
+
+		declared[Package(class.Name)] = true
+		for _, reference := range class.References {
+			referenced[Package(reference)] = true
+		}
+	}
+
+	analysis := &Analysis{
+		Modules:    make(map[string][]string),
+		Unresolved: make([]string, 0),
+	}
+	for pkg := range referenced {
+		if declared[pkg] {
+			continue
+		}
+
+		module := ModuleOf(pkg)
+		if len(module) == 0 {
+			if IsRuntimePackage(pkg) {
+				analysis.Unresolved = append(analysis.Unresolved, pkg)
+			}
+
+			continue
+		}
+
+		analysis.Modules[module] = append(analysis.Modules[module], pkg)
+	}
+
+	for _, packages := range analysis.Modules {
+		sort.Strings(packages)
+	}
+	sort.Strings(analysis.Unresolved)
+
+	return analysis, nil
+}
+
+// decodes a given class file entry
+func readClass(f *zip.File) (*Class, error) {
+	rc, err := f.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+
+	return Read(rc)
+}
@@ -0,0 +1,283 @@
+/*
+ * Copyright 2021 Johannes Donath <johannesd@torchmind.com>
+ * and other copyright owners as documented in the project's IP log.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package classfile
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+const magic = 0xCAFEBABE
+
+// constant pool tags as defined by the JVM specification (§4.4)
+const (
+	tagUtf8               = 1
+	tagInteger            = 3
+	tagFloat              = 4
+	tagLong               = 5
+	tagDouble             = 6
+	tagClass              = 7
+	tagString             = 8
+	tagFieldref           = 9
+	tagMethodref          = 10
+	tagInterfaceMethodref = 11
+	tagNameAndType        = 12
+	tagMethodHandle       = 15
+	tagMethodType         = 16
+	tagDynamic            = 17
+	tagInvokeDynamic      = 18
+	tagModule             = 19
+	tagPackage            = 20
+)
+
+var ErrMalformed = errors.New("malformed class file")
+
+// Class describes the type references of a given class file.
+type Class struct {
+	// Name identifies the internal name of the class (e.g. "foo/Main")
+	Name string
+	// References lists the internal names of all types referenced by the class
+	References []string
+}
+
+// constant pool entry
+type constant struct {
+	tag   uint8
+	value string
+	index uint16
+}
+
+// class file reader which retains the first error encountered
+type reader struct {
+	r   *bufio.Reader
+	err error
+}
+
+func (r *reader) u1() uint8 {
+	var value uint8
+	r.read(&value)
+	return value
+}
+
+func (r *reader) u2() uint16 {
+	var value uint16
+	r.read(&value)
+	return value
+}
+
+func (r *reader) u4() uint32 {
+	var value uint32
+	r.read(&value)
+	return value
+}
+
+func (r *reader) read(value interface{}) {
+	if r.err != nil {
+		return
+	}
+
+	r.err = binary.Read(r.r, binary.BigEndian, value)
+}
+
+func (r *reader) skip(n int) {
+	if r.err != nil {
+		return
+	}
+
+	_, r.err = r.r.Discard(n)
+}
+
+func (r *reader) bytes(n int) []byte {
+	if r.err != nil {
+		return nil
+	}
+
+	value := make([]byte, n)
+	_, r.err = io.ReadFull(r.r, value)
+	return value
+}
+
+// Read decodes the constant pool, field and method declarations of a given class file in order to
+// identify the types it references.
+//
+// References are extracted from class constants as well as field, method and method type
+// descriptors. Generic signatures and annotations are not considered.
+func Read(in io.Reader) (*Class, error) {
+	r := &reader{r: bufio.NewReader(in)}
+
+	if r.u4() != magic {
+		if r.err != nil {
+			return nil, fmt.Errorf("%w: %s", ErrMalformed, r.err)
+		}
+
+		return nil, fmt.Errorf("%w: invalid magic", ErrMalformed)
+	}
+	r.skip(4) // minor and major version
+
+	count := int(r.u2())
+	pool := make([]constant, count)
+	for i := 1; i < count && r.err == nil; i++ {
+		tag := r.u1()
+		pool[i].tag = tag
+
+		switch tag {
+		case tagUtf8:
+			// type names are restricted to characters which are encoded identically in modified and
+			// standard UTF-8
+			pool[i].value = string(r.bytes(int(r.u2())))
+		case tagClass, tagMethodType:
+			pool[i].index = r.u2()
+		case tagNameAndType:
+			r.skip(2)
+			pool[i].index = r.u2()
+		case tagString, tagModule, tagPackage:
+			r.skip(2)
+		case tagMethodHandle:
+			r.skip(3)
+		case tagInteger, tagFloat, tagFieldref, tagMethodref, tagInterfaceMethodref, tagDynamic, tagInvokeDynamic:
+			r.skip(4)
+		case tagLong, tagDouble:
+			// eight byte constants occupy two entries within the constant pool
+			r.skip(8)
+			i++
+		default:
+			if r.err == nil {
+				return nil, fmt.Errorf("%w: unknown constant pool tag %d at index %d", ErrMalformed, tag, i)
+			}
+		}
+	}
+
+	r.skip(2) // access flags
+	thisIndex := r.u2()
+	r.skip(2) // super class
+	r.skip(int(r.u2()) * 2)
+
+	descriptors := make([]uint16, 0)
+	for member := 0; member < 2 && r.err == nil; member++ {
+		// fields and methods share a common structure
+		memberCount := int(r.u2())
+		for i := 0; i < memberCount && r.err == nil; i++ {
+			r.skip(4) // access flags and name
+			descriptors = append(descriptors, r.u2())
+
+			attributeCount := int(r.u2())
+			for j := 0; j < attributeCount && r.err == nil; j++ {
+				r.skip(2)
+				r.skip(int(r.u4()))
+			}
+		}
+	}
+
+	if r.err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrMalformed, r.err)
+	}
+
+	utf8 := func(index uint16) (string, error) {
+		if int(index) >= len(pool) || pool[index].tag != tagUtf8 {
+			return "", fmt.Errorf("%w: invalid constant pool reference %d", ErrMalformed, index)
+		}
+
+		return pool[index].value, nil
+	}
+
+	class := &Class{}
+	if int(thisIndex) >= len(pool) || pool[thisIndex].tag != tagClass {
+		return nil, fmt.Errorf("%w: invalid this class reference %d", ErrMalformed, thisIndex)
+	}
+	name, err := utf8(pool[thisIndex].index)
+	if err != nil {
+		return nil, err
+	}
+	class.Name = name
+
+	references := make(map[string]bool)
+	for i, entry := range pool {
+		switch entry.tag {
+		case tagClass:
+			if uint16(i) == thisIndex {
+				continue
+			}
+
+			value, err := utf8(entry.index)
+			if err != nil {
+				return nil, err
+			}
+
+			if strings.HasPrefix(value, "[") {
+				parseDescriptor(value, references)
+			} else {
+				references[value] = true
+			}
+		case tagNameAndType, tagMethodType:
+			value, err := utf8(entry.index)
+			if err != nil {
+				return nil, err
+			}
+
+			parseDescriptor(value, references)
+		}
+	}
+	for _, index := range descriptors {
+		value, err := utf8(index)
+		if err != nil {
+			return nil, err
+		}
+
+		parseDescriptor(value, references)
+	}
+	delete(references, class.Name)
+
+	class.References = make([]string, 0, len(references))
+	for reference := range references {
+		class.References = append(class.References, reference)
+	}
+
+	return class, nil
+}
+
+// extracts all object types from a given field or method descriptor
+func parseDescriptor(descriptor string, references map[string]bool) {
+	for i := 0; i < len(descriptor); i++ {
+		if descriptor[i] != 'L' {
+			continue
+		}
+
+		end := strings.IndexByte(descriptor[i:], ';')
+		if end == -1 {
+			return
+		}
+
+		references[descriptor[i+1:i+end]] = true
+		i += end
+	}
+}
+
+// Package returns the package of a given internal class name in its binary form (e.g. "java.lang").
+//
+// Returns an empty string for classes within the unnamed package.
+func Package(name string) string {
+	separator := strings.LastIndexByte(name, '/')
+	if separator == -1 {
+		return ""
+	}
+
+	return strings.ReplaceAll(name[:separator], "/", ".")
+}
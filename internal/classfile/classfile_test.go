@@ -0,0 +1,148 @@
+/*
+ * Copyright 2021 Johannes Donath <johannesd@torchmind.com>
+ * and other copyright owners as documented in the project's IP log.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package classfile
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"sort"
+	"testing"
+)
+
+// assembles a class file which references a set of runtime types through different kinds of
+// constant pool entries
+func testClass(name string) []byte {
+	buf := &bytes.Buffer{}
+	write := func(values ...interface{}) {
+		for _, value := range values {
+			_ = binary.Write(buf, binary.BigEndian, value)
+		}
+	}
+	utf8 := func(value string) {
+		write(uint8(tagUtf8), uint16(len(value)))
+		buf.WriteString(value)
+	}
+
+	write(uint32(magic), uint16(0), uint16(55))
+	write(uint16(14))
+	utf8(name)                                          // 1
+	write(uint8(tagClass), uint16(1))                   // 2
+	utf8("java/lang/Object")                            // 3
+	write(uint8(tagClass), uint16(3))                   // 4
+	utf8("[Ljava/util/logging/Logger;")                 // 5
+	write(uint8(tagClass), uint16(5))                   // 6
+	write(uint8(tagLong), uint64(42))                   // 7 and 8
+	utf8("run")                                         // 9
+	utf8("(ILjava/sql/Connection;[Lfoo/Other;)V")       // 10
+	write(uint8(tagNameAndType), uint16(9), uint16(10)) // 11
+	utf8("Ljava/net/http/HttpClient;")                  // 12
+	utf8("client")                                      // 13
+
+	write(uint16(0x21), uint16(2), uint16(4), uint16(0))
+	write(uint16(1), uint16(0x2), uint16(13), uint16(12), uint16(1), uint16(9), uint32(3), []byte{1, 2, 3})
+	write(uint16(0), uint16(0))
+
+	return buf.Bytes()
+}
+
+func TestRead(t *testing.T) {
+	class, err := Read(bytes.NewReader(testClass("foo/Main")))
+	if err != nil {
+		t.Fatalf("failed to read class: %s", err)
+	}
+
+	if class.Name != "foo/Main" {
+		t.Errorf("expected class foo/Main but got %s", class.Name)
+	}
+
+	sort.Strings(class.References)
+	expected := []string{"foo/Other", "java/lang/Object", "java/net/http/HttpClient", "java/sql/Connection", "java/util/logging/Logger"}
+	if len(class.References) != len(expected) {
+		t.Fatalf("expected references %v but got %v", expected, class.References)
+	}
+	for i, reference := range expected {
+		if class.References[i] != reference {
+			t.Errorf("expected reference %s but got %s", reference, class.References[i])
+		}
+	}
+
+	if _, err := Read(bytes.NewReader([]byte{0xCA, 0xFE, 0xBA, 0xBE, 0, 0})); !errors.Is(err, ErrMalformed) {
+		t.Errorf("expected malformed class but got %v", err)
+	}
+	if _, err := Read(bytes.NewReader([]byte("not a class"))); !errors.Is(err, ErrMalformed) {
+		t.Errorf("expected malformed class but got %v", err)
+	}
+}
+
+func TestAnalyze(t *testing.T) {
+	buf := &bytes.Buffer{}
+	w := zip.NewWriter(buf)
+	for name, contents := range map[string][]byte{
+		"foo/Main.class":      testClass("foo/Main"),
+		"java/sql/Fake.class": testClass("java/sql/Fake"),
+		"foo/readme.txt":      []byte("not a class"),
+	} {
+		entry, err := w.Create(name)
+		if err != nil {
+			t.Fatalf("failed to create entry %s: %s", name, err)
+		}
+		_, _ = entry.Write(contents)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("failed to finalize archive: %s", err)
+	}
+
+	r, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatalf("failed to open archive: %s", err)
+	}
+
+	analysis, err := Analyze(r, false)
+	if err != nil {
+		t.Fatalf("failed to analyze archive: %s", err)
+	}
+
+	// packages which are declared within the archive itself are not attributed to runtime modules
+	modules := analysis.RequiredModules()
+	expected := []string{"java.base", "java.logging", "java.net.http"}
+	if len(modules) != len(expected) {
+		t.Fatalf("expected modules %v but got %v", expected, modules)
+	}
+	for i, module := range expected {
+		if modules[i] != module {
+			t.Errorf("expected module %s but got %s", module, modules[i])
+		}
+	}
+
+	if packages := analysis.Modules["java.logging"]; len(packages) != 1 || packages[0] != "java.util.logging" {
+		t.Errorf("expected java.logging to be referenced via java.util.logging but got %v", packages)
+	}
+}
+
+func TestModuleOf(t *testing.T) {
+	if module := ModuleOf("javax.swing.table"); module != "java.desktop" {
+		t.Errorf("expected java.desktop but got %q", module)
+	}
+	if module := ModuleOf("sun.misc"); module != "jdk.unsupported" {
+		t.Errorf("expected jdk.unsupported but got %q", module)
+	}
+	if module := ModuleOf("javax.inject"); module != "" {
+		t.Errorf("expected third party package to be unresolved but got %q", module)
+	}
+}
@@ -0,0 +1,363 @@
+/*
+ * Copyright 2021 Johannes Donath <johannesd@torchmind.com>
+ * and other copyright owners as documented in the project's IP log.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package config
+
+import (
+	"errors"
+	"fmt"
+	"gopkg.in/yaml.v3"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Version identifies the revision of the configuration file format understood by this
+// implementation.
+const Version = 1
+
+var ErrUnsupportedVersion = errors.New("unsupported configuration version")
+var ErrMalformed = errors.New("malformed configuration")
+var ErrCircularExtends = errors.New("circular extends")
+var ErrApplicationRequired = errors.New("application selection required")
+var ErrUnknownApplication = errors.New("unknown application")
+var ErrUnknownProfile = errors.New("unknown profile")
+
+// Error describes a problem within a specific location of a configuration file.
+type Error struct {
+	File string
+	Line int
+	Err  error
+}
+
+func (e *Error) Error() string {
+	if e.Line == 0 {
+		return fmt.Sprintf("%s: %s", e.File, e.Err)
+	}
+
+	return fmt.Sprintf("%s:%d: %s", e.File, e.Line, e.Err)
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Option describes a single configuration value along with its origin.
+type Option struct {
+	Name  string
+	Value string
+
+	// lists the values of options which have been declared as a sequence (nil otherwise)
+	Values []string
+
+	File string
+	Line int
+}
+
+// Errorf creates a new error which refers to the location of a given option.
+func (o *Option) Errorf(format string, args ...interface{}) error {
+	return &Error{
+		File: o.File,
+		Line: o.Line,
+		Err:  fmt.Errorf(format, args...),
+	}
+}
+
+// ResolvePath resolves a given option value relative to the directory of the file which declared
+// it.
+func (o *Option) ResolvePath() string {
+	if len(o.Value) == 0 || filepath.IsAbs(o.Value) {
+		return o.Value
+	}
+
+	return filepath.Join(filepath.Dir(o.File), o.Value)
+}
+
+// section encapsulates a set of options in order of declaration.
+type section []*Option
+
+// File represents a configuration file along with all files it extends.
+type File struct {
+	Path string
+
+	parent       *File
+	defaults     section
+	applications map[string]section
+	profiles     map[string]section
+}
+
+type document struct {
+	Version      int                  `yaml:"version"`
+	Extends      string               `yaml:"extends"`
+	Defaults     yaml.Node            `yaml:"defaults"`
+	Applications map[string]yaml.Node `yaml:"applications"`
+	Profiles     map[string]yaml.Node `yaml:"profiles"`
+}
+
+// Load parses the configuration file at a given path as well as any files it extends.
+func Load(path string) (*File, error) {
+	return load(path, make(map[string]bool))
+}
+
+func load(path string, visited map[string]bool) (*File, error) {
+	absolutePath, err := filepath.Abs(path)
+	if err != nil {
+		return nil, err
+	}
+	if visited[absolutePath] {
+		return nil, &Error{File: path, Err: ErrCircularExtends}
+	}
+	visited[absolutePath] = true
+
+	encoded, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	root := &yaml.Node{}
+	if err := yaml.Unmarshal(encoded, root); err != nil {
+		return nil, &Error{File: path, Err: fmt.Errorf("%w: %s", ErrMalformed, err)}
+	}
+	if len(root.Content) == 0 {
+		return nil, &Error{File: path, Err: fmt.Errorf("%w: file is empty", ErrMalformed)}
+	}
+	if err := checkKeys(path, root.Content[0], "version", "extends", "defaults", "applications", "profiles"); err != nil {
+		return nil, err
+	}
+
+	doc := &document{}
+	if err := root.Decode(doc); err != nil {
+		return nil, &Error{File: path, Err: fmt.Errorf("%w: %s", ErrMalformed, err)}
+	}
+	if doc.Version != Version {
+		return nil, &Error{File: path, Line: keyLine(root.Content[0], "version"), Err: fmt.Errorf("%w: %d (expected %d)", ErrUnsupportedVersion, doc.Version, Version)}
+	}
+
+	file := &File{
+		Path:         path,
+		applications: make(map[string]section),
+		profiles:     make(map[string]section),
+	}
+
+	if file.defaults, err = decodeSection(path, &doc.Defaults); err != nil {
+		return nil, err
+	}
+	for name, node := range doc.Applications {
+		node := node
+		if file.applications[name], err = decodeSection(path, &node); err != nil {
+			return nil, err
+		}
+	}
+	for name, node := range doc.Profiles {
+		node := node
+		if file.profiles[name], err = decodeSection(path, &node); err != nil {
+			return nil, err
+		}
+	}
+
+	if len(doc.Extends) != 0 {
+		parentPath := doc.Extends
+		if !filepath.IsAbs(parentPath) {
+			parentPath = filepath.Join(filepath.Dir(path), parentPath)
+		}
+
+		file.parent, err = load(parentPath, visited)
+		if err != nil {
+			var configErr *Error
+			if errors.As(err, &configErr) {
+				return nil, err
+			}
+
+			return nil, &Error{File: path, Line: keyLine(root.Content[0], "extends"), Err: fmt.Errorf("failed to load extended file: %w", err)}
+		}
+	}
+
+	return file, nil
+}
+
+// checks whether a given mapping node solely contains a set of permitted keys
+func checkKeys(path string, node *yaml.Node, permitted ...string) error {
+	if node.Kind != yaml.MappingNode {
+		return &Error{File: path, Line: node.Line, Err: fmt.Errorf("%w: expected mapping", ErrMalformed)}
+	}
+
+	for i := 0; i < len(node.Content); i += 2 {
+		key := node.Content[i]
+
+		found := false
+		for _, name := range permitted {
+			if key.Value == name {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return &Error{File: path, Line: key.Line, Err: fmt.Errorf("%w: unknown key %q", ErrMalformed, key.Value)}
+		}
+	}
+
+	return nil
+}
+
+// retrieves the line on which a given key has been declared within a mapping node
+func keyLine(node *yaml.Node, name string) int {
+	for i := 0; i < len(node.Content); i += 2 {
+		if node.Content[i].Value == name {
+			return node.Content[i].Line
+		}
+	}
+
+	return node.Line
+}
+
+// decodes a mapping of option names to scalar values
+func decodeSection(path string, node *yaml.Node) (section, error) {
+	if node.IsZero() || node.Tag == "!!null" {
+		return nil, nil
+	}
+	if node.Kind != yaml.MappingNode {
+		return nil, &Error{File: path, Line: node.Line, Err: fmt.Errorf("%w: expected mapping of options", ErrMalformed)}
+	}
+
+	options := make(section, 0, len(node.Content)/2)
+	for i := 0; i < len(node.Content); i += 2 {
+		key := node.Content[i]
+		value := node.Content[i+1]
+
+		option := &Option{
+			Name: key.Value,
+			File: path,
+			Line: key.Line,
+		}
+
+		switch value.Kind {
+		case yaml.ScalarNode:
+			option.Value = value.Value
+		case yaml.SequenceNode:
+			option.Values = make([]string, len(value.Content))
+			for j, item := range value.Content {
+				if item.Kind != yaml.ScalarNode {
+					return nil, &Error{File: path, Line: item.Line, Err: fmt.Errorf("%w: expected scalar values for option %q", ErrMalformed, key.Value)}
+				}
+
+				option.Values[j] = item.Value
+			}
+		default:
+			return nil, &Error{File: path, Line: value.Line, Err: fmt.Errorf("%w: expected scalar value or sequence for option %q", ErrMalformed, key.Value)}
+		}
+
+		options = append(options, option)
+	}
+
+	return options, nil
+}
+
+// Applications retrieves the names of all applications declared within the file or any of the
+// files it extends.
+func (f *File) Applications() []string {
+	names := make(map[string]bool)
+	for current := f; current != nil; current = current.parent {
+		for name := range current.applications {
+			names[name] = true
+		}
+	}
+
+	result := make([]string, 0, len(names))
+	for name := range names {
+		result = append(result, name)
+	}
+	sort.Strings(result)
+
+	return result
+}
+
+// Resolve computes the effective set of options for a given application and profile.
+//
+// Options are applied in order of precedence: defaults, application and profile with options
+// declared within a file taking precedence over the files it extends. The application may be left
+// empty when the file declares at most one application. Similarly, the profile may be left empty
+// in order to skip profile specific options.
+func (f *File) Resolve(application string, profile string) ([]*Option, error) {
+	applications := f.Applications()
+	if len(application) == 0 {
+		if len(applications) > 1 {
+			return nil, &Error{File: f.Path, Err: fmt.Errorf("%w: file declares applications %s", ErrApplicationRequired, strings.Join(applications, ", "))}
+		}
+		if len(applications) == 1 {
+			application = applications[0]
+		}
+	} else if !f.declares(application, func(f *File) map[string]section { return f.applications }) {
+		return nil, &Error{File: f.Path, Err: fmt.Errorf("%w: %s", ErrUnknownApplication, application)}
+	}
+
+	if len(profile) != 0 && !f.declares(profile, func(f *File) map[string]section { return f.profiles }) {
+		return nil, &Error{File: f.Path, Err: fmt.Errorf("%w: %s", ErrUnknownProfile, profile)}
+	}
+
+	resolved := make(map[string]*Option)
+	order := make([]string, 0)
+	merge := func(options section) {
+		for _, option := range options {
+			if _, ok := resolved[option.Name]; !ok {
+				order = append(order, option.Name)
+			}
+
+			resolved[option.Name] = option
+		}
+	}
+
+	f.visit(func(file *File) {
+		merge(file.defaults)
+	})
+	if len(application) != 0 {
+		f.visit(func(file *File) {
+			merge(file.applications[application])
+		})
+	}
+	if len(profile) != 0 {
+		f.visit(func(file *File) {
+			merge(file.profiles[profile])
+		})
+	}
+
+	result := make([]*Option, len(order))
+	for i, name := range order {
+		result[i] = resolved[name]
+	}
+
+	return result, nil
+}
+
+// identifies whether a given named section is declared within the file or any of its parents
+func (f *File) declares(name string, sections func(f *File) map[string]section) bool {
+	for current := f; current != nil; current = current.parent {
+		if _, ok := sections(current)[name]; ok {
+			return true
+		}
+	}
+
+	return false
+}
+
+// invokes a given function for all files starting with the outermost parent
+func (f *File) visit(fn func(file *File)) {
+	if f.parent != nil {
+		f.parent.visit(fn)
+	}
+
+	fn(f)
+}
@@ -0,0 +1,245 @@
+/*
+ * Copyright 2021 Johannes Donath <johannesd@torchmind.com>
+ * and other copyright owners as documented in the project's IP log.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package config
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// writes a configuration file into a given directory
+func writeConfig(t *testing.T, dir string, name string, contents string) string {
+	path := filepath.Join(dir, name)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatalf("failed to create directory: %s", err)
+	}
+	if err := os.WriteFile(path, []byte(contents), 0644); err != nil {
+		t.Fatalf("failed to write configuration: %s", err)
+	}
+
+	return path
+}
+
+// converts a set of options into a map of names to values
+func optionValues(options []*Option) map[string]string {
+	values := make(map[string]string)
+	for _, option := range options {
+		values[option.Name] = option.Value
+	}
+
+	return values
+}
+
+func TestResolve(t *testing.T) {
+	dir := t.TempDir()
+	writeConfig(t, dir, "org/base.yaml", `version: 1
+defaults:
+  runtime-version: 11
+  support-url: https://example.org/help
+applications:
+  foo:
+    name: Base Foo
+profiles:
+  release:
+    runtime-args: -Xshare:auto
+`)
+	path := writeConfig(t, dir, "project/canoe.yaml", `version: 1
+extends: ../org/base.yaml
+defaults:
+  runtime-version: 17
+applications:
+  foo:
+    in: build/foo.jar
+  bar:
+    in: build/bar.jar
+profiles:
+  debug:
+    runtime-args: -Xdebug
+    runtime-version: 18
+`)
+
+	file, err := Load(path)
+	if err != nil {
+		t.Fatalf("failed to load configuration: %s", err)
+	}
+
+	apps := file.Applications()
+	if len(apps) != 2 || apps[0] != "bar" || apps[1] != "foo" {
+		t.Errorf("expected applications [bar foo] but got %v", apps)
+	}
+
+	options, err := file.Resolve("foo", "release")
+	if err != nil {
+		t.Fatalf("failed to resolve configuration: %s", err)
+	}
+	values := optionValues(options)
+
+	expected := map[string]string{
+		"runtime-version": "17",
+		"support-url":     "https://example.org/help",
+		"name":            "Base Foo",
+		"in":              "build/foo.jar",
+		"runtime-args":    "-Xshare:auto",
+	}
+	if len(values) != len(expected) {
+		t.Errorf("expected %d options but got %v", len(expected), values)
+	}
+	for name, value := range expected {
+		if values[name] != value {
+			t.Errorf("expected option %s to be %q but got %q", name, value, values[name])
+		}
+	}
+
+	options, err = file.Resolve("bar", "debug")
+	if err != nil {
+		t.Fatalf("failed to resolve configuration: %s", err)
+	}
+	values = optionValues(options)
+	if values["runtime-version"] != "18" {
+		t.Errorf("expected profile to override runtime version but got %q", values["runtime-version"])
+	}
+	if _, ok := values["name"]; ok {
+		t.Errorf("expected options of other applications to be omitted")
+	}
+
+	for _, option := range options {
+		if option.Name == "in" {
+			expectedPath := filepath.Join(dir, "project", "build", "bar.jar")
+			if option.ResolvePath() != expectedPath {
+				t.Errorf("expected path %s but got %s", expectedPath, option.ResolvePath())
+			}
+			if option.Line != 9 {
+				t.Errorf("expected option to be declared on line 9 but got %d", option.Line)
+			}
+		}
+	}
+}
+
+func TestResolveSequence(t *testing.T) {
+	dir := t.TempDir()
+	path := writeConfig(t, dir, "canoe.yaml", `version: 1
+defaults:
+  document-type:
+    - Foo Document:foo
+applications:
+  foo:
+    document-type:
+      - Foo Document:foo
+      - Bar Document:bar,baz
+`)
+
+	file, err := Load(path)
+	if err != nil {
+		t.Fatalf("failed to load configuration: %s", err)
+	}
+
+	options, err := file.Resolve("", "")
+	if err != nil {
+		t.Fatalf("failed to resolve configuration: %s", err)
+	}
+	if len(options) != 1 {
+		t.Fatalf("expected sequence to be replaced but got %d options", len(options))
+	}
+
+	values := options[0].Values
+	if len(values) != 2 || values[0] != "Foo Document:foo" || values[1] != "Bar Document:bar,baz" {
+		t.Errorf("unexpected values %q", values)
+	}
+}
+
+func TestResolveSelection(t *testing.T) {
+	dir := t.TempDir()
+	path := writeConfig(t, dir, "canoe.yaml", `version: 1
+applications:
+  foo:
+    in: foo.jar
+  bar:
+    in: bar.jar
+`)
+
+	file, err := Load(path)
+	if err != nil {
+		t.Fatalf("failed to load configuration: %s", err)
+	}
+
+	if _, err := file.Resolve("", ""); !errors.Is(err, ErrApplicationRequired) {
+		t.Errorf("expected application selection to be required but got %v", err)
+	}
+	if _, err := file.Resolve("baz", ""); !errors.Is(err, ErrUnknownApplication) {
+		t.Errorf("expected unknown application but got %v", err)
+	}
+	if _, err := file.Resolve("foo", "release"); !errors.Is(err, ErrUnknownProfile) {
+		t.Errorf("expected unknown profile but got %v", err)
+	}
+
+	path = writeConfig(t, dir, "single.yaml", `version: 1
+applications:
+  foo:
+    in: foo.jar
+`)
+	file, err = Load(path)
+	if err != nil {
+		t.Fatalf("failed to load configuration: %s", err)
+	}
+
+	options, err := file.Resolve("", "")
+	if err != nil {
+		t.Fatalf("failed to resolve configuration: %s", err)
+	}
+	if optionValues(options)["in"] != "foo.jar" {
+		t.Errorf("expected sole application to be selected implicitly")
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	dir := t.TempDir()
+
+	tests := []struct {
+		name     string
+		contents string
+		err      error
+		line     int
+	}{
+		{"version.yaml", "version: 2\n", ErrUnsupportedVersion, 1},
+		{"missing-version.yaml", "defaults:\n  in: foo.jar\n", ErrUnsupportedVersion, 1},
+		{"unknown-key.yaml", "version: 1\n\nplugins: {}\n", ErrMalformed, 3},
+		{"mapping.yaml", "version: 1\ndefaults:\n  in:\n    path: foo.jar\n", ErrMalformed, 4},
+		{"sequence.yaml", "version: 1\ndefaults:\n  in:\n    - path: foo.jar\n", ErrMalformed, 4},
+		{"circular.yaml", "version: 1\nextends: circular.yaml\n", ErrCircularExtends, 0},
+	}
+
+	for _, test := range tests {
+		path := writeConfig(t, dir, test.name, test.contents)
+
+		_, err := Load(path)
+		if !errors.Is(err, test.err) {
+			t.Errorf("%s: expected %v but got %v", test.name, test.err, err)
+			continue
+		}
+
+		var configErr *Error
+		if !errors.As(err, &configErr) {
+			t.Errorf("%s: expected configuration error but got %T", test.name, err)
+			continue
+		}
+		if configErr.Line != test.line {
+			t.Errorf("%s: expected error on line %d but got %d", test.name, test.line, configErr.Line)
+		}
+	}
+}
@@ -0,0 +1,279 @@
+/*
+ * Copyright 2021 Johannes Donath <johannesd@torchmind.com>
+ * and other copyright owners as documented in the project's IP log.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package deb
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/md5"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"time"
+)
+
+const arMagic = "!<arch>\n"
+
+// identifies the revision of the binary package format
+const formatVersion = "2.0\n"
+
+var ErrInvalidPackage = errors.New("invalid package")
+
+// Package describes the contents and control information of a Debian binary package.
+type Package struct {
+	Name         string
+	Version      string
+	Architecture string
+	Maintainer   string
+	Homepage     string
+
+	// Summary is displayed as the synopsis of the package while Description provides an optional
+	// extended description
+	Summary     string
+	Description string
+
+	Depends []string
+
+	Files   []File
+	ModTime time.Time
+}
+
+// File describes a single regular file which is installed by a package.
+type File struct {
+	// Path identifies the absolute installation path of the file (e.g. "/usr/bin/foo")
+	Path string
+	Mode os.FileMode
+	Data []byte
+}
+
+// maps Go architectures onto their Debian equivalents
+var architectures = map[string]string{
+	"386":      "i386",
+	"amd64":    "amd64",
+	"arm":      "armhf",
+	"arm64":    "arm64",
+	"mips":     "mips",
+	"mipsle":   "mipsel",
+	"mips64le": "mips64el",
+	"ppc64":    "ppc64",
+	"ppc64le":  "ppc64el",
+	"riscv64":  "riscv64",
+	"s390x":    "s390x",
+}
+
+// Architecture translates a given Go architecture into its Debian equivalent.
+//
+// Returns an empty string when the architecture is not supported by Debian.
+func Architecture(arch string) string {
+	return architectures[arch]
+}
+
+// FileName computes the conventional file name of the package.
+func (p *Package) FileName() string {
+	return p.Name + "_" + p.Version + "_" + p.Architecture + ".deb"
+}
+
+// Write encodes the package and writes it to a given writer.
+func (p *Package) Write(w io.Writer) error {
+	if len(p.Name) == 0 || len(p.Version) == 0 || len(p.Architecture) == 0 || len(p.Summary) == 0 {
+		return fmt.Errorf("%w: name, version, architecture and summary are required", ErrInvalidPackage)
+	}
+
+	data, installedSize, err := p.dataArchive()
+	if err != nil {
+		return fmt.Errorf("failed to encode data archive: %w", err)
+	}
+
+	control, err := p.controlArchive(installedSize)
+	if err != nil {
+		return fmt.Errorf("failed to encode control archive: %w", err)
+	}
+
+	buf := &bytes.Buffer{}
+	buf.WriteString(arMagic)
+	writeArMember(buf, "debian-binary", p.ModTime, []byte(formatVersion))
+	writeArMember(buf, "control.tar.gz", p.ModTime, control)
+	writeArMember(buf, "data.tar.gz", p.ModTime, data)
+
+	_, err = w.Write(buf.Bytes())
+	return err
+}
+
+// encodes the control file of the package
+func (p *Package) control(installedSize int64) []byte {
+	buf := &bytes.Buffer{}
+	field := func(name string, value string) {
+		if len(value) != 0 {
+			_, _ = fmt.Fprintf(buf, "%s: %s\n", name, value)
+		}
+	}
+
+	field("Package", p.Name)
+	field("Version", p.Version)
+	field("Architecture", p.Architecture)
+	field("Maintainer", p.Maintainer)
+	field("Installed-Size", fmt.Sprint((installedSize+1023)/1024))
+	field("Depends", strings.Join(p.Depends, ", "))
+	field("Section", "misc")
+	field("Priority", "optional")
+	field("Homepage", p.Homepage)
+
+	// extended descriptions are continued on lines which are indented by a single space while empty
+	// lines are represented by a single dot
+	field("Description", singleLine(p.Summary))
+	if description := strings.TrimSpace(p.Description); len(description) != 0 {
+		for _, line := range strings.Split(description, "\n") {
+			line = strings.TrimRight(line, " \t\r")
+			if len(line) == 0 {
+				line = "."
+			}
+
+			buf.WriteString(" " + line + "\n")
+		}
+	}
+
+	return buf.Bytes()
+}
+
+// encodes the control archive which carries the control file and the digests of all installed
+// files
+func (p *Package) controlArchive(installedSize int64) ([]byte, error) {
+	sums := &bytes.Buffer{}
+	for _, file := range p.Files {
+		digest := md5.Sum(file.Data)
+		_, _ = fmt.Fprintf(sums, "%s  %s\n", hex.EncodeToString(digest[:]), strings.TrimPrefix(path.Clean(file.Path), "/"))
+	}
+
+	return p.tarball(func(tw *tar.Writer) error {
+		if err := writeTarEntry(tw, "./", tar.TypeDir, 0755, p.ModTime, nil); err != nil {
+			return err
+		}
+		if err := writeTarEntry(tw, "./control", tar.TypeReg, 0644, p.ModTime, p.control(installedSize)); err != nil {
+			return err
+		}
+		return writeTarEntry(tw, "./md5sums", tar.TypeReg, 0644, p.ModTime, sums.Bytes())
+	})
+}
+
+// encodes the data archive which carries the installed files along with their parent directories
+//
+// returns the archive along with the total size of all installed files
+func (p *Package) dataArchive() ([]byte, int64, error) {
+	directories := make(map[string]bool)
+	for _, file := range p.Files {
+		if !path.IsAbs(file.Path) {
+			return nil, 0, fmt.Errorf("%w: file path must be absolute: %s", ErrInvalidPackage, file.Path)
+		}
+
+		for dir := path.Dir(path.Clean(file.Path)); dir != "/"; dir = path.Dir(dir) {
+			directories[dir] = true
+		}
+	}
+
+	sortedDirectories := make([]string, 0, len(directories))
+	for dir := range directories {
+		sortedDirectories = append(sortedDirectories, dir)
+	}
+	sort.Strings(sortedDirectories)
+
+	var installedSize int64
+	archive, err := p.tarball(func(tw *tar.Writer) error {
+		if err := writeTarEntry(tw, "./", tar.TypeDir, 0755, p.ModTime, nil); err != nil {
+			return err
+		}
+		for _, dir := range sortedDirectories {
+			if err := writeTarEntry(tw, "."+dir+"/", tar.TypeDir, 0755, p.ModTime, nil); err != nil {
+				return err
+			}
+		}
+
+		for _, file := range p.Files {
+			installedSize += int64(len(file.Data))
+			if err := writeTarEntry(tw, "."+path.Clean(file.Path), tar.TypeReg, int64(file.Mode.Perm()), p.ModTime, file.Data); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+
+	return archive, installedSize, err
+}
+
+// encodes a gzip compressed tar archive whose entries are written by a given function
+func (p *Package) tarball(write func(tw *tar.Writer) error) ([]byte, error) {
+	buf := &bytes.Buffer{}
+	gz, err := gzip.NewWriterLevel(buf, gzip.BestCompression)
+	if err != nil {
+		return nil, err
+	}
+
+	tw := tar.NewWriter(gz)
+	if err := write(tw); err != nil {
+		return nil, err
+	}
+	if err := tw.Close(); err != nil {
+		return nil, err
+	}
+	if err := gz.Close(); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// writes a single entry owned by root to a given tar archive
+func writeTarEntry(tw *tar.Writer, name string, typ byte, mode int64, modTime time.Time, data []byte) error {
+	err := tw.WriteHeader(&tar.Header{
+		Typeflag: typ,
+		Name:     name,
+		Mode:     mode,
+		Size:     int64(len(data)),
+		ModTime:  modTime,
+		Uname:    "root",
+		Gname:    "root",
+		Format:   tar.FormatGNU,
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = tw.Write(data)
+	return err
+}
+
+// writes a single member to a given ar archive
+//
+// members are aligned to two bytes as required by the ar format
+func writeArMember(buf *bytes.Buffer, name string, modTime time.Time, data []byte) {
+	_, _ = fmt.Fprintf(buf, "%-16s%-12d%-6d%-6d%-8s%-10d`\n", name, modTime.Unix(), 0, 0, "100644", len(data))
+	buf.Write(data)
+	if len(data)%2 != 0 {
+		buf.WriteByte('\n')
+	}
+}
+
+// collapses a given string into a single line
+func singleLine(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
@@ -0,0 +1,162 @@
+/*
+ * Copyright 2021 Johannes Donath <johannesd@torchmind.com>
+ * and other copyright owners as documented in the project's IP log.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package deb
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"io"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func testPackage() *Package {
+	return &Package{
+		Name:         "foo",
+		Version:      "1.2.0",
+		Architecture: "amd64",
+		Maintainer:   "Example Inc. <foo@example.org>",
+		Homepage:     "https://example.org",
+		Summary:      "Does foo",
+		Description:  "Does foo in a\nvery reliable way.\n\nAlso does bar.",
+		Depends:      []string{"openjdk-17-jre-headless | java17-runtime-headless"},
+		Files: []File{
+			{Path: "/usr/bin/foo", Mode: 0755, Data: []byte("#!/bin/sh\n")},
+		},
+		ModTime: time.Unix(1600000000, 0),
+	}
+}
+
+// splits a given ar archive into its members
+func readAr(t *testing.T, data []byte) map[string][]byte {
+	if !bytes.HasPrefix(data, []byte(arMagic)) {
+		t.Fatalf("missing ar magic")
+	}
+
+	members := make(map[string][]byte)
+	for offset := len(arMagic); offset < len(data); {
+		header := data[offset : offset+60]
+		if string(header[58:60]) != "`\n" {
+			t.Fatalf("malformed member header at offset %d", offset)
+		}
+
+		name := strings.TrimSpace(string(header[:16]))
+		size, err := strconv.Atoi(strings.TrimSpace(string(header[48:58])))
+		if err != nil {
+			t.Fatalf("malformed member size: %s", err)
+		}
+
+		offset += 60
+		members[name] = data[offset : offset+size]
+		offset += size + size%2
+	}
+
+	return members
+}
+
+// extracts all regular files from a given gzip compressed tar archive
+func readTarball(t *testing.T, data []byte) map[string]*tar.Header {
+	gz, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("failed to decompress archive: %s", err)
+	}
+
+	entries := make(map[string]*tar.Header)
+	tr := tar.NewReader(gz)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("failed to read archive: %s", err)
+		}
+
+		entries[header.Name] = header
+	}
+
+	return entries
+}
+
+func TestWrite(t *testing.T) {
+	buf := &bytes.Buffer{}
+	if err := testPackage().Write(buf); err != nil {
+		t.Fatalf("failed to write package: %s", err)
+	}
+
+	members := readAr(t, buf.Bytes())
+	if string(members["debian-binary"]) != "2.0\n" {
+		t.Errorf("unexpected format version %q", members["debian-binary"])
+	}
+
+	data := readTarball(t, members["data.tar.gz"])
+	for _, name := range []string{"./", "./usr/", "./usr/bin/"} {
+		if header, ok := data[name]; !ok || header.Typeflag != tar.TypeDir {
+			t.Errorf("expected directory %s within data archive", name)
+		}
+	}
+	if header, ok := data["./usr/bin/foo"]; !ok || header.Mode != 0755 || header.Size != 10 {
+		t.Errorf("expected executable ./usr/bin/foo within data archive but got %+v", header)
+	}
+
+	control := readTarball(t, members["control.tar.gz"])
+	if _, ok := control["./control"]; !ok {
+		t.Errorf("expected control file within control archive")
+	}
+	if _, ok := control["./md5sums"]; !ok {
+		t.Errorf("expected digests within control archive")
+	}
+}
+
+func TestControl(t *testing.T) {
+	expected := `Package: foo
+Version: 1.2.0
+Architecture: amd64
+Maintainer: Example Inc. <foo@example.org>
+Installed-Size: 1
+Depends: openjdk-17-jre-headless | java17-runtime-headless
+Section: misc
+Priority: optional
+Homepage: https://example.org
+Description: Does foo
+ Does foo in a
+ very reliable way.
+ .
+ Also does bar.
+`
+	if actual := string(testPackage().control(10)); actual != expected {
+		t.Errorf("unexpected control file:\n%s", actual)
+	}
+}
+
+func TestWriteInvalid(t *testing.T) {
+	pkg := testPackage()
+	pkg.Version = ""
+	if err := pkg.Write(io.Discard); !errors.Is(err, ErrInvalidPackage) {
+		t.Errorf("expected ErrInvalidPackage but got %v", err)
+	}
+
+	pkg = testPackage()
+	pkg.Files[0].Path = "usr/bin/foo"
+	if err := pkg.Write(io.Discard); !errors.Is(err, ErrInvalidPackage) {
+		t.Errorf("expected ErrInvalidPackage but got %v", err)
+	}
+}
@@ -0,0 +1,287 @@
+/*
+ * Copyright 2021 Johannes Donath <johannesd@torchmind.com>
+ * and other copyright owners as documented in the project's IP log.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package desktop
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"github.com/dotstart/canoe/internal/icon"
+	"github.com/dotstart/canoe/internal/metadata"
+	"image"
+	"os"
+	"path"
+	"strings"
+)
+
+// IconSizes lists the sizes at which icons are installed within the hicolor theme.
+var IconSizes = []int{16, 24, 32, 48, 64, 128, 256, 512}
+
+// lists the menu categories of applications which do not declare any categories
+var defaultCategories = []string{"Utility"}
+
+// File describes a single desktop integration file.
+type File struct {
+	// Path identifies the location of the file relative to the data directory (e.g. "/usr/share" or
+	// "~/.local/share")
+	Path string
+	Mode os.FileMode
+	Data []byte
+}
+
+// ID computes the desktop file ID of a given application.
+//
+// Applications are identified by their reverse DNS identifier when available while the passed
+// fallback (typically the name of the installed executable) is used otherwise.
+func ID(meta *metadata.ApplicationContainer, fallback string) string {
+	if identifier := meta.GetIdentity().GetIdentifier(); len(identifier) != 0 {
+		return identifier
+	}
+
+	return fallback
+}
+
+// SanitizeName derives the name of an installed executable (or package) from a given application
+// name.
+//
+// The result consists of lower case letters, digits, dots, plus and minus signs only.
+func SanitizeName(name string) string {
+	name = strings.ToLower(strings.Join(strings.Fields(name), "-"))
+
+	sanitized := &strings.Builder{}
+	for _, c := range name {
+		switch {
+		case c >= 'a' && c <= 'z', c >= '0' && c <= '9', c == '.', c == '+', c == '-':
+			sanitized.WriteRune(c)
+		case c == '_':
+			sanitized.WriteRune('-')
+		}
+	}
+
+	return strings.TrimLeft(sanitized.String(), ".+-")
+}
+
+// Files generates the desktop entry, icons and MIME type declarations of a given application.
+//
+// The desktop entry launches the passed executable (either an absolute path or the name of an
+// executable within the search path). Icons are omitted when no icon is given.
+func Files(meta *metadata.ApplicationContainer, id string, executable string, src image.Image) ([]File, error) {
+	iconName := ""
+	var files []File
+	if src != nil {
+		iconName = id
+
+		for _, size := range IconSizes {
+			// icons are not scaled beyond their original size unless they are smaller than the
+			// smallest size
+			if size > src.Bounds().Dx() && size != IconSizes[0] {
+				break
+			}
+
+			encoded, err := icon.EncodePNG(src, size)
+			if err != nil {
+				return nil, fmt.Errorf("failed to encode icon: %w", err)
+			}
+
+			files = append(files, File{
+				Path: path.Join("icons", "hicolor", fmt.Sprintf("%dx%d", size, size), "apps", iconName+".png"),
+				Mode: 0644,
+				Data: encoded,
+			})
+		}
+	}
+
+	files = append(files, File{
+		Path: path.Join("applications", id+".desktop"),
+		Mode: 0644,
+		Data: Entry(meta, executable, iconName),
+	})
+
+	if mimeInfo := MimeInfo(meta); mimeInfo != nil {
+		files = append(files, File{
+			Path: path.Join("mime", "packages", id+".xml"),
+			Mode: 0644,
+			Data: mimeInfo,
+		})
+	}
+
+	return files, nil
+}
+
+// Entry generates a desktop entry which launches a given executable.
+//
+// Documents and URLs are passed to the executable as application arguments.
+func Entry(meta *metadata.ApplicationContainer, executable string, iconName string) []byte {
+	identity := meta.GetIdentity()
+	desktop := meta.GetDesktop()
+
+	name := identity.GetName()
+	if len(name) == 0 {
+		name = path.Base(executable)
+	}
+
+	// percent signs introduce field codes and are thus escaped by doubling them
+	exec := quoteArgument(strings.ReplaceAll(executable, "%", "%%"))
+	if len(desktop.GetUrlSchemes()) != 0 {
+		// URLs and documents are both passed as URLs (local documents are passed as file paths by
+		// most implementations)
+		exec += " %U"
+	} else if len(desktop.GetDocumentTypes()) != 0 {
+		exec += " %F"
+	}
+
+	categories := desktop.GetCategories()
+	if len(categories) == 0 {
+		categories = defaultCategories
+	}
+
+	buf := &bytes.Buffer{}
+	field := func(key string, value string) {
+		if len(value) != 0 {
+			_, _ = fmt.Fprintf(buf, "%s=%s\n", key, value)
+		}
+	}
+
+	buf.WriteString("[Desktop Entry]\n")
+	field("Type", "Application")
+	field("Version", "1.5")
+	field("Name", escapeString(name))
+	field("Comment", escapeString(identity.GetDescription()))
+	field("Exec", escapeString(exec))
+	field("Icon", iconName)
+	field("Terminal", "false")
+	field("Categories", joinList(categories))
+	field("MimeType", joinList(MimeTypes(meta)))
+
+	// AWT derives the window class of applications from their main class thus permitting desktop
+	// environments to associate windows with their respective entry
+	field("StartupWMClass", strings.ReplaceAll(meta.GetApplication().GetMainClass(), ".", "-"))
+
+	return buf.Bytes()
+}
+
+// MimeTypes lists the MIME types which are handled by a given application (including the pseudo
+// types which identify URL scheme handlers).
+func MimeTypes(meta *metadata.ApplicationContainer) []string {
+	var mimeTypes []string
+	for _, documentType := range meta.GetDesktop().GetDocumentTypes() {
+		mimeTypes = append(mimeTypes, documentMimeTypes(documentType)...)
+	}
+	for _, scheme := range meta.GetDesktop().GetUrlSchemes() {
+		mimeTypes = append(mimeTypes, "x-scheme-handler/"+scheme)
+	}
+
+	return mimeTypes
+}
+
+type mimeInfo struct {
+	XMLName   xml.Name   `xml:"http://www.freedesktop.org/standards/shared-mime-info mime-info"`
+	MimeTypes []mimeType `xml:"mime-type"`
+}
+
+type mimeType struct {
+	Type    string     `xml:"type,attr"`
+	Comment string     `xml:"comment"`
+	Globs   []mimeGlob `xml:"glob"`
+}
+
+type mimeGlob struct {
+	Pattern string `xml:"pattern,attr"`
+}
+
+// MimeInfo generates a shared-mime-info package which associates the file extensions of the
+// document types declared by a given application with their respective MIME types.
+//
+// Returns nil when no document type declares file extensions.
+func MimeInfo(meta *metadata.ApplicationContainer) []byte {
+	info := &mimeInfo{}
+	for _, documentType := range meta.GetDesktop().GetDocumentTypes() {
+		if len(documentType.GetExtensions()) == 0 {
+			continue
+		}
+
+		var globs []mimeGlob
+		for _, extension := range documentType.GetExtensions() {
+			globs = append(globs, mimeGlob{Pattern: "*." + extension})
+		}
+
+		for _, typ := range documentMimeTypes(documentType) {
+			info.MimeTypes = append(info.MimeTypes, mimeType{
+				Type:    typ,
+				Comment: documentType.GetName(),
+				Globs:   globs,
+			})
+		}
+	}
+	if len(info.MimeTypes) == 0 {
+		return nil
+	}
+
+	encoded, err := xml.MarshalIndent(info, "", "  ")
+	if err != nil {
+		// the structure above cannot produce invalid documents
+		panic(err)
+	}
+
+	return append(append([]byte(xml.Header), encoded...), '\n')
+}
+
+// computes the MIME types of a given document type
+//
+// document types which do not declare any MIME types receive a type derived from their name
+func documentMimeTypes(documentType *metadata.DocumentType) []string {
+	if len(documentType.GetMimeTypes()) != 0 {
+		return documentType.GetMimeTypes()
+	}
+
+	name := &strings.Builder{}
+	for _, c := range strings.ToLower(strings.Join(strings.Fields(documentType.GetName()), "-")) {
+		if (c >= 'a' && c <= 'z') || (c >= '0' && c <= '9') || c == '-' || c == '.' || c == '+' {
+			name.WriteRune(c)
+		}
+	}
+
+	if name.Len() == 0 {
+		return []string{"application/x-" + documentType.GetExtensions()[0]}
+	}
+
+	return []string{"application/x-" + name.String()}
+}
+
+// quotes an argument of the Exec key when it contains reserved characters
+func quoteArgument(argument string) string {
+	if !strings.ContainsAny(argument, " \t\n\"'\\><~|&;$*?#()`") {
+		return argument
+	}
+
+	replacer := strings.NewReplacer(`"`, `\"`, "`", "\\`", `$`, `\$`, `\`, `\\`)
+	return `"` + replacer.Replace(argument) + `"`
+}
+
+// escapes a string value of a desktop entry
+func escapeString(value string) string {
+	return strings.NewReplacer(`\`, `\\`, "\n", `\n`, "\t", `\t`, "\r", `\r`).Replace(value)
+}
+
+// joins a list value of a desktop entry (each element is terminated by a semicolon)
+func joinList(values []string) string {
+	if len(values) == 0 {
+		return ""
+	}
+
+	return strings.Join(values, ";") + ";"
+}
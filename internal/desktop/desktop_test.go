@@ -0,0 +1,119 @@
+/*
+ * Copyright 2021 Johannes Donath <johannesd@torchmind.com>
+ * and other copyright owners as documented in the project's IP log.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package desktop
+
+import (
+	"github.com/dotstart/canoe/internal/metadata"
+	"image"
+	"strings"
+	"testing"
+)
+
+func testMetadata() *metadata.ApplicationContainer {
+	return &metadata.ApplicationContainer{
+		Application: &metadata.ApplicationConfiguration{
+			MainClass: "org.example.foo.Main",
+		},
+		Identity: &metadata.ApplicationIdentity{
+			Name:        "Foo",
+			Description: "Does foo",
+			Identifier:  "org.example.foo",
+		},
+		Desktop: &metadata.DesktopConfiguration{
+			DocumentTypes: []*metadata.DocumentType{
+				{Name: "Foo Document", Extensions: []string{"foo", "foox"}},
+				{Name: "Text", MimeTypes: []string{"text/plain"}},
+			},
+			UrlSchemes: []string{"foo"},
+			Categories: []string{"Development", "IDE"},
+		},
+	}
+}
+
+func TestEntry(t *testing.T) {
+	expected := `[Desktop Entry]
+Type=Application
+Version=1.5
+Name=Foo
+Comment=Does foo
+Exec=foo %U
+Icon=org.example.foo
+Terminal=false
+Categories=Development;IDE;
+MimeType=application/x-foo-document;text/plain;x-scheme-handler/foo;
+StartupWMClass=org-example-foo-Main
+`
+	if actual := string(Entry(testMetadata(), "foo", "org.example.foo")); actual != expected {
+		t.Errorf("unexpected desktop entry:\n%s", actual)
+	}
+
+	meta := testMetadata()
+	meta.Desktop = &metadata.DesktopConfiguration{
+		DocumentTypes: []*metadata.DocumentType{{Name: "Foo Document", Extensions: []string{"foo"}}},
+	}
+	entry := string(Entry(meta, "/home/foo/My Apps/foo%1", ""))
+	if !strings.Contains(entry, `Exec="/home/foo/My Apps/foo%%1" %F`+"\n") {
+		t.Errorf("expected quoted executable within desktop entry:\n%s", entry)
+	}
+	if !strings.Contains(entry, "Categories=Utility;\n") || strings.Contains(entry, "Icon=") {
+		t.Errorf("unexpected desktop entry:\n%s", entry)
+	}
+}
+
+func TestMimeInfo(t *testing.T) {
+	expected := `<?xml version="1.0" encoding="UTF-8"?>
+<mime-info xmlns="http://www.freedesktop.org/standards/shared-mime-info">
+  <mime-type type="application/x-foo-document">
+    <comment>Foo Document</comment>
+    <glob pattern="*.foo"></glob>
+    <glob pattern="*.foox"></glob>
+  </mime-type>
+</mime-info>
+`
+	if actual := string(MimeInfo(testMetadata())); actual != expected {
+		t.Errorf("unexpected MIME information:\n%s", actual)
+	}
+
+	if info := MimeInfo(&metadata.ApplicationContainer{}); info != nil {
+		t.Errorf("expected no MIME information but got %s", info)
+	}
+}
+
+func TestFiles(t *testing.T) {
+	files, err := Files(testMetadata(), "org.example.foo", "foo", image.NewNRGBA(image.Rect(0, 0, 64, 64)))
+	if err != nil {
+		t.Fatalf("failed to generate files: %s", err)
+	}
+
+	expected := []string{
+		"icons/hicolor/16x16/apps/org.example.foo.png",
+		"icons/hicolor/24x24/apps/org.example.foo.png",
+		"icons/hicolor/32x32/apps/org.example.foo.png",
+		"icons/hicolor/48x48/apps/org.example.foo.png",
+		"icons/hicolor/64x64/apps/org.example.foo.png",
+		"applications/org.example.foo.desktop",
+		"mime/packages/org.example.foo.xml",
+	}
+	if len(files) != len(expected) {
+		t.Fatalf("expected %d files but got %d", len(expected), len(files))
+	}
+	for i, file := range files {
+		if file.Path != expected[i] {
+			t.Errorf("expected file %s but got %s", expected[i], file.Path)
+		}
+	}
+}
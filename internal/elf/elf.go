@@ -0,0 +1,479 @@
+/*
+ * Copyright 2021 Johannes Donath <johannesd@torchmind.com>
+ * and other copyright owners as documented in the project's IP log.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package elf
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"sort"
+)
+
+const (
+	classELF32 = 1
+	classELF64 = 2
+
+	dataLittleEndian = 1
+	dataBigEndian    = 2
+
+	// identifies section header indices which carry special meaning (e.g. extended numbering)
+	sectionIndexReserved = 0xFF00
+
+	sectionTypeNull     = 0
+	sectionTypeProgBits = 1
+	sectionTypeRel      = 9
+	sectionTypeRela     = 4
+	sectionTypeNoBits   = 8
+
+	sectionFlagsAlloc    = 0x2
+	sectionFlagsInfoLink = 0x40
+)
+
+var ErrMalformed = errors.New("malformed ELF image")
+var ErrUnsupported = errors.New("unsupported ELF image")
+
+// Image provides access to the section headers of an ELF image.
+type Image struct {
+	data  []byte
+	order binary.ByteOrder
+	is64  bool
+}
+
+// Section describes a single section within an image.
+type Section struct {
+	Name      string
+	Type      uint32
+	Flags     uint64
+	Address   uint64
+	Offset    uint64
+	Size      uint64
+	Link      uint32
+	Info      uint32
+	Alignment uint64
+	EntrySize uint64
+
+	// contents of sections which have not yet been written to the image
+	contents []byte
+}
+
+// Parse decodes the headers of a given ELF image.
+//
+// The passed data is referenced by the resulting image until it is modified.
+func Parse(data []byte) (*Image, error) {
+	if len(data) < 0x34 || !bytes.Equal(data[:4], []byte("\x7FELF")) {
+		return nil, fmt.Errorf("%w: missing header", ErrMalformed)
+	}
+
+	img := &Image{data: data}
+	switch data[4] {
+	case classELF32:
+	case classELF64:
+		img.is64 = true
+		if len(data) < 0x40 {
+			return nil, fmt.Errorf("%w: truncated header", ErrMalformed)
+		}
+	default:
+		return nil, fmt.Errorf("%w: unknown class %d", ErrMalformed, data[4])
+	}
+	switch data[5] {
+	case dataLittleEndian:
+		img.order = binary.LittleEndian
+	case dataBigEndian:
+		img.order = binary.BigEndian
+	default:
+		return nil, fmt.Errorf("%w: unknown byte order %d", ErrMalformed, data[5])
+	}
+
+	if img.sectionCount() == 0 || img.sectionCount() >= sectionIndexReserved || img.stringTableIndex() >= img.sectionCount() {
+		return nil, fmt.Errorf("%w: missing or extended section header table", ErrUnsupported)
+	}
+	if img.sectionOffset()+uint64(img.sectionCount())*uint64(img.sectionEntrySize()) > uint64(len(data)) {
+		return nil, fmt.Errorf("%w: truncated section header table", ErrMalformed)
+	}
+	if img.programOffset()+uint64(img.programCount())*uint64(img.programEntrySize()) > uint64(len(data)) {
+		return nil, fmt.Errorf("%w: truncated program header table", ErrMalformed)
+	}
+	if img.sectionEntrySize() < img.expectedSectionEntrySize() {
+		return nil, fmt.Errorf("%w: illegal section header size", ErrMalformed)
+	}
+
+	for _, section := range img.Sections() {
+		if section.Type != sectionTypeNoBits && section.Offset+section.Size > uint64(len(data)) {
+			return nil, fmt.Errorf("%w: section %s exceeds image bounds", ErrMalformed, section.Name)
+		}
+	}
+
+	return img, nil
+}
+
+// reads an address sized field (which spans 32 or 64 bits depending on the image class)
+func (img *Image) word(offset int) uint64 {
+	if img.is64 {
+		return img.order.Uint64(img.data[offset:])
+	}
+
+	return uint64(img.order.Uint32(img.data[offset:]))
+}
+
+// writes an address sized field
+func putWord(data []byte, order binary.ByteOrder, is64 bool, value uint64) {
+	if is64 {
+		order.PutUint64(data, value)
+	} else {
+		order.PutUint32(data, uint32(value))
+	}
+}
+
+func (img *Image) programOffset() uint64 {
+	if img.is64 {
+		return img.word(0x20)
+	}
+	return img.word(0x1C)
+}
+
+func (img *Image) sectionOffset() uint64 {
+	if img.is64 {
+		return img.word(0x28)
+	}
+	return img.word(0x20)
+}
+
+func (img *Image) headerField(offset64 int, offset32 int) int {
+	if img.is64 {
+		return int(img.order.Uint16(img.data[offset64:]))
+	}
+	return int(img.order.Uint16(img.data[offset32:]))
+}
+
+func (img *Image) headerSize() int       { return img.headerField(0x34, 0x28) }
+func (img *Image) programEntrySize() int { return img.headerField(0x36, 0x2A) }
+func (img *Image) programCount() int     { return img.headerField(0x38, 0x2C) }
+func (img *Image) sectionEntrySize() int { return img.headerField(0x3A, 0x2E) }
+func (img *Image) sectionCount() int     { return img.headerField(0x3C, 0x30) }
+func (img *Image) stringTableIndex() int { return img.headerField(0x3E, 0x32) }
+
+func (img *Image) expectedSectionEntrySize() int {
+	if img.is64 {
+		return 64
+	}
+	return 40
+}
+
+// Bytes retrieves the encoded image.
+func (img *Image) Bytes() []byte {
+	return img.data
+}
+
+// Sections retrieves the headers of all sections within the image.
+func (img *Image) Sections() []*Section {
+	sections := make([]*Section, img.sectionCount())
+	for i := range sections {
+		offset := int(img.sectionOffset()) + i*img.sectionEntrySize()
+
+		section := &Section{
+			Type: img.order.Uint32(img.data[offset+4:]),
+		}
+		nameOffset := img.order.Uint32(img.data[offset:])
+		if img.is64 {
+			section.Flags = img.word(offset + 8)
+			section.Address = img.word(offset + 16)
+			section.Offset = img.word(offset + 24)
+			section.Size = img.word(offset + 32)
+			section.Link = img.order.Uint32(img.data[offset+40:])
+			section.Info = img.order.Uint32(img.data[offset+44:])
+			section.Alignment = img.word(offset + 48)
+			section.EntrySize = img.word(offset + 56)
+		} else {
+			section.Flags = img.word(offset + 8)
+			section.Address = img.word(offset + 12)
+			section.Offset = img.word(offset + 16)
+			section.Size = img.word(offset + 20)
+			section.Link = img.order.Uint32(img.data[offset+24:])
+			section.Info = img.order.Uint32(img.data[offset+28:])
+			section.Alignment = img.word(offset + 32)
+			section.EntrySize = img.word(offset + 36)
+		}
+
+		sections[i] = section
+		section.Name = img.sectionName(nameOffset)
+	}
+
+	return sections
+}
+
+// resolves a given offset within the section name table
+func (img *Image) sectionName(offset uint32) string {
+	table := int(img.sectionOffset()) + img.stringTableIndex()*img.sectionEntrySize()
+
+	var start, size uint64
+	if img.is64 {
+		start, size = img.word(table+24), img.word(table+32)
+	} else {
+		start, size = img.word(table+16), img.word(table+20)
+	}
+	if uint64(offset) >= size || start+size > uint64(len(img.data)) {
+		return ""
+	}
+
+	names := img.data[start+uint64(offset) : start+size]
+	if end := bytes.IndexByte(names, 0); end != -1 {
+		names = names[:end]
+	}
+
+	return string(names)
+}
+
+// Section retrieves the header of a section with a given name.
+//
+// Returns nil if no such section exists.
+func (img *Image) Section(name string) *Section {
+	for _, section := range img.Sections() {
+		if section.Name == name {
+			return section
+		}
+	}
+
+	return nil
+}
+
+// AddSection appends a new non-allocated section with a given set of contents to the image.
+//
+// Non-allocated sections are not mapped into memory and are thus not referenced by the program
+// headers. Returns the header of the new section.
+func (img *Image) AddSection(name string, contents []byte) (*Section, error) {
+	if len(name) == 0 || img.Section(name) != nil {
+		return nil, fmt.Errorf("%w: illegal or duplicate section name %q", ErrUnsupported, name)
+	}
+
+	section := &Section{
+		Name:      name,
+		Type:      sectionTypeProgBits,
+		Size:      uint64(len(contents)),
+		Alignment: 1,
+		contents:  contents,
+	}
+
+	sections := append(img.Sections(), section)
+	if err := img.rebuild(sections, img.stringTableIndex()); err != nil {
+		return nil, err
+	}
+
+	return section, nil
+}
+
+// RemoveSection removes a non-allocated section with a given name from the image.
+func (img *Image) RemoveSection(name string) error {
+	sections := img.Sections()
+	stringTable := img.stringTableIndex()
+
+	index := -1
+	for i, section := range sections {
+		if section.Name == name {
+			index = i
+		}
+	}
+	if index <= 0 || index == stringTable {
+		return fmt.Errorf("%w: missing section %s", ErrUnsupported, name)
+	}
+	if sections[index].Flags&sectionFlagsAlloc != 0 {
+		return fmt.Errorf("%w: section %s is allocated", ErrUnsupported, name)
+	}
+
+	// section indices which follow the removed section are shifted accordingly
+	adjust := func(value uint32) (uint32, error) {
+		if value == uint32(index) {
+			return 0, fmt.Errorf("%w: section %s is referenced by another section", ErrUnsupported, name)
+		}
+		if value > uint32(index) && value < sectionIndexReserved {
+			return value - 1, nil
+		}
+		return value, nil
+	}
+
+	sections = append(sections[:index:index], sections[index+1:]...)
+	for _, section := range sections {
+		var err error
+		if section.Link, err = adjust(section.Link); err != nil {
+			return err
+		}
+		if section.Type == sectionTypeRel || section.Type == sectionTypeRela || section.Flags&sectionFlagsInfoLink != 0 {
+			if section.Info, err = adjust(section.Info); err != nil {
+				return err
+			}
+		}
+	}
+	if stringTable > index {
+		stringTable--
+	}
+
+	return img.rebuild(sections, stringTable)
+}
+
+// computes the end of the data which is referenced by the loader (e.g. the headers, segments and
+// allocated sections)
+func (img *Image) loadedEnd() uint64 {
+	end := uint64(img.headerSize())
+	if tableEnd := img.programOffset() + uint64(img.programCount()*img.programEntrySize()); tableEnd > end {
+		end = tableEnd
+	}
+
+	for i := 0; i < img.programCount(); i++ {
+		offset := int(img.programOffset()) + i*img.programEntrySize()
+
+		var segmentOffset, segmentSize uint64
+		if img.is64 {
+			segmentOffset, segmentSize = img.word(offset+8), img.word(offset+32)
+		} else {
+			segmentOffset, segmentSize = img.word(offset+4), img.word(offset+16)
+		}
+		if segmentEnd := segmentOffset + segmentSize; segmentEnd > end {
+			end = segmentEnd
+		}
+	}
+
+	for _, section := range img.Sections() {
+		if section.Flags&sectionFlagsAlloc == 0 || section.Type == sectionTypeNoBits {
+			continue
+		}
+		if sectionEnd := section.Offset + section.Size; sectionEnd > end {
+			end = sectionEnd
+		}
+	}
+
+	return end
+}
+
+// rewrites the image using a given set of sections
+//
+// non-allocated sections which follow the loaded portion of the image are repacked in order of
+// their original offsets (similarly to objcopy) while the section name table and section header
+// table are regenerated and appended to the end of the image.
+func (img *Image) rebuild(sections []*Section, stringTable int) error {
+	base := img.loadedEnd()
+	if base > uint64(len(img.data)) {
+		return fmt.Errorf("%w: segments exceed image bounds", ErrMalformed)
+	}
+
+	data := make([]byte, base)
+	copy(data, img.data)
+
+	names := []byte{0}
+	nameOffsets := make([]uint32, len(sections))
+	for i, section := range sections {
+		if i == 0 {
+			continue
+		}
+
+		nameOffsets[i] = uint32(len(names))
+		names = append(names, section.Name...)
+		names = append(names, 0)
+	}
+
+	movable := make([]*Section, 0)
+	for i, section := range sections {
+		if i == 0 || i == stringTable || section.Type == sectionTypeNull || section.Type == sectionTypeNoBits {
+			continue
+		}
+		if section.contents == nil && (section.Flags&sectionFlagsAlloc != 0 || section.Offset < base) {
+			continue
+		}
+
+		movable = append(movable, section)
+	}
+	sort.SliceStable(movable, func(i, j int) bool {
+		// new sections are appended after all existing sections
+		if (movable[i].contents == nil) != (movable[j].contents == nil) {
+			return movable[i].contents == nil
+		}
+		return movable[i].Offset < movable[j].Offset
+	})
+
+	for _, section := range movable {
+		contents := section.contents
+		if contents == nil {
+			contents = img.data[section.Offset : section.Offset+section.Size]
+		}
+
+		if section.Alignment > 1 {
+			data = append(data, make([]byte, alignPadding(uint64(len(data)), section.Alignment))...)
+		}
+
+		section.Offset = uint64(len(data))
+		data = append(data, contents...)
+	}
+
+	sections[stringTable].Offset = uint64(len(data))
+	sections[stringTable].Size = uint64(len(names))
+	data = append(data, names...)
+
+	entrySize := img.expectedSectionEntrySize()
+	tableAlignment := uint64(4)
+	if img.is64 {
+		tableAlignment = 8
+	}
+	data = append(data, make([]byte, alignPadding(uint64(len(data)), tableAlignment))...)
+
+	tableOffset := uint64(len(data))
+	table := make([]byte, len(sections)*entrySize)
+	for i, section := range sections {
+		entry := table[i*entrySize:]
+		img.order.PutUint32(entry, nameOffsets[i])
+		img.order.PutUint32(entry[4:], section.Type)
+		if img.is64 {
+			putWord(entry[8:], img.order, true, section.Flags)
+			putWord(entry[16:], img.order, true, section.Address)
+			putWord(entry[24:], img.order, true, section.Offset)
+			putWord(entry[32:], img.order, true, section.Size)
+			img.order.PutUint32(entry[40:], section.Link)
+			img.order.PutUint32(entry[44:], section.Info)
+			putWord(entry[48:], img.order, true, section.Alignment)
+			putWord(entry[56:], img.order, true, section.EntrySize)
+		} else {
+			putWord(entry[8:], img.order, false, section.Flags)
+			putWord(entry[12:], img.order, false, section.Address)
+			putWord(entry[16:], img.order, false, section.Offset)
+			putWord(entry[20:], img.order, false, section.Size)
+			img.order.PutUint32(entry[24:], section.Link)
+			img.order.PutUint32(entry[28:], section.Info)
+			putWord(entry[32:], img.order, false, section.Alignment)
+			putWord(entry[36:], img.order, false, section.EntrySize)
+		}
+		section.contents = nil
+	}
+	data = append(data, table...)
+
+	if img.is64 {
+		putWord(data[0x28:], img.order, true, tableOffset)
+		img.order.PutUint16(data[0x3A:], uint16(entrySize))
+		img.order.PutUint16(data[0x3C:], uint16(len(sections)))
+		img.order.PutUint16(data[0x3E:], uint16(stringTable))
+	} else {
+		putWord(data[0x20:], img.order, false, tableOffset)
+		img.order.PutUint16(data[0x2E:], uint16(entrySize))
+		img.order.PutUint16(data[0x30:], uint16(len(sections)))
+		img.order.PutUint16(data[0x32:], uint16(stringTable))
+	}
+
+	img.data = data
+	return nil
+}
+
+// computes the amount of padding required in order to align a given offset
+func alignPadding(offset uint64, alignment uint64) uint64 {
+	return (alignment - offset%alignment) % alignment
+}
@@ -0,0 +1,310 @@
+/*
+ * Copyright 2021 Johannes Donath <johannesd@torchmind.com>
+ * and other copyright owners as documented in the project's IP log.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package elf
+
+import (
+	"bytes"
+	"debug/elf"
+	"encoding/binary"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+)
+
+var testText = bytes.Repeat([]byte{0x90}, 0x10)
+var testSymbols = bytes.Repeat([]byte{0xAB}, 0x30)
+var testStrings = []byte("\x00main\x00")
+var testContents = []byte("canoe payload section contents")
+
+// assembles a minimal executable consisting of a loaded .text section as well as non-allocated
+// .symtab, .strtab and .shstrtab sections
+func testImage(class elf.Class, order binary.ByteOrder) []byte {
+	const textOffset = 0x100
+	const loadSize = 0x1000
+
+	names := []byte("\x00.text\x00.symtab\x00.strtab\x00.shstrtab\x00")
+	type section struct {
+		name      uint32
+		typ       elf.SectionType
+		flags     elf.SectionFlag
+		offset    uint64
+		size      uint64
+		link      uint32
+		alignment uint64
+		entrySize uint64
+	}
+
+	data := make([]byte, loadSize)
+	copy(data[textOffset:], testText)
+	sections := []section{
+		{},
+		{name: 1, typ: elf.SHT_PROGBITS, flags: elf.SHF_ALLOC | elf.SHF_EXECINSTR, offset: textOffset, size: uint64(len(testText)), alignment: 16},
+		{name: 7, typ: elf.SHT_SYMTAB, offset: uint64(len(data)), size: uint64(len(testSymbols)), link: 3, alignment: 8, entrySize: 0x18},
+	}
+	data = append(data, testSymbols...)
+	sections = append(sections, section{name: 15, typ: elf.SHT_STRTAB, offset: uint64(len(data)), size: uint64(len(testStrings)), alignment: 1})
+	data = append(data, testStrings...)
+	sections = append(sections, section{name: 23, typ: elf.SHT_STRTAB, offset: uint64(len(data)), size: uint64(len(names)), alignment: 1})
+	data = append(data, names...)
+	for len(data)%8 != 0 {
+		data = append(data, 0)
+	}
+	tableOffset := len(data)
+
+	table := &bytes.Buffer{}
+	header := &bytes.Buffer{}
+	ident := [elf.EI_NIDENT]byte{0x7F, 'E', 'L', 'F', byte(class), byte(elf.ELFDATA2LSB), byte(elf.EV_CURRENT)}
+	if order == binary.BigEndian {
+		ident[elf.EI_DATA] = byte(elf.ELFDATA2MSB)
+	}
+
+	if class == elf.ELFCLASS64 {
+		for _, s := range sections {
+			_ = binary.Write(table, order, elf.Section64{Name: s.name, Type: uint32(s.typ), Flags: uint64(s.flags), Off: s.offset, Size: s.size, Link: s.link, Addralign: s.alignment, Entsize: s.entrySize})
+		}
+		_ = binary.Write(header, order, elf.Header64{
+			Ident: ident, Type: uint16(elf.ET_EXEC), Machine: uint16(elf.EM_X86_64), Version: uint32(elf.EV_CURRENT),
+			Entry: 0x400000 + textOffset, Phoff: 64, Shoff: uint64(tableOffset), Ehsize: 64,
+			Phentsize: 56, Phnum: 1, Shentsize: 64, Shnum: uint16(len(sections)), Shstrndx: 4,
+		})
+		_ = binary.Write(header, order, elf.Prog64{Type: uint32(elf.PT_LOAD), Flags: uint32(elf.PF_R | elf.PF_X), Vaddr: 0x400000, Paddr: 0x400000, Filesz: loadSize, Memsz: loadSize, Align: 0x1000})
+	} else {
+		for _, s := range sections {
+			_ = binary.Write(table, order, elf.Section32{Name: s.name, Type: uint32(s.typ), Flags: uint32(s.flags), Off: uint32(s.offset), Size: uint32(s.size), Link: s.link, Addralign: uint32(s.alignment), Entsize: uint32(s.entrySize)})
+		}
+		_ = binary.Write(header, order, elf.Header32{
+			Ident: ident, Type: uint16(elf.ET_EXEC), Machine: uint16(elf.EM_386), Version: uint32(elf.EV_CURRENT),
+			Entry: 0x400000 + textOffset, Phoff: 52, Shoff: uint32(tableOffset), Ehsize: 52,
+			Phentsize: 32, Phnum: 1, Shentsize: 40, Shnum: uint16(len(sections)), Shstrndx: 4,
+		})
+		_ = binary.Write(header, order, elf.Prog32{Type: uint32(elf.PT_LOAD), Flags: uint32(elf.PF_R | elf.PF_X), Vaddr: 0x400000, Paddr: 0x400000, Filesz: loadSize, Memsz: loadSize, Align: 0x1000})
+	}
+
+	copy(data, header.Bytes())
+	return append(data, table.Bytes()...)
+}
+
+// verifies that the contents of the sections within a given image have been retained
+func verifyImage(t *testing.T, data []byte, expected map[string][]byte) *elf.File {
+	f, err := elf.NewFile(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("failed to parse image: %s", err)
+	}
+
+	for name, contents := range expected {
+		section := f.Section(name)
+		if section == nil {
+			t.Errorf("expected section %s", name)
+			continue
+		}
+
+		actual, err := section.Data()
+		if err != nil {
+			t.Errorf("failed to read section %s: %s", name, err)
+			continue
+		}
+		if !bytes.Equal(actual, contents) {
+			t.Errorf("unexpected contents within section %s: %x", name, actual)
+		}
+	}
+
+	if symtab := f.Section(".symtab"); symtab != nil && f.Sections[symtab.Link].Name != ".strtab" {
+		t.Errorf("expected .symtab to link .strtab but got %s", f.Sections[symtab.Link].Name)
+	}
+
+	return f
+}
+
+var testFormats = []struct {
+	name  string
+	class elf.Class
+	order binary.ByteOrder
+}{
+	{"elf64-le", elf.ELFCLASS64, binary.LittleEndian},
+	{"elf64-be", elf.ELFCLASS64, binary.BigEndian},
+	{"elf32-le", elf.ELFCLASS32, binary.LittleEndian},
+	{"elf32-be", elf.ELFCLASS32, binary.BigEndian},
+}
+
+func TestAddSection(t *testing.T) {
+	for _, format := range testFormats {
+		t.Run(format.name, func(t *testing.T) {
+			img, err := Parse(testImage(format.class, format.order))
+			if err != nil {
+				t.Fatalf("failed to parse image: %s", err)
+			}
+
+			section, err := img.AddSection(".canoe", testContents)
+			if err != nil {
+				t.Fatalf("failed to add section: %s", err)
+			}
+			if !bytes.Equal(img.Bytes()[section.Offset:section.Offset+section.Size], testContents) {
+				t.Errorf("unexpected contents at reported section offset %d", section.Offset)
+			}
+
+			f := verifyImage(t, img.Bytes(), map[string][]byte{
+				".text":   testText,
+				".symtab": testSymbols,
+				".strtab": testStrings,
+				".canoe":  testContents,
+			})
+			if canoe := f.Section(".canoe"); canoe.Flags&elf.SHF_ALLOC != 0 || canoe.Offset != section.Offset {
+				t.Errorf("unexpected section header %+v", canoe.SectionHeader)
+			}
+			if len(f.Progs) != 1 || f.Progs[0].Off != 0 || f.Progs[0].Filesz != 0x1000 {
+				t.Errorf("expected program headers to be retained")
+			}
+
+			if _, err := img.AddSection(".canoe", testContents); err == nil {
+				t.Errorf("expected duplicate section to be rejected")
+			}
+		})
+	}
+}
+
+func TestRemoveSection(t *testing.T) {
+	for _, format := range testFormats {
+		t.Run(format.name, func(t *testing.T) {
+			original := testImage(format.class, format.order)
+			img, err := Parse(original)
+			if err != nil {
+				t.Fatalf("failed to parse image: %s", err)
+			}
+			if _, err := img.AddSection(".canoe", testContents); err != nil {
+				t.Fatalf("failed to add section: %s", err)
+			}
+
+			if err := img.RemoveSection(".canoe"); err != nil {
+				t.Fatalf("failed to remove section: %s", err)
+			}
+			if !bytes.Equal(img.Bytes(), original) {
+				t.Errorf("expected original image to be restored")
+			}
+
+			if err := img.RemoveSection(".text"); !errors.Is(err, ErrUnsupported) {
+				t.Errorf("expected allocated section to be retained but got %v", err)
+			}
+			if err := img.RemoveSection(".strtab"); !errors.Is(err, ErrUnsupported) {
+				t.Errorf("expected linked section to be retained but got %v", err)
+			}
+		})
+	}
+}
+
+// verifies that sections survive rewriting of the non-allocated portion of the image similarly to
+// strip and objcopy
+func TestRelocateSection(t *testing.T) {
+	img, err := Parse(testImage(elf.ELFCLASS64, binary.LittleEndian))
+	if err != nil {
+		t.Fatalf("failed to parse image: %s", err)
+	}
+	added, err := img.AddSection(".canoe", testContents)
+	if err != nil {
+		t.Fatalf("failed to add section: %s", err)
+	}
+
+	// removing the symbol table shifts all subsequent sections towards the beginning of the file
+	if err := img.RemoveSection(".symtab"); err != nil {
+		t.Fatalf("failed to remove section: %s", err)
+	}
+	if err := img.RemoveSection(".strtab"); err != nil {
+		t.Fatalf("failed to remove section: %s", err)
+	}
+	if _, err := img.AddSection(".comment", []byte("GCC: (GNU) 11.2.0\x00")); err != nil {
+		t.Fatalf("failed to add section: %s", err)
+	}
+
+	f := verifyImage(t, img.Bytes(), map[string][]byte{
+		".text":  testText,
+		".canoe": testContents,
+	})
+	if f.Section(".canoe").Offset == added.Offset {
+		t.Errorf("expected section to be relocated")
+	}
+}
+
+// verifies that sections survive actual invocations of strip and objcopy (when available)
+func TestObjcopy(t *testing.T) {
+	if _, err := exec.LookPath("objcopy"); err != nil {
+		t.Skip("objcopy is not available")
+	}
+
+	img, err := Parse(testImage(elf.ELFCLASS64, binary.LittleEndian))
+	if err != nil {
+		t.Fatalf("failed to parse image: %s", err)
+	}
+	if _, err := img.AddSection(".canoe", testContents); err != nil {
+		t.Fatalf("failed to add section: %s", err)
+	}
+
+	dir := t.TempDir()
+	input := filepath.Join(dir, "input")
+	output := filepath.Join(dir, "output")
+	comment := filepath.Join(dir, "comment")
+	if err := os.WriteFile(input, img.Bytes(), 0755); err != nil {
+		t.Fatalf("failed to write image: %s", err)
+	}
+	if err := os.WriteFile(comment, []byte("GCC: (GNU) 11.2.0\x00"), 0644); err != nil {
+		t.Fatalf("failed to write comment: %s", err)
+	}
+
+	for _, args := range [][]string{
+		{"--strip-all", input, output},
+		{"--add-section", ".comment=" + comment, "--set-section-alignment", ".comment=64", output},
+	} {
+		if out, err := exec.Command("objcopy", args...).CombinedOutput(); err != nil {
+			t.Fatalf("objcopy %v failed: %s: %s", args, err, out)
+		}
+	}
+
+	data, err := os.ReadFile(output)
+	if err != nil {
+		t.Fatalf("failed to read image: %s", err)
+	}
+
+	verifyImage(t, data, map[string][]byte{
+		".text":  testText,
+		".canoe": testContents,
+	})
+
+	img, err = Parse(data)
+	if err != nil {
+		t.Fatalf("failed to parse rewritten image: %s", err)
+	}
+	if err := img.RemoveSection(".canoe"); err != nil {
+		t.Fatalf("failed to remove section from rewritten image: %s", err)
+	}
+	if bytes.Contains(img.Bytes(), testContents) {
+		t.Errorf("expected section contents to be removed")
+	}
+}
+
+func TestParseMalformed(t *testing.T) {
+	data := testImage(elf.ELFCLASS64, binary.LittleEndian)
+
+	for name, malformed := range map[string][]byte{
+		"empty":     nil,
+		"magic":     append([]byte("\x7FELG"), data[4:]...),
+		"truncated": data[:len(data)-1],
+	} {
+		if _, err := Parse(malformed); err == nil {
+			t.Errorf("%s: expected parsing to fail", name)
+		}
+	}
+}
@@ -0,0 +1,38 @@
+//go:build darwin
+
+/*
+ * Copyright 2021 Johannes Donath <johannesd@torchmind.com>
+ * and other copyright owners as documented in the project's IP log.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package internal
+
+import (
+	"os"
+	"os/exec"
+	"syscall"
+)
+
+// replaces the current process with a given command where required by the platform
+//
+// executables within application bundles are replaced by the runtime in order to permit the
+// application to receive Apple Events (such as requests to open documents) which are delivered to
+// the bundle process. Returns nil when the command is to be executed as a child process instead.
+func replaceProcess(executable string, cmd *exec.Cmd) error {
+	if !IsBundleExecutable(executable) || cmd.Err != nil {
+		return nil
+	}
+
+	return syscall.Exec(cmd.Path, cmd.Args, os.Environ())
+}
@@ -0,0 +1,77 @@
+/*
+ * Copyright 2021 Johannes Donath <johannesd@torchmind.com>
+ * and other copyright owners as documented in the project's IP log.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package icon
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+)
+
+// identifies the ICNS element types which carry PNG encoded images along with their respective
+// pixel sizes (high resolution variants are stored at twice their nominal size)
+var icnsTypes = []struct {
+	Type string
+	Size int
+}{
+	{"icp4", 16},
+	{"icp5", 32},
+	{"ic11", 32},
+	{"ic12", 64},
+	{"ic07", 128},
+	{"ic13", 256},
+	{"ic08", 256},
+	{"ic14", 512},
+	{"ic09", 512},
+	{"ic10", 1024},
+}
+
+// IsICNS identifies whether a given file is an ICNS file.
+func IsICNS(data []byte) bool {
+	return len(data) >= 8 && bytes.Equal(data[:4], []byte("icns"))
+}
+
+// EncodeICNS scales a given image to each of the sizes requested by Mac OS and encodes the results
+// as an ICNS file.
+func EncodeICNS(src image.Image) ([]byte, error) {
+	encoded := make(map[int][]byte)
+
+	elements := &bytes.Buffer{}
+	for _, element := range icnsTypes {
+		data, ok := encoded[element.Size]
+		if !ok {
+			var err error
+			data, err = EncodePNG(src, element.Size)
+			if err != nil {
+				return nil, err
+			}
+
+			encoded[element.Size] = data
+		}
+
+		elements.WriteString(element.Type)
+		_ = binary.Write(elements, binary.BigEndian, uint32(8+len(data)))
+		elements.Write(data)
+	}
+
+	buf := &bytes.Buffer{}
+	buf.WriteString("icns")
+	_ = binary.Write(buf, binary.BigEndian, uint32(8+elements.Len()))
+	buf.Write(elements.Bytes())
+
+	return buf.Bytes(), nil
+}
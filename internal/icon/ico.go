@@ -0,0 +1,215 @@
+/*
+ * Copyright 2021 Johannes Donath <johannesd@torchmind.com>
+ * and other copyright owners as documented in the project's IP log.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package icon
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"image"
+)
+
+// WindowsSizes lists the icon sizes which are commonly requested by Windows.
+var WindowsSizes = []int{16, 24, 32, 48, 64, 256}
+
+// identifies the minimum size at which images are stored in PNG format (smaller images are stored
+// as bitmaps in order to retain compatibility with legacy consumers)
+const pngThreshold = 256
+
+// Image represents a single image within an ICO file.
+type Image struct {
+	Width      int
+	Height     int
+	ColorCount uint8
+	Planes     uint16
+	BitCount   uint16
+
+	// Data contains the encoded image (either a PNG image or a device independent bitmap)
+	Data []byte
+}
+
+type iconDirectory struct {
+	Reserved uint16
+	Type     uint16
+	Count    uint16
+}
+
+type iconDirectoryEntry struct {
+	Width      uint8
+	Height     uint8
+	ColorCount uint8
+	Reserved   uint8
+	Planes     uint16
+	BitCount   uint16
+	Size       uint32
+	Offset     uint32
+}
+
+// IsICO identifies whether a given file is an ICO file.
+func IsICO(data []byte) bool {
+	return len(data) >= 6 && bytes.Equal(data[:4], []byte{0, 0, 1, 0})
+}
+
+// ParseICO splits a given ICO file into its individual images.
+func ParseICO(data []byte) ([]*Image, error) {
+	r := bytes.NewReader(data)
+
+	var dir iconDirectory
+	if err := binary.Read(r, binary.LittleEndian, &dir); err != nil || dir.Type != 1 {
+		return nil, fmt.Errorf("%w: malformed ICO header", ErrInvalidImage)
+	}
+	if dir.Count == 0 {
+		return nil, fmt.Errorf("%w: ICO file contains no images", ErrInvalidImage)
+	}
+
+	images := make([]*Image, dir.Count)
+	for i := range images {
+		var entry iconDirectoryEntry
+		if err := binary.Read(r, binary.LittleEndian, &entry); err != nil {
+			return nil, fmt.Errorf("%w: malformed ICO directory", ErrInvalidImage)
+		}
+		if uint64(entry.Offset)+uint64(entry.Size) > uint64(len(data)) {
+			return nil, fmt.Errorf("%w: image %d exceeds ICO file", ErrInvalidImage, i)
+		}
+
+		images[i] = &Image{
+			Width:      dimension(entry.Width),
+			Height:     dimension(entry.Height),
+			ColorCount: entry.ColorCount,
+			Planes:     entry.Planes,
+			BitCount:   entry.BitCount,
+			Data:       data[entry.Offset : entry.Offset+entry.Size],
+		}
+	}
+
+	return images, nil
+}
+
+// converts an ICO dimension into its actual value (zero indicates a dimension of 256 pixels)
+func dimension(value uint8) int {
+	if value == 0 {
+		return 256
+	}
+
+	return int(value)
+}
+
+// EncodeImages scales a given image to each of the given sizes and encodes the results for use
+// within an ICO file.
+func EncodeImages(src image.Image, sizes []int) ([]*Image, error) {
+	images := make([]*Image, len(sizes))
+	for i, size := range sizes {
+		var data []byte
+		if size >= pngThreshold {
+			var err error
+			data, err = EncodePNG(src, size)
+			if err != nil {
+				return nil, err
+			}
+		} else {
+			data = encodeBitmap(Scale(src, size))
+		}
+
+		images[i] = &Image{
+			Width:    size,
+			Height:   size,
+			Planes:   1,
+			BitCount: 32,
+			Data:     data,
+		}
+	}
+
+	return images, nil
+}
+
+// EncodeICO encodes a given set of images as an ICO file.
+func EncodeICO(images []*Image) []byte {
+	buf := &bytes.Buffer{}
+	_ = binary.Write(buf, binary.LittleEndian, iconDirectory{Type: 1, Count: uint16(len(images))})
+
+	offset := 6 + 16*len(images)
+	for _, img := range images {
+		_ = binary.Write(buf, binary.LittleEndian, iconDirectoryEntry{
+			Width:      uint8(img.Width),
+			Height:     uint8(img.Height),
+			ColorCount: img.ColorCount,
+			Planes:     img.Planes,
+			BitCount:   img.BitCount,
+			Size:       uint32(len(img.Data)),
+			Offset:     uint32(offset),
+		})
+
+		offset += len(img.Data)
+	}
+
+	for _, img := range images {
+		buf.Write(img.Data)
+	}
+
+	return buf.Bytes()
+}
+
+// encodes a given image as a 32-bit device independent bitmap as expected within ICO files
+//
+// icon bitmaps consist of the color data (stored bottom-up) followed by a monochrome transparency
+// mask. Their header thus declares twice the actual image height.
+func encodeBitmap(img *image.NRGBA) []byte {
+	width := img.Bounds().Dx()
+	height := img.Bounds().Dy()
+	maskStride := (width + 31) / 32 * 4
+
+	buf := &bytes.Buffer{}
+	_ = binary.Write(buf, binary.LittleEndian, struct {
+		Size          uint32
+		Width         int32
+		Height        int32
+		Planes        uint16
+		BitCount      uint16
+		Compression   uint32
+		SizeImage     uint32
+		XPelsPerMeter int32
+		YPelsPerMeter int32
+		ClrUsed       uint32
+		ClrImportant  uint32
+	}{
+		Size:      40,
+		Width:     int32(width),
+		Height:    int32(height * 2),
+		Planes:    1,
+		BitCount:  32,
+		SizeImage: uint32(width*height*4 + maskStride*height),
+	})
+
+	for y := height - 1; y >= 0; y-- {
+		for x := 0; x < width; x++ {
+			c := img.NRGBAAt(x, y)
+			buf.Write([]byte{c.B, c.G, c.R, c.A})
+		}
+	}
+
+	for y := height - 1; y >= 0; y-- {
+		row := make([]byte, maskStride)
+		for x := 0; x < width; x++ {
+			if img.NRGBAAt(x, y).A == 0 {
+				row[x/8] |= 0x80 >> (x % 8)
+			}
+		}
+		buf.Write(row)
+	}
+
+	return buf.Bytes()
+}
@@ -0,0 +1,139 @@
+/*
+ * Copyright 2021 Johannes Donath <johannesd@torchmind.com>
+ * and other copyright owners as documented in the project's IP log.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package icon
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"image"
+	"image/color"
+	"image/png"
+	"testing"
+)
+
+// creates a square test image which is split into an opaque red and a transparent half
+func testImage(size int) *image.NRGBA {
+	img := image.NewNRGBA(image.Rect(0, 0, size, size))
+	for y := 0; y < size; y++ {
+		for x := 0; x < size/2; x++ {
+			img.SetNRGBA(x, y, color.NRGBA{R: 0xFF, A: 0xFF})
+		}
+	}
+
+	return img
+}
+
+func TestScale(t *testing.T) {
+	scaled := Scale(testImage(300), 16)
+	if scaled.Bounds().Dx() != 16 || scaled.Bounds().Dy() != 16 {
+		t.Fatalf("expected 16x16 image but got %v", scaled.Bounds())
+	}
+
+	if c := scaled.NRGBAAt(0, 0); c != (color.NRGBA{R: 0xFF, A: 0xFF}) {
+		t.Errorf("expected opaque red pixel but got %v", c)
+	}
+	if c := scaled.NRGBAAt(15, 15); c.A != 0 {
+		t.Errorf("expected transparent pixel but got %v", c)
+	}
+}
+
+func TestEncodeICO(t *testing.T) {
+	images, err := EncodeImages(testImage(300), WindowsSizes)
+	if err != nil {
+		t.Fatalf("failed to encode images: %s", err)
+	}
+
+	encoded := EncodeICO(images)
+	if !IsICO(encoded) {
+		t.Fatalf("expected encoded file to be recognized as ICO")
+	}
+
+	decoded, err := ParseICO(encoded)
+	if err != nil {
+		t.Fatalf("failed to parse ICO: %s", err)
+	}
+	if len(decoded) != len(WindowsSizes) {
+		t.Fatalf("expected %d images but got %d", len(WindowsSizes), len(decoded))
+	}
+
+	for i, img := range decoded {
+		if img.Width != WindowsSizes[i] || img.Height != WindowsSizes[i] {
+			t.Errorf("expected image %d to be %dpx but got %dx%d", i, WindowsSizes[i], img.Width, img.Height)
+		}
+		if !bytes.Equal(img.Data, images[i].Data) {
+			t.Errorf("expected image %d data to be retained", i)
+		}
+	}
+
+	// large images are stored as PNG while smaller images rely on bitmaps
+	large, err := png.Decode(bytes.NewReader(decoded[len(decoded)-1].Data))
+	if err != nil {
+		t.Fatalf("failed to decode 256px image: %s", err)
+	}
+	if large.Bounds().Dx() != 256 {
+		t.Errorf("expected 256px image but got %v", large.Bounds())
+	}
+	if size := len(decoded[0].Data); size != 40+16*16*4+4*16 {
+		t.Errorf("expected 16px bitmap of %d bytes but got %d", 40+16*16*4+4*16, size)
+	}
+}
+
+func TestParseICOMalformed(t *testing.T) {
+	if _, err := ParseICO([]byte{0, 0, 1, 0, 1, 0, 16, 16}); !errors.Is(err, ErrInvalidImage) {
+		t.Errorf("expected truncated ICO to be rejected but got %v", err)
+	}
+}
+
+func TestEncodeICNS(t *testing.T) {
+	encoded, err := EncodeICNS(testImage(300))
+	if err != nil {
+		t.Fatalf("failed to encode ICNS: %s", err)
+	}
+	if !IsICNS(encoded) {
+		t.Fatalf("expected ICNS header")
+	}
+	if length := binary.BigEndian.Uint32(encoded[4:]); int(length) != len(encoded) {
+		t.Errorf("expected length %d but got %d", len(encoded), length)
+	}
+
+	sizes := make(map[string]int)
+	for offset := 8; offset < len(encoded); {
+		typ := string(encoded[offset : offset+4])
+		length := int(binary.BigEndian.Uint32(encoded[offset+4:]))
+		if length < 8 || offset+length > len(encoded) {
+			t.Fatalf("malformed element %s at offset %d", typ, offset)
+		}
+
+		cfg, err := png.DecodeConfig(bytes.NewReader(encoded[offset+8 : offset+length]))
+		if err != nil {
+			t.Fatalf("element %s: failed to decode PNG: %s", typ, err)
+		}
+		if cfg.Width != cfg.Height {
+			t.Errorf("element %s: expected square image but got %dx%d", typ, cfg.Width, cfg.Height)
+		}
+
+		sizes[typ] = cfg.Width
+		offset += length
+	}
+
+	for typ, size := range map[string]int{"icp4": 16, "ic11": 32, "ic07": 128, "ic14": 512, "ic10": 1024} {
+		if sizes[typ] != size {
+			t.Errorf("expected element %s to be %d pixels but got %d", typ, size, sizes[typ])
+		}
+	}
+}
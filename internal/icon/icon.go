@@ -0,0 +1,110 @@
+/*
+ * Copyright 2021 Johannes Donath <johannesd@torchmind.com>
+ * and other copyright owners as documented in the project's IP log.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package icon
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+)
+
+var ErrInvalidImage = errors.New("invalid icon image")
+
+// Decode decodes a given PNG encoded icon image.
+func Decode(data []byte) (image.Image, error) {
+	img, err := png.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidImage, err)
+	}
+
+	return img, nil
+}
+
+// Scale resizes a given image to a square of the given size.
+//
+// Images are downscaled by averaging all source pixels which contribute to a given target pixel
+// (weighted by their alpha channel) while upscaling relies on nearest neighbour sampling.
+func Scale(src image.Image, size int) *image.NRGBA {
+	bounds := src.Bounds()
+	if bounds.Dx() == size && bounds.Dy() == size {
+		dst := image.NewNRGBA(image.Rect(0, 0, size, size))
+		draw.Draw(dst, dst.Bounds(), src, bounds.Min, draw.Src)
+		return dst
+	}
+
+	dst := image.NewNRGBA(image.Rect(0, 0, size, size))
+	for y := 0; y < size; y++ {
+		y0 := bounds.Min.Y + y*bounds.Dy()/size
+		y1 := bounds.Min.Y + (y+1)*bounds.Dy()/size
+		if y1 <= y0 {
+			y1 = y0 + 1
+		}
+
+		for x := 0; x < size; x++ {
+			x0 := bounds.Min.X + x*bounds.Dx()/size
+			x1 := bounds.Min.X + (x+1)*bounds.Dx()/size
+			if x1 <= x0 {
+				x1 = x0 + 1
+			}
+
+			dst.SetNRGBA(x, y, average(src, x0, y0, x1, y1))
+		}
+	}
+
+	return dst
+}
+
+// computes the average color of a given region within an image
+func average(src image.Image, x0 int, y0 int, x1 int, y1 int) color.NRGBA {
+	var r, g, b, a, count uint64
+	for y := y0; y < y1; y++ {
+		for x := x0; x < x1; x++ {
+			// RGBA returns alpha premultiplied values which are weighted by their opacity
+			pr, pg, pb, pa := src.At(x, y).RGBA()
+			r += uint64(pr)
+			g += uint64(pg)
+			b += uint64(pb)
+			a += uint64(pa)
+			count++
+		}
+	}
+
+	if a == 0 {
+		return color.NRGBA{}
+	}
+
+	return color.NRGBA{
+		R: uint8(r * 0xFF / a),
+		G: uint8(g * 0xFF / a),
+		B: uint8(b * 0xFF / a),
+		A: uint8(a / count >> 8),
+	}
+}
+
+// EncodePNG scales a given image to a given size and encodes it as PNG.
+func EncodePNG(src image.Image, size int) ([]byte, error) {
+	buf := &bytes.Buffer{}
+	if err := png.Encode(buf, Scale(src, size)); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,484 @@
+/*
+ * Copyright 2021 Johannes Donath <johannesd@torchmind.com>
+ * and other copyright owners as documented in the project's IP log.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package internal
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/dotstart/canoe/internal/desktop"
+	"github.com/dotstart/canoe/internal/metadata"
+	"image"
+	"image/png"
+	"os"
+	"os/exec"
+	"path/filepath"
+	goruntime "runtime"
+	"sort"
+	"strings"
+)
+
+// identifies the reserved arguments which install or uninstall the executable instead of launching
+// the application (only recognized as the first argument)
+const (
+	InstallArgument   = "--canoe-install"
+	UninstallArgument = "--canoe-uninstall"
+
+	userScopeArgument   = "--user"
+	systemScopeArgument = "--system"
+)
+
+// identifies the revision of the install manifest format
+const installManifestVersion = 1
+
+var ErrNotInstalled = errors.New("application is not installed")
+var ErrInstallConflict = errors.New("refusing to replace file which has not been installed by this application")
+var ErrInstallUnsupported = errors.New("self-installation is not supported on this platform")
+var ErrInstallClassPath = errors.New("application references class path entries relative to the executable")
+
+// InstallScope identifies whether an application is installed for the current user or for all users
+// of the system.
+type InstallScope int
+
+const (
+	UserScope InstallScope = iota
+	SystemScope
+)
+
+// installLayout describes the directories to which applications are installed within a given scope.
+type installLayout struct {
+	binDir  string
+	dataDir string
+}
+
+// installManifest records the files and directories which have been created by an installation.
+type installManifest struct {
+	Version     int      `json:"version"`
+	Identifier  string   `json:"identifier"`
+	Executable  string   `json:"executable"`
+	Files       []string `json:"files"`
+	Directories []string `json:"directories"`
+}
+
+// IsInstallCommand identifies whether a given set of application arguments requests the
+// installation or removal of the executable.
+func IsInstallCommand(args []string) bool {
+	return len(args) != 0 && (args[0] == InstallArgument || args[0] == UninstallArgument)
+}
+
+// RunInstallCommand installs or uninstalls a given executable as requested by the passed
+// arguments and returns the resulting exit code.
+//
+// Executables are copied to ~/.local/bin (--user; default) or /usr/local/bin (--system) along with
+// a desktop entry, icons and MIME type declarations generated from their embedded metadata. An
+// install manifest permits the removal of exactly these files via --canoe-uninstall. Applications
+// which reference class path entries relative to the executable cannot be installed.
+func RunInstallCommand(executable string, args []string) int {
+	scope := UserScope
+	explicitScope := false
+	for _, arg := range args[1:] {
+		switch arg {
+		case userScopeArgument:
+			scope = UserScope
+		case systemScopeArgument:
+			scope = SystemScope
+		default:
+			_, _ = fmt.Fprintf(os.Stderr, "invalid argument: %s (expected %s or %s)\n", arg, userScopeArgument, systemScopeArgument)
+			return 2
+		}
+
+		explicitScope = true
+	}
+
+	footer, meta, err := ReadExecutableContainer(executable)
+	if err != nil {
+		_, _ = fmt.Fprintf(os.Stderr, "failed to read executable: %s\n", err)
+		return 1
+	}
+
+	if args[0] == UninstallArgument {
+		// installations for the current user take precedence unless a scope is given explicitly
+		if !explicitScope {
+			if layout, err := layoutFor(UserScope); err == nil {
+				if _, err := readInstallManifest(layout, installIdentifier(meta, executable)); errors.Is(err, ErrNotInstalled) {
+					scope = SystemScope
+				}
+			}
+		}
+
+		if err := Uninstall(executable, meta, scope); err != nil {
+			_, _ = fmt.Fprintf(os.Stderr, "failed to uninstall application: %s\n", err)
+			return 1
+		}
+
+		fmt.Println("application has been uninstalled")
+		return 0
+	}
+
+	// damaged executables are not installed as they would otherwise fail to launch later on
+	if err := VerifyExecutableSignature(executable, footer, meta); err != nil {
+		_, _ = fmt.Fprintf(os.Stderr, "refusing to install executable: %s\n", err)
+		return 1
+	}
+	if err := VerifyIntegrity(executable, footer, meta); err != nil {
+		_, _ = fmt.Fprintf(os.Stderr, "refusing to install executable: %s\n", err)
+		return 1
+	}
+
+	installed, err := Install(executable, meta, scope)
+	if err != nil {
+		_, _ = fmt.Fprintf(os.Stderr, "failed to install application: %s\n", err)
+		return 1
+	}
+
+	fmt.Printf("application has been installed to %s\n", installed)
+	if !isInSearchPath(filepath.Dir(installed)) {
+		fmt.Printf("note: %s is not within the search path (PATH) and may thus only be launched via its full path or desktop entry\n", filepath.Dir(installed))
+	}
+
+	return 0
+}
+
+// Install installs a given executable within the specified scope and returns the path of the
+// installed executable.
+//
+// Previous installations of the same application are replaced.
+func Install(executable string, meta *metadata.ApplicationContainer, scope InstallScope) (string, error) {
+	layout, err := layoutFor(scope)
+	if err != nil {
+		return "", err
+	}
+
+	manifest, err := install(layout, executable, meta)
+	if err != nil {
+		return "", err
+	}
+
+	updateDesktopDatabases(layout)
+	return manifest.Executable, nil
+}
+
+// Uninstall removes all files which have been installed for the application embedded within a
+// given executable within the specified scope.
+func Uninstall(executable string, meta *metadata.ApplicationContainer, scope InstallScope) error {
+	layout, err := layoutFor(scope)
+	if err != nil {
+		return err
+	}
+
+	manifest, err := uninstallFiles(layout, installIdentifier(meta, executable))
+	if err != nil {
+		return err
+	}
+
+	// caches are refreshed prior to removing the directories of the installation as the tools
+	// expect their source directories to be present
+	updateDesktopDatabases(layout)
+	uninstallDirectories(manifest)
+	return nil
+}
+
+// resolves the install directories of a given scope
+func layoutFor(scope InstallScope) (*installLayout, error) {
+	if goruntime.GOOS == "windows" || goruntime.GOOS == "darwin" {
+		return nil, ErrInstallUnsupported
+	}
+
+	if scope == SystemScope {
+		return &installLayout{
+			binDir:  "/usr/local/bin",
+			dataDir: "/usr/local/share",
+		}, nil
+	}
+
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return nil, fmt.Errorf("failed to locate home directory: %w", err)
+	}
+
+	dataDir := os.Getenv("XDG_DATA_HOME")
+	if !filepath.IsAbs(dataDir) {
+		dataDir = filepath.Join(home, ".local", "share")
+	}
+
+	return &installLayout{
+		binDir:  filepath.Join(home, ".local", "bin"),
+		dataDir: dataDir,
+	}, nil
+}
+
+// computes the name of the installed executable for a given application
+func installName(meta *metadata.ApplicationContainer, executable string) string {
+	name := desktop.SanitizeName(meta.GetIdentity().GetName())
+	if len(name) == 0 {
+		name = filepath.Base(executable)
+	}
+
+	return name
+}
+
+// computes the identifier under which a given application is installed
+func installIdentifier(meta *metadata.ApplicationContainer, executable string) string {
+	return desktop.ID(meta, installName(meta, executable))
+}
+
+// locates the install manifest of a given application within a layout
+func (l *installLayout) manifestPath(id string) string {
+	return filepath.Join(l.dataDir, "canoe", "installs", id+".json")
+}
+
+// installs a given executable along with its desktop integration files to a given layout
+func install(layout *installLayout, executable string, meta *metadata.ApplicationContainer) (*installManifest, error) {
+	// only the executable itself is installed thus breaking references to files next to it
+	if entries := RelativeClassPath(meta); len(entries) != 0 {
+		return nil, fmt.Errorf("%w: %s", ErrInstallClassPath, strings.Join(entries, ", "))
+	}
+
+	id := installIdentifier(meta, executable)
+
+	previous, err := readInstallManifest(layout, id)
+	if err != nil && !errors.Is(err, ErrNotInstalled) {
+		return nil, err
+	}
+	if previous == nil {
+		previous = &installManifest{}
+	}
+
+	data, err := os.ReadFile(executable)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read executable: %w", err)
+	}
+
+	var src image.Image
+	if iconData := meta.GetDesktop().GetIcon(); len(iconData) != 0 {
+		src, err = png.Decode(bytes.NewReader(iconData))
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode embedded icon: %w", err)
+		}
+	}
+
+	installed := filepath.Join(layout.binDir, installName(meta, executable))
+	desktopFiles, err := desktop.Files(meta, id, installed, src)
+	if err != nil {
+		return nil, err
+	}
+
+	files := map[string][]byte{installed: data}
+	modes := map[string]os.FileMode{installed: 0755}
+	for _, file := range desktopFiles {
+		path := filepath.Join(layout.dataDir, filepath.FromSlash(file.Path))
+		files[path] = file.Data
+		modes[path] = file.Mode
+	}
+
+	// files which exist but have not been installed by a previous installation are left untouched
+	owned := make(map[string]bool)
+	for _, path := range previous.Files {
+		owned[path] = true
+	}
+	for path := range files {
+		if _, err := os.Lstat(path); err == nil && !owned[path] {
+			return nil, fmt.Errorf("%w: %s", ErrInstallConflict, path)
+		}
+	}
+
+	manifest := &installManifest{
+		Version:     installManifestVersion,
+		Identifier:  id,
+		Executable:  installed,
+		Directories: previous.Directories,
+	}
+	createdDirectories := make(map[string]bool)
+	for _, dir := range previous.Directories {
+		createdDirectories[dir] = true
+	}
+	mkdir := func(dir string) error {
+		created, err := mkdirAll(dir)
+		for _, path := range created {
+			if !createdDirectories[path] {
+				createdDirectories[path] = true
+				manifest.Directories = append(manifest.Directories, path)
+			}
+		}
+
+		return err
+	}
+
+	paths := make([]string, 0, len(files))
+	for path := range files {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	for _, path := range paths {
+		if err := mkdir(filepath.Dir(path)); err != nil {
+			return nil, fmt.Errorf("failed to create directory: %w", err)
+		}
+		if err := writeFileAtomically(path, files[path], modes[path]); err != nil {
+			return nil, fmt.Errorf("failed to install %s: %w", path, err)
+		}
+
+		manifest.Files = append(manifest.Files, path)
+	}
+
+	// files which are no longer provided by the application are removed
+	for _, path := range previous.Files {
+		if _, ok := files[path]; !ok {
+			_ = os.Remove(path)
+		}
+	}
+
+	manifestPath := layout.manifestPath(id)
+	if err := mkdir(filepath.Dir(manifestPath)); err != nil {
+		return nil, fmt.Errorf("failed to create directory: %w", err)
+	}
+
+	encoded, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode install manifest: %w", err)
+	}
+	if err := writeFileAtomically(manifestPath, encoded, 0644); err != nil {
+		return nil, fmt.Errorf("failed to write install manifest: %w", err)
+	}
+
+	return manifest, nil
+}
+
+// removes all files which have been recorded by the install manifest of a given application
+// along with the manifest itself
+func uninstallFiles(layout *installLayout, id string) (*installManifest, error) {
+	manifest, err := readInstallManifest(layout, id)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, path := range manifest.Files {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return nil, fmt.Errorf("failed to remove %s: %w", path, err)
+		}
+	}
+	if err := os.Remove(layout.manifestPath(id)); err != nil {
+		return nil, fmt.Errorf("failed to remove install manifest: %w", err)
+	}
+
+	return manifest, nil
+}
+
+// removes all directories which have been created by a given installation
+//
+// directories are only removed when they are empty
+func uninstallDirectories(manifest *installManifest) {
+	// nested directories are removed before their parents
+	directories := append([]string{}, manifest.Directories...)
+	sort.Sort(sort.Reverse(sort.StringSlice(directories)))
+	for _, dir := range directories {
+		_ = os.Remove(dir)
+	}
+}
+
+// reads the install manifest of a given application
+func readInstallManifest(layout *installLayout, id string) (*installManifest, error) {
+	encoded, err := os.ReadFile(layout.manifestPath(id))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, ErrNotInstalled
+		}
+
+		return nil, fmt.Errorf("failed to read install manifest: %w", err)
+	}
+
+	manifest := &installManifest{}
+	if err := json.Unmarshal(encoded, manifest); err != nil {
+		return nil, fmt.Errorf("failed to decode install manifest: %w", err)
+	}
+	if manifest.Version > installManifestVersion {
+		return nil, fmt.Errorf("unsupported install manifest version %d", manifest.Version)
+	}
+
+	return manifest, nil
+}
+
+// creates a directory along with its parents and returns the directories which have been created
+func mkdirAll(dir string) ([]string, error) {
+	var missing []string
+	for path := dir; ; path = filepath.Dir(path) {
+		if _, err := os.Stat(path); err == nil || filepath.Dir(path) == path {
+			break
+		}
+
+		missing = append(missing, path)
+	}
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+
+	return missing, nil
+}
+
+// writes a given file via a temporary file in order to avoid leaving partially written files
+// behind (and to permit replacing running executables)
+func writeFileAtomically(path string, data []byte, mode os.FileMode) error {
+	out, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(out.Name())
+	defer out.Close()
+
+	if _, err := out.Write(data); err != nil {
+		return err
+	}
+	if err := out.Chmod(mode); err != nil {
+		return err
+	}
+	if err := out.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(out.Name(), path)
+}
+
+// refreshes the desktop entry and MIME type caches of a given layout (when the respective tools
+// are available)
+func updateDesktopDatabases(layout *installLayout) {
+	tools := [][]string{
+		{"update-desktop-database", "-q", filepath.Join(layout.dataDir, "applications")},
+		{"update-mime-database", filepath.Join(layout.dataDir, "mime")},
+	}
+
+	for _, tool := range tools {
+		if _, err := os.Stat(tool[len(tool)-1]); err != nil {
+			continue
+		}
+		if path, err := exec.LookPath(tool[0]); err == nil {
+			_ = exec.Command(path, tool[1:]...).Run()
+		}
+	}
+}
+
+// identifies whether a given directory is part of the search path
+func isInSearchPath(dir string) bool {
+	for _, entry := range filepath.SplitList(os.Getenv("PATH")) {
+		if filepath.Clean(entry) == filepath.Clean(dir) {
+			return true
+		}
+	}
+
+	return false
+}
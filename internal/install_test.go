@@ -0,0 +1,164 @@
+/*
+ * Copyright 2021 Johannes Donath <johannesd@torchmind.com>
+ * and other copyright owners as documented in the project's IP log.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package internal
+
+import (
+	"bytes"
+	"errors"
+	"github.com/dotstart/canoe/internal/metadata"
+	"image"
+	"image/png"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// creates an install layout within a temporary directory along with a fake executable
+func testInstallLayout(t *testing.T) (*installLayout, string, string) {
+	root := t.TempDir()
+
+	executable := filepath.Join(t.TempDir(), "foo-linux-amd64")
+	if err := os.WriteFile(executable, []byte("foo"), 0755); err != nil {
+		t.Fatalf("failed to write executable: %s", err)
+	}
+
+	return &installLayout{
+		binDir:  filepath.Join(root, "bin"),
+		dataDir: filepath.Join(root, "share"),
+	}, root, executable
+}
+
+func testInstallMetadata(t *testing.T) *metadata.ApplicationContainer {
+	encoded := &bytes.Buffer{}
+	if err := png.Encode(encoded, image.NewNRGBA(image.Rect(0, 0, 32, 32))); err != nil {
+		t.Fatalf("failed to encode icon: %s", err)
+	}
+
+	meta := testMetadata()
+	meta.Identity = &metadata.ApplicationIdentity{Name: "Foo App", Identifier: "org.example.foo"}
+	meta.Desktop = &metadata.DesktopConfiguration{
+		DocumentTypes: []*metadata.DocumentType{{Name: "Foo Document", Extensions: []string{"foo"}}},
+		Icon:          encoded.Bytes(),
+	}
+
+	return meta
+}
+
+func TestInstall(t *testing.T) {
+	layout, root, executable := testInstallLayout(t)
+	meta := testInstallMetadata(t)
+
+	manifest, err := install(layout, executable, meta)
+	if err != nil {
+		t.Fatalf("failed to install application: %s", err)
+	}
+
+	installed := filepath.Join(layout.binDir, "foo-app")
+	if manifest.Executable != installed {
+		t.Errorf("expected executable %s but got %s", installed, manifest.Executable)
+	}
+	if stat, err := os.Stat(installed); err != nil || stat.Mode().Perm() != 0755 {
+		t.Errorf("expected executable at %s: %v", installed, err)
+	}
+
+	entry, err := os.ReadFile(filepath.Join(layout.dataDir, "applications", "org.example.foo.desktop"))
+	if err != nil {
+		t.Fatalf("failed to read desktop entry: %s", err)
+	}
+	if !strings.Contains(string(entry), "Exec="+installed+" %F\n") {
+		t.Errorf("expected desktop entry to launch %s:\n%s", installed, entry)
+	}
+	for _, path := range []string{
+		filepath.Join(layout.dataDir, "icons", "hicolor", "32x32", "apps", "org.example.foo.png"),
+		filepath.Join(layout.dataDir, "mime", "packages", "org.example.foo.xml"),
+		layout.manifestPath("org.example.foo"),
+	} {
+		if _, err := os.Stat(path); err != nil {
+			t.Errorf("expected file %s: %s", path, err)
+		}
+	}
+
+	// reinstalling without an icon removes the previously installed icons
+	meta.Desktop.Icon = nil
+	if _, err := install(layout, executable, meta); err != nil {
+		t.Fatalf("failed to reinstall application: %s", err)
+	}
+	if _, err := os.Stat(filepath.Join(layout.dataDir, "icons", "hicolor", "32x32", "apps", "org.example.foo.png")); !os.IsNotExist(err) {
+		t.Errorf("expected icon to be removed but got %v", err)
+	}
+
+	manifest, err = uninstallFiles(layout, "org.example.foo")
+	if err != nil {
+		t.Fatalf("failed to uninstall application: %s", err)
+	}
+	uninstallDirectories(manifest)
+	if entries, _ := os.ReadDir(root); len(entries) != 0 {
+		t.Errorf("expected all installed files and directories to be removed but found %d entries", len(entries))
+	}
+
+	if _, err := uninstallFiles(layout, "org.example.foo"); !errors.Is(err, ErrNotInstalled) {
+		t.Errorf("expected ErrNotInstalled but got %v", err)
+	}
+}
+
+func TestInstallConflict(t *testing.T) {
+	layout, _, executable := testInstallLayout(t)
+
+	if err := os.MkdirAll(layout.binDir, 0755); err != nil {
+		t.Fatalf("failed to create directory: %s", err)
+	}
+	if err := os.WriteFile(filepath.Join(layout.binDir, "foo-app"), []byte("bar"), 0755); err != nil {
+		t.Fatalf("failed to write file: %s", err)
+	}
+
+	if _, err := install(layout, executable, testInstallMetadata(t)); !errors.Is(err, ErrInstallConflict) {
+		t.Errorf("expected ErrInstallConflict but got %v", err)
+	}
+	if data, _ := os.ReadFile(filepath.Join(layout.binDir, "foo-app")); string(data) != "bar" {
+		t.Errorf("expected conflicting file to remain untouched")
+	}
+}
+
+func TestInstallRelativeClassPath(t *testing.T) {
+	layout, _, executable := testInstallLayout(t)
+	meta := testInstallMetadata(t)
+	meta.Application = &metadata.ApplicationConfiguration{ClassPath: []string{"lib/bar.jar"}}
+
+	if _, err := install(layout, executable, meta); !errors.Is(err, ErrInstallClassPath) {
+		t.Errorf("expected ErrInstallClassPath but got %v", err)
+	}
+	if _, err := os.Stat(layout.binDir); !os.IsNotExist(err) {
+		t.Errorf("expected no files to be installed")
+	}
+}
+
+func TestIsInstallCommand(t *testing.T) {
+	cases := map[string]bool{
+		"--canoe-install":        true,
+		"--canoe-install --user": true,
+		"--canoe-uninstall":      true,
+		"foo --canoe-install":    false,
+		"":                       false,
+	}
+
+	for args, expected := range cases {
+		if actual := IsInstallCommand(strings.Fields(args)); actual != expected {
+			t.Errorf("expected %v for %q but got %v", expected, args, actual)
+		}
+	}
+}
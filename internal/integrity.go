@@ -0,0 +1,189 @@
+/*
+ * Copyright 2021 Johannes Donath <johannesd@torchmind.com>
+ * and other copyright owners as documented in the project's IP log.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package internal
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/dotstart/canoe/internal/metadata"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+var ErrCorrupted = errors.New("executable is corrupted")
+
+// DigestSection computes the SHA-256 digest of a given section of an executable.
+func DigestSection(r io.ReaderAt, offset uint64, length uint64) ([]byte, error) {
+	h := sha256.New()
+	if _, err := io.Copy(h, io.NewSectionReader(r, int64(offset), int64(length))); err != nil {
+		return nil, err
+	}
+
+	return h.Sum(nil), nil
+}
+
+// VerifyIntegrity verifies the wrapper and payload digests recorded within the container metadata
+// of a given executable.
+//
+// Successful verifications are cached along with the size and modification time of the executable
+// in order to avoid rehashing large payloads upon every launch. As modification times are trivially
+// forged, the cache is bypassed for signed executables and wrappers which pin a signing key.
+func VerifyIntegrity(executable string, footer *Footer, meta *metadata.ApplicationContainer) error {
+	integrity := meta.GetIntegrity()
+	if len(integrity.GetWrapperSha256()) == 0 && len(integrity.GetPayloadSha256()) == 0 {
+		return nil
+	}
+
+	if footer.FormatVersion < FooterFormatVersion {
+		return fmt.Errorf("%w: payload location is unknown", ErrCorruptFooter)
+	}
+
+	f, err := os.Open(executable)
+	if err != nil {
+		return fmt.Errorf("failed to open executable: %w", err)
+	}
+	defer f.Close()
+
+	stat, err := f.Stat()
+	if err != nil {
+		return fmt.Errorf("failed to stat executable: %w", err)
+	}
+
+	entry := &integrityCacheEntry{
+		Size:          stat.Size(),
+		ModTime:       stat.ModTime().UnixNano(),
+		WrapperSha256: hex.EncodeToString(integrity.GetWrapperSha256()),
+		PayloadSha256: hex.EncodeToString(integrity.GetPayloadSha256()),
+	}
+
+	cachePath := ""
+	if usesIntegrityCache(meta) {
+		cachePath = integrityCachePath(executable)
+	}
+	if len(cachePath) != 0 && entry.matches(cachePath) {
+		return nil
+	}
+
+	if err := VerifyDigests(f, footer, meta); err != nil {
+		return err
+	}
+
+	if len(cachePath) != 0 {
+		// failing to cache the result merely results in the executable being verified again upon its
+		// next launch
+		_ = entry.store(cachePath)
+	}
+
+	return nil
+}
+
+// identifies whether successful verifications of a given application may be cached
+//
+// signatures only cover the recorded digests and thus rely on their verification upon every launch
+func usesIntegrityCache(meta *metadata.ApplicationContainer) bool {
+	return meta.GetSignature() == nil && PinnedPublicKey() == nil
+}
+
+// VerifyDigests verifies the wrapper and payload digests recorded within the container metadata
+// against the contents of a given executable.
+func VerifyDigests(r io.ReaderAt, footer *Footer, meta *metadata.ApplicationContainer) error {
+	integrity := meta.GetIntegrity()
+
+	if err := verifySection(r, "wrapper", footer.WrapperOffset, footer.PayloadOffset-footer.WrapperOffset, integrity.GetWrapperSha256()); err != nil {
+		return err
+	}
+
+	return verifySection(r, "payload", footer.PayloadOffset, footer.PayloadLength, integrity.GetPayloadSha256())
+}
+
+// verifies a given section of an executable against its expected digest (if any)
+func verifySection(r io.ReaderAt, name string, offset uint64, length uint64, expected []byte) error {
+	if len(expected) == 0 {
+		return nil
+	}
+
+	actual, err := DigestSection(r, offset, length)
+	if err != nil {
+		return fmt.Errorf("%w: failed to read %s section: %s", ErrCorrupted, name, err)
+	}
+
+	if !bytes.Equal(actual, expected) {
+		return fmt.Errorf("%w: %s digest mismatch", ErrCorrupted, name)
+	}
+
+	return nil
+}
+
+// encapsulates the parameters of a previously verified executable
+type integrityCacheEntry struct {
+	Size          int64  `json:"size"`
+	ModTime       int64  `json:"mod_time"`
+	WrapperSha256 string `json:"wrapper_sha256"`
+	PayloadSha256 string `json:"payload_sha256"`
+}
+
+// identifies whether the entry stored at the given path refers to the same executable state
+func (e *integrityCacheEntry) matches(path string) bool {
+	encoded, err := os.ReadFile(path)
+	if err != nil {
+		return false
+	}
+
+	var cached integrityCacheEntry
+	if err := json.Unmarshal(encoded, &cached); err != nil {
+		return false
+	}
+
+	return cached == *e
+}
+
+// stores the entry at the given path
+func (e *integrityCacheEntry) store(path string) error {
+	encoded, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+
+	return os.WriteFile(path, encoded, 0600)
+}
+
+// computes the location of the integrity cache entry for a given executable
+//
+// returns an empty string when no cache directory is available within the current environment
+func integrityCachePath(executable string) string {
+	cacheDir, err := os.UserCacheDir()
+	if err != nil {
+		return ""
+	}
+
+	abs, err := filepath.Abs(executable)
+	if err != nil {
+		return ""
+	}
+
+	key := sha256.Sum256([]byte(abs))
+	return filepath.Join(cacheDir, "canoe", "integrity", hex.EncodeToString(key[:]))
+}
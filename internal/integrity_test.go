@@ -0,0 +1,139 @@
+/*
+ * Copyright 2021 Johannes Donath <johannesd@torchmind.com>
+ * and other copyright owners as documented in the project's IP log.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package internal
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"errors"
+	"github.com/dotstart/canoe/internal/metadata"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// writes a test executable with valid digests to a temporary location
+func writeTestExecutable(t *testing.T) (string, *Footer, *metadata.ApplicationContainer) {
+	wrapperDigest := sha256.Sum256(testWrapper)
+	payloadDigest := sha256.Sum256(testPayload)
+
+	meta := testMetadata()
+	meta.Integrity = &metadata.IntegrityConfiguration{
+		WrapperSha256: wrapperDigest[:],
+		PayloadSha256: payloadDigest[:],
+	}
+
+	buf := &bytes.Buffer{}
+	buf.Write(testWrapper)
+	buf.Write(testPayload)
+
+	footer := &Footer{
+		FormatVersion: FooterFormatVersion,
+		PayloadOffset: uint64(len(testWrapper)),
+		PayloadLength: uint64(len(testPayload)),
+	}
+	if _, err := WriteExecutableFooter(buf, footer, meta); err != nil {
+		t.Fatalf("failed to write footer: %s", err)
+	}
+
+	path := filepath.Join(t.TempDir(), "executable")
+	if err := os.WriteFile(path, buf.Bytes(), 0755); err != nil {
+		t.Fatalf("failed to write executable: %s", err)
+	}
+
+	return path, footer, meta
+}
+
+func TestVerifyIntegrity(t *testing.T) {
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+	path, footer, meta := writeTestExecutable(t)
+
+	if err := VerifyIntegrity(path, footer, meta); err != nil {
+		t.Fatalf("expected valid executable but got %s", err)
+	}
+
+	f, err := os.OpenFile(path, os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatalf("failed to open executable: %s", err)
+	}
+	_, _ = f.WriteAt([]byte{'X'}, int64(footer.PayloadOffset))
+	_ = f.Close()
+
+	// bump the modification time in order to invalidate the cache entry
+	later := time.Now().Add(time.Minute)
+	_ = os.Chtimes(path, later, later)
+
+	if err := VerifyIntegrity(path, footer, meta); !errors.Is(err, ErrCorrupted) {
+		t.Errorf("expected corruption error but got %v", err)
+	}
+}
+
+func TestVerifyIntegrityCache(t *testing.T) {
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+	path, footer, meta := writeTestExecutable(t)
+
+	if err := VerifyIntegrity(path, footer, meta); err != nil {
+		t.Fatalf("expected valid executable but got %s", err)
+	}
+
+	stat, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("failed to stat executable: %s", err)
+	}
+
+	f, err := os.OpenFile(path, os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatalf("failed to open executable: %s", err)
+	}
+	_, _ = f.WriteAt([]byte{'X'}, int64(footer.PayloadOffset))
+	_ = f.Close()
+	_ = os.Chtimes(path, stat.ModTime(), stat.ModTime())
+
+	// size and modification time remain unchanged thus the cached result is expected to apply
+	if err := VerifyIntegrity(path, footer, meta); err != nil {
+		t.Errorf("expected cached verification result but got %s", err)
+	}
+}
+
+func TestVerifyIntegrityCacheSigned(t *testing.T) {
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+	path, footer, meta := writeTestExecutable(t)
+	meta.Signature = &metadata.SignatureConfiguration{}
+
+	if err := VerifyIntegrity(path, footer, meta); err != nil {
+		t.Fatalf("expected valid executable but got %s", err)
+	}
+
+	stat, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("failed to stat executable: %s", err)
+	}
+
+	f, err := os.OpenFile(path, os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatalf("failed to open executable: %s", err)
+	}
+	_, _ = f.WriteAt([]byte{'X'}, int64(footer.PayloadOffset))
+	_ = f.Close()
+	_ = os.Chtimes(path, stat.ModTime(), stat.ModTime())
+
+	// signed executables are verified upon every launch regardless of their modification time
+	if err := VerifyIntegrity(path, footer, meta); !errors.Is(err, ErrCorrupted) {
+		t.Errorf("expected corruption error but got %v", err)
+	}
+}
@@ -21,61 +21,86 @@ import (
 	"fmt"
 	"github.com/dotstart/canoe/internal/metadata"
 	"github.com/dotstart/canoe/internal/runtime"
-	"github.com/gen2brain/dlgs"
 	"os"
 	"os/exec"
 	"path"
+	"path/filepath"
 	"strings"
 )
 
-func Launch(runtimeExecutable string) int {
+// Launch executes the application embedded within the current executable and reports any errors
+// via the passed reporter.
+//
+// The command line arguments of the current process are passed to the application unless they
+// request the installation or removal of the executable (refer to RunInstallCommand).
+func Launch(runtimeExecutable string, reporter Reporter) int {
 	executable, err := os.Executable()
 	if err != nil {
-		_, _ = dlgs.Error("Application Error", "Failed to open application executable.")
+		reporter.Report(NewErrorReport(ConfigurationError, nil, fmt.Errorf("failed to open application executable: %w", err)))
 		return -1
 	}
 
-	return LaunchApplication(executable, runtimeExecutable)
+	if IsInstallCommand(os.Args[1:]) {
+		return RunInstallCommand(executable, os.Args[1:])
+	}
+
+	return LaunchApplication(executable, runtimeExecutable, os.Args[1:], reporter)
 }
 
-func LaunchApplication(executable string, runtimeExecutable string) int {
-	cfg, err := ReadExecutableFooter(executable)
+// LaunchApplication executes the application embedded within a given executable and reports any
+// errors via the passed reporter.
+//
+// The passed arguments (such as the documents or URLs passed by desktop environments) are appended
+// to the runtime arguments and are thus received by the application main method.
+func LaunchApplication(executable string, runtimeExecutable string, args []string, reporter Reporter) int {
+	footer, cfg, err := ReadExecutableContainer(executable)
 	if err != nil {
-		_, _ = dlgs.Error("Application Error", "Failed to load application configuration.")
+		reporter.Report(NewErrorReport(ConfigurationError, nil, err))
 		return -2
 	}
 
-	home, err := runtime.Find(cfg.Runtime.MinimumVersion, cfg.Runtime.MaximumVersion)
+	if footer.MinimumWrapperVersion > WrapperVersion {
+		reporter.Report(NewErrorReport(ConfigurationError, cfg, fmt.Errorf("%w: wrapper revision %d or newer required (revision %d present)", ErrUnsupportedContainer, footer.MinimumWrapperVersion, WrapperVersion)))
+		return -2
+	}
+
+	if err := VerifyExecutableSignature(executable, footer, cfg); err != nil {
+		reporter.Report(NewErrorReport(IntegrityError, cfg, err))
+		return -6
+	}
+
+	if err := VerifyIntegrity(executable, footer, cfg); err != nil {
+		reporter.Report(NewErrorReport(IntegrityError, cfg, err))
+		return -6
+	}
+
+	home, version, err := runtime.Find(cfg.Runtime.MinimumVersion, cfg.Runtime.MaximumVersion)
 	if errors.Is(err, runtime.ErrNotFound) {
 		home = ""
-		err = runtime.FindInPath(runtimeExecutable, cfg.Runtime.MinimumVersion, cfg.Runtime.MaximumVersion)
+		version, err = runtime.FindInPath(runtimeExecutable, cfg.Runtime.MinimumVersion, cfg.Runtime.MaximumVersion)
 	}
 	if err != nil {
-		_, _ = dlgs.Error("Runtime Error", fmt.Sprintf("Failed to locate valid Java Runtime: %s", err))
+		reporter.Report(NewErrorReport(categorizeRuntimeError(err), cfg, err))
 		return -3
 	}
 
-	executablePath := path.Join(home, runtimeExecutable)
-	if _, err := os.Stat(executablePath); err != nil {
-		_, _ = dlgs.Error("Runtime Error", "Invalid Java Runtime installation: Cannot find executable")
-		return -4
-	}
-
-	arguments := make([]string, 0)
-
-	if cfg.Runtime.InitialMemory != 0 {
-		arguments = append(arguments, "-Xms"+metadata.AppendByteSuffix(cfg.Runtime.InitialMemory))
-	}
-	if cfg.Runtime.MemoryLimit != 0 {
-		arguments = append(arguments, "-Xmx"+metadata.AppendByteSuffix(cfg.Runtime.MemoryLimit))
+	executablePath := runtimeExecutable
+	if len(home) != 0 {
+		// runtimes located via PATH have already been resolved by the lookup
+		executablePath = path.Join(home, runtimeExecutable)
+		if _, err := os.Stat(executablePath); err != nil {
+			reporter.Report(NewErrorReport(RuntimeInvalidError, cfg, fmt.Errorf("%w: cannot find executable", runtime.ErrInvalidInstallation)))
+			return -4
+		}
 	}
 
-	if len(cfg.Runtime.AdditionalArguments) != 0 {
-		arguments = append(arguments, strings.Split(cfg.Runtime.AdditionalArguments, " ")...)
+	archive, err := PayloadArchive(executable, footer, cfg)
+	if err != nil {
+		reporter.Report(NewErrorReport(LaunchError, cfg, err))
+		return -5
 	}
 
-	arguments = append(arguments, "-cp", executable)
-	arguments = append(arguments, cfg.Application.MainClass)
+	arguments := append(RuntimeArguments(cfg, executable, archive, version), args...)
 
 	cmd := exec.Command(executablePath, arguments...)
 
@@ -83,13 +108,96 @@ func LaunchApplication(executable string, runtimeExecutable string) int {
 	cmd.Stdout = os.Stdout
 	cmd.Stderr = os.Stderr
 
+	if err := replaceProcess(executable, cmd); err != nil {
+		reporter.Report(NewErrorReport(LaunchError, cfg, err))
+		return -5
+	}
+
 	if err := cmd.Run(); err != nil {
 		if exitErr, ok := err.(*exec.ExitError); ok {
 			return exitErr.ExitCode()
 		}
 
+		reporter.Report(NewErrorReport(LaunchError, cfg, err))
 		return -5
 	}
 
 	return 0
 }
+
+// identify the minimum runtime versions which support the respective launch options
+const (
+	ModuleOptionsVersion      = 9
+	NativeAccessOptionVersion = 17
+)
+
+// RuntimeArguments computes the arguments passed to a runtime of a given version in order to launch
+// the application embedded within an executable.
+//
+// The archive refers to the file from which the runtime loads the payload (typically the
+// executable itself) while additional class path entries are resolved relative to the executable.
+//
+// Launch attributes which would otherwise be read from the archive manifest (when launching via
+// -jar) are translated into their command line equivalents. Options which are not supported by the
+// selected runtime version are omitted.
+func RuntimeArguments(cfg *metadata.ApplicationContainer, executable string, archive string, version uint64) []string {
+	arguments := make([]string, 0)
+
+	if cfg.GetRuntime().GetInitialMemory() != 0 {
+		arguments = append(arguments, "-Xms"+metadata.AppendByteSuffix(cfg.Runtime.InitialMemory))
+	}
+	if cfg.GetRuntime().GetMemoryLimit() != 0 {
+		arguments = append(arguments, "-Xmx"+metadata.AppendByteSuffix(cfg.Runtime.MemoryLimit))
+	}
+
+	application := cfg.GetApplication()
+	if version >= ModuleOptionsVersion {
+		for _, pkg := range application.GetAddOpens() {
+			arguments = append(arguments, "--add-opens", pkg+"=ALL-UNNAMED")
+		}
+		for _, pkg := range application.GetAddExports() {
+			arguments = append(arguments, "--add-exports", pkg+"=ALL-UNNAMED")
+		}
+	}
+	if version >= NativeAccessOptionVersion && application.GetEnableNativeAccess() {
+		arguments = append(arguments, "--enable-native-access=ALL-UNNAMED")
+	}
+	if len(application.GetLauncherAgentClass()) != 0 {
+		// the agent is loaded via the Premain-Class attribute of the embedded archive manifest (which
+		// names the same class) thus invoking its premain method rather than agentmain
+		arguments = append(arguments, "-javaagent:"+archive)
+	}
+
+	if len(cfg.GetRuntime().GetAdditionalArguments()) != 0 {
+		arguments = append(arguments, strings.Split(cfg.Runtime.AdditionalArguments, " ")...)
+	}
+
+	classPath := []string{archive}
+	for _, entry := range application.GetClassPath() {
+		if !filepath.IsAbs(entry) {
+			entry = filepath.Join(filepath.Dir(executable), filepath.FromSlash(entry))
+		}
+
+		classPath = append(classPath, entry)
+	}
+
+	arguments = append(arguments, "-cp", strings.Join(classPath, string(os.PathListSeparator)))
+	arguments = append(arguments, application.GetMainClass())
+
+	return arguments
+}
+
+// RelativeClassPath lists the additional class path entries of a given application which are
+// resolved relative to the location of the executable.
+//
+// Executables which reference such entries cannot be relocated without their dependencies.
+func RelativeClassPath(cfg *metadata.ApplicationContainer) []string {
+	var entries []string
+	for _, entry := range cfg.GetApplication().GetClassPath() {
+		if !filepath.IsAbs(entry) {
+			entries = append(entries, entry)
+		}
+	}
+
+	return entries
+}
@@ -0,0 +1,88 @@
+/*
+ * Copyright 2021 Johannes Donath <johannesd@torchmind.com>
+ * and other copyright owners as documented in the project's IP log.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package internal
+
+import (
+	"github.com/dotstart/canoe/internal/metadata"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRuntimeArguments(t *testing.T) {
+	executable := filepath.Join("opt", "foo", "foo")
+	cfg := &metadata.ApplicationContainer{
+		Runtime: &metadata.RuntimeConfiguration{
+			MemoryLimit:         1024 * 1024 * 1024,
+			AdditionalArguments: "-Dfoo=bar",
+		},
+		Application: &metadata.ApplicationConfiguration{
+			MainClass:          "foo.Main",
+			AddOpens:           []string{"java.base/java.lang"},
+			AddExports:         []string{"java.base/sun.nio.ch"},
+			EnableNativeAccess: true,
+			LauncherAgentClass: "foo.Agent",
+			ClassPath:          []string{"lib/bar.jar"},
+		},
+	}
+
+	expected := []string{
+		"-Xmx1G",
+		"--add-opens", "java.base/java.lang=ALL-UNNAMED",
+		"--add-exports", "java.base/sun.nio.ch=ALL-UNNAMED",
+		"--enable-native-access=ALL-UNNAMED",
+		"-javaagent:" + executable,
+		"-Dfoo=bar",
+		"-cp", executable + string(os.PathListSeparator) + filepath.Join("opt", "foo", "lib", "bar.jar"),
+		"foo.Main",
+	}
+	if arguments := RuntimeArguments(cfg, executable, executable, 17); strings.Join(arguments, " ") != strings.Join(expected, " ") {
+		t.Errorf("expected arguments %v but got %v", expected, arguments)
+	}
+
+	// options which are not understood by legacy runtimes are omitted
+	expected = []string{
+		"-Xmx1G",
+		"-javaagent:" + executable,
+		"-Dfoo=bar",
+		"-cp", executable + string(os.PathListSeparator) + filepath.Join("opt", "foo", "lib", "bar.jar"),
+		"foo.Main",
+	}
+	if arguments := RuntimeArguments(cfg, executable, executable, 8); strings.Join(arguments, " ") != strings.Join(expected, " ") {
+		t.Errorf("expected arguments %v but got %v", expected, arguments)
+	}
+
+	arguments := RuntimeArguments(cfg, executable, executable, 11)
+	if !strings.Contains(strings.Join(arguments, " "), "--add-opens") || strings.Contains(strings.Join(arguments, " "), "--enable-native-access") {
+		t.Errorf("expected module options without native access on version 11 but got %v", arguments)
+	}
+
+	// payloads which have been extracted from the executable are loaded from their respective
+	// archive while class path entries remain relative to the executable
+	archive := filepath.Join("cache", "payload.jar")
+	expected = []string{
+		"-Xmx1G",
+		"-javaagent:" + archive,
+		"-Dfoo=bar",
+		"-cp", archive + string(os.PathListSeparator) + filepath.Join("opt", "foo", "lib", "bar.jar"),
+		"foo.Main",
+	}
+	if arguments := RuntimeArguments(cfg, executable, archive, 8); strings.Join(arguments, " ") != strings.Join(expected, " ") {
+		t.Errorf("expected arguments %v but got %v", expected, arguments)
+	}
+}
@@ -0,0 +1,235 @@
+/*
+ * Copyright 2021 Johannes Donath <johannesd@torchmind.com>
+ * and other copyright owners as documented in the project's IP log.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package macho
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+)
+
+const (
+	codeSignatureMagic = 0xFADE0CC0
+	codeDirectoryMagic = 0xFADE0C02
+
+	codeDirectoryVersion = 0x20400
+	codeDirectorySlot    = 0
+
+	// flags of ad-hoc signatures as generated by the linker
+	codeDirectoryFlagsAdHoc        = 0x2
+	codeDirectoryFlagsLinkerSigned = 0x20000
+
+	codeDirectoryHashTypeSha256 = 2
+	codeDirectoryExecSegMain    = 0x1
+
+	codePageSizeBits = 12
+	codePageSize     = 1 << codePageSizeBits
+
+	superBlobHeaderSize     = 12
+	blobIndexSize           = 8
+	codeDirectoryHeaderSize = 88
+
+	// code signatures are expected to be aligned to 16 bytes within the __LINKEDIT segment
+	codeSignatureAlignment = 16
+
+	codeSignatureCommandSize = 16
+)
+
+// resets the code signature command (if any) to an empty signature located at a given offset
+func (img *Image) clearCodeSignature(offset uint64) {
+	if command := img.findCommand(loadCommandCodeSignature); command != -1 {
+		img.putU32(command+8, uint32(offset))
+		img.putU32(command+12, 0)
+	}
+}
+
+// Identifier retrieves the identifier recorded within the code signature of the image.
+//
+// Returns an empty string if the image has not been signed.
+func (img *Image) Identifier() string {
+	command := img.findCommand(loadCommandCodeSignature)
+	if command == -1 {
+		return ""
+	}
+
+	offset := int(img.u32(command + 8))
+	size := int(img.u32(command + 12))
+	if size < superBlobHeaderSize || offset+size > len(img.data) {
+		return ""
+	}
+
+	signature := img.data[offset : offset+size]
+	if binary.BigEndian.Uint32(signature) != codeSignatureMagic {
+		return ""
+	}
+
+	count := int(binary.BigEndian.Uint32(signature[8:]))
+	for i := 0; i < count; i++ {
+		index := superBlobHeaderSize + i*blobIndexSize
+		if index+blobIndexSize > len(signature) {
+			return ""
+		}
+		if binary.BigEndian.Uint32(signature[index:]) != codeDirectorySlot {
+			continue
+		}
+
+		directory := int(binary.BigEndian.Uint32(signature[index+4:]))
+		if directory+codeDirectoryHeaderSize > len(signature) || binary.BigEndian.Uint32(signature[directory:]) != codeDirectoryMagic {
+			return ""
+		}
+
+		identifier := directory + int(binary.BigEndian.Uint32(signature[directory+20:]))
+		if identifier >= len(signature) {
+			return ""
+		}
+
+		end := bytes.IndexByte(signature[identifier:], 0)
+		if end == -1 {
+			return ""
+		}
+
+		return string(signature[identifier : identifier+end])
+	}
+
+	return ""
+}
+
+// IsSigned evaluates whether the image carries a code signature.
+func (img *Image) IsSigned() bool {
+	return img.findCommand(loadCommandCodeSignature) != -1
+}
+
+// Sign replaces the code signature of the image with an ad-hoc signature.
+//
+// The signature is appended to the __LINKEDIT segment and consists of a single code directory
+// which lists the SHA-256 digests of all pages within the image (as produced by the linker when
+// targeting Apple Silicon). A code signature command is added when the image has not been signed
+// previously.
+func (img *Image) Sign(identifier string) error {
+	linkEdit, err := img.linkEdit()
+	if err != nil {
+		return err
+	}
+	end, err := img.linkEditEnd(linkEdit)
+	if err != nil {
+		return err
+	}
+
+	text := img.Segment(textSegmentName)
+	if text == nil {
+		return fmt.Errorf("%w: missing %s segment", ErrUnsupported, textSegmentName)
+	}
+
+	command := img.findCommand(loadCommandCodeSignature)
+	if command == -1 {
+		encoded := make([]byte, codeSignatureCommandSize)
+		binary.LittleEndian.PutUint32(encoded, loadCommandCodeSignature)
+		binary.LittleEndian.PutUint32(encoded[4:], codeSignatureCommandSize)
+
+		command = headerSize + img.commandsSize()
+		if err := img.insertCommand(command, encoded); err != nil {
+			return err
+		}
+	}
+
+	codeLimit := align(end, codeSignatureAlignment)
+	if codeLimit > 0xFFFFFFFF {
+		return fmt.Errorf("%w: image exceeds 32-bit range", ErrUnsupported)
+	}
+
+	pageCount := (codeLimit + codePageSize - 1) / codePageSize
+	identifierOffset := uint64(codeDirectoryHeaderSize)
+	hashOffset := identifierOffset + uint64(len(identifier)) + 1
+	directorySize := hashOffset + pageCount*sha256.Size
+	size := superBlobHeaderSize + blobIndexSize + directorySize
+
+	data := make([]byte, codeLimit+size)
+	copy(data, img.data[:end])
+	img.data = data
+
+	img.putU32(command+8, uint32(codeLimit))
+	img.putU32(command+12, uint32(size))
+	img.resizeLinkEdit(linkEdit, codeLimit+size-linkEdit.Offset)
+
+	execSegFlags := uint64(0)
+	if img.fileType() == fileTypeExecute {
+		execSegFlags = codeDirectoryExecSegMain
+	}
+
+	signature := &bytes.Buffer{}
+	_ = binary.Write(signature, binary.BigEndian, []uint32{
+		codeSignatureMagic,
+		uint32(size),
+		1,
+		codeDirectorySlot,
+		superBlobHeaderSize + blobIndexSize,
+	})
+	_ = binary.Write(signature, binary.BigEndian, struct {
+		Magic         uint32
+		Length        uint32
+		Version       uint32
+		Flags         uint32
+		HashOffset    uint32
+		IdentOffset   uint32
+		SpecialSlots  uint32
+		CodeSlots     uint32
+		CodeLimit     uint32
+		HashSize      uint8
+		HashType      uint8
+		Platform      uint8
+		PageSize      uint8
+		Spare2        uint32
+		ScatterOffset uint32
+		TeamOffset    uint32
+		Spare3        uint32
+		CodeLimit64   uint64
+		ExecSegBase   uint64
+		ExecSegLimit  uint64
+		ExecSegFlags  uint64
+	}{
+		Magic:        codeDirectoryMagic,
+		Length:       uint32(directorySize),
+		Version:      codeDirectoryVersion,
+		Flags:        codeDirectoryFlagsAdHoc | codeDirectoryFlagsLinkerSigned,
+		HashOffset:   uint32(hashOffset),
+		IdentOffset:  uint32(identifierOffset),
+		CodeSlots:    uint32(pageCount),
+		CodeLimit:    uint32(codeLimit),
+		HashSize:     sha256.Size,
+		HashType:     codeDirectoryHashTypeSha256,
+		PageSize:     codePageSizeBits,
+		ExecSegBase:  text.Offset,
+		ExecSegLimit: text.FileSize,
+		ExecSegFlags: execSegFlags,
+	})
+	signature.WriteString(identifier)
+	signature.WriteByte(0)
+
+	for offset := uint64(0); offset < codeLimit; offset += codePageSize {
+		pageEnd := offset + codePageSize
+		if pageEnd > codeLimit {
+			pageEnd = codeLimit
+		}
+
+		digest := sha256.Sum256(img.data[offset:pageEnd])
+		signature.Write(digest[:])
+	}
+
+	copy(img.data[codeLimit:], signature.Bytes())
+	return nil
+}
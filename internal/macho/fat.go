@@ -0,0 +1,118 @@
+/*
+ * Copyright 2021 Johannes Donath <johannesd@torchmind.com>
+ * and other copyright owners as documented in the project's IP log.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package macho
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+const (
+	fatMagic = 0xCAFEBABE
+
+	fatHeaderSize    = 8
+	fatArchEntrySize = 20
+
+	// slices are aligned to the page size of arm64 systems (expressed as a power of two)
+	fatAlignment = 14
+)
+
+// CPU retrieves the CPU type and subtype for which the image has been built.
+func (img *Image) CPU() (uint32, uint32) {
+	return img.u32(4), img.u32(8)
+}
+
+// IsFat identifies whether a given file is a universal binary.
+func IsFat(data []byte) bool {
+	return len(data) >= fatHeaderSize && binary.BigEndian.Uint32(data) == fatMagic
+}
+
+// Fat assembles a universal binary (similarly to lipo) which combines the given images.
+//
+// Each image is expected to target a distinct CPU type.
+func Fat(images ...*Image) ([]byte, error) {
+	if len(images) == 0 {
+		return nil, fmt.Errorf("%w: universal binaries require at least one image", ErrUnsupported)
+	}
+
+	alignment := uint64(1 << fatAlignment)
+	offset := align(uint64(fatHeaderSize+len(images)*fatArchEntrySize), alignment)
+
+	header := make([]byte, fatHeaderSize+len(images)*fatArchEntrySize)
+	binary.BigEndian.PutUint32(header, fatMagic)
+	binary.BigEndian.PutUint32(header[4:], uint32(len(images)))
+
+	cpuTypes := make(map[uint32]bool)
+	for i, img := range images {
+		cpuType, cpuSubtype := img.CPU()
+		if cpuTypes[cpuType] {
+			return nil, fmt.Errorf("%w: duplicate CPU type %#x", ErrUnsupported, cpuType)
+		}
+		cpuTypes[cpuType] = true
+
+		entry := header[fatHeaderSize+i*fatArchEntrySize:]
+		binary.BigEndian.PutUint32(entry, cpuType)
+		binary.BigEndian.PutUint32(entry[4:], cpuSubtype)
+		binary.BigEndian.PutUint32(entry[8:], uint32(offset))
+		binary.BigEndian.PutUint32(entry[12:], uint32(len(img.data)))
+		binary.BigEndian.PutUint32(entry[16:], fatAlignment)
+
+		offset = align(offset+uint64(len(img.data)), alignment)
+	}
+
+	data := make([]byte, 0, offset)
+	data = append(data, header...)
+	for _, img := range images {
+		data = append(data, make([]byte, int(align(uint64(len(data)), alignment))-len(data))...)
+		data = append(data, img.data...)
+	}
+
+	return data, nil
+}
+
+// Slices splits a given universal binary into its images (in the order of their declaration).
+//
+// Each image references a copy of its respective slice.
+func Slices(data []byte) ([]*Image, error) {
+	if !IsFat(data) {
+		return nil, fmt.Errorf("%w: missing universal binary header", ErrMalformed)
+	}
+
+	count := int(binary.BigEndian.Uint32(data[4:]))
+	if count == 0 || fatHeaderSize+count*fatArchEntrySize > len(data) {
+		return nil, fmt.Errorf("%w: truncated universal binary header", ErrMalformed)
+	}
+
+	images := make([]*Image, count)
+	for i := range images {
+		entry := data[fatHeaderSize+i*fatArchEntrySize:]
+		offset := uint64(binary.BigEndian.Uint32(entry[8:]))
+		size := uint64(binary.BigEndian.Uint32(entry[12:]))
+		if offset+size > uint64(len(data)) {
+			return nil, fmt.Errorf("%w: slice %d exceeds universal binary", ErrMalformed, i)
+		}
+
+		img, err := Parse(append([]byte{}, data[offset:offset+size]...))
+		if err != nil {
+			return nil, fmt.Errorf("slice %d: %w", i, err)
+		}
+
+		images[i] = img
+	}
+
+	return images, nil
+}
@@ -0,0 +1,499 @@
+/*
+ * Copyright 2021 Johannes Donath <johannesd@torchmind.com>
+ * and other copyright owners as documented in the project's IP log.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package macho
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+const (
+	magic64 = 0xFEEDFACF
+
+	headerSize         = 32
+	segmentCommandSize = 72
+	sectionHeaderSize  = 80
+
+	// segments are aligned to the page size of arm64 systems (which is also a multiple of the page
+	// size on amd64 systems)
+	segmentAlignment = 0x4000
+
+	fileTypeExecute = 0x2
+
+	protectionRead = 0x1
+)
+
+// identifies the load commands which are relevant when rewriting images
+const (
+	loadCommandSymbolTable        = 0x2
+	loadCommandDynamicSymbolTable = 0xB
+	loadCommandSegment64          = 0x19
+	loadCommandCodeSignature      = 0x1D
+	loadCommandSegmentSplitInfo   = 0x1E
+	loadCommandDyldInfo           = 0x22
+	loadCommandFunctionStarts     = 0x26
+	loadCommandDataInCode         = 0x29
+	loadCommandDylibCodeSignDrs   = 0x2B
+	loadCommandLinkerOptimization = 0x2E
+	loadCommandDyldInfoOnly       = 0x80000022
+	loadCommandDyldExportsTrie    = 0x80000033
+	loadCommandDyldChainedFixups  = 0x80000034
+	linkEditSegmentName           = "__LINKEDIT"
+	textSegmentName               = "__TEXT"
+)
+
+// identifies the offsets of fields which refer to data within the __LINKEDIT segment for each
+// respective load command
+var linkEditOffsetFields = map[uint32][]int{
+	loadCommandSymbolTable:        {8, 16},
+	loadCommandDynamicSymbolTable: {32, 40, 48, 56, 64, 72},
+	loadCommandCodeSignature:      {8},
+	loadCommandSegmentSplitInfo:   {8},
+	loadCommandDyldInfo:           {8, 16, 24, 32, 40},
+	loadCommandFunctionStarts:     {8},
+	loadCommandDataInCode:         {8},
+	loadCommandDylibCodeSignDrs:   {8},
+	loadCommandLinkerOptimization: {8},
+	loadCommandDyldInfoOnly:       {8, 16, 24, 32, 40},
+	loadCommandDyldExportsTrie:    {8},
+	loadCommandDyldChainedFixups:  {8},
+}
+
+var ErrMalformed = errors.New("malformed Mach-O image")
+var ErrUnsupported = errors.New("unsupported Mach-O image")
+var ErrInsufficientHeaderSpace = errors.New("insufficient space for additional load command")
+
+// Image provides access to the load commands of a 64-bit little endian Mach-O image.
+type Image struct {
+	data []byte
+}
+
+// Segment describes a single segment within an image.
+type Segment struct {
+	Name     string
+	Address  uint64
+	Size     uint64
+	Offset   uint64
+	FileSize uint64
+
+	// identifies the location of the segment command within the image
+	command int
+}
+
+// Section describes a single section within an image.
+type Section struct {
+	Segment string
+	Name    string
+	Address uint64
+	Size    uint64
+	Offset  uint32
+}
+
+// Parse decodes the headers of a given Mach-O image.
+//
+// The passed data is referenced by the resulting image until it is modified.
+func Parse(data []byte) (*Image, error) {
+	if len(data) < headerSize || binary.LittleEndian.Uint32(data) != magic64 {
+		return nil, fmt.Errorf("%w: missing header", ErrMalformed)
+	}
+
+	img := &Image{data: data}
+	if headerSize+int(img.u32(20)) > len(data) {
+		return nil, fmt.Errorf("%w: truncated load commands", ErrMalformed)
+	}
+
+	offset := headerSize
+	for i := 0; i < img.commandCount(); i++ {
+		if offset+8 > headerSize+img.commandsSize() {
+			return nil, fmt.Errorf("%w: truncated load command", ErrMalformed)
+		}
+
+		size := int(img.u32(offset + 4))
+		if size < 8 || offset+size > headerSize+img.commandsSize() {
+			return nil, fmt.Errorf("%w: illegal load command size", ErrMalformed)
+		}
+		if img.u32(offset) == loadCommandSegment64 && size < segmentCommandSize+int(img.u32(offset+64))*sectionHeaderSize {
+			return nil, fmt.Errorf("%w: truncated segment command", ErrMalformed)
+		}
+
+		offset += size
+	}
+
+	return img, nil
+}
+
+func (img *Image) u32(offset int) uint32 {
+	return binary.LittleEndian.Uint32(img.data[offset:])
+}
+
+func (img *Image) u64(offset int) uint64 {
+	return binary.LittleEndian.Uint64(img.data[offset:])
+}
+
+func (img *Image) putU32(offset int, value uint32) {
+	binary.LittleEndian.PutUint32(img.data[offset:], value)
+}
+
+func (img *Image) putU64(offset int, value uint64) {
+	binary.LittleEndian.PutUint64(img.data[offset:], value)
+}
+
+func (img *Image) fileType() uint32 {
+	return img.u32(12)
+}
+
+func (img *Image) commandCount() int {
+	return int(img.u32(16))
+}
+
+func (img *Image) commandsSize() int {
+	return int(img.u32(20))
+}
+
+// Bytes retrieves the encoded image.
+func (img *Image) Bytes() []byte {
+	return img.data
+}
+
+// retrieves the offsets of all load commands within the image
+func (img *Image) commands() []int {
+	commands := make([]int, 0, img.commandCount())
+
+	offset := headerSize
+	for i := 0; i < img.commandCount(); i++ {
+		commands = append(commands, offset)
+		offset += int(img.u32(offset + 4))
+	}
+
+	return commands
+}
+
+// locates the first load command of a given type
+//
+// returns -1 if no such command exists
+func (img *Image) findCommand(cmd uint32) int {
+	for _, offset := range img.commands() {
+		if img.u32(offset) == cmd {
+			return offset
+		}
+	}
+
+	return -1
+}
+
+// Segments retrieves the headers of all segments within the image in order of their respective
+// load commands.
+func (img *Image) Segments() []*Segment {
+	segments := make([]*Segment, 0)
+	for _, offset := range img.commands() {
+		if img.u32(offset) != loadCommandSegment64 {
+			continue
+		}
+
+		segments = append(segments, &Segment{
+			Name:     decodeName(img.data[offset+8 : offset+24]),
+			Address:  img.u64(offset + 24),
+			Size:     img.u64(offset + 32),
+			Offset:   img.u64(offset + 40),
+			FileSize: img.u64(offset + 48),
+			command:  offset,
+		})
+	}
+
+	return segments
+}
+
+// Segment retrieves the header of a segment with a given name.
+//
+// Returns nil if no such segment exists.
+func (img *Image) Segment(name string) *Segment {
+	for _, segment := range img.Segments() {
+		if segment.Name == name {
+			return segment
+		}
+	}
+
+	return nil
+}
+
+// Sections retrieves the headers of all sections within a given segment.
+func (img *Image) Sections(segment *Segment) []*Section {
+	count := int(img.u32(segment.command + 64))
+
+	sections := make([]*Section, count)
+	for i := range sections {
+		offset := segment.command + segmentCommandSize + i*sectionHeaderSize
+		sections[i] = &Section{
+			Name:    decodeName(img.data[offset : offset+16]),
+			Segment: decodeName(img.data[offset+16 : offset+32]),
+			Address: img.u64(offset + 32),
+			Size:    img.u64(offset + 40),
+			Offset:  img.u32(offset + 48),
+		}
+	}
+
+	return sections
+}
+
+// computes the amount of unused space between the load commands and the first section
+func (img *Image) headerPadding() int {
+	end := len(img.data)
+	for _, segment := range img.Segments() {
+		for _, section := range img.Sections(segment) {
+			if section.Offset != 0 && int(section.Offset) < end {
+				end = int(section.Offset)
+			}
+		}
+	}
+
+	return end - headerSize - img.commandsSize()
+}
+
+// inserts a given load command at a given offset within the load command area
+func (img *Image) insertCommand(offset int, command []byte) error {
+	if img.headerPadding() < len(command) {
+		return ErrInsufficientHeaderSpace
+	}
+
+	end := headerSize + img.commandsSize()
+	copy(img.data[offset+len(command):end+len(command)], img.data[offset:end])
+	copy(img.data[offset:], command)
+
+	img.putU32(16, uint32(img.commandCount()+1))
+	img.putU32(20, uint32(img.commandsSize()+len(command)))
+	return nil
+}
+
+// removes the load command at a given offset
+func (img *Image) removeCommand(offset int) {
+	size := int(img.u32(offset + 4))
+	end := headerSize + img.commandsSize()
+
+	copy(img.data[offset:], img.data[offset+size:end])
+	copy(img.data[end-size:end], make([]byte, size))
+
+	img.putU32(16, uint32(img.commandCount()-1))
+	img.putU32(20, uint32(img.commandsSize()-size))
+}
+
+// locates the __LINKEDIT segment and ensures that it is the last segment within the file
+func (img *Image) linkEdit() (*Segment, error) {
+	var linkEdit *Segment
+	for _, segment := range img.Segments() {
+		if segment.Name == linkEditSegmentName {
+			linkEdit = segment
+		}
+	}
+	if linkEdit == nil {
+		return nil, fmt.Errorf("%w: missing %s segment", ErrUnsupported, linkEditSegmentName)
+	}
+
+	for _, segment := range img.Segments() {
+		if segment.command != linkEdit.command && segment.FileSize != 0 && segment.Offset >= linkEdit.Offset {
+			return nil, fmt.Errorf("%w: %s is not the last segment", ErrUnsupported, linkEditSegmentName)
+		}
+	}
+	if linkEdit.Offset > uint64(len(img.data)) {
+		return nil, fmt.Errorf("%w: %s exceeds image bounds", ErrMalformed, linkEditSegmentName)
+	}
+
+	return linkEdit, nil
+}
+
+// computes the end of the __LINKEDIT contents excluding the code signature (if any)
+func (img *Image) linkEditEnd(linkEdit *Segment) (uint64, error) {
+	end := linkEdit.Offset + linkEdit.FileSize
+	if command := img.findCommand(loadCommandCodeSignature); command != -1 {
+		if offset := uint64(img.u32(command + 8)); offset != 0 && offset < end {
+			end = offset
+		}
+	}
+
+	if end > uint64(len(img.data)) || end < linkEdit.Offset {
+		return 0, fmt.Errorf("%w: %s exceeds image bounds", ErrMalformed, linkEditSegmentName)
+	}
+
+	return end, nil
+}
+
+// relocates the __LINKEDIT segment (along with all references to its contents) by a given amount
+// of bytes within the file and address space
+func (img *Image) moveLinkEdit(linkEdit *Segment, fileDelta int64, addressDelta int64) {
+	for _, offset := range img.commands() {
+		for _, field := range linkEditOffsetFields[img.u32(offset)] {
+			if value := img.u32(offset + field); value != 0 {
+				img.putU32(offset+field, uint32(int64(value)+fileDelta))
+			}
+		}
+	}
+
+	img.putU64(linkEdit.command+24, uint64(int64(linkEdit.Address)+addressDelta))
+	img.putU64(linkEdit.command+40, uint64(int64(linkEdit.Offset)+fileDelta))
+}
+
+// updates the size of the __LINKEDIT segment
+func (img *Image) resizeLinkEdit(linkEdit *Segment, size uint64) {
+	img.putU64(linkEdit.command+32, align(size, segmentAlignment))
+	img.putU64(linkEdit.command+48, size)
+}
+
+// SegmentOffset computes the file offset at which a segment added via AddSegment will be located.
+func (img *Image) SegmentOffset() (uint64, error) {
+	linkEdit, err := img.linkEdit()
+	if err != nil {
+		return 0, err
+	}
+
+	return align(linkEdit.Offset, segmentAlignment), nil
+}
+
+// AddSegment inserts a new read-only segment consisting of a single section in front of the
+// __LINKEDIT segment.
+//
+// The code signature of the image (if any) is discarded and must be recomputed via Sign once all
+// modifications have been applied. Returns the header of the new section.
+func (img *Image) AddSegment(segmentName string, sectionName string, contents []byte) (*Section, error) {
+	if len(segmentName) > 16 || len(sectionName) > 16 {
+		return nil, fmt.Errorf("illegal segment or section name: %s,%s", segmentName, sectionName)
+	}
+	if img.Segment(segmentName) != nil {
+		return nil, fmt.Errorf("%w: segment %s already exists", ErrUnsupported, segmentName)
+	}
+
+	linkEdit, err := img.linkEdit()
+	if err != nil {
+		return nil, err
+	}
+	linkEditEnd, err := img.linkEditEnd(linkEdit)
+	if err != nil {
+		return nil, err
+	}
+
+	// sections are numbered in order of their segment commands thus requiring the new segment to
+	// follow all existing sections in order to retain the numbering referenced by the symbol table
+	for _, segment := range img.Segments() {
+		if segment.command > linkEdit.command && len(img.Sections(segment)) != 0 {
+			return nil, fmt.Errorf("%w: sections follow %s", ErrUnsupported, linkEditSegmentName)
+		}
+	}
+
+	section := &Section{
+		Segment: segmentName,
+		Name:    sectionName,
+		Address: align(linkEdit.Address, segmentAlignment),
+		Size:    uint64(len(contents)),
+		Offset:  uint32(align(linkEdit.Offset, segmentAlignment)),
+	}
+	if uint64(section.Offset) != align(linkEdit.Offset, segmentAlignment) {
+		return nil, fmt.Errorf("%w: segment offset exceeds 32-bit range", ErrUnsupported)
+	}
+	segmentSize := align(section.Size, segmentAlignment)
+
+	command := make([]byte, segmentCommandSize+sectionHeaderSize)
+	binary.LittleEndian.PutUint32(command, loadCommandSegment64)
+	binary.LittleEndian.PutUint32(command[4:], uint32(len(command)))
+	copy(command[8:24], segmentName)
+	binary.LittleEndian.PutUint64(command[24:], section.Address)
+	binary.LittleEndian.PutUint64(command[32:], segmentSize)
+	binary.LittleEndian.PutUint64(command[40:], uint64(section.Offset))
+	binary.LittleEndian.PutUint64(command[48:], segmentSize)
+	binary.LittleEndian.PutUint32(command[56:], protectionRead)
+	binary.LittleEndian.PutUint32(command[60:], protectionRead)
+	binary.LittleEndian.PutUint32(command[64:], 1)
+
+	header := command[segmentCommandSize:]
+	copy(header[0:16], sectionName)
+	copy(header[16:32], segmentName)
+	binary.LittleEndian.PutUint64(header[32:], section.Address)
+	binary.LittleEndian.PutUint64(header[40:], section.Size)
+	binary.LittleEndian.PutUint32(header[48:], section.Offset)
+
+	linkEditContents := img.data[linkEdit.Offset:linkEditEnd]
+	head := img.data[:linkEdit.Offset]
+
+	data := make([]byte, uint64(section.Offset)+segmentSize, uint64(section.Offset)+segmentSize+uint64(len(linkEditContents)))
+	copy(data, head)
+	copy(data[section.Offset:], contents)
+	data = append(data, linkEditContents...)
+
+	updated := &Image{data: data}
+	if err := updated.insertCommand(linkEdit.command, command); err != nil {
+		return nil, err
+	}
+
+	// the segment command of __LINKEDIT has been shifted by the insertion of the new command
+	linkEdit.command += len(command)
+	updated.moveLinkEdit(linkEdit, int64(uint64(section.Offset)+segmentSize-linkEdit.Offset), int64(section.Address+segmentSize-linkEdit.Address))
+	updated.resizeLinkEdit(linkEdit, uint64(len(linkEditContents)))
+	updated.clearCodeSignature(uint64(len(data)))
+
+	img.data = updated.data
+	return section, nil
+}
+
+// RemoveSegment removes a segment which has previously been added via AddSegment.
+//
+// The code signature of the image (if any) is discarded and must be recomputed via Sign once all
+// modifications have been applied.
+func (img *Image) RemoveSegment(name string) error {
+	segment := img.Segment(name)
+	if segment == nil {
+		return fmt.Errorf("%w: missing segment %s", ErrUnsupported, name)
+	}
+
+	linkEdit, err := img.linkEdit()
+	if err != nil {
+		return err
+	}
+	linkEditEnd, err := img.linkEditEnd(linkEdit)
+	if err != nil {
+		return err
+	}
+	if segment.Offset+segment.FileSize != linkEdit.Offset || segment.Address+segment.Size != linkEdit.Address {
+		return fmt.Errorf("%w: segment %s does not directly precede %s", ErrUnsupported, name, linkEditSegmentName)
+	}
+
+	data := make([]byte, segment.Offset, segment.Offset+linkEditEnd-linkEdit.Offset)
+	copy(data, img.data[:segment.Offset])
+	data = append(data, img.data[linkEdit.Offset:linkEditEnd]...)
+
+	updated := &Image{data: data}
+	size := int(updated.u32(segment.command + 4))
+	updated.removeCommand(segment.command)
+	if linkEdit.command > segment.command {
+		linkEdit.command -= size
+	}
+
+	updated.moveLinkEdit(linkEdit, -int64(segment.FileSize), -int64(segment.Size))
+	updated.resizeLinkEdit(linkEdit, linkEditEnd-linkEdit.Offset)
+	updated.clearCodeSignature(uint64(len(data)))
+
+	img.data = updated.data
+	return nil
+}
+
+// aligns a given value to the next multiple of a given alignment
+func align(value uint64, alignment uint64) uint64 {
+	return (value + alignment - 1) / alignment * alignment
+}
+
+// decodes a null padded segment or section name
+func decodeName(name []byte) string {
+	return string(bytes.TrimRight(name, "\x00"))
+}
@@ -0,0 +1,350 @@
+/*
+ * Copyright 2021 Johannes Donath <johannesd@torchmind.com>
+ * and other copyright owners as documented in the project's IP log.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package macho
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"debug/macho"
+	"encoding/binary"
+	"errors"
+	"testing"
+)
+
+// assembles a minimal executable consisting of a __TEXT segment with a single section, a
+// __LINKEDIT segment referenced by a symbol table and a code signature command
+func testImage() []byte {
+	const textSize = 0x4000
+	const linkEditOffset = textSize
+	const linkEditSize = 0x40
+
+	commands := &bytes.Buffer{}
+	writeSegment := func(name string, address uint64, size uint64, offset uint64, fileSize uint64, sections ...macho.Section64) {
+		segment := macho.Segment64{
+			Cmd:     macho.LoadCmdSegment64,
+			Len:     uint32(segmentCommandSize + len(sections)*sectionHeaderSize),
+			Addr:    address,
+			Memsz:   size,
+			Offset:  offset,
+			Filesz:  fileSize,
+			Maxprot: 5,
+			Prot:    5,
+			Nsect:   uint32(len(sections)),
+		}
+		copy(segment.Name[:], name)
+		_ = binary.Write(commands, binary.LittleEndian, segment)
+		_ = binary.Write(commands, binary.LittleEndian, sections)
+	}
+
+	text := macho.Section64{
+		Addr:   0x100000000 + 0x1000,
+		Size:   0x10,
+		Offset: 0x1000,
+	}
+	copy(text.Name[:], "__text")
+	copy(text.Seg[:], "__TEXT")
+
+	writeSegment("__PAGEZERO", 0, 0x100000000, 0, 0)
+	writeSegment("__TEXT", 0x100000000, textSize, 0, textSize, text)
+	writeSegment("__LINKEDIT", 0x100000000+textSize, linkEditSize, linkEditOffset, linkEditSize)
+	_ = binary.Write(commands, binary.LittleEndian, macho.SymtabCmd{
+		Cmd:     macho.LoadCmdSymtab,
+		Len:     24,
+		Symoff:  linkEditOffset,
+		Nsyms:   0,
+		Stroff:  linkEditOffset + 0x20,
+		Strsize: 0x20,
+	})
+	_ = binary.Write(commands, binary.LittleEndian, []uint32{loadCommandCodeSignature, codeSignatureCommandSize, linkEditOffset + 0x40, 0})
+
+	data := make([]byte, linkEditOffset+linkEditSize)
+	_ = binary.Write(bytes.NewBuffer(data[:0]), binary.LittleEndian, macho.FileHeader{
+		Magic: macho.Magic64,
+		Cpu:   macho.CpuArm64,
+		Type:  macho.TypeExec,
+		Ncmd:  5,
+		Cmdsz: uint32(commands.Len()),
+		Flags: macho.FlagPIE,
+	})
+	copy(data[headerSize:], commands.Bytes())
+	data[0x1000] = 0xC0
+	copy(data[linkEditOffset+0x20:], "\x00_main\x00")
+
+	return data
+}
+
+// verifies the ad-hoc signature of a given image
+func verifySignature(t *testing.T, data []byte, identifier string) {
+	f, err := macho.NewFile(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("failed to parse image: %s", err)
+	}
+
+	var offset, size uint32
+	for _, load := range f.Loads {
+		raw := load.Raw()
+		if binary.LittleEndian.Uint32(raw) == loadCommandCodeSignature {
+			offset = binary.LittleEndian.Uint32(raw[8:])
+			size = binary.LittleEndian.Uint32(raw[12:])
+		}
+	}
+	if offset == 0 || int(offset+size) != len(data) {
+		t.Fatalf("expected code signature at end of image but got %d (%d bytes) within %d bytes", offset, size, len(data))
+	}
+	if linkEdit := f.Segment(linkEditSegmentName); linkEdit.Offset+linkEdit.Filesz != uint64(len(data)) {
+		t.Errorf("expected %s to span the code signature", linkEditSegmentName)
+	}
+
+	signature := data[offset:]
+	if binary.BigEndian.Uint32(signature) != codeSignatureMagic || binary.BigEndian.Uint32(signature[4:]) != size {
+		t.Fatalf("malformed signature header")
+	}
+
+	directory := signature[binary.BigEndian.Uint32(signature[16:]):]
+	if binary.BigEndian.Uint32(directory) != codeDirectoryMagic {
+		t.Fatalf("malformed code directory")
+	}
+
+	hashOffset := binary.BigEndian.Uint32(directory[16:])
+	identifierOffset := binary.BigEndian.Uint32(directory[20:])
+	pageCount := binary.BigEndian.Uint32(directory[28:])
+	codeLimit := binary.BigEndian.Uint32(directory[32:])
+	if codeLimit != offset {
+		t.Errorf("expected code limit %d but got %d", offset, codeLimit)
+	}
+	if actual := string(directory[identifierOffset : identifierOffset+uint32(len(identifier))]); actual != identifier {
+		t.Errorf("expected identifier %q but got %q", identifier, actual)
+	}
+
+	if expected := (codeLimit + codePageSize - 1) / codePageSize; pageCount != expected {
+		t.Fatalf("expected %d pages but got %d", expected, pageCount)
+	}
+	for i := uint32(0); i < pageCount; i++ {
+		end := (i + 1) * codePageSize
+		if end > codeLimit {
+			end = codeLimit
+		}
+
+		expected := sha256.Sum256(data[i*codePageSize : end])
+		actual := directory[hashOffset+i*sha256.Size : hashOffset+(i+1)*sha256.Size]
+		if !bytes.Equal(expected[:], actual) {
+			t.Errorf("digest mismatch for page %d", i)
+		}
+	}
+}
+
+func TestSign(t *testing.T) {
+	img, err := Parse(testImage())
+	if err != nil {
+		t.Fatalf("failed to parse image: %s", err)
+	}
+	if !img.IsSigned() {
+		t.Errorf("expected image to declare a code signature")
+	}
+
+	if err := img.Sign("foo"); err != nil {
+		t.Fatalf("failed to sign image: %s", err)
+	}
+	verifySignature(t, img.Bytes(), "foo")
+
+	if identifier := img.Identifier(); identifier != "foo" {
+		t.Errorf("expected identifier foo but got %q", identifier)
+	}
+
+	// signing an image again replaces its existing signature
+	signed := append([]byte{}, img.Bytes()...)
+	if err := img.Sign("foo"); err != nil {
+		t.Fatalf("failed to sign image: %s", err)
+	}
+	if !bytes.Equal(signed, img.Bytes()) {
+		t.Errorf("expected repeated signature to be identical")
+	}
+}
+
+func TestAddSegment(t *testing.T) {
+	img, err := Parse(testImage())
+	if err != nil {
+		t.Fatalf("failed to parse image: %s", err)
+	}
+
+	offset, err := img.SegmentOffset()
+	if err != nil {
+		t.Fatalf("failed to compute segment offset: %s", err)
+	}
+
+	contents := bytes.Repeat([]byte("payload"), 5000)
+	section, err := img.AddSegment("__CANOE", "__canoe", contents)
+	if err != nil {
+		t.Fatalf("failed to add segment: %s", err)
+	}
+	if uint64(section.Offset) != offset {
+		t.Errorf("expected section at offset %d but got %d", offset, section.Offset)
+	}
+	if err := img.Sign("foo"); err != nil {
+		t.Fatalf("failed to sign image: %s", err)
+	}
+	verifySignature(t, img.Bytes(), "foo")
+
+	f, err := macho.NewFile(bytes.NewReader(img.Bytes()))
+	if err != nil {
+		t.Fatalf("failed to parse image: %s", err)
+	}
+
+	embedded := f.Section("__canoe")
+	if embedded == nil || embedded.Seg != "__CANOE" {
+		t.Fatalf("expected __canoe section")
+	}
+	data, err := embedded.Data()
+	if err != nil {
+		t.Fatalf("failed to read section: %s", err)
+	}
+	if !bytes.Equal(data, contents) {
+		t.Errorf("section contents mismatch")
+	}
+
+	// the symbol table has moved along with __LINKEDIT
+	linkEdit := f.Segment(linkEditSegmentName)
+	symbolTable := img.findCommand(loadCommandSymbolTable)
+	if symbolOffset := img.u32(symbolTable + 8); symbolOffset != uint32(linkEdit.Offset) {
+		t.Errorf("expected symbol table at offset %d but got %d", linkEdit.Offset, symbolOffset)
+	}
+	if !bytes.HasPrefix(img.Bytes()[img.u32(symbolTable+16):], []byte("\x00_main\x00")) {
+		t.Errorf("expected string table to be relocated")
+	}
+
+	segment := f.Segment("__CANOE")
+	if segment.Offset+segment.Filesz != linkEdit.Offset || segment.Addr+segment.Memsz != linkEdit.Addr {
+		t.Errorf("expected %s to directly follow the new segment", linkEditSegmentName)
+	}
+	if segment.Prot != protectionRead {
+		t.Errorf("expected read-only segment but got protection %d", segment.Prot)
+	}
+
+	if _, err := img.AddSegment("__CANOE", "__canoe", contents); err == nil {
+		t.Errorf("expected duplicate segment to be rejected")
+	}
+}
+
+func TestRemoveSegment(t *testing.T) {
+	img, err := Parse(testImage())
+	if err != nil {
+		t.Fatalf("failed to parse image: %s", err)
+	}
+	if err := img.Sign("foo"); err != nil {
+		t.Fatalf("failed to sign image: %s", err)
+	}
+	original := append([]byte{}, img.Bytes()...)
+
+	if _, err := img.AddSegment("__CANOE", "__canoe", []byte("payload")); err != nil {
+		t.Fatalf("failed to add segment: %s", err)
+	}
+	if err := img.Sign("foo"); err != nil {
+		t.Fatalf("failed to sign image: %s", err)
+	}
+
+	if err := img.RemoveSegment("__CANOE"); err != nil {
+		t.Fatalf("failed to remove segment: %s", err)
+	}
+	if err := img.Sign("foo"); err != nil {
+		t.Fatalf("failed to sign image: %s", err)
+	}
+	if !bytes.Equal(original, img.Bytes()) {
+		t.Errorf("expected image to be restored")
+	}
+}
+
+func TestSignUnsigned(t *testing.T) {
+	data := testImage()
+	img, err := Parse(data)
+	if err != nil {
+		t.Fatalf("failed to parse image: %s", err)
+	}
+
+	// strip the code signature command
+	commands := img.commands()
+	img.removeCommand(commands[len(commands)-1])
+	if img.IsSigned() {
+		t.Fatalf("expected code signature command to be removed")
+	}
+
+	if err := img.Sign("foo"); err != nil {
+		t.Fatalf("failed to sign image: %s", err)
+	}
+	verifySignature(t, img.Bytes(), "foo")
+}
+
+func TestParseMalformed(t *testing.T) {
+	if _, err := Parse([]byte("#!/bin/sh\n")); !errors.Is(err, ErrMalformed) {
+		t.Errorf("expected malformed image but got %v", err)
+	}
+}
+
+func TestFat(t *testing.T) {
+	arm64, err := Parse(testImage())
+	if err != nil {
+		t.Fatalf("failed to parse image: %s", err)
+	}
+
+	amd64Data := testImage()
+	binary.LittleEndian.PutUint32(amd64Data[4:], uint32(macho.CpuAmd64))
+	binary.LittleEndian.PutUint32(amd64Data[8:], 3)
+	amd64, err := Parse(amd64Data)
+	if err != nil {
+		t.Fatalf("failed to parse image: %s", err)
+	}
+
+	data, err := Fat(amd64, arm64)
+	if err != nil {
+		t.Fatalf("failed to assemble universal binary: %s", err)
+	}
+	if !IsFat(data) {
+		t.Errorf("expected universal binary to be identified")
+	}
+
+	f, err := macho.NewFatFile(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("failed to parse universal binary: %s", err)
+	}
+	if len(f.Arches) != 2 || f.Arches[0].Cpu != macho.CpuAmd64 || f.Arches[1].Cpu != macho.CpuArm64 {
+		t.Fatalf("unexpected architectures %+v", f.Arches)
+	}
+
+	for i, expected := range [][]byte{amd64Data, testImage()} {
+		arch := f.Arches[i]
+		if arch.Offset%(1<<fatAlignment) != 0 || arch.Align != fatAlignment {
+			t.Errorf("expected slice %d to be aligned but got offset %#x", i, arch.Offset)
+		}
+		if !bytes.Equal(data[arch.Offset:arch.Offset+arch.Size], expected) {
+			t.Errorf("unexpected contents within slice %d", i)
+		}
+	}
+
+	slices, err := Slices(data)
+	if err != nil {
+		t.Fatalf("failed to split universal binary: %s", err)
+	}
+	if len(slices) != 2 || !bytes.Equal(slices[0].Bytes(), amd64Data) || !bytes.Equal(slices[1].Bytes(), testImage()) {
+		t.Errorf("expected slices to match the original images")
+	}
+	if _, err := Slices(data[:fatHeaderSize+fatArchEntrySize]); !errors.Is(err, ErrMalformed) {
+		t.Errorf("expected truncated universal binary to be rejected but got %v", err)
+	}
+
+	if _, err := Fat(arm64, arm64); !errors.Is(err, ErrUnsupported) {
+		t.Errorf("expected duplicate CPU types to be rejected but got %v", err)
+	}
+}
@@ -17,67 +17,468 @@
 package internal
 
 import (
+	"bytes"
+	"debug/elf"
+	"debug/macho"
+	"debug/pe"
 	"encoding/binary"
+	"errors"
 	"fmt"
+	"github.com/dotstart/canoe/internal/archive"
 	"github.com/dotstart/canoe/internal/metadata"
 	"github.com/golang/protobuf/proto"
 	"io"
+	"math"
 	"os"
+	goruntime "runtime"
 )
 
-const expectedMagicNumber = 0xBADC0FEE
-const footerSize = 4 + 2 // Magic number + Length
+// FooterFormatVersion identifies the revision of the footer format written by this version of
+// canoe.
+const FooterFormatVersion = 2
+
+// identify the wrapper revisions which introduced the respective launch behavior
+const (
+	// BaseWrapperVersion identifies the initial wrapper revision.
+	BaseWrapperVersion = 1
+
+	// LaunchAttributesWrapperVersion identifies the revision which translates the launch attributes
+	// of the archive manifest (such as Add-Opens or Launcher-Agent-Class) into runtime options.
+	LaunchAttributesWrapperVersion = 2
+
+	// PayloadSectionWrapperVersion identifies the revision which locates payloads within dedicated
+	// Mach-O and ELF sections.
+	PayloadSectionWrapperVersion = 3
+
+	// DesktopWrapperVersion identifies the revision which passes its command line arguments (such
+	// as the documents or URLs passed by desktop environments) to the application.
+	DesktopWrapperVersion = 4
+)
+
+// WrapperVersion identifies the revision of the wrapper implementation. It is incremented whenever
+// executables may rely on launch behavior which previous wrapper revisions do not provide.
+const WrapperVersion = DesktopWrapperVersion
+
+const legacyMagicNumber = 0xBADC0FEE
+const legacyFooterSize = 4 + 2 // Magic number + Length
+
+const footerMagicNumber = 0xCA0EC0DE
+const footerTrailerSize = 2 + 2 + 1 + 4          // Footer length + Minimum wrapper version + Format version + Magic number
+const footerSize = 8 + 8 + 4 + footerTrailerSize // Payload offset + Payload length + Metadata length + Trailer
+
+// identify the segment and section which embed the payload and container metadata within signed
+// Mach-O executables
+const PayloadSegmentName = "__CANOE"
+const PayloadSectionName = "__canoe"
+
+// identifies the non-allocated section which embeds the payload and container metadata within ELF
+// executables (when requested)
+const ELFPayloadSectionName = ".canoe"
 
 var byteOrder = binary.BigEndian
 
+var ErrMissingFooter = errors.New("no wrapper footer present")
+var ErrCorruptFooter = errors.New("corrupt wrapper footer")
+var ErrFooterOverflow = errors.New("container metadata exceeds maximum size")
+var ErrUnsupportedContainer = errors.New("executable requires a newer wrapper")
+
+// Footer describes the layout of a given canoe executable.
+//
+// Executables consist of a wrapper executable, the embedded application payload, the encoded
+// container metadata and a fixed size footer (in that order). Legacy executables (format version 1)
+// do not record the location of their payload in which case PayloadOffset and PayloadLength are
+// set to zero.
+type Footer struct {
+	FormatVersion         uint8
+	MinimumWrapperVersion uint16
+
+	PayloadOffset uint64
+	PayloadLength uint64
+
+	MetadataOffset uint64
+	MetadataLength uint64
+
+	// identifies the offset of the image which embeds the payload within universal Mach-O
+	// executables (zero otherwise)
+	//
+	// this field is not encoded within the footer but derived from the layout of the executable
+	WrapperOffset uint64
+
+	// identifies the offset of the section which embeds the payload and container metadata within
+	// Mach-O and ELF executables (zero otherwise)
+	//
+	// this field is not encoded within the footer but derived from the layout of the executable
+	SectionOffset uint64
+}
+
+// RequiredWrapperVersion computes the minimum wrapper revision which is capable of launching a
+// given application.
+//
+// Executables which embed their payload within a dedicated section additionally require a wrapper
+// which is capable of locating it.
+func RequiredWrapperVersion(meta *metadata.ApplicationContainer, payloadSection bool) uint16 {
+	application := meta.GetApplication()
+	desktop := meta.GetDesktop()
+
+	switch {
+	case len(desktop.GetDocumentTypes()) != 0 || len(desktop.GetUrlSchemes()) != 0:
+		return DesktopWrapperVersion
+	case payloadSection:
+		return PayloadSectionWrapperVersion
+	case len(application.GetAddOpens()) != 0 || len(application.GetAddExports()) != 0 || application.GetEnableNativeAccess() || len(application.GetLauncherAgentClass()) != 0 || len(application.GetClassPath()) != 0:
+		return LaunchAttributesWrapperVersion
+	}
+
+	return BaseWrapperVersion
+}
+
+// ReadExecutableFooter retrieves the container metadata from a given canoe executable.
 func ReadExecutableFooter(target string) (*metadata.ApplicationContainer, error) {
+	_, meta, err := ReadExecutableContainer(target)
+	return meta, err
+}
+
+// ReadExecutableContainer retrieves the footer and container metadata from a given canoe
+// executable.
+func ReadExecutableContainer(target string) (*Footer, *metadata.ApplicationContainer, error) {
 	f, err := os.OpenFile(target, os.O_RDONLY, 0)
 	if err != nil {
-		return nil, fmt.Errorf("failed to open target: %w", err)
+		return nil, nil, fmt.Errorf("failed to open target: %w", err)
+	}
+	defer f.Close()
+
+	stat, err := f.Stat()
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to stat target: %w", err)
+	}
+
+	return DecodeExecutableFooter(f, stat.Size())
+}
+
+// DecodeExecutableFooter decodes the footer and container metadata from a given executable of the
+// specified size.
+func DecodeExecutableFooter(r io.ReaderAt, size int64) (*Footer, *metadata.ApplicationContainer, error) {
+	footer, err := decodeFooter(r, size, false)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	heap := make([]byte, footer.MetadataLength)
+	if _, err := r.ReadAt(heap, int64(footer.MetadataOffset)); err != nil {
+		return nil, nil, fmt.Errorf("failed to read container metadata: %w", err)
 	}
 
-	// size field is 16-bit
-	if _, err := f.Seek(-footerSize, io.SeekEnd); err != nil {
-		return nil, fmt.Errorf("failed to seek to wrapper footer: %w", err)
+	var meta metadata.ApplicationContainer
+	if err := proto.Unmarshal(heap, &meta); err != nil {
+		return nil, nil, fmt.Errorf("failed to unmarshal container metadata: %w", err)
+	}
+
+	return footer, &meta, nil
+}
+
+// decodes the footer at the end of a given executable
+//
+// when padded is set, the executable is followed by an Authenticode certificate table which is
+// required to be aligned to eight bytes (in which case the payload may be followed by padding)
+func decodeFooter(r io.ReaderAt, size int64, padded bool) (*Footer, error) {
+	if size < legacyFooterSize {
+		return nil, ErrMissingFooter
 	}
 
 	var magicNumber uint32
-	if err := binary.Read(f, byteOrder, &magicNumber); err != nil {
+	if err := binary.Read(io.NewSectionReader(r, size-4, 4), byteOrder, &magicNumber); err != nil {
 		return nil, fmt.Errorf("failed to decode magic number: %w", err)
 	}
 
-	if magicNumber != expectedMagicNumber {
-		return nil, fmt.Errorf("magic number mismatch")
+	if magicNumber == footerMagicNumber {
+		return decodeCurrentFooter(r, size, padded)
 	}
 
-	var length uint16
-	if err := binary.Read(f, byteOrder, &length); err != nil {
-		return nil, fmt.Errorf("failed to decode footer size: %w", err)
+	// Authenticode signatures are appended to the end of signed executables thus requiring us to
+	// look for the footer in front of the signature instead
+	if end, ok := certificateTableOffset(r, size); ok {
+		return decodeFooter(r, end, true)
 	}
 
-	if _, err := f.Seek(-(footerSize + int64(length)), 2); err != nil {
-		return nil, fmt.Errorf("failed to seek to wrapper configuration: %w", err)
+	// signed Mach-O executables and ELF executables (when requested) carry their payload and
+	// metadata within a dedicated section instead
+	if base, offset, length, ok := payloadSection(r, size); ok {
+		return decodeSectionFooter(r, base, offset, length)
 	}
 
-	heap := make([]byte, length)
-	if _, err := f.Read(heap); err != nil {
-		return nil, fmt.Errorf("failed to read container metadata: %w", err)
+	return decodeLegacyFooter(r, size)
+}
+
+// identifies the offset of the Authenticode certificate table within a given PE executable
+//
+// returns false if the executable is not a PE image or if its certificate table is not located at
+// the end of the file
+func certificateTableOffset(r io.ReaderAt, size int64) (int64, bool) {
+	f, err := pe.NewFile(io.NewSectionReader(r, 0, size))
+	if err != nil {
+		return 0, false
 	}
 
-	var meta metadata.ApplicationContainer
-	if err := proto.Unmarshal(heap, &meta); err != nil {
-		return nil, fmt.Errorf("failed to unmarshal container metadata: %w", err)
+	var directory pe.DataDirectory
+	switch header := f.OptionalHeader.(type) {
+	case *pe.OptionalHeader32:
+		if header.NumberOfRvaAndSizes <= pe.IMAGE_DIRECTORY_ENTRY_SECURITY {
+			return 0, false
+		}
+		directory = header.DataDirectory[pe.IMAGE_DIRECTORY_ENTRY_SECURITY]
+	case *pe.OptionalHeader64:
+		if header.NumberOfRvaAndSizes <= pe.IMAGE_DIRECTORY_ENTRY_SECURITY {
+			return 0, false
+		}
+		directory = header.DataDirectory[pe.IMAGE_DIRECTORY_ENTRY_SECURITY]
+	default:
+		return 0, false
 	}
 
-	return &meta, nil
+	offset := int64(directory.VirtualAddress)
+	if offset == 0 || directory.Size == 0 || offset+int64(directory.Size) != size {
+		return 0, false
+	}
+
+	return offset, true
 }
 
-func WriteExecutableFooter(writer io.Writer, meta *metadata.ApplicationContainer) (int, error) {
-	length := 0
+// decodes a footer which resides at the end of a given payload section
+//
+// offsets within section footers are relative to the beginning of their section as tools such as
+// strip or objcopy may relocate sections which are not mapped into memory. The base identifies the
+// offset of the image which declares the section within universal binaries.
+func decodeSectionFooter(r io.ReaderAt, base int64, offset int64, length int64) (*Footer, error) {
+	footer, err := decodeFooter(io.NewSectionReader(r, offset, length), length, false)
+	if err != nil {
+		return nil, err
+	}
+
+	footer.PayloadOffset += uint64(offset)
+	footer.MetadataOffset += uint64(offset)
+	footer.WrapperOffset = uint64(base)
+	footer.SectionOffset = uint64(offset)
+	return footer, nil
+}
+
+// PayloadSection identifies the location of the section which embeds the payload and container
+// metadata within a given Mach-O or ELF executable.
+//
+// Universal Mach-O executables embed a copy of the payload within each of their images. In this
+// case, the image which matches the architecture of the current process is preferred.
+//
+// Returns false if the executable is neither a Mach-O nor an ELF image or does not contain a
+// payload section.
+func PayloadSection(r io.ReaderAt, size int64) (int64, int64, bool) {
+	_, offset, length, ok := payloadSection(r, size)
+	return offset, length, ok
+}
+
+// identifies the location of the payload section along with the offset of the image which declares
+// it (non-zero within universal binaries only)
+func payloadSection(r io.ReaderAt, size int64) (int64, int64, int64, bool) {
+	if offset, length, ok := elfPayloadSection(r, size); ok {
+		return 0, offset, length, true
+	}
+
+	if fat, err := macho.NewFatFile(io.NewSectionReader(r, 0, size)); err == nil {
+		arch := fat.Arches[0]
+		for _, candidate := range fat.Arches {
+			if machoArchitectures[candidate.Cpu] == goruntime.GOARCH {
+				arch = candidate
+			}
+		}
+
+		offset, length, ok := machoPayloadSection(arch.File, int64(arch.Size))
+		if !ok || int64(arch.Offset)+int64(arch.Size) > size {
+			return 0, 0, 0, false
+		}
+
+		return int64(arch.Offset), int64(arch.Offset) + offset, length, true
+	}
 
+	f, err := macho.NewFile(io.NewSectionReader(r, 0, size))
+	if err != nil {
+		return 0, 0, 0, false
+	}
+
+	offset, length, ok := machoPayloadSection(f, size)
+	return 0, offset, length, ok
+}
+
+// identifies the location of the payload section within a given Mach-O image of the specified size
+func machoPayloadSection(f *macho.File, size int64) (int64, int64, bool) {
+	for _, section := range f.Sections {
+		if section.Seg != PayloadSegmentName || section.Name != PayloadSectionName {
+			continue
+		}
+
+		offset := int64(section.Offset)
+		length := int64(section.Size)
+		if offset == 0 || offset+length > size {
+			return 0, 0, false
+		}
+
+		return offset, length, true
+	}
+
+	return 0, 0, false
+}
+
+// identifies the location of the payload section within a given ELF executable
+func elfPayloadSection(r io.ReaderAt, size int64) (int64, int64, bool) {
+	f, err := elf.NewFile(io.NewSectionReader(r, 0, size))
+	if err != nil {
+		return 0, 0, false
+	}
+
+	section := f.Section(ELFPayloadSectionName)
+	if section == nil || section.Type == elf.SHT_NOBITS || section.Flags&elf.SHF_ALLOC != 0 {
+		return 0, 0, false
+	}
+
+	offset := int64(section.Offset)
+	length := int64(section.Size)
+	if offset == 0 || offset+length > size {
+		return 0, 0, false
+	}
+
+	return offset, length, true
+}
+
+// decodes a footer of format version 2 or newer
+//
+// newer revisions of the format may only prepend fields to the footer thus permitting older
+// implementations to decode all fields they are aware of.
+//
+// the payload is required to end at the beginning of the container metadata as any data in between
+// would not be covered by digests or signatures (while the runtime may still locate an archive
+// within it). Padded executables may only separate both by the zeroed padding which aligns the
+// subsequent certificate table.
+func decodeCurrentFooter(r io.ReaderAt, size int64, padded bool) (*Footer, error) {
+	if size < footerSize {
+		return nil, fmt.Errorf("%w: truncated footer", ErrCorruptFooter)
+	}
+
+	var fields struct {
+		PayloadOffset         uint64
+		PayloadLength         uint64
+		MetadataLength        uint32
+		FooterLength          uint16
+		MinimumWrapperVersion uint16
+		FormatVersion         uint8
+	}
+	if err := binary.Read(io.NewSectionReader(r, size-footerSize, footerSize-4), byteOrder, &fields); err != nil {
+		return nil, fmt.Errorf("failed to decode footer: %w", err)
+	}
+
+	if fields.FormatVersion < FooterFormatVersion || fields.FooterLength < footerSize {
+		return nil, fmt.Errorf("%w: illegal format version %d with length %d", ErrCorruptFooter, fields.FormatVersion, fields.FooterLength)
+	}
+
+	metadataEnd := size - int64(fields.FooterLength)
+	metadataOffset := metadataEnd - int64(fields.MetadataLength)
+	if metadataOffset < 0 {
+		return nil, fmt.Errorf("%w: metadata exceeds executable bounds", ErrCorruptFooter)
+	}
+	payloadEnd := fields.PayloadOffset + fields.PayloadLength
+	if payloadEnd < fields.PayloadOffset || payloadEnd > uint64(metadataOffset) {
+		return nil, fmt.Errorf("%w: payload exceeds executable bounds", ErrCorruptFooter)
+	}
+
+	padding := uint64(0)
+	if padded {
+		padding = (8 - (payloadEnd+uint64(size-metadataOffset))%8) % 8
+	}
+	if payloadEnd+padding != uint64(metadataOffset) {
+		return nil, fmt.Errorf("%w: payload is not followed by container metadata", ErrCorruptFooter)
+	}
+
+	if padding != 0 {
+		gap := make([]byte, padding)
+		if _, err := r.ReadAt(gap, int64(payloadEnd)); err != nil {
+			return nil, fmt.Errorf("failed to read padding: %w", err)
+		}
+		if !bytes.Equal(gap, make([]byte, padding)) {
+			return nil, fmt.Errorf("%w: illegal padding", ErrCorruptFooter)
+		}
+	}
+
+	return &Footer{
+		FormatVersion:         fields.FormatVersion,
+		MinimumWrapperVersion: fields.MinimumWrapperVersion,
+		PayloadOffset:         fields.PayloadOffset,
+		PayloadLength:         fields.PayloadLength,
+		MetadataOffset:        uint64(metadataOffset),
+		MetadataLength:        uint64(fields.MetadataLength),
+	}, nil
+}
+
+// decodes a legacy (format version 1) footer which consists of a magic number and 16-bit metadata
+// length
+func decodeLegacyFooter(r io.ReaderAt, size int64) (*Footer, error) {
+	var fields struct {
+		MagicNumber uint32
+		Length      uint16
+	}
+	if err := binary.Read(io.NewSectionReader(r, size-legacyFooterSize, legacyFooterSize), byteOrder, &fields); err != nil {
+		return nil, fmt.Errorf("failed to decode legacy footer: %w", err)
+	}
+
+	if fields.MagicNumber != legacyMagicNumber {
+		return nil, fmt.Errorf("%w: magic number mismatch", ErrMissingFooter)
+	}
+
+	metadataOffset := size - legacyFooterSize - int64(fields.Length)
+	if metadataOffset < 0 {
+		return nil, fmt.Errorf("%w: metadata exceeds executable bounds", ErrCorruptFooter)
+	}
+
+	return &Footer{
+		FormatVersion:  1,
+		MetadataOffset: uint64(metadataOffset),
+		MetadataLength: uint64(fields.Length),
+	}, nil
+}
+
+// LocatePayload identifies the location of the payload within a given executable.
+//
+// Legacy executables do not record the location of their payload. In this case, the payload
+// location is derived from the central directory of the embedded archive instead.
+func LocatePayload(r io.ReaderAt, footer *Footer) (uint64, uint64, error) {
+	if footer.FormatVersion >= FooterFormatVersion {
+		return footer.PayloadOffset, footer.PayloadLength, nil
+	}
+
+	start, err := archive.Locate(r, int64(footer.MetadataOffset))
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to locate payload: %w", err)
+	}
+
+	return uint64(start), footer.MetadataOffset - uint64(start), nil
+}
+
+// WriteExecutableFooter encodes the container metadata along with a footer describing the layout
+// of the executable. The format version field of the passed footer is ignored as footers are
+// always written using the current format version.
+//
+// Returns the total amount of bytes written.
+func WriteExecutableFooter(writer io.Writer, footer *Footer, meta *metadata.ApplicationContainer) (int, error) {
 	encoded, err := proto.Marshal(meta)
 	if err != nil {
-		return length, fmt.Errorf("failed to encode container metadata: %w", err)
+		return 0, fmt.Errorf("failed to encode container metadata: %w", err)
+	}
+
+	return writeFooter(writer, footer, encoded)
+}
+
+// writes a given set of encoded container metadata along with a footer describing the layout of the
+// executable
+func writeFooter(writer io.Writer, footer *Footer, encoded []byte) (int, error) {
+	length := 0
+
+	if uint64(len(encoded)) > math.MaxUint32 {
+		return length, fmt.Errorf("%w: %d bytes exceed limit of %d bytes", ErrFooterOverflow, len(encoded), uint32(math.MaxUint32))
 	}
 
 	if _, err := writer.Write(encoded); err != nil {
@@ -85,9 +486,26 @@ func WriteExecutableFooter(writer io.Writer, meta *metadata.ApplicationContainer
 	}
 	length += len(encoded)
 
-	if err := binary.Write(writer, byteOrder, uint32(expectedMagicNumber)); err != nil {
+	fields := struct {
+		PayloadOffset         uint64
+		PayloadLength         uint64
+		MetadataLength        uint32
+		FooterLength          uint16
+		MinimumWrapperVersion uint16
+		FormatVersion         uint8
+		MagicNumber           uint32
+	}{
+		PayloadOffset:         footer.PayloadOffset,
+		PayloadLength:         footer.PayloadLength,
+		MetadataLength:        uint32(len(encoded)),
+		FooterLength:          footerSize,
+		MinimumWrapperVersion: footer.MinimumWrapperVersion,
+		FormatVersion:         FooterFormatVersion,
+		MagicNumber:           footerMagicNumber,
+	}
+	if err := binary.Write(writer, byteOrder, &fields); err != nil {
 		return length, err
 	}
 
-	return length, binary.Write(writer, byteOrder, uint16(length))
+	return length + footerSize, nil
 }
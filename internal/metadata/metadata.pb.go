@@ -1,13 +1,12 @@
 // Code generated by protoc-gen-go. DO NOT EDIT.
 // versions:
-// 	protoc-gen-go v1.25.0
+// 	protoc-gen-go v1.27.1
 // 	protoc        v3.18.0
 // source: metadata.proto
 
 package metadata
 
 import (
-	proto "github.com/golang/protobuf/proto"
 	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
 	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
 	reflect "reflect"
@@ -21,10 +20,6 @@ const (
 	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
 )
 
-// This is a compile-time assertion that a sufficiently up-to-date version
-// of the legacy proto package is being used.
-const _ = proto.ProtoPackageIsVersion4
-
 // encapsulates the parameters of a given application container
 type ApplicationContainer struct {
 	state         protoimpl.MessageState
@@ -42,6 +37,22 @@ type ApplicationContainer struct {
 	// provides various configuration parameters which affect how applications are
 	// launched
 	Application *ApplicationConfiguration `protobuf:"bytes,21,opt,name=application,proto3" json:"application,omitempty"`
+	// provides human readable information on the wrapped application
+	Identity *ApplicationIdentity `protobuf:"bytes,22,opt,name=identity,proto3" json:"identity,omitempty"`
+	// provides various configuration parameters which affect how errors are
+	// reported to users
+	Support *SupportConfiguration `protobuf:"bytes,23,opt,name=support,proto3" json:"support,omitempty"`
+	// provides digests which permit the wrapper to verify the integrity of the
+	// executable prior to launching the application
+	Integrity *IntegrityConfiguration `protobuf:"bytes,24,opt,name=integrity,proto3" json:"integrity,omitempty"`
+	// provides a signature over the remaining container metadata
+	//
+	// this field is excluded from the signed data and is thus always appended to
+	// the encoded metadata
+	Signature *SignatureConfiguration `protobuf:"bytes,25,opt,name=signature,proto3" json:"signature,omitempty"`
+	// provides various configuration parameters which affect how the application
+	// integrates with desktop environments
+	Desktop *DesktopConfiguration `protobuf:"bytes,26,opt,name=desktop,proto3" json:"desktop,omitempty"`
 }
 
 func (x *ApplicationContainer) Reset() {
@@ -104,6 +115,41 @@ func (x *ApplicationContainer) GetApplication() *ApplicationConfiguration {
 	return nil
 }
 
+func (x *ApplicationContainer) GetIdentity() *ApplicationIdentity {
+	if x != nil {
+		return x.Identity
+	}
+	return nil
+}
+
+func (x *ApplicationContainer) GetSupport() *SupportConfiguration {
+	if x != nil {
+		return x.Support
+	}
+	return nil
+}
+
+func (x *ApplicationContainer) GetIntegrity() *IntegrityConfiguration {
+	if x != nil {
+		return x.Integrity
+	}
+	return nil
+}
+
+func (x *ApplicationContainer) GetSignature() *SignatureConfiguration {
+	if x != nil {
+		return x.Signature
+	}
+	return nil
+}
+
+func (x *ApplicationContainer) GetDesktop() *DesktopConfiguration {
+	if x != nil {
+		return x.Desktop
+	}
+	return nil
+}
+
 // encapsulates various configuration parameters which shall be passed to the
 // runtime upon application startup
 type RuntimeConfiguration struct {
@@ -129,6 +175,10 @@ type RuntimeConfiguration struct {
 	//
 	// omitted from runtime arguments if set to zero
 	MemoryLimit uint64 `protobuf:"varint,11,opt,name=memory_limit,json=memoryLimit,proto3" json:"memory_limit,omitempty"`
+	// lists the runtime modules which are referenced by the wrapped application
+	//
+	// informational only (e.g. for the purposes of assembling a minimal runtime)
+	RequiredModules []string `protobuf:"bytes,20,rep,name=required_modules,json=requiredModules,proto3" json:"required_modules,omitempty"`
 	// specifies additional command line arguments which are to be passed to the
 	// runtime upon application startup
 	AdditionalArguments string `protobuf:"bytes,100,opt,name=additional_arguments,json=additionalArguments,proto3" json:"additional_arguments,omitempty"`
@@ -194,6 +244,13 @@ func (x *RuntimeConfiguration) GetMemoryLimit() uint64 {
 	return 0
 }
 
+func (x *RuntimeConfiguration) GetRequiredModules() []string {
+	if x != nil {
+		return x.RequiredModules
+	}
+	return nil
+}
+
 func (x *RuntimeConfiguration) GetAdditionalArguments() string {
 	if x != nil {
 		return x.AdditionalArguments
@@ -211,6 +268,24 @@ type ApplicationConfiguration struct {
 	// identifies the primary application class which shall be launched within the
 	// target runtime
 	MainClass string `protobuf:"bytes,1,opt,name=main_class,json=mainClass,proto3" json:"main_class,omitempty"`
+	// lists packages (in the form of module/package) which shall be opened to the
+	// application (equivalent to the Add-Opens manifest attribute)
+	AddOpens []string `protobuf:"bytes,2,rep,name=add_opens,json=addOpens,proto3" json:"add_opens,omitempty"`
+	// lists packages (in the form of module/package) which shall be exported to
+	// the application (equivalent to the Add-Exports manifest attribute)
+	AddExports []string `protobuf:"bytes,3,rep,name=add_exports,json=addExports,proto3" json:"add_exports,omitempty"`
+	// identifies whether the application requires access to restricted native
+	// methods (equivalent to the Enable-Native-Access manifest attribute)
+	EnableNativeAccess bool `protobuf:"varint,4,opt,name=enable_native_access,json=enableNativeAccess,proto3" json:"enable_native_access,omitempty"`
+	// identifies an agent class which shall be started before the application
+	// main class (equivalent to the Launcher-Agent-Class manifest attribute)
+	//
+	// agents are started via -javaagent which invokes their premain method and
+	// thus requires the Premain-Class manifest attribute to name the same class
+	LauncherAgentClass string `protobuf:"bytes,5,opt,name=launcher_agent_class,json=launcherAgentClass,proto3" json:"launcher_agent_class,omitempty"`
+	// lists additional class path entries relative to the location of the
+	// executable (equivalent to the Class-Path manifest attribute)
+	ClassPath []string `protobuf:"bytes,6,rep,name=class_path,json=classPath,proto3" json:"class_path,omitempty"`
 }
 
 func (x *ApplicationConfiguration) Reset() {
@@ -252,11 +327,598 @@ func (x *ApplicationConfiguration) GetMainClass() string {
 	return ""
 }
 
+func (x *ApplicationConfiguration) GetAddOpens() []string {
+	if x != nil {
+		return x.AddOpens
+	}
+	return nil
+}
+
+func (x *ApplicationConfiguration) GetAddExports() []string {
+	if x != nil {
+		return x.AddExports
+	}
+	return nil
+}
+
+func (x *ApplicationConfiguration) GetEnableNativeAccess() bool {
+	if x != nil {
+		return x.EnableNativeAccess
+	}
+	return false
+}
+
+func (x *ApplicationConfiguration) GetLauncherAgentClass() string {
+	if x != nil {
+		return x.LauncherAgentClass
+	}
+	return ""
+}
+
+func (x *ApplicationConfiguration) GetClassPath() []string {
+	if x != nil {
+		return x.ClassPath
+	}
+	return nil
+}
+
+// encapsulates human readable information on the wrapped application
+type ApplicationIdentity struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// identifies the name of the application as displayed to users
+	Name string `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	// identifies the application version (e.g. "1.2.0")
+	Version string `protobuf:"bytes,2,opt,name=version,proto3" json:"version,omitempty"`
+	// identifies the organization or individual which distributes the application
+	Vendor string `protobuf:"bytes,3,opt,name=vendor,proto3" json:"vendor,omitempty"`
+	// provides a copyright notice (e.g. "Copyright (c) 2021 Example Inc.")
+	Copyright string `protobuf:"bytes,4,opt,name=copyright,proto3" json:"copyright,omitempty"`
+	// provides a short human readable description of the application
+	Description string `protobuf:"bytes,5,opt,name=description,proto3" json:"description,omitempty"`
+	// identifies a URL at which further information on the application may be
+	// obtained
+	Homepage string `protobuf:"bytes,6,opt,name=homepage,proto3" json:"homepage,omitempty"`
+	// identifies the application in reverse domain name notation (e.g.
+	// "org.example.foo")
+	Identifier string `protobuf:"bytes,7,opt,name=identifier,proto3" json:"identifier,omitempty"`
+}
+
+func (x *ApplicationIdentity) Reset() {
+	*x = ApplicationIdentity{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_metadata_proto_msgTypes[3]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *ApplicationIdentity) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ApplicationIdentity) ProtoMessage() {}
+
+func (x *ApplicationIdentity) ProtoReflect() protoreflect.Message {
+	mi := &file_metadata_proto_msgTypes[3]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ApplicationIdentity.ProtoReflect.Descriptor instead.
+func (*ApplicationIdentity) Descriptor() ([]byte, []int) {
+	return file_metadata_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *ApplicationIdentity) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *ApplicationIdentity) GetVersion() string {
+	if x != nil {
+		return x.Version
+	}
+	return ""
+}
+
+func (x *ApplicationIdentity) GetVendor() string {
+	if x != nil {
+		return x.Vendor
+	}
+	return ""
+}
+
+func (x *ApplicationIdentity) GetCopyright() string {
+	if x != nil {
+		return x.Copyright
+	}
+	return ""
+}
+
+func (x *ApplicationIdentity) GetDescription() string {
+	if x != nil {
+		return x.Description
+	}
+	return ""
+}
+
+func (x *ApplicationIdentity) GetHomepage() string {
+	if x != nil {
+		return x.Homepage
+	}
+	return ""
+}
+
+func (x *ApplicationIdentity) GetIdentifier() string {
+	if x != nil {
+		return x.Identifier
+	}
+	return ""
+}
+
+// encapsulates various configuration parameters which affect how the
+// application integrates with desktop environments
+type DesktopConfiguration struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// lists the types of documents which may be opened by the application
+	DocumentTypes []*DocumentType `protobuf:"bytes,1,rep,name=document_types,json=documentTypes,proto3" json:"document_types,omitempty"`
+	// lists the URL schemes (e.g. "foo" for "foo://bar") which are handled by
+	// the application
+	UrlSchemes []string `protobuf:"bytes,2,rep,name=url_schemes,json=urlSchemes,proto3" json:"url_schemes,omitempty"`
+	// lists the freedesktop menu categories (e.g. "Development") in which the
+	// application is listed on Linux
+	Categories []string `protobuf:"bytes,3,rep,name=categories,proto3" json:"categories,omitempty"`
+	// stores a PNG encoded icon which is installed along with the desktop entry
+	// when the executable installs itself (only embedded within Linux
+	// executables)
+	Icon []byte `protobuf:"bytes,4,opt,name=icon,proto3" json:"icon,omitempty"`
+}
+
+func (x *DesktopConfiguration) Reset() {
+	*x = DesktopConfiguration{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_metadata_proto_msgTypes[4]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *DesktopConfiguration) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DesktopConfiguration) ProtoMessage() {}
+
+func (x *DesktopConfiguration) ProtoReflect() protoreflect.Message {
+	mi := &file_metadata_proto_msgTypes[4]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DesktopConfiguration.ProtoReflect.Descriptor instead.
+func (*DesktopConfiguration) Descriptor() ([]byte, []int) {
+	return file_metadata_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *DesktopConfiguration) GetDocumentTypes() []*DocumentType {
+	if x != nil {
+		return x.DocumentTypes
+	}
+	return nil
+}
+
+func (x *DesktopConfiguration) GetUrlSchemes() []string {
+	if x != nil {
+		return x.UrlSchemes
+	}
+	return nil
+}
+
+func (x *DesktopConfiguration) GetCategories() []string {
+	if x != nil {
+		return x.Categories
+	}
+	return nil
+}
+
+func (x *DesktopConfiguration) GetIcon() []byte {
+	if x != nil {
+		return x.Icon
+	}
+	return nil
+}
+
+// describes a type of document which may be opened by the application
+type DocumentType struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// provides a human readable name for documents of this type (e.g. "Foo
+	// Document")
+	Name string `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	// lists the file extensions (without their leading period) which identify
+	// documents of this type
+	Extensions []string `protobuf:"bytes,2,rep,name=extensions,proto3" json:"extensions,omitempty"`
+	// lists the MIME types which identify documents of this type
+	MimeTypes []string `protobuf:"bytes,3,rep,name=mime_types,json=mimeTypes,proto3" json:"mime_types,omitempty"`
+}
+
+func (x *DocumentType) Reset() {
+	*x = DocumentType{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_metadata_proto_msgTypes[5]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *DocumentType) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DocumentType) ProtoMessage() {}
+
+func (x *DocumentType) ProtoReflect() protoreflect.Message {
+	mi := &file_metadata_proto_msgTypes[5]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DocumentType.ProtoReflect.Descriptor instead.
+func (*DocumentType) Descriptor() ([]byte, []int) {
+	return file_metadata_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *DocumentType) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *DocumentType) GetExtensions() []string {
+	if x != nil {
+		return x.Extensions
+	}
+	return nil
+}
+
+func (x *DocumentType) GetMimeTypes() []string {
+	if x != nil {
+		return x.MimeTypes
+	}
+	return nil
+}
+
+// encapsulates various configuration parameters which affect how errors are
+// reported to users
+type SupportConfiguration struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// identifies a URL at which users may request help with the application
+	//
+	// omitted from error messages if empty
+	SupportUrl string `protobuf:"bytes,1,opt,name=support_url,json=supportUrl,proto3" json:"support_url,omitempty"`
+	// identifies a URL from which users may obtain a compatible runtime
+	//
+	// omitted from error messages if empty
+	RuntimeDownloadUrl string `protobuf:"bytes,2,opt,name=runtime_download_url,json=runtimeDownloadUrl,proto3" json:"runtime_download_url,omitempty"`
+	// provides custom message templates for specific error categories
+	//
+	// templates may reference the following placeholders: {name}, {required},
+	// {minimum}, {maximum}, {found}, {error}, {support_url} and
+	// {runtime_download_url}
+	Messages *ErrorMessages `protobuf:"bytes,10,opt,name=messages,proto3" json:"messages,omitempty"`
+}
+
+func (x *SupportConfiguration) Reset() {
+	*x = SupportConfiguration{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_metadata_proto_msgTypes[6]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *SupportConfiguration) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SupportConfiguration) ProtoMessage() {}
+
+func (x *SupportConfiguration) ProtoReflect() protoreflect.Message {
+	mi := &file_metadata_proto_msgTypes[6]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SupportConfiguration.ProtoReflect.Descriptor instead.
+func (*SupportConfiguration) Descriptor() ([]byte, []int) {
+	return file_metadata_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *SupportConfiguration) GetSupportUrl() string {
+	if x != nil {
+		return x.SupportUrl
+	}
+	return ""
+}
+
+func (x *SupportConfiguration) GetRuntimeDownloadUrl() string {
+	if x != nil {
+		return x.RuntimeDownloadUrl
+	}
+	return ""
+}
+
+func (x *SupportConfiguration) GetMessages() *ErrorMessages {
+	if x != nil {
+		return x.Messages
+	}
+	return nil
+}
+
+// encapsulates custom message templates for each category of error reported
+// by the wrapper
+//
+// built-in messages are used for any category which is left empty
+type ErrorMessages struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// displayed when the application configuration cannot be loaded
+	Configuration string `protobuf:"bytes,1,opt,name=configuration,proto3" json:"configuration,omitempty"`
+	// displayed when no runtime installation could be located
+	RuntimeNotFound string `protobuf:"bytes,2,opt,name=runtime_not_found,json=runtimeNotFound,proto3" json:"runtime_not_found,omitempty"`
+	// displayed when none of the located runtimes satisfies the version
+	// constraints of the application
+	RuntimeUnsupported string `protobuf:"bytes,3,opt,name=runtime_unsupported,json=runtimeUnsupported,proto3" json:"runtime_unsupported,omitempty"`
+	// displayed when a located runtime installation is damaged
+	RuntimeInvalid string `protobuf:"bytes,4,opt,name=runtime_invalid,json=runtimeInvalid,proto3" json:"runtime_invalid,omitempty"`
+	// displayed when the runtime fails to launch the application
+	Launch string `protobuf:"bytes,5,opt,name=launch,proto3" json:"launch,omitempty"`
+	// displayed when the executable has been corrupted or truncated
+	Integrity string `protobuf:"bytes,6,opt,name=integrity,proto3" json:"integrity,omitempty"`
+}
+
+func (x *ErrorMessages) Reset() {
+	*x = ErrorMessages{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_metadata_proto_msgTypes[7]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *ErrorMessages) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ErrorMessages) ProtoMessage() {}
+
+func (x *ErrorMessages) ProtoReflect() protoreflect.Message {
+	mi := &file_metadata_proto_msgTypes[7]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ErrorMessages.ProtoReflect.Descriptor instead.
+func (*ErrorMessages) Descriptor() ([]byte, []int) {
+	return file_metadata_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *ErrorMessages) GetConfiguration() string {
+	if x != nil {
+		return x.Configuration
+	}
+	return ""
+}
+
+func (x *ErrorMessages) GetRuntimeNotFound() string {
+	if x != nil {
+		return x.RuntimeNotFound
+	}
+	return ""
+}
+
+func (x *ErrorMessages) GetRuntimeUnsupported() string {
+	if x != nil {
+		return x.RuntimeUnsupported
+	}
+	return ""
+}
+
+func (x *ErrorMessages) GetRuntimeInvalid() string {
+	if x != nil {
+		return x.RuntimeInvalid
+	}
+	return ""
+}
+
+func (x *ErrorMessages) GetLaunch() string {
+	if x != nil {
+		return x.Launch
+	}
+	return ""
+}
+
+func (x *ErrorMessages) GetIntegrity() string {
+	if x != nil {
+		return x.Integrity
+	}
+	return ""
+}
+
+// encapsulates digests of the individual sections of an executable
+//
+// verification is skipped for any digest which is left empty
+type IntegrityConfiguration struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// provides the SHA-256 digest of the wrapper section (e.g. all data which
+	// precedes the payload)
+	WrapperSha256 []byte `protobuf:"bytes,1,opt,name=wrapper_sha256,json=wrapperSha256,proto3" json:"wrapper_sha256,omitempty"`
+	// provides the SHA-256 digest of the embedded application payload
+	PayloadSha256 []byte `protobuf:"bytes,2,opt,name=payload_sha256,json=payloadSha256,proto3" json:"payload_sha256,omitempty"`
+}
+
+func (x *IntegrityConfiguration) Reset() {
+	*x = IntegrityConfiguration{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_metadata_proto_msgTypes[8]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *IntegrityConfiguration) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*IntegrityConfiguration) ProtoMessage() {}
+
+func (x *IntegrityConfiguration) ProtoReflect() protoreflect.Message {
+	mi := &file_metadata_proto_msgTypes[8]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use IntegrityConfiguration.ProtoReflect.Descriptor instead.
+func (*IntegrityConfiguration) Descriptor() ([]byte, []int) {
+	return file_metadata_proto_rawDescGZIP(), []int{8}
+}
+
+func (x *IntegrityConfiguration) GetWrapperSha256() []byte {
+	if x != nil {
+		return x.WrapperSha256
+	}
+	return nil
+}
+
+func (x *IntegrityConfiguration) GetPayloadSha256() []byte {
+	if x != nil {
+		return x.PayloadSha256
+	}
+	return nil
+}
+
+// encapsulates an ed25519 signature over the container metadata
+//
+// as the container metadata includes the digests of the wrapper and payload
+// sections, the signature transitively covers the entire executable
+type SignatureConfiguration struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// provides the ed25519 public key of the signer
+	PublicKey []byte `protobuf:"bytes,1,opt,name=public_key,json=publicKey,proto3" json:"public_key,omitempty"`
+	// provides the ed25519 signature over the encoded container metadata (with
+	// the exception of this message)
+	Signature []byte `protobuf:"bytes,2,opt,name=signature,proto3" json:"signature,omitempty"`
+}
+
+func (x *SignatureConfiguration) Reset() {
+	*x = SignatureConfiguration{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_metadata_proto_msgTypes[9]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *SignatureConfiguration) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SignatureConfiguration) ProtoMessage() {}
+
+func (x *SignatureConfiguration) ProtoReflect() protoreflect.Message {
+	mi := &file_metadata_proto_msgTypes[9]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SignatureConfiguration.ProtoReflect.Descriptor instead.
+func (*SignatureConfiguration) Descriptor() ([]byte, []int) {
+	return file_metadata_proto_rawDescGZIP(), []int{9}
+}
+
+func (x *SignatureConfiguration) GetPublicKey() []byte {
+	if x != nil {
+		return x.PublicKey
+	}
+	return nil
+}
+
+func (x *SignatureConfiguration) GetSignature() []byte {
+	if x != nil {
+		return x.Signature
+	}
+	return nil
+}
+
 var File_metadata_proto protoreflect.FileDescriptor
 
 var file_metadata_proto_rawDesc = []byte{
 	0x0a, 0x0e, 0x6d, 0x65, 0x74, 0x61, 0x64, 0x61, 0x74, 0x61, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f,
-	0x12, 0x08, 0x6d, 0x65, 0x74, 0x61, 0x64, 0x61, 0x74, 0x61, 0x22, 0xe2, 0x01, 0x0a, 0x14, 0x41,
+	0x12, 0x08, 0x6d, 0x65, 0x74, 0x61, 0x64, 0x61, 0x74, 0x61, 0x22, 0x91, 0x04, 0x0a, 0x14, 0x41,
 	0x70, 0x70, 0x6c, 0x69, 0x63, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x43, 0x6f, 0x6e, 0x74, 0x61, 0x69,
 	0x6e, 0x65, 0x72, 0x12, 0x23, 0x0a, 0x0d, 0x63, 0x61, 0x6e, 0x6f, 0x65, 0x5f, 0x76, 0x65, 0x72,
 	0x73, 0x69, 0x6f, 0x6e, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x0c, 0x63, 0x61, 0x6e, 0x6f,
@@ -270,27 +932,129 @@ var file_metadata_proto_rawDesc = []byte{
 	0x6c, 0x69, 0x63, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x18, 0x15, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x22,
 	0x2e, 0x6d, 0x65, 0x74, 0x61, 0x64, 0x61, 0x74, 0x61, 0x2e, 0x41, 0x70, 0x70, 0x6c, 0x69, 0x63,
 	0x61, 0x74, 0x69, 0x6f, 0x6e, 0x43, 0x6f, 0x6e, 0x66, 0x69, 0x67, 0x75, 0x72, 0x61, 0x74, 0x69,
-	0x6f, 0x6e, 0x52, 0x0b, 0x61, 0x70, 0x70, 0x6c, 0x69, 0x63, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x22,
-	0xe5, 0x01, 0x0a, 0x14, 0x52, 0x75, 0x6e, 0x74, 0x69, 0x6d, 0x65, 0x43, 0x6f, 0x6e, 0x66, 0x69,
-	0x67, 0x75, 0x72, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x12, 0x27, 0x0a, 0x0f, 0x6d, 0x69, 0x6e, 0x69,
-	0x6d, 0x75, 0x6d, 0x5f, 0x76, 0x65, 0x72, 0x73, 0x69, 0x6f, 0x6e, 0x18, 0x01, 0x20, 0x01, 0x28,
-	0x04, 0x52, 0x0e, 0x6d, 0x69, 0x6e, 0x69, 0x6d, 0x75, 0x6d, 0x56, 0x65, 0x72, 0x73, 0x69, 0x6f,
-	0x6e, 0x12, 0x27, 0x0a, 0x0f, 0x6d, 0x61, 0x78, 0x69, 0x6d, 0x75, 0x6d, 0x5f, 0x76, 0x65, 0x72,
-	0x73, 0x69, 0x6f, 0x6e, 0x18, 0x02, 0x20, 0x01, 0x28, 0x04, 0x52, 0x0e, 0x6d, 0x61, 0x78, 0x69,
-	0x6d, 0x75, 0x6d, 0x56, 0x65, 0x72, 0x73, 0x69, 0x6f, 0x6e, 0x12, 0x25, 0x0a, 0x0e, 0x69, 0x6e,
-	0x69, 0x74, 0x69, 0x61, 0x6c, 0x5f, 0x6d, 0x65, 0x6d, 0x6f, 0x72, 0x79, 0x18, 0x0a, 0x20, 0x01,
-	0x28, 0x04, 0x52, 0x0d, 0x69, 0x6e, 0x69, 0x74, 0x69, 0x61, 0x6c, 0x4d, 0x65, 0x6d, 0x6f, 0x72,
-	0x79, 0x12, 0x21, 0x0a, 0x0c, 0x6d, 0x65, 0x6d, 0x6f, 0x72, 0x79, 0x5f, 0x6c, 0x69, 0x6d, 0x69,
-	0x74, 0x18, 0x0b, 0x20, 0x01, 0x28, 0x04, 0x52, 0x0b, 0x6d, 0x65, 0x6d, 0x6f, 0x72, 0x79, 0x4c,
-	0x69, 0x6d, 0x69, 0x74, 0x12, 0x31, 0x0a, 0x14, 0x61, 0x64, 0x64, 0x69, 0x74, 0x69, 0x6f, 0x6e,
-	0x61, 0x6c, 0x5f, 0x61, 0x72, 0x67, 0x75, 0x6d, 0x65, 0x6e, 0x74, 0x73, 0x18, 0x64, 0x20, 0x01,
-	0x28, 0x09, 0x52, 0x13, 0x61, 0x64, 0x64, 0x69, 0x74, 0x69, 0x6f, 0x6e, 0x61, 0x6c, 0x41, 0x72,
-	0x67, 0x75, 0x6d, 0x65, 0x6e, 0x74, 0x73, 0x22, 0x39, 0x0a, 0x18, 0x41, 0x70, 0x70, 0x6c, 0x69,
-	0x63, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x43, 0x6f, 0x6e, 0x66, 0x69, 0x67, 0x75, 0x72, 0x61, 0x74,
-	0x69, 0x6f, 0x6e, 0x12, 0x1d, 0x0a, 0x0a, 0x6d, 0x61, 0x69, 0x6e, 0x5f, 0x63, 0x6c, 0x61, 0x73,
-	0x73, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x09, 0x6d, 0x61, 0x69, 0x6e, 0x43, 0x6c, 0x61,
-	0x73, 0x73, 0x42, 0x0c, 0x5a, 0x0a, 0x2e, 0x3b, 0x6d, 0x65, 0x74, 0x61, 0x64, 0x61, 0x74, 0x61,
-	0x62, 0x06, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x33,
+	0x6f, 0x6e, 0x52, 0x0b, 0x61, 0x70, 0x70, 0x6c, 0x69, 0x63, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x12,
+	0x39, 0x0a, 0x08, 0x69, 0x64, 0x65, 0x6e, 0x74, 0x69, 0x74, 0x79, 0x18, 0x16, 0x20, 0x01, 0x28,
+	0x0b, 0x32, 0x1d, 0x2e, 0x6d, 0x65, 0x74, 0x61, 0x64, 0x61, 0x74, 0x61, 0x2e, 0x41, 0x70, 0x70,
+	0x6c, 0x69, 0x63, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x49, 0x64, 0x65, 0x6e, 0x74, 0x69, 0x74, 0x79,
+	0x52, 0x08, 0x69, 0x64, 0x65, 0x6e, 0x74, 0x69, 0x74, 0x79, 0x12, 0x38, 0x0a, 0x07, 0x73, 0x75,
+	0x70, 0x70, 0x6f, 0x72, 0x74, 0x18, 0x17, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x1e, 0x2e, 0x6d, 0x65,
+	0x74, 0x61, 0x64, 0x61, 0x74, 0x61, 0x2e, 0x53, 0x75, 0x70, 0x70, 0x6f, 0x72, 0x74, 0x43, 0x6f,
+	0x6e, 0x66, 0x69, 0x67, 0x75, 0x72, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x52, 0x07, 0x73, 0x75, 0x70,
+	0x70, 0x6f, 0x72, 0x74, 0x12, 0x3e, 0x0a, 0x09, 0x69, 0x6e, 0x74, 0x65, 0x67, 0x72, 0x69, 0x74,
+	0x79, 0x18, 0x18, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x20, 0x2e, 0x6d, 0x65, 0x74, 0x61, 0x64, 0x61,
+	0x74, 0x61, 0x2e, 0x49, 0x6e, 0x74, 0x65, 0x67, 0x72, 0x69, 0x74, 0x79, 0x43, 0x6f, 0x6e, 0x66,
+	0x69, 0x67, 0x75, 0x72, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x52, 0x09, 0x69, 0x6e, 0x74, 0x65, 0x67,
+	0x72, 0x69, 0x74, 0x79, 0x12, 0x3e, 0x0a, 0x09, 0x73, 0x69, 0x67, 0x6e, 0x61, 0x74, 0x75, 0x72,
+	0x65, 0x18, 0x19, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x20, 0x2e, 0x6d, 0x65, 0x74, 0x61, 0x64, 0x61,
+	0x74, 0x61, 0x2e, 0x53, 0x69, 0x67, 0x6e, 0x61, 0x74, 0x75, 0x72, 0x65, 0x43, 0x6f, 0x6e, 0x66,
+	0x69, 0x67, 0x75, 0x72, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x52, 0x09, 0x73, 0x69, 0x67, 0x6e, 0x61,
+	0x74, 0x75, 0x72, 0x65, 0x12, 0x38, 0x0a, 0x07, 0x64, 0x65, 0x73, 0x6b, 0x74, 0x6f, 0x70, 0x18,
+	0x1a, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x1e, 0x2e, 0x6d, 0x65, 0x74, 0x61, 0x64, 0x61, 0x74, 0x61,
+	0x2e, 0x44, 0x65, 0x73, 0x6b, 0x74, 0x6f, 0x70, 0x43, 0x6f, 0x6e, 0x66, 0x69, 0x67, 0x75, 0x72,
+	0x61, 0x74, 0x69, 0x6f, 0x6e, 0x52, 0x07, 0x64, 0x65, 0x73, 0x6b, 0x74, 0x6f, 0x70, 0x22, 0x90,
+	0x02, 0x0a, 0x14, 0x52, 0x75, 0x6e, 0x74, 0x69, 0x6d, 0x65, 0x43, 0x6f, 0x6e, 0x66, 0x69, 0x67,
+	0x75, 0x72, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x12, 0x27, 0x0a, 0x0f, 0x6d, 0x69, 0x6e, 0x69, 0x6d,
+	0x75, 0x6d, 0x5f, 0x76, 0x65, 0x72, 0x73, 0x69, 0x6f, 0x6e, 0x18, 0x01, 0x20, 0x01, 0x28, 0x04,
+	0x52, 0x0e, 0x6d, 0x69, 0x6e, 0x69, 0x6d, 0x75, 0x6d, 0x56, 0x65, 0x72, 0x73, 0x69, 0x6f, 0x6e,
+	0x12, 0x27, 0x0a, 0x0f, 0x6d, 0x61, 0x78, 0x69, 0x6d, 0x75, 0x6d, 0x5f, 0x76, 0x65, 0x72, 0x73,
+	0x69, 0x6f, 0x6e, 0x18, 0x02, 0x20, 0x01, 0x28, 0x04, 0x52, 0x0e, 0x6d, 0x61, 0x78, 0x69, 0x6d,
+	0x75, 0x6d, 0x56, 0x65, 0x72, 0x73, 0x69, 0x6f, 0x6e, 0x12, 0x25, 0x0a, 0x0e, 0x69, 0x6e, 0x69,
+	0x74, 0x69, 0x61, 0x6c, 0x5f, 0x6d, 0x65, 0x6d, 0x6f, 0x72, 0x79, 0x18, 0x0a, 0x20, 0x01, 0x28,
+	0x04, 0x52, 0x0d, 0x69, 0x6e, 0x69, 0x74, 0x69, 0x61, 0x6c, 0x4d, 0x65, 0x6d, 0x6f, 0x72, 0x79,
+	0x12, 0x21, 0x0a, 0x0c, 0x6d, 0x65, 0x6d, 0x6f, 0x72, 0x79, 0x5f, 0x6c, 0x69, 0x6d, 0x69, 0x74,
+	0x18, 0x0b, 0x20, 0x01, 0x28, 0x04, 0x52, 0x0b, 0x6d, 0x65, 0x6d, 0x6f, 0x72, 0x79, 0x4c, 0x69,
+	0x6d, 0x69, 0x74, 0x12, 0x29, 0x0a, 0x10, 0x72, 0x65, 0x71, 0x75, 0x69, 0x72, 0x65, 0x64, 0x5f,
+	0x6d, 0x6f, 0x64, 0x75, 0x6c, 0x65, 0x73, 0x18, 0x14, 0x20, 0x03, 0x28, 0x09, 0x52, 0x0f, 0x72,
+	0x65, 0x71, 0x75, 0x69, 0x72, 0x65, 0x64, 0x4d, 0x6f, 0x64, 0x75, 0x6c, 0x65, 0x73, 0x12, 0x31,
+	0x0a, 0x14, 0x61, 0x64, 0x64, 0x69, 0x74, 0x69, 0x6f, 0x6e, 0x61, 0x6c, 0x5f, 0x61, 0x72, 0x67,
+	0x75, 0x6d, 0x65, 0x6e, 0x74, 0x73, 0x18, 0x64, 0x20, 0x01, 0x28, 0x09, 0x52, 0x13, 0x61, 0x64,
+	0x64, 0x69, 0x74, 0x69, 0x6f, 0x6e, 0x61, 0x6c, 0x41, 0x72, 0x67, 0x75, 0x6d, 0x65, 0x6e, 0x74,
+	0x73, 0x22, 0xfa, 0x01, 0x0a, 0x18, 0x41, 0x70, 0x70, 0x6c, 0x69, 0x63, 0x61, 0x74, 0x69, 0x6f,
+	0x6e, 0x43, 0x6f, 0x6e, 0x66, 0x69, 0x67, 0x75, 0x72, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x12, 0x1d,
+	0x0a, 0x0a, 0x6d, 0x61, 0x69, 0x6e, 0x5f, 0x63, 0x6c, 0x61, 0x73, 0x73, 0x18, 0x01, 0x20, 0x01,
+	0x28, 0x09, 0x52, 0x09, 0x6d, 0x61, 0x69, 0x6e, 0x43, 0x6c, 0x61, 0x73, 0x73, 0x12, 0x1b, 0x0a,
+	0x09, 0x61, 0x64, 0x64, 0x5f, 0x6f, 0x70, 0x65, 0x6e, 0x73, 0x18, 0x02, 0x20, 0x03, 0x28, 0x09,
+	0x52, 0x08, 0x61, 0x64, 0x64, 0x4f, 0x70, 0x65, 0x6e, 0x73, 0x12, 0x1f, 0x0a, 0x0b, 0x61, 0x64,
+	0x64, 0x5f, 0x65, 0x78, 0x70, 0x6f, 0x72, 0x74, 0x73, 0x18, 0x03, 0x20, 0x03, 0x28, 0x09, 0x52,
+	0x0a, 0x61, 0x64, 0x64, 0x45, 0x78, 0x70, 0x6f, 0x72, 0x74, 0x73, 0x12, 0x30, 0x0a, 0x14, 0x65,
+	0x6e, 0x61, 0x62, 0x6c, 0x65, 0x5f, 0x6e, 0x61, 0x74, 0x69, 0x76, 0x65, 0x5f, 0x61, 0x63, 0x63,
+	0x65, 0x73, 0x73, 0x18, 0x04, 0x20, 0x01, 0x28, 0x08, 0x52, 0x12, 0x65, 0x6e, 0x61, 0x62, 0x6c,
+	0x65, 0x4e, 0x61, 0x74, 0x69, 0x76, 0x65, 0x41, 0x63, 0x63, 0x65, 0x73, 0x73, 0x12, 0x30, 0x0a,
+	0x14, 0x6c, 0x61, 0x75, 0x6e, 0x63, 0x68, 0x65, 0x72, 0x5f, 0x61, 0x67, 0x65, 0x6e, 0x74, 0x5f,
+	0x63, 0x6c, 0x61, 0x73, 0x73, 0x18, 0x05, 0x20, 0x01, 0x28, 0x09, 0x52, 0x12, 0x6c, 0x61, 0x75,
+	0x6e, 0x63, 0x68, 0x65, 0x72, 0x41, 0x67, 0x65, 0x6e, 0x74, 0x43, 0x6c, 0x61, 0x73, 0x73, 0x12,
+	0x1d, 0x0a, 0x0a, 0x63, 0x6c, 0x61, 0x73, 0x73, 0x5f, 0x70, 0x61, 0x74, 0x68, 0x18, 0x06, 0x20,
+	0x03, 0x28, 0x09, 0x52, 0x09, 0x63, 0x6c, 0x61, 0x73, 0x73, 0x50, 0x61, 0x74, 0x68, 0x22, 0xd7,
+	0x01, 0x0a, 0x13, 0x41, 0x70, 0x70, 0x6c, 0x69, 0x63, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x49, 0x64,
+	0x65, 0x6e, 0x74, 0x69, 0x74, 0x79, 0x12, 0x12, 0x0a, 0x04, 0x6e, 0x61, 0x6d, 0x65, 0x18, 0x01,
+	0x20, 0x01, 0x28, 0x09, 0x52, 0x04, 0x6e, 0x61, 0x6d, 0x65, 0x12, 0x18, 0x0a, 0x07, 0x76, 0x65,
+	0x72, 0x73, 0x69, 0x6f, 0x6e, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x07, 0x76, 0x65, 0x72,
+	0x73, 0x69, 0x6f, 0x6e, 0x12, 0x16, 0x0a, 0x06, 0x76, 0x65, 0x6e, 0x64, 0x6f, 0x72, 0x18, 0x03,
+	0x20, 0x01, 0x28, 0x09, 0x52, 0x06, 0x76, 0x65, 0x6e, 0x64, 0x6f, 0x72, 0x12, 0x1c, 0x0a, 0x09,
+	0x63, 0x6f, 0x70, 0x79, 0x72, 0x69, 0x67, 0x68, 0x74, 0x18, 0x04, 0x20, 0x01, 0x28, 0x09, 0x52,
+	0x09, 0x63, 0x6f, 0x70, 0x79, 0x72, 0x69, 0x67, 0x68, 0x74, 0x12, 0x20, 0x0a, 0x0b, 0x64, 0x65,
+	0x73, 0x63, 0x72, 0x69, 0x70, 0x74, 0x69, 0x6f, 0x6e, 0x18, 0x05, 0x20, 0x01, 0x28, 0x09, 0x52,
+	0x0b, 0x64, 0x65, 0x73, 0x63, 0x72, 0x69, 0x70, 0x74, 0x69, 0x6f, 0x6e, 0x12, 0x1a, 0x0a, 0x08,
+	0x68, 0x6f, 0x6d, 0x65, 0x70, 0x61, 0x67, 0x65, 0x18, 0x06, 0x20, 0x01, 0x28, 0x09, 0x52, 0x08,
+	0x68, 0x6f, 0x6d, 0x65, 0x70, 0x61, 0x67, 0x65, 0x12, 0x1e, 0x0a, 0x0a, 0x69, 0x64, 0x65, 0x6e,
+	0x74, 0x69, 0x66, 0x69, 0x65, 0x72, 0x18, 0x07, 0x20, 0x01, 0x28, 0x09, 0x52, 0x0a, 0x69, 0x64,
+	0x65, 0x6e, 0x74, 0x69, 0x66, 0x69, 0x65, 0x72, 0x22, 0xaa, 0x01, 0x0a, 0x14, 0x44, 0x65, 0x73,
+	0x6b, 0x74, 0x6f, 0x70, 0x43, 0x6f, 0x6e, 0x66, 0x69, 0x67, 0x75, 0x72, 0x61, 0x74, 0x69, 0x6f,
+	0x6e, 0x12, 0x3d, 0x0a, 0x0e, 0x64, 0x6f, 0x63, 0x75, 0x6d, 0x65, 0x6e, 0x74, 0x5f, 0x74, 0x79,
+	0x70, 0x65, 0x73, 0x18, 0x01, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x16, 0x2e, 0x6d, 0x65, 0x74, 0x61,
+	0x64, 0x61, 0x74, 0x61, 0x2e, 0x44, 0x6f, 0x63, 0x75, 0x6d, 0x65, 0x6e, 0x74, 0x54, 0x79, 0x70,
+	0x65, 0x52, 0x0d, 0x64, 0x6f, 0x63, 0x75, 0x6d, 0x65, 0x6e, 0x74, 0x54, 0x79, 0x70, 0x65, 0x73,
+	0x12, 0x1f, 0x0a, 0x0b, 0x75, 0x72, 0x6c, 0x5f, 0x73, 0x63, 0x68, 0x65, 0x6d, 0x65, 0x73, 0x18,
+	0x02, 0x20, 0x03, 0x28, 0x09, 0x52, 0x0a, 0x75, 0x72, 0x6c, 0x53, 0x63, 0x68, 0x65, 0x6d, 0x65,
+	0x73, 0x12, 0x1e, 0x0a, 0x0a, 0x63, 0x61, 0x74, 0x65, 0x67, 0x6f, 0x72, 0x69, 0x65, 0x73, 0x18,
+	0x03, 0x20, 0x03, 0x28, 0x09, 0x52, 0x0a, 0x63, 0x61, 0x74, 0x65, 0x67, 0x6f, 0x72, 0x69, 0x65,
+	0x73, 0x12, 0x12, 0x0a, 0x04, 0x69, 0x63, 0x6f, 0x6e, 0x18, 0x04, 0x20, 0x01, 0x28, 0x0c, 0x52,
+	0x04, 0x69, 0x63, 0x6f, 0x6e, 0x22, 0x61, 0x0a, 0x0c, 0x44, 0x6f, 0x63, 0x75, 0x6d, 0x65, 0x6e,
+	0x74, 0x54, 0x79, 0x70, 0x65, 0x12, 0x12, 0x0a, 0x04, 0x6e, 0x61, 0x6d, 0x65, 0x18, 0x01, 0x20,
+	0x01, 0x28, 0x09, 0x52, 0x04, 0x6e, 0x61, 0x6d, 0x65, 0x12, 0x1e, 0x0a, 0x0a, 0x65, 0x78, 0x74,
+	0x65, 0x6e, 0x73, 0x69, 0x6f, 0x6e, 0x73, 0x18, 0x02, 0x20, 0x03, 0x28, 0x09, 0x52, 0x0a, 0x65,
+	0x78, 0x74, 0x65, 0x6e, 0x73, 0x69, 0x6f, 0x6e, 0x73, 0x12, 0x1d, 0x0a, 0x0a, 0x6d, 0x69, 0x6d,
+	0x65, 0x5f, 0x74, 0x79, 0x70, 0x65, 0x73, 0x18, 0x03, 0x20, 0x03, 0x28, 0x09, 0x52, 0x09, 0x6d,
+	0x69, 0x6d, 0x65, 0x54, 0x79, 0x70, 0x65, 0x73, 0x22, 0x9e, 0x01, 0x0a, 0x14, 0x53, 0x75, 0x70,
+	0x70, 0x6f, 0x72, 0x74, 0x43, 0x6f, 0x6e, 0x66, 0x69, 0x67, 0x75, 0x72, 0x61, 0x74, 0x69, 0x6f,
+	0x6e, 0x12, 0x1f, 0x0a, 0x0b, 0x73, 0x75, 0x70, 0x70, 0x6f, 0x72, 0x74, 0x5f, 0x75, 0x72, 0x6c,
+	0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x0a, 0x73, 0x75, 0x70, 0x70, 0x6f, 0x72, 0x74, 0x55,
+	0x72, 0x6c, 0x12, 0x30, 0x0a, 0x14, 0x72, 0x75, 0x6e, 0x74, 0x69, 0x6d, 0x65, 0x5f, 0x64, 0x6f,
+	0x77, 0x6e, 0x6c, 0x6f, 0x61, 0x64, 0x5f, 0x75, 0x72, 0x6c, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09,
+	0x52, 0x12, 0x72, 0x75, 0x6e, 0x74, 0x69, 0x6d, 0x65, 0x44, 0x6f, 0x77, 0x6e, 0x6c, 0x6f, 0x61,
+	0x64, 0x55, 0x72, 0x6c, 0x12, 0x33, 0x0a, 0x08, 0x6d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x73,
+	0x18, 0x0a, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x17, 0x2e, 0x6d, 0x65, 0x74, 0x61, 0x64, 0x61, 0x74,
+	0x61, 0x2e, 0x45, 0x72, 0x72, 0x6f, 0x72, 0x4d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x73, 0x52,
+	0x08, 0x6d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x73, 0x22, 0xf1, 0x01, 0x0a, 0x0d, 0x45, 0x72,
+	0x72, 0x6f, 0x72, 0x4d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x73, 0x12, 0x24, 0x0a, 0x0d, 0x63,
+	0x6f, 0x6e, 0x66, 0x69, 0x67, 0x75, 0x72, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x18, 0x01, 0x20, 0x01,
+	0x28, 0x09, 0x52, 0x0d, 0x63, 0x6f, 0x6e, 0x66, 0x69, 0x67, 0x75, 0x72, 0x61, 0x74, 0x69, 0x6f,
+	0x6e, 0x12, 0x2a, 0x0a, 0x11, 0x72, 0x75, 0x6e, 0x74, 0x69, 0x6d, 0x65, 0x5f, 0x6e, 0x6f, 0x74,
+	0x5f, 0x66, 0x6f, 0x75, 0x6e, 0x64, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x0f, 0x72, 0x75,
+	0x6e, 0x74, 0x69, 0x6d, 0x65, 0x4e, 0x6f, 0x74, 0x46, 0x6f, 0x75, 0x6e, 0x64, 0x12, 0x2f, 0x0a,
+	0x13, 0x72, 0x75, 0x6e, 0x74, 0x69, 0x6d, 0x65, 0x5f, 0x75, 0x6e, 0x73, 0x75, 0x70, 0x70, 0x6f,
+	0x72, 0x74, 0x65, 0x64, 0x18, 0x03, 0x20, 0x01, 0x28, 0x09, 0x52, 0x12, 0x72, 0x75, 0x6e, 0x74,
+	0x69, 0x6d, 0x65, 0x55, 0x6e, 0x73, 0x75, 0x70, 0x70, 0x6f, 0x72, 0x74, 0x65, 0x64, 0x12, 0x27,
+	0x0a, 0x0f, 0x72, 0x75, 0x6e, 0x74, 0x69, 0x6d, 0x65, 0x5f, 0x69, 0x6e, 0x76, 0x61, 0x6c, 0x69,
+	0x64, 0x18, 0x04, 0x20, 0x01, 0x28, 0x09, 0x52, 0x0e, 0x72, 0x75, 0x6e, 0x74, 0x69, 0x6d, 0x65,
+	0x49, 0x6e, 0x76, 0x61, 0x6c, 0x69, 0x64, 0x12, 0x16, 0x0a, 0x06, 0x6c, 0x61, 0x75, 0x6e, 0x63,
+	0x68, 0x18, 0x05, 0x20, 0x01, 0x28, 0x09, 0x52, 0x06, 0x6c, 0x61, 0x75, 0x6e, 0x63, 0x68, 0x12,
+	0x1c, 0x0a, 0x09, 0x69, 0x6e, 0x74, 0x65, 0x67, 0x72, 0x69, 0x74, 0x79, 0x18, 0x06, 0x20, 0x01,
+	0x28, 0x09, 0x52, 0x09, 0x69, 0x6e, 0x74, 0x65, 0x67, 0x72, 0x69, 0x74, 0x79, 0x22, 0x66, 0x0a,
+	0x16, 0x49, 0x6e, 0x74, 0x65, 0x67, 0x72, 0x69, 0x74, 0x79, 0x43, 0x6f, 0x6e, 0x66, 0x69, 0x67,
+	0x75, 0x72, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x12, 0x25, 0x0a, 0x0e, 0x77, 0x72, 0x61, 0x70, 0x70,
+	0x65, 0x72, 0x5f, 0x73, 0x68, 0x61, 0x32, 0x35, 0x36, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0c, 0x52,
+	0x0d, 0x77, 0x72, 0x61, 0x70, 0x70, 0x65, 0x72, 0x53, 0x68, 0x61, 0x32, 0x35, 0x36, 0x12, 0x25,
+	0x0a, 0x0e, 0x70, 0x61, 0x79, 0x6c, 0x6f, 0x61, 0x64, 0x5f, 0x73, 0x68, 0x61, 0x32, 0x35, 0x36,
+	0x18, 0x02, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x0d, 0x70, 0x61, 0x79, 0x6c, 0x6f, 0x61, 0x64, 0x53,
+	0x68, 0x61, 0x32, 0x35, 0x36, 0x22, 0x55, 0x0a, 0x16, 0x53, 0x69, 0x67, 0x6e, 0x61, 0x74, 0x75,
+	0x72, 0x65, 0x43, 0x6f, 0x6e, 0x66, 0x69, 0x67, 0x75, 0x72, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x12,
+	0x1d, 0x0a, 0x0a, 0x70, 0x75, 0x62, 0x6c, 0x69, 0x63, 0x5f, 0x6b, 0x65, 0x79, 0x18, 0x01, 0x20,
+	0x01, 0x28, 0x0c, 0x52, 0x09, 0x70, 0x75, 0x62, 0x6c, 0x69, 0x63, 0x4b, 0x65, 0x79, 0x12, 0x1c,
+	0x0a, 0x09, 0x73, 0x69, 0x67, 0x6e, 0x61, 0x74, 0x75, 0x72, 0x65, 0x18, 0x02, 0x20, 0x01, 0x28,
+	0x0c, 0x52, 0x09, 0x73, 0x69, 0x67, 0x6e, 0x61, 0x74, 0x75, 0x72, 0x65, 0x42, 0x0c, 0x5a, 0x0a,
+	0x2e, 0x3b, 0x6d, 0x65, 0x74, 0x61, 0x64, 0x61, 0x74, 0x61, 0x62, 0x06, 0x70, 0x72, 0x6f, 0x74,
+	0x6f, 0x33,
 }
 
 var (
@@ -305,20 +1069,34 @@ func file_metadata_proto_rawDescGZIP() []byte {
 	return file_metadata_proto_rawDescData
 }
 
-var file_metadata_proto_msgTypes = make([]protoimpl.MessageInfo, 3)
+var file_metadata_proto_msgTypes = make([]protoimpl.MessageInfo, 10)
 var file_metadata_proto_goTypes = []interface{}{
 	(*ApplicationContainer)(nil),     // 0: metadata.ApplicationContainer
 	(*RuntimeConfiguration)(nil),     // 1: metadata.RuntimeConfiguration
 	(*ApplicationConfiguration)(nil), // 2: metadata.ApplicationConfiguration
+	(*ApplicationIdentity)(nil),      // 3: metadata.ApplicationIdentity
+	(*DesktopConfiguration)(nil),     // 4: metadata.DesktopConfiguration
+	(*DocumentType)(nil),             // 5: metadata.DocumentType
+	(*SupportConfiguration)(nil),     // 6: metadata.SupportConfiguration
+	(*ErrorMessages)(nil),            // 7: metadata.ErrorMessages
+	(*IntegrityConfiguration)(nil),   // 8: metadata.IntegrityConfiguration
+	(*SignatureConfiguration)(nil),   // 9: metadata.SignatureConfiguration
 }
 var file_metadata_proto_depIdxs = []int32{
 	1, // 0: metadata.ApplicationContainer.runtime:type_name -> metadata.RuntimeConfiguration
 	2, // 1: metadata.ApplicationContainer.application:type_name -> metadata.ApplicationConfiguration
-	2, // [2:2] is the sub-list for method output_type
-	2, // [2:2] is the sub-list for method input_type
-	2, // [2:2] is the sub-list for extension type_name
-	2, // [2:2] is the sub-list for extension extendee
-	0, // [0:2] is the sub-list for field type_name
+	3, // 2: metadata.ApplicationContainer.identity:type_name -> metadata.ApplicationIdentity
+	6, // 3: metadata.ApplicationContainer.support:type_name -> metadata.SupportConfiguration
+	8, // 4: metadata.ApplicationContainer.integrity:type_name -> metadata.IntegrityConfiguration
+	9, // 5: metadata.ApplicationContainer.signature:type_name -> metadata.SignatureConfiguration
+	4, // 6: metadata.ApplicationContainer.desktop:type_name -> metadata.DesktopConfiguration
+	5, // 7: metadata.DesktopConfiguration.document_types:type_name -> metadata.DocumentType
+	7, // 8: metadata.SupportConfiguration.messages:type_name -> metadata.ErrorMessages
+	9, // [9:9] is the sub-list for method output_type
+	9, // [9:9] is the sub-list for method input_type
+	9, // [9:9] is the sub-list for extension type_name
+	9, // [9:9] is the sub-list for extension extendee
+	0, // [0:9] is the sub-list for field type_name
 }
 
 func init() { file_metadata_proto_init() }
@@ -363,6 +1141,90 @@ func file_metadata_proto_init() {
 				return nil
 			}
 		}
+		file_metadata_proto_msgTypes[3].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*ApplicationIdentity); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_metadata_proto_msgTypes[4].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*DesktopConfiguration); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_metadata_proto_msgTypes[5].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*DocumentType); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_metadata_proto_msgTypes[6].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*SupportConfiguration); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_metadata_proto_msgTypes[7].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*ErrorMessages); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_metadata_proto_msgTypes[8].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*IntegrityConfiguration); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_metadata_proto_msgTypes[9].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*SignatureConfiguration); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
 	}
 	type x struct{}
 	out := protoimpl.TypeBuilder{
@@ -370,7 +1232,7 @@ func file_metadata_proto_init() {
 			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
 			RawDescriptor: file_metadata_proto_rawDesc,
 			NumEnums:      0,
-			NumMessages:   3,
+			NumMessages:   10,
 			NumExtensions: 0,
 			NumServices:   0,
 		},
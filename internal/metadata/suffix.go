@@ -36,6 +36,10 @@ const byteSuffixBase = uint64(1024)
 const byteSuffixOffset = uint64(10)
 
 func AppendByteSuffix(size uint64) string {
+	if size == 0 {
+		return "0"
+	}
+
 	for i := len(byteSuffixes) - 1; i >= 0; i-- {
 		divisor := byteSuffixBase << (uint64(i) * byteSuffixOffset)
 
@@ -29,6 +29,7 @@ const testPetaByte = testTeraByte * 1024
 const testExaByte = testPetaByte * 1024
 
 func TestAppendByteSuffix(t *testing.T) {
+	zero := AppendByteSuffix(0)                            // none
 	none := AppendByteSuffix(testKiloByte - 1)             // none
 	kilo0 := AppendByteSuffix(testKiloByte)                // K
 	kilo1 := AppendByteSuffix(testMegaByte - testKiloByte) // K
@@ -42,6 +43,9 @@ func TestAppendByteSuffix(t *testing.T) {
 	peta1 := AppendByteSuffix(testExaByte - testPetaByte)  // P
 	exa0 := AppendByteSuffix(testExaByte)                  // E
 
+	if zero != "0" {
+		t.Errorf("expected 0 but got %q", zero)
+	}
 	if none != "1023" {
 		t.Errorf("expected 1023 but got %q", none)
 	}
@@ -0,0 +1,466 @@
+/*
+ * Copyright 2021 Johannes Donath <johannesd@torchmind.com>
+ * and other copyright owners as documented in the project's IP log.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package internal
+
+import (
+	"bytes"
+	"debug/elf"
+	"debug/macho"
+	"debug/pe"
+	"encoding/binary"
+	"errors"
+	canoeelf "github.com/dotstart/canoe/internal/elf"
+	"github.com/dotstart/canoe/internal/metadata"
+	"github.com/golang/protobuf/proto"
+	"testing"
+)
+
+var testWrapper = []byte("wrapper")
+var testPayload = []byte("payload")
+
+func testMetadata() *metadata.ApplicationContainer {
+	return &metadata.ApplicationContainer{
+		CanoeVersion: "1.0.0",
+		Application: &metadata.ApplicationConfiguration{
+			MainClass: "foo.Main",
+		},
+	}
+}
+
+func TestWriteExecutableFooter(t *testing.T) {
+	buf := &bytes.Buffer{}
+	buf.Write(testWrapper)
+	buf.Write(testPayload)
+
+	in := &Footer{
+		MinimumWrapperVersion: WrapperVersion,
+		PayloadOffset:         uint64(len(testWrapper)),
+		PayloadLength:         uint64(len(testPayload)),
+	}
+	n, err := WriteExecutableFooter(buf, in, testMetadata())
+	if err != nil {
+		t.Fatalf("failed to write footer: %s", err)
+	}
+	if n != buf.Len()-len(testWrapper)-len(testPayload) {
+		t.Errorf("expected %d bytes to be written but got %d", buf.Len()-len(testWrapper)-len(testPayload), n)
+	}
+
+	footer, meta, err := DecodeExecutableFooter(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatalf("failed to decode footer: %s", err)
+	}
+
+	if footer.FormatVersion != FooterFormatVersion {
+		t.Errorf("expected format version %d but got %d", FooterFormatVersion, footer.FormatVersion)
+	}
+	if footer.MinimumWrapperVersion != WrapperVersion {
+		t.Errorf("expected minimum wrapper version %d but got %d", WrapperVersion, footer.MinimumWrapperVersion)
+	}
+	if footer.PayloadOffset != uint64(len(testWrapper)) || footer.PayloadLength != uint64(len(testPayload)) {
+		t.Errorf("unexpected payload location %d+%d", footer.PayloadOffset, footer.PayloadLength)
+	}
+	if footer.MetadataOffset != uint64(len(testWrapper)+len(testPayload)) {
+		t.Errorf("unexpected metadata offset %d", footer.MetadataOffset)
+	}
+	if meta.Application.MainClass != "foo.Main" {
+		t.Errorf("expected main class foo.Main but got %q", meta.Application.MainClass)
+	}
+}
+
+func TestWriteExecutableFooterLarge(t *testing.T) {
+	meta := testMetadata()
+	meta.Runtime = &metadata.RuntimeConfiguration{
+		AdditionalArguments: string(bytes.Repeat([]byte{'a'}, 100*1024)),
+	}
+
+	buf := &bytes.Buffer{}
+	if _, err := WriteExecutableFooter(buf, &Footer{}, meta); err != nil {
+		t.Fatalf("failed to write footer: %s", err)
+	}
+
+	_, decoded, err := DecodeExecutableFooter(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatalf("failed to decode footer: %s", err)
+	}
+	if len(decoded.Runtime.AdditionalArguments) != 100*1024 {
+		t.Errorf("expected 102400 bytes of arguments but got %d", len(decoded.Runtime.AdditionalArguments))
+	}
+}
+
+func TestRequiredWrapperVersion(t *testing.T) {
+	tests := []struct {
+		name           string
+		configure      func(meta *metadata.ApplicationContainer)
+		payloadSection bool
+		expected       uint16
+	}{
+		{"base", func(*metadata.ApplicationContainer) {}, false, BaseWrapperVersion},
+		{"add-opens", func(meta *metadata.ApplicationContainer) { meta.Application.AddOpens = []string{"java.base/java.lang"} }, false, LaunchAttributesWrapperVersion},
+		{"agent", func(meta *metadata.ApplicationContainer) { meta.Application.LauncherAgentClass = "foo.Agent" }, false, LaunchAttributesWrapperVersion},
+		{"class-path", func(meta *metadata.ApplicationContainer) { meta.Application.ClassPath = []string{"lib/foo.jar"} }, false, LaunchAttributesWrapperVersion},
+		{"section", func(*metadata.ApplicationContainer) {}, true, PayloadSectionWrapperVersion},
+		{"url-schemes", func(meta *metadata.ApplicationContainer) {
+			meta.Desktop = &metadata.DesktopConfiguration{UrlSchemes: []string{"foo"}}
+		}, false, DesktopWrapperVersion},
+		{"icon", func(meta *metadata.ApplicationContainer) {
+			meta.Desktop = &metadata.DesktopConfiguration{Icon: []byte("icon")}
+		}, false, BaseWrapperVersion},
+	}
+
+	for _, test := range tests {
+		meta := testMetadata()
+		test.configure(meta)
+
+		if version := RequiredWrapperVersion(meta, test.payloadSection); version != test.expected {
+			t.Errorf("%s: expected wrapper version %d but got %d", test.name, test.expected, version)
+		}
+	}
+}
+
+func TestDecodeLegacyFooter(t *testing.T) {
+	encoded, err := proto.Marshal(testMetadata())
+	if err != nil {
+		t.Fatalf("failed to encode metadata: %s", err)
+	}
+
+	buf := &bytes.Buffer{}
+	buf.Write(testWrapper)
+	buf.Write(testPayload)
+	buf.Write(encoded)
+	_ = binary.Write(buf, byteOrder, uint32(legacyMagicNumber))
+	_ = binary.Write(buf, byteOrder, uint16(len(encoded)))
+
+	footer, meta, err := DecodeExecutableFooter(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatalf("failed to decode legacy footer: %s", err)
+	}
+
+	if footer.FormatVersion != 1 {
+		t.Errorf("expected format version 1 but got %d", footer.FormatVersion)
+	}
+	if footer.PayloadOffset != 0 || footer.PayloadLength != 0 {
+		t.Errorf("expected unknown payload location but got %d+%d", footer.PayloadOffset, footer.PayloadLength)
+	}
+	if meta.Application.MainClass != "foo.Main" {
+		t.Errorf("expected main class foo.Main but got %q", meta.Application.MainClass)
+	}
+}
+
+func TestDecodeFutureFooter(t *testing.T) {
+	encoded, err := proto.Marshal(testMetadata())
+	if err != nil {
+		t.Fatalf("failed to encode metadata: %s", err)
+	}
+
+	// future revisions may only prepend fields to the footer
+	buf := &bytes.Buffer{}
+	buf.Write(testWrapper)
+	buf.Write(testPayload)
+	buf.Write(encoded)
+	buf.Write([]byte{0xDE, 0xAD, 0xBE, 0xEF})
+	_ = binary.Write(buf, byteOrder, uint64(len(testWrapper)))
+	_ = binary.Write(buf, byteOrder, uint64(len(testPayload)))
+	_ = binary.Write(buf, byteOrder, uint32(len(encoded)))
+	_ = binary.Write(buf, byteOrder, uint16(footerSize+4))
+	_ = binary.Write(buf, byteOrder, uint16(WrapperVersion+1))
+	_ = binary.Write(buf, byteOrder, uint8(FooterFormatVersion+1))
+	_ = binary.Write(buf, byteOrder, uint32(footerMagicNumber))
+
+	footer, meta, err := DecodeExecutableFooter(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatalf("failed to decode future footer: %s", err)
+	}
+
+	if footer.FormatVersion != FooterFormatVersion+1 {
+		t.Errorf("expected format version %d but got %d", FooterFormatVersion+1, footer.FormatVersion)
+	}
+	if footer.MinimumWrapperVersion != WrapperVersion+1 {
+		t.Errorf("expected minimum wrapper version %d but got %d", WrapperVersion+1, footer.MinimumWrapperVersion)
+	}
+	if meta.Application.MainClass != "foo.Main" {
+		t.Errorf("expected main class foo.Main but got %q", meta.Application.MainClass)
+	}
+}
+
+func TestDecodeFooterGap(t *testing.T) {
+	buf := &bytes.Buffer{}
+	buf.Write(testWrapper)
+	buf.Write(testPayload)
+	buf.Write([]byte("injected archive"))
+
+	footer := &Footer{
+		PayloadOffset: uint64(len(testWrapper)),
+		PayloadLength: uint64(len(testPayload)),
+	}
+	if _, err := WriteExecutableFooter(buf, footer, testMetadata()); err != nil {
+		t.Fatalf("failed to write footer: %s", err)
+	}
+
+	if _, _, err := DecodeExecutableFooter(bytes.NewReader(buf.Bytes()), int64(buf.Len())); !errors.Is(err, ErrCorruptFooter) {
+		t.Errorf("expected corrupt footer error but got %v", err)
+	}
+}
+
+func TestDecodeMissingFooter(t *testing.T) {
+	data := []byte("this is not a canoe executable")
+	if _, _, err := DecodeExecutableFooter(bytes.NewReader(data), int64(len(data))); !errors.Is(err, ErrMissingFooter) {
+		t.Errorf("expected missing footer error but got %v", err)
+	}
+}
+
+func TestDecodeSignedFooter(t *testing.T) {
+	buf := &bytes.Buffer{}
+
+	dos := make([]byte, 0x40)
+	copy(dos, "MZ")
+	binary.LittleEndian.PutUint32(dos[0x3C:], 0x40)
+	buf.Write(dos)
+
+	buf.WriteString("PE\x00\x00")
+	_ = binary.Write(buf, binary.LittleEndian, pe.FileHeader{
+		Machine:              pe.IMAGE_FILE_MACHINE_AMD64,
+		SizeOfOptionalHeader: 240,
+	})
+	optionalHeader := pe.OptionalHeader64{
+		Magic:               0x20B,
+		NumberOfRvaAndSizes: 16,
+	}
+	securityDirectory := &optionalHeader.DataDirectory[pe.IMAGE_DIRECTORY_ENTRY_SECURITY]
+	headerOffset := buf.Len()
+	_ = binary.Write(buf, binary.LittleEndian, optionalHeader)
+
+	payloadOffset := buf.Len()
+	buf.Write(testPayload)
+
+	in := &Footer{
+		MinimumWrapperVersion: WrapperVersion,
+		PayloadOffset:         uint64(payloadOffset),
+		PayloadLength:         uint64(len(testPayload)),
+	}
+	if _, err := WriteExecutableFooter(buf, in, testMetadata()); err != nil {
+		t.Fatalf("failed to write footer: %s", err)
+	}
+
+	// append a certificate table and update the header accordingly
+	securityDirectory.VirtualAddress = uint32(buf.Len())
+	securityDirectory.Size = 16
+	buf.Write(make([]byte, 16))
+
+	data := buf.Bytes()
+	header := &bytes.Buffer{}
+	_ = binary.Write(header, binary.LittleEndian, optionalHeader)
+	copy(data[headerOffset:], header.Bytes())
+
+	footer, meta, err := DecodeExecutableFooter(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatalf("failed to decode footer: %s", err)
+	}
+	if footer.PayloadOffset != uint64(payloadOffset) || footer.PayloadLength != uint64(len(testPayload)) {
+		t.Errorf("unexpected payload location %d+%d", footer.PayloadOffset, footer.PayloadLength)
+	}
+	if meta.Application.MainClass != "foo.Main" {
+		t.Errorf("expected main class foo.Main but got %q", meta.Application.MainClass)
+	}
+}
+
+// assembles a minimal Mach-O executable which embeds a given payload along with its metadata within
+// a payload section
+func testMachOExecutable(t *testing.T, payload []byte) []byte {
+	const sectionOffset = 0x1000
+
+	trailer := &bytes.Buffer{}
+	footer := &Footer{
+		MinimumWrapperVersion: WrapperVersion,
+		PayloadLength:         uint64(len(payload)),
+	}
+	if _, err := WriteExecutableFooter(trailer, footer, testMetadata()); err != nil {
+		t.Fatalf("failed to write footer: %s", err)
+	}
+	contents := append(append([]byte{}, payload...), trailer.Bytes()...)
+
+	segment := macho.Segment64{
+		Cmd:     macho.LoadCmdSegment64,
+		Len:     72 + 80,
+		Addr:    0x100000000,
+		Memsz:   uint64(len(contents)),
+		Offset:  sectionOffset,
+		Filesz:  uint64(len(contents)),
+		Maxprot: 1,
+		Prot:    1,
+		Nsect:   1,
+	}
+	copy(segment.Name[:], PayloadSegmentName)
+	section := macho.Section64{
+		Addr:   0x100000000,
+		Size:   uint64(len(contents)),
+		Offset: sectionOffset,
+	}
+	copy(section.Name[:], PayloadSectionName)
+	copy(section.Seg[:], PayloadSegmentName)
+
+	buf := &bytes.Buffer{}
+	_ = binary.Write(buf, binary.LittleEndian, macho.FileHeader{
+		Magic: macho.Magic64,
+		Cpu:   macho.CpuArm64,
+		Type:  macho.TypeExec,
+		Ncmd:  1,
+		Cmdsz: segment.Len,
+	})
+	buf.Write(make([]byte, 4)) // reserved field of 64-bit headers
+	_ = binary.Write(buf, binary.LittleEndian, segment)
+	_ = binary.Write(buf, binary.LittleEndian, section)
+	buf.Write(make([]byte, sectionOffset-buf.Len()))
+	buf.Write(contents)
+
+	// the code signature follows the payload section
+	buf.Write(bytes.Repeat([]byte{0xFA}, 64))
+
+	return buf.Bytes()
+}
+
+func TestDecodeEmbeddedFooter(t *testing.T) {
+	data := testMachOExecutable(t, testPayload)
+
+	footer, meta, err := DecodeExecutableFooter(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatalf("failed to decode footer: %s", err)
+	}
+	if footer.PayloadOffset != 0x1000 || footer.PayloadLength != uint64(len(testPayload)) {
+		t.Errorf("unexpected payload location %d+%d", footer.PayloadOffset, footer.PayloadLength)
+	}
+	if meta.Application.MainClass != "foo.Main" {
+		t.Errorf("expected main class foo.Main but got %q", meta.Application.MainClass)
+	}
+}
+
+func TestDecodeUniversalFooter(t *testing.T) {
+	const sliceOffset = 0x4000
+
+	slice := testMachOExecutable(t, testPayload)
+
+	// universal binaries are prefixed by a big endian header describing each slice
+	buf := &bytes.Buffer{}
+	_ = binary.Write(buf, binary.BigEndian, []uint32{
+		macho.MagicFat, 1,
+		uint32(macho.CpuArm64), 0, sliceOffset, uint32(len(slice)), 14,
+	})
+	buf.Write(make([]byte, sliceOffset-buf.Len()))
+	buf.Write(slice)
+	data := buf.Bytes()
+
+	footer, meta, err := DecodeExecutableFooter(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatalf("failed to decode footer: %s", err)
+	}
+	if footer.WrapperOffset != sliceOffset {
+		t.Errorf("expected wrapper offset %d but got %d", sliceOffset, footer.WrapperOffset)
+	}
+	if footer.PayloadOffset != sliceOffset+0x1000 || footer.PayloadLength != uint64(len(testPayload)) {
+		t.Errorf("unexpected payload location %d+%d", footer.PayloadOffset, footer.PayloadLength)
+	}
+	if meta.Application.MainClass != "foo.Main" {
+		t.Errorf("expected main class foo.Main but got %q", meta.Application.MainClass)
+	}
+}
+
+// assembles a minimal ELF executable which embeds a given payload along with its metadata within a
+// payload section
+func testELFExecutable(t *testing.T, payload []byte) *canoeelf.Image {
+	names := []byte("\x00.comment\x00.shstrtab\x00")
+	comment := []byte("GCC: (GNU) 11.2.0\x00")
+
+	buf := &bytes.Buffer{}
+	_ = binary.Write(buf, binary.LittleEndian, elf.Header64{
+		Ident:     [elf.EI_NIDENT]byte{0x7F, 'E', 'L', 'F', byte(elf.ELFCLASS64), byte(elf.ELFDATA2LSB), byte(elf.EV_CURRENT)},
+		Type:      uint16(elf.ET_EXEC),
+		Machine:   uint16(elf.EM_X86_64),
+		Version:   uint32(elf.EV_CURRENT),
+		Shoff:     uint64(64 + len(comment) + len(names)),
+		Ehsize:    64,
+		Shentsize: 64,
+		Shnum:     3,
+		Shstrndx:  2,
+	})
+	buf.Write(comment)
+	buf.Write(names)
+	_ = binary.Write(buf, binary.LittleEndian, []elf.Section64{
+		{},
+		{Name: 1, Type: uint32(elf.SHT_PROGBITS), Off: 64, Size: uint64(len(comment)), Addralign: 1},
+		{Name: 10, Type: uint32(elf.SHT_STRTAB), Off: uint64(64 + len(comment)), Size: uint64(len(names)), Addralign: 1},
+	})
+
+	img, err := canoeelf.Parse(buf.Bytes())
+	if err != nil {
+		t.Fatalf("failed to parse executable: %s", err)
+	}
+
+	trailer := &bytes.Buffer{}
+	footer := &Footer{
+		MinimumWrapperVersion: WrapperVersion,
+		PayloadLength:         uint64(len(payload)),
+	}
+	if _, err := WriteExecutableFooter(trailer, footer, testMetadata()); err != nil {
+		t.Fatalf("failed to write footer: %s", err)
+	}
+	if _, err := img.AddSection(ELFPayloadSectionName, append(append([]byte{}, payload...), trailer.Bytes()...)); err != nil {
+		t.Fatalf("failed to add payload section: %s", err)
+	}
+
+	return img
+}
+
+func TestDecodeELFSectionFooter(t *testing.T) {
+	img := testELFExecutable(t, testPayload)
+
+	var previousOffset uint64
+	for _, rewrite := range []string{"none", "strip"} {
+		// strip removes sections in front of the payload section thus relocating it
+		if rewrite == "strip" {
+			if err := img.RemoveSection(".comment"); err != nil {
+				t.Fatalf("failed to remove section: %s", err)
+			}
+		}
+		data := img.Bytes()
+
+		f, err := elf.NewFile(bytes.NewReader(data))
+		if err != nil {
+			t.Fatalf("%s: failed to parse executable: %s", rewrite, err)
+		}
+		section := f.Section(ELFPayloadSectionName)
+		if section == nil || section.Flags&elf.SHF_ALLOC != 0 {
+			t.Fatalf("%s: expected non-allocated payload section", rewrite)
+		}
+		if section.Offset == previousOffset {
+			t.Errorf("%s: expected payload section to be relocated", rewrite)
+		}
+		previousOffset = section.Offset
+
+		footer, meta, err := DecodeExecutableFooter(bytes.NewReader(data), int64(len(data)))
+		if err != nil {
+			t.Fatalf("%s: failed to decode footer: %s", rewrite, err)
+		}
+		if footer.PayloadOffset != section.Offset || footer.PayloadLength != uint64(len(testPayload)) {
+			t.Errorf("%s: unexpected payload location %d+%d", rewrite, footer.PayloadOffset, footer.PayloadLength)
+		}
+		if !bytes.Equal(data[footer.PayloadOffset:footer.PayloadOffset+footer.PayloadLength], testPayload) {
+			t.Errorf("%s: unexpected payload contents", rewrite)
+		}
+		if meta.Application.MainClass != "foo.Main" {
+			t.Errorf("%s: expected main class foo.Main but got %q", rewrite, meta.Application.MainClass)
+		}
+	}
+}
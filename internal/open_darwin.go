@@ -1,3 +1,5 @@
+//go:build darwin
+
 /*
  * Copyright 2021 Johannes Donath <johannesd@torchmind.com>
  * and other copyright owners as documented in the project's IP log.
@@ -14,14 +16,11 @@
  * See the License for the specific language governing permissions and
  * limitations under the License.
  */
-package main
+package internal
 
-import (
-	"github.com/dotstart/canoe/internal"
-	"github.com/dotstart/canoe/internal/runtime"
-	"os"
-)
+import "os/exec"
 
-func main() {
-	os.Exit(internal.Launch(runtime.GuiExecutableName))
+// opens a given URL within the default browser
+func openURL(url string) error {
+	return exec.Command("open", url).Start()
 }
@@ -0,0 +1,159 @@
+/*
+ * Copyright 2021 Johannes Donath <johannesd@torchmind.com>
+ * and other copyright owners as documented in the project's IP log.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package internal
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"github.com/dotstart/canoe/internal/metadata"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// PayloadArchive identifies the archive from which the runtime loads the payload of a given
+// executable.
+//
+// Payloads are typically appended to the executable thus permitting the runtime to open the
+// executable itself. Payloads which have been embedded within a Mach-O or ELF section are not
+// located at the end of the file and are thus extracted to the user cache directory instead (and
+// extracted again when the cached copy does not match the payload digest). The payload is expected
+// to have been verified prior to invoking this function.
+func PayloadArchive(executable string, footer *Footer, meta *metadata.ApplicationContainer) (string, error) {
+	f, err := os.Open(executable)
+	if err != nil {
+		return "", fmt.Errorf("failed to open executable: %w", err)
+	}
+	defer f.Close()
+
+	stat, err := f.Stat()
+	if err != nil {
+		return "", fmt.Errorf("failed to stat executable: %w", err)
+	}
+
+	if _, _, ok := PayloadSection(f, stat.Size()); !ok {
+		return executable, nil
+	}
+
+	digest := meta.GetIntegrity().GetPayloadSha256()
+	if len(digest) == 0 {
+		digest, err = DigestSection(f, footer.PayloadOffset, footer.PayloadLength)
+		if err != nil {
+			return "", fmt.Errorf("failed to read payload: %w", err)
+		}
+	}
+
+	cacheDir, err := os.UserCacheDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to locate cache directory: %w", err)
+	}
+
+	path := filepath.Join(cacheDir, "canoe", "payload", hex.EncodeToString(digest)+".jar")
+	useCache := usesIntegrityCache(meta)
+	if isCachedPayload(path, footer.PayloadLength, digest, useCache) {
+		return path, nil
+	}
+
+	if err := extractPayload(f, footer, path); err != nil {
+		return "", fmt.Errorf("failed to extract payload: %w", err)
+	}
+	if useCache {
+		// failing to cache the state of the extracted payload merely results in it being verified
+		// again upon the next launch
+		if stat, err := os.Stat(path); err == nil {
+			_ = payloadCacheEntry(stat, digest).store(integrityCachePath(path))
+		}
+	}
+
+	return path, nil
+}
+
+// identifies whether a previously extracted payload at a given path matches the expected length
+// and digest
+//
+// cached payloads are verified as they are loaded by the runtime in place of the verified payload
+// within the executable. Successful verifications are cached along with the size and modification
+// time of the extracted payload (with the exception of signed executables and wrappers which pin a
+// signing key) in order to avoid rehashing it upon every launch.
+func isCachedPayload(path string, length uint64, digest []byte, useCache bool) bool {
+	f, err := os.Open(path)
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+
+	stat, err := f.Stat()
+	if err != nil || uint64(stat.Size()) != length {
+		return false
+	}
+
+	entry := payloadCacheEntry(stat, digest)
+	cachePath := ""
+	if useCache {
+		cachePath = integrityCachePath(path)
+	}
+	if len(cachePath) != 0 && entry.matches(cachePath) {
+		return true
+	}
+
+	actual, err := DigestSection(f, 0, length)
+	if err != nil || !bytes.Equal(actual, digest) {
+		return false
+	}
+
+	if len(cachePath) != 0 {
+		_ = entry.store(cachePath)
+	}
+
+	return true
+}
+
+// produces the integrity cache entry which describes a given state of an extracted payload
+func payloadCacheEntry(stat os.FileInfo, digest []byte) *integrityCacheEntry {
+	return &integrityCacheEntry{
+		Size:          stat.Size(),
+		ModTime:       stat.ModTime().UnixNano(),
+		PayloadSha256: hex.EncodeToString(digest),
+	}
+}
+
+// extracts the payload of a given executable to a given path
+//
+// the payload is written to a temporary file first in order to prevent concurrently launched
+// instances from observing partially written archives
+func extractPayload(r io.ReaderAt, footer *Footer, path string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+
+	out, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(out.Name())
+	defer out.Close()
+
+	if _, err := io.Copy(out, io.NewSectionReader(r, int64(footer.PayloadOffset), int64(footer.PayloadLength))); err != nil {
+		return err
+	}
+	if err := out.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(out.Name(), path)
+}
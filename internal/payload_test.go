@@ -0,0 +1,150 @@
+/*
+ * Copyright 2021 Johannes Donath <johannesd@torchmind.com>
+ * and other copyright owners as documented in the project's IP log.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package internal
+
+import (
+	"bytes"
+	"github.com/dotstart/canoe/internal/metadata"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestPayloadArchive(t *testing.T) {
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+
+	path := filepath.Join(t.TempDir(), "executable")
+	if err := os.WriteFile(path, testMachOExecutable(t, testPayload), 0755); err != nil {
+		t.Fatalf("failed to write executable: %s", err)
+	}
+
+	footer, meta, err := ReadExecutableContainer(path)
+	if err != nil {
+		t.Fatalf("failed to read executable: %s", err)
+	}
+
+	archive, err := PayloadArchive(path, footer, meta)
+	if err != nil {
+		t.Fatalf("failed to locate payload archive: %s", err)
+	}
+	if archive == path {
+		t.Fatalf("expected embedded payload to be extracted")
+	}
+
+	extracted, err := os.ReadFile(archive)
+	if err != nil {
+		t.Fatalf("failed to read extracted payload: %s", err)
+	}
+	if !bytes.Equal(extracted, testPayload) {
+		t.Errorf("expected payload %q but got %q", testPayload, extracted)
+	}
+
+	// subsequent launches reuse the extracted payload
+	if cached, err := PayloadArchive(path, footer, meta); err != nil || cached != archive {
+		t.Errorf("expected cached payload %s but got %s (%v)", archive, cached, err)
+	}
+
+	// modified copies of the same size are replaced with the verified payload
+	tampered := bytes.Repeat([]byte{'X'}, len(testPayload))
+	if err := os.WriteFile(archive, tampered, 0600); err != nil {
+		t.Fatalf("failed to modify extracted payload: %s", err)
+	}
+	if _, err := PayloadArchive(path, footer, meta); err != nil {
+		t.Fatalf("failed to locate payload archive: %s", err)
+	}
+
+	extracted, err = os.ReadFile(archive)
+	if err != nil {
+		t.Fatalf("failed to read extracted payload: %s", err)
+	}
+	if !bytes.Equal(extracted, testPayload) {
+		t.Errorf("expected payload %q to be restored but got %q", testPayload, extracted)
+	}
+}
+
+func TestPayloadArchiveCache(t *testing.T) {
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+
+	path := filepath.Join(t.TempDir(), "executable")
+	if err := os.WriteFile(path, testMachOExecutable(t, testPayload), 0755); err != nil {
+		t.Fatalf("failed to write executable: %s", err)
+	}
+
+	footer, meta, err := ReadExecutableContainer(path)
+	if err != nil {
+		t.Fatalf("failed to read executable: %s", err)
+	}
+
+	archive, err := PayloadArchive(path, footer, meta)
+	if err != nil {
+		t.Fatalf("failed to locate payload archive: %s", err)
+	}
+	stat, err := os.Stat(archive)
+	if err != nil {
+		t.Fatalf("failed to stat extracted payload: %s", err)
+	}
+
+	// modifications which retain the size and modification time are not detected without rehashing
+	// the extracted payload
+	tampered := bytes.Repeat([]byte{'X'}, len(testPayload))
+	if err := os.WriteFile(archive, tampered, 0600); err != nil {
+		t.Fatalf("failed to modify extracted payload: %s", err)
+	}
+	_ = os.Chtimes(archive, stat.ModTime(), stat.ModTime())
+
+	if _, err := PayloadArchive(path, footer, meta); err != nil {
+		t.Fatalf("failed to locate payload archive: %s", err)
+	}
+	if extracted, _ := os.ReadFile(archive); !bytes.Equal(extracted, tampered) {
+		t.Errorf("expected cached verification to be reused")
+	}
+
+	// signed executables verify the extracted payload upon every launch
+	meta.Signature = &metadata.SignatureConfiguration{}
+	if _, err := PayloadArchive(path, footer, meta); err != nil {
+		t.Fatalf("failed to locate payload archive: %s", err)
+	}
+	if extracted, _ := os.ReadFile(archive); !bytes.Equal(extracted, testPayload) {
+		t.Errorf("expected payload %q to be restored but got %q", testPayload, extracted)
+	}
+}
+
+func TestPayloadArchiveAppended(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "executable")
+
+	buf := &bytes.Buffer{}
+	buf.Write(testWrapper)
+	buf.Write(testPayload)
+	footer := &Footer{
+		PayloadOffset: uint64(len(testWrapper)),
+		PayloadLength: uint64(len(testPayload)),
+	}
+	if _, err := WriteExecutableFooter(buf, footer, testMetadata()); err != nil {
+		t.Fatalf("failed to write footer: %s", err)
+	}
+	if err := os.WriteFile(path, buf.Bytes(), 0755); err != nil {
+		t.Fatalf("failed to write executable: %s", err)
+	}
+
+	archive, err := PayloadArchive(path, footer, testMetadata())
+	if err != nil {
+		t.Fatalf("failed to locate payload archive: %s", err)
+	}
+	if archive != path {
+		t.Errorf("expected appended payload to be loaded from the executable but got %s", archive)
+	}
+}
@@ -0,0 +1,342 @@
+/*
+ * Copyright 2021 Johannes Donath <johannesd@torchmind.com>
+ * and other copyright owners as documented in the project's IP log.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package pe
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"software.sslmate.com/src/go-pkcs12"
+	"sort"
+	"unicode/utf16"
+)
+
+// identifies the revision and type of certificates within the certificate table
+const (
+	certificateRevision        = 0x0200
+	certificateTypePkcsSigned  = 0x0002
+	certificateHeaderSize      = 8
+	certificateTableAlignment  = 8
+	authenticodeObsoleteString = "<<<Obsolete>>>"
+)
+
+var (
+	oidSignedData           = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 2}
+	oidContentType          = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 3}
+	oidMessageDigest        = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 4}
+	oidSha256               = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 2, 1}
+	oidRsaEncryption        = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 1, 1}
+	oidEcdsaWithSha256      = asn1.ObjectIdentifier{1, 2, 840, 10045, 4, 3, 2}
+	oidSpcIndirectData      = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 311, 2, 1, 4}
+	oidSpcStatementType     = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 311, 2, 1, 11}
+	oidSpcSpOpusInfo        = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 311, 2, 1, 12}
+	oidSpcPeImageData       = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 311, 2, 1, 15}
+	oidSpcIndividualSigning = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 311, 2, 1, 21}
+)
+
+var ErrUnsupportedKey = errors.New("unsupported signing key")
+
+// Signer encapsulates the certificate chain and private key with which Authenticode signatures are
+// created.
+type Signer struct {
+	Certificate *x509.Certificate
+	Chain       []*x509.Certificate
+	Key         crypto.Signer
+}
+
+// ReadPKCS12 decodes a signer from a given PKCS#12 archive.
+//
+// The archive is expected to contain a single private key along with its certificate and
+// (optionally) the intermediate certificates of its chain.
+func ReadPKCS12(data []byte, password string) (*Signer, error) {
+	key, certificate, chain, err := pkcs12.DecodeChain(data, password)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode PKCS#12 archive: %w", err)
+	}
+
+	switch key.(type) {
+	case *rsa.PrivateKey, *ecdsa.PrivateKey:
+	default:
+		return nil, fmt.Errorf("%w: %T", ErrUnsupportedKey, key)
+	}
+
+	return &Signer{
+		Certificate: certificate,
+		Chain:       chain,
+		Key:         key.(crypto.Signer),
+	}, nil
+}
+
+// CertificateTable retrieves the file offset and size of the certificate table.
+//
+// Returns zero when the image has not been signed.
+func (img *Image) CertificateTable() (uint32, uint32) {
+	return img.Directory(securityDirectory)
+}
+
+// SetCertificateTable updates the file offset and size of the certificate table.
+//
+// Certificate tables are located at the end of the image and are thus not mapped into memory. As
+// such, the offset refers to a location within the file rather than a virtual address.
+func (img *Image) SetCertificateTable(offset uint32, size uint32) error {
+	if offset%certificateTableAlignment != 0 {
+		return fmt.Errorf("%w: certificate table offset must be aligned to %d bytes", ErrMalformed, certificateTableAlignment)
+	}
+
+	return img.setDirectory(securityDirectory, offset, size)
+}
+
+// AuthenticodeDigest computes the SHA-256 Authenticode digest of a given image.
+//
+// The digest covers the image headers (excluding the checksum and certificate table directory),
+// all sections in order of their file offsets and any data which follows the last section up to
+// the certificate table (if present).
+func AuthenticodeDigest(data []byte) ([]byte, error) {
+	img, err := Parse(data)
+	if err != nil {
+		return nil, err
+	}
+
+	end := uint32(len(data))
+	if offset, size := img.CertificateTable(); offset != 0 && size != 0 && offset < end {
+		end = offset
+	}
+
+	headerSize := img.sizeOfHeaders()
+	if headerSize > end {
+		return nil, fmt.Errorf("%w: headers exceed image bounds", ErrMalformed)
+	}
+
+	checksumField := uint32(img.optionalOffset + checksumOffset)
+	directoryField := uint32(img.directories + securityDirectory*8)
+	if securityDirectory >= img.directoryCount {
+		return nil, fmt.Errorf("%w: certificate table directory is not present", ErrMalformed)
+	}
+
+	h := sha256.New()
+	h.Write(data[:checksumField])
+	h.Write(data[checksumField+4 : directoryField])
+	h.Write(data[directoryField+8 : headerSize])
+
+	sections := img.Sections()
+	sort.Slice(sections, func(i, j int) bool {
+		return sections[i].PointerToRawData < sections[j].PointerToRawData
+	})
+
+	hashed := headerSize
+	for _, section := range sections {
+		if section.SizeOfRawData == 0 {
+			continue
+		}
+
+		sectionEnd := section.PointerToRawData + section.SizeOfRawData
+		if sectionEnd > end || sectionEnd < section.PointerToRawData {
+			return nil, fmt.Errorf("%w: section %s exceeds image bounds", ErrMalformed, section.Name)
+		}
+
+		h.Write(data[section.PointerToRawData:sectionEnd])
+		hashed += section.SizeOfRawData
+	}
+
+	if hashed < end {
+		h.Write(data[hashed:end])
+	}
+
+	return h.Sum(nil), nil
+}
+
+// Sign computes the Authenticode signature of a given image and encodes it as a certificate table.
+//
+// The certificate table directory of the image is expected to refer to the end of the passed data
+// (where the resulting table is to be appended) already as it is covered by the signature. The
+// program name and URL are displayed by Windows when prompting users for consent and may be left
+// empty.
+func (s *Signer) Sign(data []byte, programName string, url string) ([]byte, error) {
+	digest, err := AuthenticodeDigest(data)
+	if err != nil {
+		return nil, err
+	}
+
+	signedData, err := s.encodeSignedData(digest, programName, url)
+	if err != nil {
+		return nil, err
+	}
+
+	length := certificateHeaderSize + len(signedData)
+	table := make([]byte, align(uint32(length), certificateTableAlignment))
+	binary.LittleEndian.PutUint32(table, uint32(length))
+	binary.LittleEndian.PutUint16(table[4:], certificateRevision)
+	binary.LittleEndian.PutUint16(table[6:], certificateTypePkcsSigned)
+	copy(table[certificateHeaderSize:], signedData)
+
+	return table, nil
+}
+
+// encodes the PKCS#7 SignedData structure which embeds a given image digest
+func (s *Signer) encodeSignedData(digest []byte, programName string, url string) ([]byte, error) {
+	var encryptionAlgorithm []byte
+	switch s.Key.Public().(type) {
+	case *rsa.PublicKey:
+		encryptionAlgorithm = algorithmIdentifier(oidRsaEncryption, true)
+	case *ecdsa.PublicKey:
+		encryptionAlgorithm = algorithmIdentifier(oidEcdsaWithSha256, false)
+	default:
+		return nil, fmt.Errorf("%w: %T", ErrUnsupportedKey, s.Key.Public())
+	}
+	digestAlgorithm := algorithmIdentifier(oidSha256, true)
+
+	obsolete := encodeBMPString(authenticodeObsoleteString)
+	peImageData := sequence(
+		mustMarshal(asn1.BitString{}),
+		tagged(0, true, tagged(2, true, tagged(0, false, obsolete))),
+	)
+	indirectData := sequence(
+		sequence(mustMarshal(oidSpcPeImageData), peImageData),
+		sequence(digestAlgorithm, mustMarshal(digest)),
+	)
+
+	// the message digest only covers the contents of the indirect data structure (excluding its
+	// tag and length)
+	var indirectDataContents asn1.RawValue
+	if _, err := asn1.Unmarshal(indirectData, &indirectDataContents); err != nil {
+		return nil, err
+	}
+	indirectDataDigest := sha256.Sum256(indirectDataContents.Bytes)
+
+	opusInfo := make([]byte, 0)
+	if len(programName) != 0 {
+		opusInfo = append(opusInfo, tagged(0, true, tagged(0, false, encodeBMPString(programName)))...)
+	}
+	if len(url) != 0 {
+		opusInfo = append(opusInfo, tagged(1, true, tagged(0, false, []byte(url)))...)
+	}
+
+	attributes := [][]byte{
+		attribute(oidContentType, mustMarshal(oidSpcIndirectData)),
+		attribute(oidMessageDigest, mustMarshal(indirectDataDigest[:])),
+		attribute(oidSpcSpOpusInfo, sequence(opusInfo)),
+		attribute(oidSpcStatementType, sequence(mustMarshal(oidSpcIndividualSigning))),
+	}
+	encodedAttributes := set(attributes...)
+
+	attributesDigest := sha256.Sum256(encodedAttributes)
+	signature, err := s.Key.Sign(rand.Reader, attributesDigest[:], crypto.SHA256)
+	if err != nil {
+		return nil, fmt.Errorf("failed to sign image: %w", err)
+	}
+
+	serialNumber, err := asn1.Marshal(s.Certificate.SerialNumber)
+	if err != nil {
+		return nil, err
+	}
+
+	// authenticated attributes are stored with an implicit tag but signed using their SET encoding
+	var implicitAttributes asn1.RawValue
+	if _, err := asn1.Unmarshal(encodedAttributes, &implicitAttributes); err != nil {
+		return nil, err
+	}
+
+	signerInfo := sequence(
+		mustMarshal(1),
+		sequence(s.Certificate.RawIssuer, serialNumber),
+		digestAlgorithm,
+		tagged(0, true, implicitAttributes.Bytes),
+		encryptionAlgorithm,
+		mustMarshal(signature),
+	)
+
+	certificates := make([]byte, 0)
+	certificates = append(certificates, s.Certificate.Raw...)
+	for _, certificate := range s.Chain {
+		certificates = append(certificates, certificate.Raw...)
+	}
+
+	signedData := sequence(
+		mustMarshal(1),
+		set(digestAlgorithm),
+		sequence(mustMarshal(oidSpcIndirectData), tagged(0, true, indirectData)),
+		tagged(0, true, certificates),
+		set(signerInfo),
+	)
+
+	return sequence(mustMarshal(oidSignedData), tagged(0, true, signedData)), nil
+}
+
+// encodes an algorithm identifier with optional NULL parameters
+func algorithmIdentifier(oid asn1.ObjectIdentifier, nullParameters bool) []byte {
+	if nullParameters {
+		return sequence(mustMarshal(oid), asn1.NullBytes)
+	}
+
+	return sequence(mustMarshal(oid))
+}
+
+// encodes a PKCS#9 attribute with a single value
+func attribute(oid asn1.ObjectIdentifier, value []byte) []byte {
+	return sequence(mustMarshal(oid), set(value))
+}
+
+// encodes a SEQUENCE of pre-encoded elements
+func sequence(elements ...[]byte) []byte {
+	return mustMarshal(asn1.RawValue{Class: asn1.ClassUniversal, Tag: asn1.TagSequence, IsCompound: true, Bytes: bytes.Join(elements, nil)})
+}
+
+// encodes a SET of pre-encoded elements in canonical (sorted) order
+func set(elements ...[]byte) []byte {
+	sorted := make([][]byte, len(elements))
+	copy(sorted, elements)
+	sort.Slice(sorted, func(i, j int) bool {
+		return bytes.Compare(sorted[i], sorted[j]) < 0
+	})
+
+	return mustMarshal(asn1.RawValue{Class: asn1.ClassUniversal, Tag: asn1.TagSet, IsCompound: true, Bytes: bytes.Join(sorted, nil)})
+}
+
+// encodes a context specific element with a given tag
+func tagged(tag int, compound bool, contents []byte) []byte {
+	return mustMarshal(asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: tag, IsCompound: compound, Bytes: contents})
+}
+
+// encodes a given string using big endian UTF-16 as expected of BMPString values
+func encodeBMPString(value string) []byte {
+	encoded := utf16.Encode([]rune(value))
+
+	result := make([]byte, len(encoded)*2)
+	for i, unit := range encoded {
+		binary.BigEndian.PutUint16(result[i*2:], unit)
+	}
+
+	return result
+}
+
+// encodes a given value which is known to be encodable
+func mustMarshal(value interface{}) []byte {
+	encoded, err := asn1.Marshal(value)
+	if err != nil {
+		panic(err)
+	}
+
+	return encoded
+}
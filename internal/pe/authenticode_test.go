@@ -0,0 +1,310 @@
+/*
+ * Copyright 2021 Johannes Donath <johannesd@torchmind.com>
+ * and other copyright owners as documented in the project's IP log.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package pe
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"debug/pe"
+	"encoding/asn1"
+	"encoding/binary"
+	"errors"
+	"math/big"
+	"software.sslmate.com/src/go-pkcs12"
+	"testing"
+	"time"
+)
+
+type testContentInfo struct {
+	ContentType asn1.ObjectIdentifier
+	Content     asn1.RawValue `asn1:"explicit,tag:0"`
+}
+
+type testSignedData struct {
+	Version          int
+	DigestAlgorithms asn1.RawValue
+	ContentInfo      testIndirectContentInfo
+	Certificates     asn1.RawValue
+	SignerInfos      []testSignerInfo `asn1:"set"`
+}
+
+type testIndirectContentInfo struct {
+	ContentType asn1.ObjectIdentifier
+	Content     testIndirectData `asn1:"explicit,tag:0"`
+}
+
+type testIndirectData struct {
+	Data          asn1.RawValue
+	MessageDigest testDigestInfo
+}
+
+type testDigestInfo struct {
+	Algorithm pkix.AlgorithmIdentifier
+	Digest    []byte
+}
+
+type testSignerInfo struct {
+	Version                   int
+	IssuerAndSerialNumber     asn1.RawValue
+	DigestAlgorithm           pkix.AlgorithmIdentifier
+	AuthenticatedAttributes   asn1.RawValue
+	DigestEncryptionAlgorithm pkix.AlgorithmIdentifier
+	EncryptedDigest           []byte
+}
+
+type testAttribute struct {
+	Type   asn1.ObjectIdentifier
+	Values asn1.RawValue
+}
+
+// generates a test certificate authority along with a code signing certificate which has been
+// issued by it
+func testCertificates(t *testing.T, key crypto.Signer) (*x509.Certificate, *x509.Certificate) {
+	caKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate CA key: %s", err)
+	}
+
+	now := time.Now()
+	caTemplate := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "canoe Test CA"},
+		NotBefore:             now.Add(-time.Hour),
+		NotAfter:              now.Add(time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	caDer, err := x509.CreateCertificate(rand.Reader, caTemplate, caTemplate, caKey.Public(), caKey)
+	if err != nil {
+		t.Fatalf("failed to create CA certificate: %s", err)
+	}
+	ca, err := x509.ParseCertificate(caDer)
+	if err != nil {
+		t.Fatalf("failed to parse CA certificate: %s", err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(0x1337),
+		Subject:      pkix.Name{CommonName: "canoe Test Publisher"},
+		NotBefore:    now.Add(-time.Hour),
+		NotAfter:     now.Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageCodeSigning},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, ca, key.Public(), caKey)
+	if err != nil {
+		t.Fatalf("failed to create code signing certificate: %s", err)
+	}
+	certificate, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatalf("failed to parse code signing certificate: %s", err)
+	}
+
+	return ca, certificate
+}
+
+func TestSign(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("failed to generate RSA key: %s", err)
+	}
+	ecdsaKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate ECDSA key: %s", err)
+	}
+
+	for _, key := range []crypto.Signer{rsaKey, ecdsaKey} {
+		ca, certificate := testCertificates(t, key)
+
+		bundle, err := pkcs12.Encode(rand.Reader, key, certificate, []*x509.Certificate{ca}, "secret")
+		if err != nil {
+			t.Fatalf("failed to encode PKCS#12 bundle: %s", err)
+		}
+		if _, err := ReadPKCS12(bundle, "incorrect"); err == nil {
+			t.Errorf("expected incorrect password to be rejected")
+		}
+		signer, err := ReadPKCS12(bundle, "secret")
+		if err != nil {
+			t.Fatalf("failed to read PKCS#12 bundle: %s", err)
+		}
+
+		data := append(testImage(), []byte("appended payload")...)
+		img, err := Parse(data)
+		if err != nil {
+			t.Fatalf("failed to parse image: %s", err)
+		}
+		if err := img.SetCertificateTable(uint32(len(data))+1, 0); err == nil {
+			t.Errorf("expected unaligned certificate table to be rejected")
+		}
+		if err := img.SetCertificateTable(uint32(len(data)), 0); err != nil {
+			t.Fatalf("failed to update certificate table: %s", err)
+		}
+
+		table, err := signer.Sign(data, "Foo", "https://example.org")
+		if err != nil {
+			t.Fatalf("failed to sign image: %s", err)
+		}
+		if err := img.SetCertificateTable(uint32(len(data)), uint32(len(table))); err != nil {
+			t.Fatalf("failed to update certificate table: %s", err)
+		}
+		signed := append(data, table...)
+
+		f, err := pe.NewFile(bytes.NewReader(signed))
+		if err != nil {
+			t.Fatalf("failed to parse signed image: %s", err)
+		}
+		directory := f.OptionalHeader.(*pe.OptionalHeader64).DataDirectory[pe.IMAGE_DIRECTORY_ENTRY_SECURITY]
+		if directory.VirtualAddress != uint32(len(data)) || directory.Size != uint32(len(table)) {
+			t.Errorf("expected certificate table at %d (%d bytes) but got %d (%d bytes)", len(data), len(table), directory.VirtualAddress, directory.Size)
+		}
+
+		if len(table)%certificateTableAlignment != 0 {
+			t.Errorf("expected certificate table to be aligned but got length %d", len(table))
+		}
+		length := binary.LittleEndian.Uint32(table)
+		if binary.LittleEndian.Uint16(table[4:]) != certificateRevision || binary.LittleEndian.Uint16(table[6:]) != certificateTypePkcsSigned {
+			t.Errorf("unexpected certificate revision or type")
+		}
+
+		var content testContentInfo
+		if _, err := asn1.Unmarshal(table[certificateHeaderSize:length], &content); err != nil {
+			t.Fatalf("failed to decode content info: %s", err)
+		}
+		if !content.ContentType.Equal(oidSignedData) {
+			t.Errorf("expected signed data but got %s", content.ContentType)
+		}
+
+		var signedData testSignedData
+		if _, err := asn1.Unmarshal(content.Content.Bytes, &signedData); err != nil {
+			t.Fatalf("failed to decode signed data: %s", err)
+		}
+
+		expectedDigest, err := AuthenticodeDigest(signed)
+		if err != nil {
+			t.Fatalf("failed to compute digest: %s", err)
+		}
+		if !bytes.Equal(signedData.ContentInfo.Content.MessageDigest.Digest, expectedDigest) {
+			t.Errorf("expected image digest %x but got %x", expectedDigest, signedData.ContentInfo.Content.MessageDigest.Digest)
+		}
+
+		certificates, err := x509.ParseCertificates(signedData.Certificates.Bytes)
+		if err != nil {
+			t.Fatalf("failed to decode certificates: %s", err)
+		}
+		if len(certificates) != 2 || !certificates[0].Equal(certificate) || !certificates[1].Equal(ca) {
+			t.Fatalf("expected signing certificate and CA to be embedded")
+		}
+
+		roots := x509.NewCertPool()
+		roots.AddCert(ca)
+		if _, err := certificates[0].Verify(x509.VerifyOptions{Roots: roots, KeyUsages: []x509.ExtKeyUsage{x509.ExtKeyUsageCodeSigning}}); err != nil {
+			t.Errorf("failed to verify certificate chain: %s", err)
+		}
+
+		if len(signedData.SignerInfos) != 1 {
+			t.Fatalf("expected a single signer but got %d", len(signedData.SignerInfos))
+		}
+		signerInfo := signedData.SignerInfos[0]
+
+		// authenticated attributes are signed using their SET encoding
+		encodedAttributes := append([]byte{}, signerInfo.AuthenticatedAttributes.FullBytes...)
+		encodedAttributes[0] = 0x31
+
+		var attributes []testAttribute
+		if _, err := asn1.UnmarshalWithParams(encodedAttributes, &attributes, "set"); err != nil {
+			t.Fatalf("failed to decode authenticated attributes: %s", err)
+		}
+
+		indirectData := extractIndirectData(t, content.Content.Bytes)
+		indirectDataDigest := sha256.Sum256(indirectData.Bytes)
+
+		foundDigest := false
+		for _, attribute := range attributes {
+			if !attribute.Type.Equal(oidMessageDigest) {
+				continue
+			}
+
+			var digest []byte
+			if _, err := asn1.Unmarshal(attribute.Values.Bytes, &digest); err != nil {
+				t.Fatalf("failed to decode message digest: %s", err)
+			}
+			if !bytes.Equal(digest, indirectDataDigest[:]) {
+				t.Errorf("expected message digest %x but got %x", indirectDataDigest, digest)
+			}
+			foundDigest = true
+		}
+		if !foundDigest {
+			t.Errorf("expected message digest attribute")
+		}
+
+		attributesDigest := sha256.Sum256(encodedAttributes)
+		switch public := certificate.PublicKey.(type) {
+		case *rsa.PublicKey:
+			if err := rsa.VerifyPKCS1v15(public, crypto.SHA256, attributesDigest[:], signerInfo.EncryptedDigest); err != nil {
+				t.Errorf("failed to verify RSA signature: %s", err)
+			}
+		case *ecdsa.PublicKey:
+			if !ecdsa.VerifyASN1(public, attributesDigest[:], signerInfo.EncryptedDigest) {
+				t.Errorf("failed to verify ECDSA signature")
+			}
+		}
+	}
+}
+
+// extracts the indirect data structure from a given encoded SignedData structure
+func extractIndirectData(t *testing.T, signedData []byte) asn1.RawValue {
+	var outer asn1.RawValue
+	if _, err := asn1.Unmarshal(signedData, &outer); err != nil {
+		t.Fatalf("failed to decode signed data: %s", err)
+	}
+
+	// skip version and digest algorithms
+	rest := outer.Bytes
+	for i := 0; i < 2; i++ {
+		var skipped asn1.RawValue
+		var err error
+		if rest, err = asn1.Unmarshal(rest, &skipped); err != nil {
+			t.Fatalf("failed to decode signed data: %s", err)
+		}
+	}
+
+	var contentInfo testContentInfo
+	if _, err := asn1.Unmarshal(rest, &contentInfo); err != nil {
+		t.Fatalf("failed to decode content info: %s", err)
+	}
+
+	var indirectData asn1.RawValue
+	if _, err := asn1.Unmarshal(contentInfo.Content.Bytes, &indirectData); err != nil {
+		t.Fatalf("failed to decode indirect data: %s", err)
+	}
+
+	return indirectData
+}
+
+func TestReadPKCS12Malformed(t *testing.T) {
+	if _, err := ReadPKCS12([]byte("not a bundle"), ""); err == nil || errors.Is(err, ErrUnsupportedKey) {
+		t.Errorf("expected malformed bundle to be rejected but got %v", err)
+	}
+}
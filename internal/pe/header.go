@@ -0,0 +1,97 @@
+/*
+ * Copyright 2021 Johannes Donath <johannesd@torchmind.com>
+ * and other copyright owners as documented in the project's IP log.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package pe
+
+import (
+	"fmt"
+)
+
+// subsystems which may be selected via the optional header
+const (
+	SubsystemWindowsGUI = 2
+	SubsystemWindowsCUI = 3
+)
+
+// identifies the offsets of the respective fields within the optional header (shared between PE32
+// and PE32+ images)
+const (
+	checksumOffset  = 64
+	subsystemOffset = 68
+)
+
+// Subsystem retrieves the subsystem for which the image has been built.
+func (img *Image) Subsystem() uint16 {
+	return img.u16(img.optionalOffset + subsystemOffset)
+}
+
+// SetSubsystem replaces the subsystem for which the image has been built.
+//
+// The image checksum is not updated automatically. Callers are expected to invoke UpdateChecksum
+// once all modifications have been applied.
+func (img *Image) SetSubsystem(subsystem uint16) error {
+	if subsystem != SubsystemWindowsGUI && subsystem != SubsystemWindowsCUI {
+		return fmt.Errorf("%w: unsupported subsystem %d", ErrMalformed, subsystem)
+	}
+
+	img.putU16(img.optionalOffset+subsystemOffset, subsystem)
+	return nil
+}
+
+// Checksum retrieves the checksum stored within the optional header.
+func (img *Image) Checksum() uint32 {
+	return img.u32(img.optionalOffset + checksumOffset)
+}
+
+// ComputeChecksum computes the checksum of the image as expected by the Windows loader.
+//
+// The checksum consists of the 16-bit one's complement sum of the image (excluding the checksum
+// field itself) to which the image length is added.
+func (img *Image) ComputeChecksum() uint32 {
+	field := img.optionalOffset + checksumOffset
+
+	var sum uint64
+	for i := 0; i < len(img.data); i += 2 {
+		if i >= field && i < field+4 {
+			continue
+		}
+
+		word := uint64(img.data[i])
+		if i+1 < len(img.data) {
+			word |= uint64(img.data[i+1]) << 8
+		}
+
+		sum += word
+		sum = (sum & 0xFFFF) + (sum >> 16)
+	}
+	sum = (sum & 0xFFFF) + (sum >> 16)
+
+	return uint32(sum) + uint32(len(img.data))
+}
+
+// UpdateChecksum replaces the checksum stored within the optional header with the checksum of the
+// current image contents.
+func (img *Image) UpdateChecksum() {
+	img.putU32(img.optionalOffset+checksumOffset, img.ComputeChecksum())
+}
+
+// ClearChecksum removes the checksum from the optional header.
+//
+// Images without a checksum are accepted by the Windows loader with the exception of drivers and
+// critical system libraries.
+func (img *Image) ClearChecksum() {
+	img.putU32(img.optionalOffset+checksumOffset, 0)
+}
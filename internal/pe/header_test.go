@@ -0,0 +1,99 @@
+/*
+ * Copyright 2021 Johannes Donath <johannesd@torchmind.com>
+ * and other copyright owners as documented in the project's IP log.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package pe
+
+import (
+	"bytes"
+	"debug/pe"
+	"encoding/binary"
+	"errors"
+	"testing"
+)
+
+// computes the checksum of a given image using 32-bit words as an independent reference for the
+// 16-bit implementation
+func referenceChecksum(data []byte, field int) uint32 {
+	padded := append(append([]byte{}, data...), make([]byte, (4-len(data)%4)%4)...)
+
+	var sum uint64
+	for i := 0; i < len(padded); i += 4 {
+		if i == field {
+			continue
+		}
+
+		sum = (sum & 0xFFFFFFFF) + uint64(binary.LittleEndian.Uint32(padded[i:])) + (sum >> 32)
+		if sum > 1<<32 {
+			sum = (sum & 0xFFFFFFFF) + (sum >> 32)
+		}
+	}
+
+	sum = (sum & 0xFFFF) + (sum >> 16)
+	sum = sum + (sum >> 16)
+	sum &= 0xFFFF
+
+	return uint32(sum) + uint32(len(data))
+}
+
+func TestSetSubsystem(t *testing.T) {
+	img, err := Parse(testImage())
+	if err != nil {
+		t.Fatalf("failed to parse image: %s", err)
+	}
+	if err := img.SetResources([]*Resource{{Type: TypeManifest, ID: 1, Data: []byte("<assembly/>")}}); err != nil {
+		t.Fatalf("failed to set resources: %s", err)
+	}
+
+	if err := img.SetSubsystem(SubsystemWindowsGUI); err != nil {
+		t.Fatalf("failed to set subsystem: %s", err)
+	}
+	img.UpdateChecksum()
+
+	f, err := pe.NewFile(bytes.NewReader(img.Bytes()))
+	if err != nil {
+		t.Fatalf("failed to parse modified image: %s", err)
+	}
+
+	header := f.OptionalHeader.(*pe.OptionalHeader64)
+	if header.Subsystem != pe.IMAGE_SUBSYSTEM_WINDOWS_GUI {
+		t.Errorf("expected GUI subsystem but got %d", header.Subsystem)
+	}
+	if header.CheckSum == 0 || header.CheckSum != img.ComputeChecksum() {
+		t.Errorf("expected checksum 0x%X but got 0x%X", img.ComputeChecksum(), header.CheckSum)
+	}
+	if expected := referenceChecksum(img.Bytes(), img.optionalOffset+checksumOffset); header.CheckSum != expected {
+		t.Errorf("expected reference checksum 0x%X but got 0x%X", expected, header.CheckSum)
+	}
+
+	if err := img.SetSubsystem(SubsystemWindowsCUI); err != nil {
+		t.Fatalf("failed to set subsystem: %s", err)
+	}
+	if img.Subsystem() != SubsystemWindowsCUI {
+		t.Errorf("expected console subsystem but got %d", img.Subsystem())
+	}
+	if img.ComputeChecksum() == img.Checksum() {
+		t.Errorf("expected subsystem change to invalidate checksum")
+	}
+
+	if err := img.SetSubsystem(1); !errors.Is(err, ErrMalformed) {
+		t.Errorf("expected native subsystem to be rejected but got %v", err)
+	}
+
+	img.ClearChecksum()
+	if img.Checksum() != 0 {
+		t.Errorf("expected checksum to be cleared but got 0x%X", img.Checksum())
+	}
+}
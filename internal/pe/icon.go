@@ -0,0 +1,73 @@
+/*
+ * Copyright 2021 Johannes Donath <johannesd@torchmind.com>
+ * and other copyright owners as documented in the project's IP log.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package pe
+
+import (
+	"bytes"
+	"encoding/binary"
+	"github.com/dotstart/canoe/internal/icon"
+)
+
+// identifies the resource identifier of the application icon group (Windows Explorer selects the
+// group with the lowest identifier)
+const iconGroupID = 1
+
+// IconResources converts a given set of icon images into their respective resources.
+//
+// Each image is stored as a separate icon resource which is referenced by a single icon group.
+func IconResources(images []*icon.Image) []*Resource {
+	resources := make([]*Resource, 0, len(images)+1)
+
+	group := &bytes.Buffer{}
+	_ = binary.Write(group, binary.LittleEndian, []uint16{0, 1, uint16(len(images))})
+
+	for i, img := range images {
+		id := uint16(i + 1)
+		resources = append(resources, &Resource{
+			Type:     TypeIcon,
+			ID:       id,
+			Language: LanguageNeutral,
+			Data:     img.Data,
+		})
+
+		_ = binary.Write(group, binary.LittleEndian, struct {
+			Width      uint8
+			Height     uint8
+			ColorCount uint8
+			Reserved   uint8
+			Planes     uint16
+			BitCount   uint16
+			Size       uint32
+			ID         uint16
+		}{
+			Width:      uint8(img.Width),
+			Height:     uint8(img.Height),
+			ColorCount: img.ColorCount,
+			Planes:     img.Planes,
+			BitCount:   img.BitCount,
+			Size:       uint32(len(img.Data)),
+			ID:         id,
+		})
+	}
+
+	return append(resources, &Resource{
+		Type:     TypeGroupIcon,
+		ID:       iconGroupID,
+		Language: LanguageNeutral,
+		Data:     group.Bytes(),
+	})
+}
@@ -0,0 +1,113 @@
+/*
+ * Copyright 2021 Johannes Donath <johannesd@torchmind.com>
+ * and other copyright owners as documented in the project's IP log.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package pe
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// execution levels which may be requested via the application manifest
+const (
+	ExecutionLevelAsInvoker            = "asInvoker"
+	ExecutionLevelHighestAvailable     = "highestAvailable"
+	ExecutionLevelRequireAdministrator = "requireAdministrator"
+)
+
+// DPI awareness modes which may be declared via the application manifest
+const (
+	DpiAwarenessUnaware      = "unaware"
+	DpiAwarenessSystem       = "system"
+	DpiAwarenessPerMonitorV2 = "permonitorv2"
+)
+
+var ErrInvalidManifest = errors.New("invalid manifest option")
+
+// Manifest describes the application manifest of an image.
+type Manifest struct {
+	ExecutionLevel string
+	DpiAwareness   string
+	LongPathAware  bool
+}
+
+// identifies the operating system versions which the application declares compatibility with
+// (Windows Vista through Windows 10 and newer)
+var supportedOperatingSystems = []string{
+	"e2011457-1546-43c5-a5fe-008deee3d3f0",
+	"35138b9a-5d96-4fbd-8e2d-a2440225f93a",
+	"4a2f28e3-53b9-4441-ba9c-d69d4a4a6e38",
+	"1f676c76-80e1-4239-95bb-83d0f6d0da78",
+	"8e0f7a12-bfb3-4fe8-b9a5-48fd50a15a9a",
+}
+
+// Encode encodes the manifest as XML.
+func (m *Manifest) Encode() ([]byte, error) {
+	executionLevel := m.ExecutionLevel
+	if len(executionLevel) == 0 {
+		executionLevel = ExecutionLevelAsInvoker
+	}
+	if executionLevel != ExecutionLevelAsInvoker && executionLevel != ExecutionLevelHighestAvailable && executionLevel != ExecutionLevelRequireAdministrator {
+		return nil, fmt.Errorf("%w: unknown execution level %q", ErrInvalidManifest, executionLevel)
+	}
+
+	dpiAware := ""
+	dpiAwareness := ""
+	switch strings.ToLower(m.DpiAwareness) {
+	case "", DpiAwarenessPerMonitorV2:
+		dpiAware = "true/pm"
+		dpiAwareness = "PerMonitorV2, PerMonitor"
+	case DpiAwarenessSystem:
+		dpiAware = "true"
+		dpiAwareness = "System"
+	case DpiAwarenessUnaware:
+		dpiAware = "false"
+		dpiAwareness = "Unaware"
+	default:
+		return nil, fmt.Errorf("%w: unknown DPI awareness %q", ErrInvalidManifest, m.DpiAwareness)
+	}
+
+	b := &strings.Builder{}
+	b.WriteString(`<?xml version="1.0" encoding="UTF-8" standalone="yes"?>` + "\r\n")
+	b.WriteString(`<assembly xmlns="urn:schemas-microsoft-com:asm.v1" manifestVersion="1.0">` + "\r\n")
+	b.WriteString(`  <trustInfo xmlns="urn:schemas-microsoft-com:asm.v3">` + "\r\n")
+	b.WriteString(`    <security>` + "\r\n")
+	b.WriteString(`      <requestedPrivileges>` + "\r\n")
+	b.WriteString(`        <requestedExecutionLevel level="` + executionLevel + `" uiAccess="false"/>` + "\r\n")
+	b.WriteString(`      </requestedPrivileges>` + "\r\n")
+	b.WriteString(`    </security>` + "\r\n")
+	b.WriteString(`  </trustInfo>` + "\r\n")
+	b.WriteString(`  <compatibility xmlns="urn:schemas-microsoft-com:compatibility.v1">` + "\r\n")
+	b.WriteString(`    <application>` + "\r\n")
+	for _, id := range supportedOperatingSystems {
+		b.WriteString(`      <supportedOS Id="{` + id + `}"/>` + "\r\n")
+	}
+	b.WriteString(`    </application>` + "\r\n")
+	b.WriteString(`  </compatibility>` + "\r\n")
+	b.WriteString(`  <application xmlns="urn:schemas-microsoft-com:asm.v3">` + "\r\n")
+	b.WriteString(`    <windowsSettings>` + "\r\n")
+	b.WriteString(`      <dpiAware xmlns="http://schemas.microsoft.com/SMI/2005/WindowsSettings">` + dpiAware + `</dpiAware>` + "\r\n")
+	b.WriteString(`      <dpiAwareness xmlns="http://schemas.microsoft.com/SMI/2016/WindowsSettings">` + dpiAwareness + `</dpiAwareness>` + "\r\n")
+	if m.LongPathAware {
+		b.WriteString(`      <longPathAware xmlns="http://schemas.microsoft.com/SMI/2016/WindowsSettings">true</longPathAware>` + "\r\n")
+	}
+	b.WriteString(`    </windowsSettings>` + "\r\n")
+	b.WriteString(`  </application>` + "\r\n")
+	b.WriteString(`</assembly>` + "\r\n")
+
+	return []byte(b.String()), nil
+}
@@ -0,0 +1,283 @@
+/*
+ * Copyright 2021 Johannes Donath <johannesd@torchmind.com>
+ * and other copyright owners as documented in the project's IP log.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package pe
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+const (
+	optionalHeaderMagic32 = 0x10B
+	optionalHeaderMagic64 = 0x20B
+
+	sectionHeaderSize = 40
+
+	// identifies the indices of the respective data directories
+	resourceDirectory = 2
+	securityDirectory = 4
+
+	sectionCharacteristicsInitializedData = 0x00000040
+	sectionCharacteristicsRead            = 0x40000000
+)
+
+var ErrMalformed = errors.New("malformed PE image")
+var ErrSigned = errors.New("PE image is signed")
+var ErrInsufficientHeaderSpace = errors.New("insufficient space for additional section header")
+
+// Image provides access to the headers of a PE image.
+type Image struct {
+	data []byte
+
+	coffOffset     int
+	optionalOffset int
+	directories    int
+	directoryCount int
+	sectionOffset  int
+	sectionCount   int
+}
+
+// Section describes a single section within a PE image.
+type Section struct {
+	Name             string
+	VirtualSize      uint32
+	VirtualAddress   uint32
+	SizeOfRawData    uint32
+	PointerToRawData uint32
+	Characteristics  uint32
+}
+
+// Parse decodes the headers of a given PE image.
+//
+// The passed data is referenced by the resulting image and is modified in place when the image is
+// patched.
+func Parse(data []byte) (*Image, error) {
+	if len(data) < 0x40 || data[0] != 'M' || data[1] != 'Z' {
+		return nil, fmt.Errorf("%w: missing DOS header", ErrMalformed)
+	}
+
+	signatureOffset := int(binary.LittleEndian.Uint32(data[0x3C:]))
+	if signatureOffset < 0 || signatureOffset+24 > len(data) || !bytes.Equal(data[signatureOffset:signatureOffset+4], []byte("PE\x00\x00")) {
+		return nil, fmt.Errorf("%w: missing PE signature", ErrMalformed)
+	}
+
+	img := &Image{
+		data:           data,
+		coffOffset:     signatureOffset + 4,
+		optionalOffset: signatureOffset + 24,
+	}
+
+	optionalHeaderSize := int(binary.LittleEndian.Uint16(data[img.coffOffset+16:]))
+	img.sectionCount = int(binary.LittleEndian.Uint16(data[img.coffOffset+2:]))
+	img.sectionOffset = img.optionalOffset + optionalHeaderSize
+	if img.sectionOffset+img.sectionCount*sectionHeaderSize > len(data) {
+		return nil, fmt.Errorf("%w: truncated section table", ErrMalformed)
+	}
+
+	switch img.u16(img.optionalOffset) {
+	case optionalHeaderMagic32:
+		img.directoryCount = int(img.u32(img.optionalOffset + 92))
+		img.directories = img.optionalOffset + 96
+	case optionalHeaderMagic64:
+		img.directoryCount = int(img.u32(img.optionalOffset + 108))
+		img.directories = img.optionalOffset + 112
+	default:
+		return nil, fmt.Errorf("%w: unknown optional header magic", ErrMalformed)
+	}
+	if img.directories+img.directoryCount*8 > img.sectionOffset {
+		return nil, fmt.Errorf("%w: truncated data directories", ErrMalformed)
+	}
+
+	return img, nil
+}
+
+func (img *Image) u16(offset int) uint16 {
+	return binary.LittleEndian.Uint16(img.data[offset:])
+}
+
+func (img *Image) u32(offset int) uint32 {
+	return binary.LittleEndian.Uint32(img.data[offset:])
+}
+
+func (img *Image) putU16(offset int, value uint16) {
+	binary.LittleEndian.PutUint16(img.data[offset:], value)
+}
+
+func (img *Image) putU32(offset int, value uint32) {
+	binary.LittleEndian.PutUint32(img.data[offset:], value)
+}
+
+// Bytes retrieves the encoded image.
+func (img *Image) Bytes() []byte {
+	return img.data
+}
+
+// Sections retrieves the headers of all sections within the image.
+func (img *Image) Sections() []*Section {
+	sections := make([]*Section, img.sectionCount)
+	for i := range sections {
+		offset := img.sectionOffset + i*sectionHeaderSize
+		sections[i] = &Section{
+			Name:             string(bytes.TrimRight(img.data[offset:offset+8], "\x00")),
+			VirtualSize:      img.u32(offset + 8),
+			VirtualAddress:   img.u32(offset + 12),
+			SizeOfRawData:    img.u32(offset + 16),
+			PointerToRawData: img.u32(offset + 20),
+			Characteristics:  img.u32(offset + 36),
+		}
+	}
+
+	return sections
+}
+
+// Directory retrieves the virtual address and size of a given data directory.
+func (img *Image) Directory(index int) (uint32, uint32) {
+	if index >= img.directoryCount {
+		return 0, 0
+	}
+
+	offset := img.directories + index*8
+	return img.u32(offset), img.u32(offset + 4)
+}
+
+// retrieves the raw data of the section which contains a given virtual address along with the
+// virtual address at which this section is mapped
+//
+// returns nil if no section contains the address or if its data is truncated
+func (img *Image) sectionData(address uint32) ([]byte, uint32) {
+	for _, section := range img.Sections() {
+		if address < section.VirtualAddress || address-section.VirtualAddress >= section.SizeOfRawData {
+			continue
+		}
+
+		end := uint64(section.PointerToRawData) + uint64(section.SizeOfRawData)
+		if end > uint64(len(img.data)) {
+			return nil, 0
+		}
+
+		return img.data[section.PointerToRawData:end], section.VirtualAddress
+	}
+
+	return nil, 0
+}
+
+// sets the virtual address and size of a given data directory
+func (img *Image) setDirectory(index int, address uint32, size uint32) error {
+	if index >= img.directoryCount {
+		return fmt.Errorf("%w: data directory %d is not present", ErrMalformed, index)
+	}
+
+	offset := img.directories + index*8
+	img.putU32(offset, address)
+	img.putU32(offset+4, size)
+	return nil
+}
+
+func (img *Image) sectionAlignment() uint32 {
+	return img.u32(img.optionalOffset + 32)
+}
+
+func (img *Image) fileAlignment() uint32 {
+	return img.u32(img.optionalOffset + 36)
+}
+
+func (img *Image) sizeOfHeaders() uint32 {
+	return img.u32(img.optionalOffset + 60)
+}
+
+// AddSection appends a new section to the image.
+//
+// The contents of the section are produced by a given function which receives the virtual address
+// at which the section will be mapped (e.g. in order to encode absolute references). Returns the
+// header of the new section.
+func (img *Image) AddSection(name string, characteristics uint32, contents func(address uint32) ([]byte, error)) (*Section, error) {
+	if len(name) > 8 {
+		return nil, fmt.Errorf("illegal section name: %s", name)
+	}
+	if address, _ := img.Directory(securityDirectory); address != 0 {
+		return nil, ErrSigned
+	}
+
+	sectionAlignment := img.sectionAlignment()
+	fileAlignment := img.fileAlignment()
+	if sectionAlignment == 0 || fileAlignment == 0 {
+		return nil, fmt.Errorf("%w: invalid alignment", ErrMalformed)
+	}
+
+	headerOffset := img.sectionOffset + img.sectionCount*sectionHeaderSize
+	headerEnd := uint32(headerOffset + sectionHeaderSize)
+	virtualEnd := uint32(0)
+	for _, section := range img.Sections() {
+		if section.SizeOfRawData != 0 && section.PointerToRawData < headerEnd {
+			return nil, ErrInsufficientHeaderSpace
+		}
+
+		size := section.VirtualSize
+		if size == 0 {
+			size = section.SizeOfRawData
+		}
+		if end := section.VirtualAddress + size; end > virtualEnd {
+			virtualEnd = end
+		}
+	}
+	if headerEnd > img.sizeOfHeaders() {
+		return nil, ErrInsufficientHeaderSpace
+	}
+
+	section := &Section{
+		Name:             name,
+		VirtualAddress:   align(virtualEnd, sectionAlignment),
+		PointerToRawData: align(uint32(len(img.data)), fileAlignment),
+		Characteristics:  characteristics,
+	}
+
+	data, err := contents(section.VirtualAddress)
+	if err != nil {
+		return nil, err
+	}
+	section.VirtualSize = uint32(len(data))
+	section.SizeOfRawData = align(uint32(len(data)), fileAlignment)
+
+	image := make([]byte, section.PointerToRawData+section.SizeOfRawData)
+	copy(image, img.data)
+	copy(image[section.PointerToRawData:], data)
+	img.data = image
+
+	copy(img.data[headerOffset:headerOffset+sectionHeaderSize], make([]byte, sectionHeaderSize))
+	copy(img.data[headerOffset:headerOffset+8], append([]byte(name), make([]byte, 8-len(name))...))
+	img.putU32(headerOffset+8, section.VirtualSize)
+	img.putU32(headerOffset+12, section.VirtualAddress)
+	img.putU32(headerOffset+16, section.SizeOfRawData)
+	img.putU32(headerOffset+20, section.PointerToRawData)
+	img.putU32(headerOffset+36, section.Characteristics)
+
+	img.sectionCount++
+	img.putU16(img.coffOffset+2, uint16(img.sectionCount))
+	img.putU32(img.optionalOffset+56, align(section.VirtualAddress+section.VirtualSize, sectionAlignment))
+	if characteristics&sectionCharacteristicsInitializedData != 0 {
+		img.putU32(img.optionalOffset+8, img.u32(img.optionalOffset+8)+section.SizeOfRawData)
+	}
+
+	return section, nil
+}
+
+// aligns a given value to the next multiple of a given alignment
+func align(value uint32, alignment uint32) uint32 {
+	return (value + alignment - 1) / alignment * alignment
+}
@@ -0,0 +1,229 @@
+/*
+ * Copyright 2021 Johannes Donath <johannesd@torchmind.com>
+ * and other copyright owners as documented in the project's IP log.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package pe
+
+import (
+	"bytes"
+	"debug/pe"
+	"encoding/binary"
+	"errors"
+	"testing"
+)
+
+// assembles a minimal PE32+ image consisting of a single code section
+func testImage() []byte {
+	buf := &bytes.Buffer{}
+
+	dos := make([]byte, 0x40)
+	copy(dos, "MZ")
+	binary.LittleEndian.PutUint32(dos[0x3C:], 0x40)
+	buf.Write(dos)
+
+	buf.WriteString("PE\x00\x00")
+	_ = binary.Write(buf, binary.LittleEndian, pe.FileHeader{
+		Machine:              pe.IMAGE_FILE_MACHINE_AMD64,
+		NumberOfSections:     1,
+		SizeOfOptionalHeader: 240,
+		Characteristics:      pe.IMAGE_FILE_EXECUTABLE_IMAGE | pe.IMAGE_FILE_LARGE_ADDRESS_AWARE,
+	})
+	_ = binary.Write(buf, binary.LittleEndian, pe.OptionalHeader64{
+		Magic:                       optionalHeaderMagic64,
+		SizeOfCode:                  0x200,
+		AddressOfEntryPoint:         0x1000,
+		BaseOfCode:                  0x1000,
+		ImageBase:                   0x140000000,
+		SectionAlignment:            0x1000,
+		FileAlignment:               0x200,
+		MajorOperatingSystemVersion: 6,
+		MajorSubsystemVersion:       6,
+		SizeOfImage:                 0x2000,
+		SizeOfHeaders:               0x200,
+		Subsystem:                   pe.IMAGE_SUBSYSTEM_WINDOWS_CUI,
+		SizeOfStackReserve:          0x100000,
+		SizeOfStackCommit:           0x1000,
+		SizeOfHeapReserve:           0x100000,
+		SizeOfHeapCommit:            0x1000,
+		NumberOfRvaAndSizes:         16,
+	})
+	_ = binary.Write(buf, binary.LittleEndian, pe.SectionHeader32{
+		Name:             [8]uint8{'.', 't', 'e', 'x', 't'},
+		VirtualSize:      0x10,
+		VirtualAddress:   0x1000,
+		SizeOfRawData:    0x200,
+		PointerToRawData: 0x200,
+		Characteristics:  pe.IMAGE_SCN_CNT_CODE | pe.IMAGE_SCN_MEM_EXECUTE | pe.IMAGE_SCN_MEM_READ,
+	})
+
+	buf.Write(make([]byte, 0x200-buf.Len()))
+	code := make([]byte, 0x200)
+	code[0] = 0xC3
+	buf.Write(code)
+
+	return buf.Bytes()
+}
+
+// locates the data of a given resource within an image
+func findResource(t *testing.T, data []byte, typ uint16, id uint16) []byte {
+	f, err := pe.NewFile(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("failed to parse image: %s", err)
+	}
+
+	header := f.OptionalHeader.(*pe.OptionalHeader64)
+	directory := header.DataDirectory[resourceDirectory]
+
+	var section *pe.Section
+	for _, s := range f.Sections {
+		if s.VirtualAddress == directory.VirtualAddress {
+			section = s
+		}
+	}
+	if section == nil {
+		t.Fatalf("resource directory does not refer to a section")
+	}
+
+	contents, err := section.Data()
+	if err != nil {
+		t.Fatalf("failed to read resource section: %s", err)
+	}
+
+	// walks a single directory level in order to locate the entry with a given identifier
+	lookup := func(offset uint32, id uint16) (uint32, bool) {
+		count := binary.LittleEndian.Uint16(contents[offset+12:]) + binary.LittleEndian.Uint16(contents[offset+14:])
+		for i := uint32(0); i < uint32(count); i++ {
+			entry := offset + 16 + i*8
+			if binary.LittleEndian.Uint32(contents[entry:]) == uint32(id) {
+				return binary.LittleEndian.Uint32(contents[entry+4:]), true
+			}
+		}
+
+		return 0, false
+	}
+
+	typeOffset, ok := lookup(0, typ)
+	if !ok || typeOffset&subdirectoryFlag == 0 {
+		return nil
+	}
+	idOffset, ok := lookup(typeOffset&^subdirectoryFlag, id)
+	if !ok || idOffset&subdirectoryFlag == 0 {
+		return nil
+	}
+
+	languages := idOffset &^ subdirectoryFlag
+	entry := binary.LittleEndian.Uint32(contents[languages+16+4:])
+	address := binary.LittleEndian.Uint32(contents[entry:])
+	size := binary.LittleEndian.Uint32(contents[entry+4:])
+
+	offset := address - section.VirtualAddress
+	return contents[offset : offset+size]
+}
+
+func TestSetResources(t *testing.T) {
+	img, err := Parse(testImage())
+	if err != nil {
+		t.Fatalf("failed to parse image: %s", err)
+	}
+
+	manifest := []byte("<assembly/>")
+	version := (&VersionInfo{FileVersion: [4]uint16{1, 2, 3, 0}}).Encode()
+	if err := img.SetResources([]*Resource{
+		{Type: TypeManifest, ID: 1, Language: LanguageEnglishUS, Data: manifest},
+		{Type: TypeVersion, ID: 1, Language: LanguageEnglishUS, Data: version},
+	}); err != nil {
+		t.Fatalf("failed to set resources: %s", err)
+	}
+
+	f, err := pe.NewFile(bytes.NewReader(img.Bytes()))
+	if err != nil {
+		t.Fatalf("failed to parse modified image: %s", err)
+	}
+	if len(f.Sections) != 2 || f.Sections[1].Name != ".rsrc" {
+		t.Fatalf("expected resource section to be appended")
+	}
+	if f.Sections[1].VirtualAddress != 0x2000 || f.Sections[1].Offset%0x200 != 0 {
+		t.Errorf("expected aligned resource section but got address 0x%X and offset 0x%X", f.Sections[1].VirtualAddress, f.Sections[1].Offset)
+	}
+	if size := f.OptionalHeader.(*pe.OptionalHeader64).SizeOfImage; size != 0x3000 {
+		t.Errorf("expected image size 0x3000 but got 0x%X", size)
+	}
+
+	if data := findResource(t, img.Bytes(), TypeManifest, 1); !bytes.Equal(data, manifest) {
+		t.Errorf("expected manifest %q but got %q", manifest, data)
+	}
+	if data := findResource(t, img.Bytes(), TypeVersion, 1); !bytes.Equal(data, version) {
+		t.Errorf("expected version information to be retained")
+	}
+}
+
+func TestResources(t *testing.T) {
+	img, err := Parse(testImage())
+	if err != nil {
+		t.Fatalf("failed to parse image: %s", err)
+	}
+
+	if resources, err := img.Resources(); err != nil || len(resources) != 0 {
+		t.Fatalf("expected no resources but got %v (%v)", resources, err)
+	}
+
+	expected := []*Resource{
+		{Type: TypeIcon, ID: 1, Language: LanguageNeutral, Data: []byte("icon")},
+		{Type: TypeIcon, ID: 2, Language: LanguageNeutral, Data: []byte("larger icon")},
+		{Type: TypeGroupIcon, ID: 1, Language: LanguageNeutral, Data: []byte("group")},
+		{Type: TypeManifest, ID: 1, Language: LanguageEnglishUS, Data: []byte("<assembly/>")},
+	}
+	if err := img.SetResources(expected); err != nil {
+		t.Fatalf("failed to set resources: %s", err)
+	}
+
+	img, err = Parse(img.Bytes())
+	if err != nil {
+		t.Fatalf("failed to parse modified image: %s", err)
+	}
+	resources, err := img.Resources()
+	if err != nil {
+		t.Fatalf("failed to read resources: %s", err)
+	}
+	if len(resources) != len(expected) {
+		t.Fatalf("expected %d resources but got %d", len(expected), len(resources))
+	}
+	for i, resource := range resources {
+		if resource.Type != expected[i].Type || resource.ID != expected[i].ID || resource.Language != expected[i].Language || !bytes.Equal(resource.Data, expected[i].Data) {
+			t.Errorf("expected resource %v but got %v", expected[i], resource)
+		}
+	}
+}
+
+func TestSetResourcesSigned(t *testing.T) {
+	data := testImage()
+	img, err := Parse(data)
+	if err != nil {
+		t.Fatalf("failed to parse image: %s", err)
+	}
+	if err := img.setDirectory(securityDirectory, 0x400, 0x10); err != nil {
+		t.Fatalf("failed to update security directory: %s", err)
+	}
+
+	if err := img.SetResources(nil); !errors.Is(err, ErrSigned) {
+		t.Errorf("expected signed image to be rejected but got %v", err)
+	}
+}
+
+func TestParseMalformed(t *testing.T) {
+	if _, err := Parse([]byte("#!/bin/sh\n")); !errors.Is(err, ErrMalformed) {
+		t.Errorf("expected malformed image but got %v", err)
+	}
+}
@@ -0,0 +1,298 @@
+/*
+ * Copyright 2021 Johannes Donath <johannesd@torchmind.com>
+ * and other copyright owners as documented in the project's IP log.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package pe
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"sort"
+)
+
+// resource types as defined by the Windows SDK
+const (
+	TypeIcon      = 3
+	TypeGroupIcon = 14
+	TypeVersion   = 16
+	TypeManifest  = 24
+)
+
+// LanguageNeutral identifies resources which apply to all languages.
+const LanguageNeutral = 0
+
+// LanguageEnglishUS identifies resources which apply to the en-US locale.
+const LanguageEnglishUS = 0x0409
+
+// identifies directory entries which refer to a subdirectory rather than a data entry
+const subdirectoryFlag = 0x80000000
+
+// identifies directory entries which are identified by name rather than by numeric identifier
+const nameFlag = 0x80000000
+
+var ErrUnsupportedResource = errors.New("unsupported resource")
+
+// Resource describes a single resource within a PE image.
+type Resource struct {
+	Type     uint16
+	ID       uint16
+	Language uint16
+	Data     []byte
+}
+
+// SetResources replaces the resources of a given image.
+//
+// The resources are written to a newly appended section as the existing resource section cannot be
+// relocated safely (e.g. packers may store additional data within it). The resource directory is
+// updated to refer to the new section.
+func (img *Image) SetResources(resources []*Resource) error {
+	name := ".rsrc"
+	for _, section := range img.Sections() {
+		if section.Name == name {
+			name = ".rsrc2"
+		}
+	}
+
+	section, err := img.AddSection(name, sectionCharacteristicsInitializedData|sectionCharacteristicsRead, func(address uint32) ([]byte, error) {
+		return EncodeResources(resources, address), nil
+	})
+	if err != nil {
+		return err
+	}
+
+	return img.setDirectory(resourceDirectory, section.VirtualAddress, section.VirtualSize)
+}
+
+// Resources retrieves the resources of a given image.
+//
+// Only resources which are identified by numeric type, identifier and language are supported as
+// named resources cannot be encoded by this package. Returns an empty slice if the image does not
+// contain any resources.
+func (img *Image) Resources() ([]*Resource, error) {
+	address, size := img.Directory(resourceDirectory)
+	if address == 0 || size == 0 {
+		return nil, nil
+	}
+
+	contents, base := img.sectionData(address)
+	if contents == nil {
+		return nil, fmt.Errorf("%w: resource directory does not refer to a section", ErrMalformed)
+	}
+
+	// retrieves the entries of the directory at a given offset relative to the start of the section
+	entries := func(offset uint32) ([][2]uint32, error) {
+		start := uint64(offset) + uint64(address-base)
+		if start+16 > uint64(len(contents)) {
+			return nil, fmt.Errorf("%w: truncated resource directory", ErrMalformed)
+		}
+
+		count := uint64(binary.LittleEndian.Uint16(contents[start+12:])) + uint64(binary.LittleEndian.Uint16(contents[start+14:]))
+		if start+16+count*8 > uint64(len(contents)) {
+			return nil, fmt.Errorf("%w: truncated resource directory", ErrMalformed)
+		}
+
+		result := make([][2]uint32, count)
+		for i := range result {
+			entry := start + 16 + uint64(i)*8
+			result[i] = [2]uint32{binary.LittleEndian.Uint32(contents[entry:]), binary.LittleEndian.Uint32(contents[entry+4:])}
+			if result[i][0]&nameFlag != 0 {
+				return nil, fmt.Errorf("%w: named resources are not supported", ErrUnsupportedResource)
+			}
+		}
+
+		return result, nil
+	}
+
+	// retrieves the subdirectory to which a given directory entry refers
+	subdirectory := func(entry [2]uint32) ([][2]uint32, error) {
+		if entry[1]&subdirectoryFlag == 0 {
+			return nil, fmt.Errorf("%w: unexpected data entry within resource directory", ErrMalformed)
+		}
+
+		return entries(entry[1] &^ subdirectoryFlag)
+	}
+
+	types, err := entries(0)
+	if err != nil {
+		return nil, err
+	}
+
+	var resources []*Resource
+	for _, typeEntry := range types {
+		ids, err := subdirectory(typeEntry)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, idEntry := range ids {
+			languages, err := subdirectory(idEntry)
+			if err != nil {
+				return nil, err
+			}
+
+			for _, languageEntry := range languages {
+				if languageEntry[1]&subdirectoryFlag != 0 {
+					return nil, fmt.Errorf("%w: unexpected subdirectory within resource directory", ErrMalformed)
+				}
+
+				entry := uint64(languageEntry[1]) + uint64(address-base)
+				if entry+16 > uint64(len(contents)) {
+					return nil, fmt.Errorf("%w: truncated resource data entry", ErrMalformed)
+				}
+
+				dataAddress := binary.LittleEndian.Uint32(contents[entry:])
+				dataSize := binary.LittleEndian.Uint32(contents[entry+4:])
+				data, dataBase := img.sectionData(dataAddress)
+				if data == nil || uint64(dataAddress-dataBase)+uint64(dataSize) > uint64(len(data)) {
+					return nil, fmt.Errorf("%w: truncated resource data", ErrMalformed)
+				}
+
+				resources = append(resources, &Resource{
+					Type:     uint16(typeEntry[0]),
+					ID:       uint16(idEntry[0]),
+					Language: uint16(languageEntry[0]),
+					Data:     append([]byte{}, data[dataAddress-dataBase:dataAddress-dataBase+dataSize]...),
+				})
+			}
+		}
+	}
+
+	return resources, nil
+}
+
+// EncodeResources encodes a given set of resources as a resource section which is mapped at a
+// given virtual address.
+//
+// Resources are organized in a three level tree (type, identifier and language) followed by the
+// data entries and the actual resource data.
+func EncodeResources(resources []*Resource, address uint32) []byte {
+	resources = append([]*Resource{}, resources...)
+	sort.Slice(resources, func(i, j int) bool {
+		a, b := resources[i], resources[j]
+		if a.Type != b.Type {
+			return a.Type < b.Type
+		}
+		if a.ID != b.ID {
+			return a.ID < b.ID
+		}
+		return a.Language < b.Language
+	})
+
+	type node struct {
+		id       uint16
+		children []*node
+		resource *Resource
+	}
+
+	root := &node{}
+	for _, resource := range resources {
+		var typeNode, idNode *node
+		if len(root.children) != 0 && root.children[len(root.children)-1].id == resource.Type {
+			typeNode = root.children[len(root.children)-1]
+		} else {
+			typeNode = &node{id: resource.Type}
+			root.children = append(root.children, typeNode)
+		}
+
+		if len(typeNode.children) != 0 && typeNode.children[len(typeNode.children)-1].id == resource.ID {
+			idNode = typeNode.children[len(typeNode.children)-1]
+		} else {
+			idNode = &node{id: resource.ID}
+			typeNode.children = append(typeNode.children, idNode)
+		}
+
+		idNode.children = append(idNode.children, &node{id: resource.Language, resource: resource})
+	}
+
+	// compute the offsets of all directories in breadth first order followed by the data entries
+	directorySize := func(n *node) uint32 {
+		return 16 + 8*uint32(len(n.children))
+	}
+
+	offsets := make(map[*node]uint32)
+	offset := directorySize(root)
+	offsets[root] = 0
+	for _, typeNode := range root.children {
+		offsets[typeNode] = offset
+		offset += directorySize(typeNode)
+	}
+	for _, typeNode := range root.children {
+		for _, idNode := range typeNode.children {
+			offsets[idNode] = offset
+			offset += directorySize(idNode)
+		}
+	}
+
+	dataEntryOffset := offset
+	dataOffset := alignResource(dataEntryOffset + 16*uint32(len(resources)))
+
+	buf := &bytes.Buffer{}
+	writeDirectory := func(n *node, childOffset func(child *node) uint32) {
+		_ = binary.Write(buf, binary.LittleEndian, struct {
+			Characteristics uint32
+			TimeDateStamp   uint32
+			MajorVersion    uint16
+			MinorVersion    uint16
+			NamedEntries    uint16
+			IDEntries       uint16
+		}{IDEntries: uint16(len(n.children))})
+
+		for _, child := range n.children {
+			_ = binary.Write(buf, binary.LittleEndian, []uint32{uint32(child.id), childOffset(child)})
+		}
+	}
+
+	subdirectory := func(child *node) uint32 {
+		return offsets[child] | subdirectoryFlag
+	}
+
+	writeDirectory(root, subdirectory)
+	for _, typeNode := range root.children {
+		writeDirectory(typeNode, subdirectory)
+	}
+
+	entryOffset := dataEntryOffset
+	leaves := make([]*Resource, 0, len(resources))
+	for _, typeNode := range root.children {
+		for _, idNode := range typeNode.children {
+			writeDirectory(idNode, func(child *node) uint32 {
+				current := entryOffset
+				entryOffset += 16
+				leaves = append(leaves, child.resource)
+				return current
+			})
+		}
+	}
+
+	currentDataOffset := dataOffset
+	for _, resource := range leaves {
+		_ = binary.Write(buf, binary.LittleEndian, []uint32{address + currentDataOffset, uint32(len(resource.Data)), 0, 0})
+		currentDataOffset = alignResource(currentDataOffset + uint32(len(resource.Data)))
+	}
+
+	for _, resource := range leaves {
+		buf.Write(make([]byte, int(alignResource(uint32(buf.Len())))-buf.Len()))
+		buf.Write(resource.Data)
+	}
+
+	return buf.Bytes()
+}
+
+// aligns resource data to 8 byte boundaries
+func alignResource(offset uint32) uint32 {
+	return align(offset, 8)
+}
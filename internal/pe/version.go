@@ -0,0 +1,164 @@
+/*
+ * Copyright 2021 Johannes Donath <johannesd@torchmind.com>
+ * and other copyright owners as documented in the project's IP log.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package pe
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+)
+
+const (
+	fixedFileInfoSignature     = 0xFEEF04BD
+	fixedFileInfoStructVersion = 0x00010000
+	fixedFileInfoFlagsMask     = 0x3F
+	fileOperatingSystemWindows = 0x00040004
+	fileTypeApplication        = 0x1
+
+	// identifies the Unicode code page in which version strings are encoded
+	codePageUnicode = 1200
+
+	valueTypeBinary = 0
+	valueTypeText   = 1
+)
+
+// VersionString describes a single string within the version information of an image.
+type VersionString struct {
+	Key   string
+	Value string
+}
+
+// VersionInfo describes the version information resource of an image.
+type VersionInfo struct {
+	FileVersion    [4]uint16
+	ProductVersion [4]uint16
+
+	// Strings lists the human readable version information (e.g. CompanyName or FileDescription)
+	Strings []VersionString
+}
+
+// ParseVersion converts a given version string into its numeric representation.
+//
+// Only the leading numeric components are considered (e.g. "1.2.3-beta" evaluates to 1.2.3.0).
+// Components which exceed the permitted range are clamped.
+func ParseVersion(version string) [4]uint16 {
+	var result [4]uint16
+
+	components := strings.SplitN(version, ".", 4)
+	for i, component := range components {
+		end := strings.IndexFunc(component, func(r rune) bool {
+			return r < '0' || r > '9'
+		})
+		if end != -1 {
+			component = component[:end]
+		}
+
+		value, err := strconv.ParseUint(component, 10, 64)
+		if err != nil {
+			break
+		}
+		if value > 0xFFFF {
+			value = 0xFFFF
+		}
+
+		result[i] = uint16(value)
+		if end != -1 {
+			break
+		}
+	}
+
+	return result
+}
+
+// Encode encodes the version information as a VS_VERSIONINFO resource.
+func (v *VersionInfo) Encode() []byte {
+	fixed := &bytes.Buffer{}
+	_ = binary.Write(fixed, binary.LittleEndian, []uint32{
+		fixedFileInfoSignature,
+		fixedFileInfoStructVersion,
+		uint32(v.FileVersion[0])<<16 | uint32(v.FileVersion[1]),
+		uint32(v.FileVersion[2])<<16 | uint32(v.FileVersion[3]),
+		uint32(v.ProductVersion[0])<<16 | uint32(v.ProductVersion[1]),
+		uint32(v.ProductVersion[2])<<16 | uint32(v.ProductVersion[3]),
+		fixedFileInfoFlagsMask,
+		0,
+		fileOperatingSystemWindows,
+		fileTypeApplication,
+		0,
+		0,
+		0,
+	})
+
+	strs := make([][]byte, 0, len(v.Strings))
+	for _, str := range v.Strings {
+		value := encodeUTF16(str.Value)
+		strs = append(strs, encodeVersionBlock(str.Key, value, uint16(len(value)/2), valueTypeText))
+	}
+
+	table := encodeVersionBlock(fmt.Sprintf("%04X%04X", LanguageEnglishUS, codePageUnicode), nil, 0, valueTypeText, strs...)
+	stringFileInfo := encodeVersionBlock("StringFileInfo", nil, 0, valueTypeText, table)
+
+	translation := &bytes.Buffer{}
+	_ = binary.Write(translation, binary.LittleEndian, []uint16{LanguageEnglishUS, codePageUnicode})
+	varFileInfo := encodeVersionBlock("VarFileInfo", nil, 0, valueTypeText,
+		encodeVersionBlock("Translation", translation.Bytes(), uint16(translation.Len()), valueTypeBinary))
+
+	return encodeVersionBlock("VS_VERSION_INFO", fixed.Bytes(), uint16(fixed.Len()), valueTypeBinary, stringFileInfo, varFileInfo)
+}
+
+// encodes a single block within a version information resource
+//
+// blocks consist of their length, value length, type and key followed by their value and children
+// which are each aligned to 32-bit boundaries
+func encodeVersionBlock(key string, value []byte, valueLength uint16, valueType uint16, children ...[]byte) []byte {
+	buf := &bytes.Buffer{}
+	_ = binary.Write(buf, binary.LittleEndian, []uint16{0, valueLength, valueType})
+	buf.Write(encodeUTF16(key))
+
+	if len(value) != 0 {
+		padVersionBlock(buf)
+		buf.Write(value)
+	}
+
+	for _, child := range children {
+		padVersionBlock(buf)
+		buf.Write(child)
+	}
+
+	data := buf.Bytes()
+	binary.LittleEndian.PutUint16(data, uint16(len(data)))
+	return data
+}
+
+// pads a given buffer to the next 32-bit boundary
+func padVersionBlock(buf *bytes.Buffer) {
+	for buf.Len()%4 != 0 {
+		buf.WriteByte(0)
+	}
+}
+
+// encodes a given string as null terminated UTF-16
+func encodeUTF16(value string) []byte {
+	encoded := utf16.Encode([]rune(value + "\x00"))
+
+	buf := &bytes.Buffer{}
+	_ = binary.Write(buf, binary.LittleEndian, encoded)
+	return buf.Bytes()
+}
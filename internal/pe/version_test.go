@@ -0,0 +1,82 @@
+/*
+ * Copyright 2021 Johannes Donath <johannesd@torchmind.com>
+ * and other copyright owners as documented in the project's IP log.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package pe
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func TestParseVersion(t *testing.T) {
+	tests := map[string][4]uint16{
+		"1.2.3":        {1, 2, 3, 0},
+		"1.2.3.4.5":    {1, 2, 3, 4},
+		"2.0.0-beta.1": {2, 0, 0, 0},
+		"10.70000":     {10, 0xFFFF, 0, 0},
+		"snapshot":     {0, 0, 0, 0},
+		"1.2.3.4":      {1, 2, 3, 4},
+		"3.1-SNAPSHOT": {3, 1, 0, 0},
+		"":             {0, 0, 0, 0},
+	}
+
+	for input, expected := range tests {
+		if actual := ParseVersion(input); actual != expected {
+			t.Errorf("expected %q to evaluate to %v but got %v", input, expected, actual)
+		}
+	}
+}
+
+func TestVersionInfoEncode(t *testing.T) {
+	encoded := (&VersionInfo{
+		FileVersion:    [4]uint16{1, 2, 3, 4},
+		ProductVersion: [4]uint16{1, 2, 0, 0},
+		Strings: []VersionString{
+			{Key: "ProductName", Value: "Foo"},
+		},
+	}).Encode()
+
+	if length := int(encoded[0]) | int(encoded[1])<<8; length != len(encoded) {
+		t.Errorf("expected block length %d but got %d", len(encoded), length)
+	}
+	if !bytes.Contains(encoded, encodeUTF16("VS_VERSION_INFO")) {
+		t.Errorf("expected version information key to be present")
+	}
+	if !bytes.Contains(encoded, encodeUTF16("ProductName")) || !bytes.Contains(encoded, encodeUTF16("Foo")) {
+		t.Errorf("expected string table to contain product name")
+	}
+}
+
+func TestManifestEncode(t *testing.T) {
+	encoded, err := (&Manifest{ExecutionLevel: ExecutionLevelRequireAdministrator, LongPathAware: true}).Encode()
+	if err != nil {
+		t.Fatalf("failed to encode manifest: %s", err)
+	}
+	if !bytes.Contains(encoded, []byte(`level="requireAdministrator"`)) {
+		t.Errorf("expected execution level to be declared")
+	}
+	if !bytes.Contains(encoded, []byte("<longPathAware")) {
+		t.Errorf("expected long path awareness to be declared")
+	}
+
+	if _, err := (&Manifest{ExecutionLevel: "root"}).Encode(); !errors.Is(err, ErrInvalidManifest) {
+		t.Errorf("expected invalid execution level to be rejected but got %v", err)
+	}
+	if _, err := (&Manifest{DpiAwareness: "sometimes"}).Encode(); !errors.Is(err, ErrInvalidManifest) {
+		t.Errorf("expected invalid DPI awareness to be rejected but got %v", err)
+	}
+}
@@ -0,0 +1,112 @@
+/*
+ * Copyright 2021 Johannes Donath <johannesd@torchmind.com>
+ * and other copyright owners as documented in the project's IP log.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package plist
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+const header = `<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE plist PUBLIC "-//Apple//DTD PLIST 1.0//EN" "http://www.apple.com/DTDs/PropertyList-1.0.dtd">
+<plist version="1.0">
+`
+
+var ErrUnsupportedValue = errors.New("unsupported property list value")
+
+// Dict represents a property list dictionary which retains the order of its keys.
+type Dict []Entry
+
+// Entry represents a single key within a dictionary.
+//
+// Values are expected to be strings, booleans, integers, dictionaries or slices thereof.
+type Entry struct {
+	Key   string
+	Value interface{}
+}
+
+// Encode encodes a given dictionary as an XML property list.
+func Encode(dict Dict) ([]byte, error) {
+	buf := &bytes.Buffer{}
+	buf.WriteString(header)
+	if err := encodeValue(buf, dict, 0); err != nil {
+		return nil, err
+	}
+	buf.WriteString("</plist>\n")
+
+	return buf.Bytes(), nil
+}
+
+// encodes a single value at a given indentation level
+func encodeValue(buf *bytes.Buffer, value interface{}, level int) error {
+	indent := strings.Repeat("\t", level)
+
+	switch v := value.(type) {
+	case string:
+		buf.WriteString(indent + "<string>")
+		_ = xml.EscapeText(buf, []byte(v))
+		buf.WriteString("</string>\n")
+	case bool:
+		buf.WriteString(indent + "<" + strconv.FormatBool(v) + "/>\n")
+	case int:
+		buf.WriteString(indent + "<integer>" + strconv.Itoa(v) + "</integer>\n")
+	case uint64:
+		buf.WriteString(indent + "<integer>" + strconv.FormatUint(v, 10) + "</integer>\n")
+	case Dict:
+		buf.WriteString(indent + "<dict>\n")
+		for _, entry := range v {
+			buf.WriteString(indent + "\t<key>")
+			_ = xml.EscapeText(buf, []byte(entry.Key))
+			buf.WriteString("</key>\n")
+
+			if err := encodeValue(buf, entry.Value, level+1); err != nil {
+				return fmt.Errorf("%s: %w", entry.Key, err)
+			}
+		}
+		buf.WriteString(indent + "</dict>\n")
+	case []string:
+		elements := make([]interface{}, len(v))
+		for i, element := range v {
+			elements[i] = element
+		}
+
+		return encodeValue(buf, elements, level)
+	case []Dict:
+		elements := make([]interface{}, len(v))
+		for i, element := range v {
+			elements[i] = element
+		}
+
+		return encodeValue(buf, elements, level)
+	case []interface{}:
+		buf.WriteString(indent + "<array>\n")
+		for _, element := range v {
+			if err := encodeValue(buf, element, level+1); err != nil {
+				return err
+			}
+		}
+		buf.WriteString(indent + "</array>\n")
+	default:
+		return fmt.Errorf("%w: %T", ErrUnsupportedValue, value)
+	}
+
+	return nil
+}
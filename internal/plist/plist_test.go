@@ -0,0 +1,68 @@
+/*
+ * Copyright 2021 Johannes Donath <johannesd@torchmind.com>
+ * and other copyright owners as documented in the project's IP log.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package plist
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestEncode(t *testing.T) {
+	encoded, err := Encode(Dict{
+		{"CFBundleName", "Foo & Bar"},
+		{"NSHighResolutionCapable", true},
+		{"Count", 42},
+		{"CFBundleDocumentTypes", []Dict{
+			{
+				{"CFBundleTypeExtensions", []string{"foo", "bar"}},
+			},
+		}},
+	})
+	if err != nil {
+		t.Fatalf("failed to encode property list: %s", err)
+	}
+
+	expected := header + `<dict>
+	<key>CFBundleName</key>
+	<string>Foo &amp; Bar</string>
+	<key>NSHighResolutionCapable</key>
+	<true/>
+	<key>Count</key>
+	<integer>42</integer>
+	<key>CFBundleDocumentTypes</key>
+	<array>
+		<dict>
+			<key>CFBundleTypeExtensions</key>
+			<array>
+				<string>foo</string>
+				<string>bar</string>
+			</array>
+		</dict>
+	</array>
+</dict>
+</plist>
+`
+	if string(encoded) != expected {
+		t.Errorf("unexpected property list:\n%s", encoded)
+	}
+}
+
+func TestEncodeUnsupported(t *testing.T) {
+	if _, err := Encode(Dict{{"Value", 1.5}}); !errors.Is(err, ErrUnsupportedValue) {
+		t.Errorf("expected unsupported value but got %v", err)
+	}
+}
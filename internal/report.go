@@ -0,0 +1,194 @@
+/*
+ * Copyright 2021 Johannes Donath <johannesd@torchmind.com>
+ * and other copyright owners as documented in the project's IP log.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package internal
+
+import (
+	"errors"
+	"fmt"
+	"github.com/dotstart/canoe/internal/metadata"
+	"github.com/dotstart/canoe/internal/runtime"
+	"strconv"
+	"strings"
+)
+
+// ErrorCategory identifies a class of errors which may be encountered while launching an
+// application.
+type ErrorCategory int
+
+const (
+	ConfigurationError ErrorCategory = iota
+	RuntimeNotFoundError
+	RuntimeUnsupportedError
+	RuntimeInvalidError
+	LaunchError
+	IntegrityError
+)
+
+const defaultApplicationName = "This application"
+
+var defaultMessages = map[ErrorCategory]string{
+	ConfigurationError:      "Failed to load application configuration: {error}",
+	RuntimeNotFoundError:    "{name} requires Java {required} but no Java Runtime could be located on this system.",
+	RuntimeUnsupportedError: "{name} requires Java {required} but only incompatible versions were found on this system ({found}).",
+	RuntimeInvalidError:     "The Java Runtime installed on this system appears to be damaged: {error}",
+	LaunchError:             "Failed to launch {name}: {error}",
+	IntegrityError:          "{name} appears to be damaged, possibly due to an incomplete or corrupted download. Please download the application again.",
+}
+
+var errorTitles = map[ErrorCategory]string{
+	ConfigurationError:      "Application Error",
+	RuntimeNotFoundError:    "Runtime Error",
+	RuntimeUnsupportedError: "Runtime Error",
+	RuntimeInvalidError:     "Runtime Error",
+	LaunchError:             "Application Error",
+	IntegrityError:          "Application Error",
+}
+
+// ErrorReport encapsulates all information on an error which is to be presented to the user.
+type ErrorReport struct {
+	Category ErrorCategory
+	Title    string
+	Message  string
+
+	SupportURL         string
+	RuntimeDownloadURL string
+}
+
+// identifies whether the report refers to a missing or incompatible runtime
+func (r *ErrorReport) isRuntimeError() bool {
+	return r.Category == RuntimeNotFoundError || r.Category == RuntimeUnsupportedError || r.Category == RuntimeInvalidError
+}
+
+// Reporter presents errors to the user.
+type Reporter interface {
+	Report(report *ErrorReport)
+}
+
+// NewErrorReport creates a new error report for a given error category and cause based on the
+// support configuration within the passed application metadata.
+//
+// The passed metadata may be nil when the application configuration could not be loaded in which
+// case the built-in messages are used.
+func NewErrorReport(category ErrorCategory, cfg *metadata.ApplicationContainer, cause error) *ErrorReport {
+	found := "none"
+	var versionErr *runtime.VersionError
+	if errors.As(cause, &versionErr) {
+		found = strconv.FormatUint(versionErr.Found, 10)
+	}
+
+	causeText := ""
+	if cause != nil {
+		causeText = cause.Error()
+	}
+
+	return NewErrorReportTemplate(category, cfg, found, causeText)
+}
+
+// NewErrorReportTemplate creates a new error report for a given error category in which the
+// version of the located runtime and the error description are substituted with the passed values.
+//
+// This permits launch scripts to substitute values which are only known at launch time.
+func NewErrorReportTemplate(category ErrorCategory, cfg *metadata.ApplicationContainer, found string, cause string) *ErrorReport {
+	report := &ErrorReport{
+		Category: category,
+		Title:    errorTitles[category],
+	}
+
+	name := defaultApplicationName
+	if identity := cfg.GetIdentity(); len(identity.GetName()) != 0 {
+		name = identity.GetName()
+
+		if len(identity.GetVersion()) != 0 {
+			report.Title = name + " " + identity.GetVersion() + " - " + report.Title
+		} else {
+			report.Title = name + " - " + report.Title
+		}
+	}
+
+	support := cfg.GetSupport()
+	report.SupportURL = support.GetSupportUrl()
+	report.RuntimeDownloadURL = support.GetRuntimeDownloadUrl()
+
+	template := selectMessage(category, support.GetMessages())
+	if len(template) == 0 {
+		template = defaultMessages[category]
+	}
+
+	minimumVersion := cfg.GetRuntime().GetMinimumVersion()
+	maximumVersion := cfg.GetRuntime().GetMaximumVersion()
+
+	replacer := strings.NewReplacer(
+		"{name}", name,
+		"{version}", cfg.GetIdentity().GetVersion(),
+		"{vendor}", cfg.GetIdentity().GetVendor(),
+		"{homepage}", cfg.GetIdentity().GetHomepage(),
+		"{required}", formatVersionRequirement(minimumVersion, maximumVersion),
+		"{minimum}", strconv.FormatUint(minimumVersion, 10),
+		"{maximum}", strconv.FormatUint(maximumVersion, 10),
+		"{found}", found,
+		"{error}", cause,
+		"{support_url}", report.SupportURL,
+		"{runtime_download_url}", report.RuntimeDownloadURL,
+	)
+	report.Message = replacer.Replace(template)
+
+	return report
+}
+
+// selects the custom message template for a given error category (if any)
+func selectMessage(category ErrorCategory, messages *metadata.ErrorMessages) string {
+	switch category {
+	case ConfigurationError:
+		return messages.GetConfiguration()
+	case RuntimeNotFoundError:
+		return messages.GetRuntimeNotFound()
+	case RuntimeUnsupportedError:
+		return messages.GetRuntimeUnsupported()
+	case RuntimeInvalidError:
+		return messages.GetRuntimeInvalid()
+	case LaunchError:
+		return messages.GetLaunch()
+	case IntegrityError:
+		return messages.GetIntegrity()
+	}
+
+	return ""
+}
+
+// produces a human readable representation of a given runtime version range
+func formatVersionRequirement(minimumVersion uint64, maximumVersion uint64) string {
+	if maximumVersion == 0 {
+		return fmt.Sprintf("%d or newer", minimumVersion)
+	}
+	if minimumVersion == maximumVersion {
+		return strconv.FormatUint(minimumVersion, 10)
+	}
+
+	return fmt.Sprintf("%d through %d", minimumVersion, maximumVersion)
+}
+
+// categorizes a given runtime lookup error
+func categorizeRuntimeError(err error) ErrorCategory {
+	if errors.Is(err, runtime.ErrUnsupported) {
+		return RuntimeUnsupportedError
+	}
+	if errors.Is(err, runtime.ErrInvalidInstallation) {
+		return RuntimeInvalidError
+	}
+
+	return RuntimeNotFoundError
+}
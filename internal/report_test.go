@@ -0,0 +1,103 @@
+/*
+ * Copyright 2021 Johannes Donath <johannesd@torchmind.com>
+ * and other copyright owners as documented in the project's IP log.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package internal
+
+import (
+	"errors"
+	"github.com/dotstart/canoe/internal/metadata"
+	"github.com/dotstart/canoe/internal/runtime"
+	"testing"
+)
+
+func TestNewErrorReport(t *testing.T) {
+	cfg := &metadata.ApplicationContainer{
+		Runtime: &metadata.RuntimeConfiguration{
+			MinimumVersion: 17,
+		},
+		Identity: &metadata.ApplicationIdentity{
+			Name: "Foo",
+		},
+		Support: &metadata.SupportConfiguration{
+			RuntimeDownloadUrl: "https://example.org/java",
+			Messages: &metadata.ErrorMessages{
+				RuntimeUnsupported: "{name} needs {required} (found {found}), see {runtime_download_url}",
+			},
+		},
+	}
+
+	cause := &runtime.VersionError{Minimum: 17, Found: 11}
+
+	custom := NewErrorReport(RuntimeUnsupportedError, cfg, cause)
+	if custom.Message != "Foo needs 17 or newer (found 11), see https://example.org/java" {
+		t.Errorf("unexpected custom message: %q", custom.Message)
+	}
+	if custom.Title != "Foo - Runtime Error" {
+		t.Errorf("unexpected custom title: %q", custom.Title)
+	}
+	if custom.RuntimeDownloadURL != "https://example.org/java" {
+		t.Errorf("unexpected download url: %q", custom.RuntimeDownloadURL)
+	}
+
+	builtin := NewErrorReport(RuntimeNotFoundError, cfg, runtime.ErrNotFound)
+	if builtin.Message != "Foo requires Java 17 or newer but no Java Runtime could be located on this system." {
+		t.Errorf("unexpected built-in message: %q", builtin.Message)
+	}
+
+	fallback := NewErrorReport(ConfigurationError, nil, errors.New("magic number mismatch"))
+	if fallback.Message != "Failed to load application configuration: magic number mismatch" {
+		t.Errorf("unexpected fallback message: %q", fallback.Message)
+	}
+	if fallback.Title != "Application Error" {
+		t.Errorf("unexpected fallback title: %q", fallback.Title)
+	}
+}
+
+func TestNewErrorReportIdentity(t *testing.T) {
+	cfg := &metadata.ApplicationContainer{
+		Identity: &metadata.ApplicationIdentity{
+			Name:     "Foo",
+			Version:  "1.2.0",
+			Vendor:   "Example Inc.",
+			Homepage: "https://example.org",
+		},
+		Support: &metadata.SupportConfiguration{
+			Messages: &metadata.ErrorMessages{
+				Launch: "{name} {version} by {vendor} failed to start, see {homepage}",
+			},
+		},
+	}
+
+	report := NewErrorReport(LaunchError, cfg, errors.New("exit status 1"))
+	if report.Message != "Foo 1.2.0 by Example Inc. failed to start, see https://example.org" {
+		t.Errorf("unexpected message: %q", report.Message)
+	}
+	if report.Title != "Foo 1.2.0 - Application Error" {
+		t.Errorf("unexpected title: %q", report.Title)
+	}
+}
+
+func TestCategorizeRuntimeError(t *testing.T) {
+	if c := categorizeRuntimeError(&runtime.VersionError{Minimum: 17, Found: 11}); c != RuntimeUnsupportedError {
+		t.Errorf("expected unsupported category but got %d", c)
+	}
+	if c := categorizeRuntimeError(runtime.ErrInvalidInstallation); c != RuntimeInvalidError {
+		t.Errorf("expected invalid category but got %d", c)
+	}
+	if c := categorizeRuntimeError(runtime.ErrNotFound); c != RuntimeNotFoundError {
+		t.Errorf("expected not found category but got %d", c)
+	}
+}
@@ -0,0 +1,36 @@
+/*
+ * Copyright 2021 Johannes Donath <johannesd@torchmind.com>
+ * and other copyright owners as documented in the project's IP log.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package internal
+
+import (
+	"fmt"
+	"os"
+)
+
+// CliReporter presents errors via the standard error stream.
+type CliReporter struct{}
+
+func (CliReporter) Report(report *ErrorReport) {
+	_, _ = fmt.Fprintf(os.Stderr, "%s: %s\n", report.Title, report.Message)
+
+	if len(report.RuntimeDownloadURL) != 0 && report.isRuntimeError() {
+		_, _ = fmt.Fprintf(os.Stderr, "\nA compatible Java Runtime may be downloaded from: %s\n", report.RuntimeDownloadURL)
+	}
+	if len(report.SupportURL) != 0 {
+		_, _ = fmt.Fprintf(os.Stderr, "\nFor further assistance, please visit: %s\n", report.SupportURL)
+	}
+}
@@ -0,0 +1,46 @@
+/*
+ * Copyright 2021 Johannes Donath <johannesd@torchmind.com>
+ * and other copyright owners as documented in the project's IP log.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package internal
+
+import (
+	"github.com/gen2brain/dlgs"
+)
+
+// GuiReporter presents errors via native dialogs.
+type GuiReporter struct{}
+
+func (GuiReporter) Report(report *ErrorReport) {
+	message := report.Message
+	if len(report.SupportURL) != 0 {
+		message += "\n\nFor further assistance, please visit: " + report.SupportURL
+	}
+
+	if len(report.RuntimeDownloadURL) == 0 || !report.isRuntimeError() {
+		_, _ = dlgs.Error(report.Title, message)
+		return
+	}
+
+	message += "\n\nWould you like to download a compatible Java Runtime now?"
+	download, err := dlgs.Question(report.Title, message, false)
+	if err != nil || !download {
+		return
+	}
+
+	if err := openURL(report.RuntimeDownloadURL); err != nil {
+		_, _ = dlgs.Error(report.Title, "Failed to open download page. Please visit "+report.RuntimeDownloadURL+" manually.")
+	}
+}
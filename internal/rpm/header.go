@@ -0,0 +1,153 @@
+/*
+ * Copyright 2021 Johannes Donath <johannesd@torchmind.com>
+ * and other copyright owners as documented in the project's IP log.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package rpm
+
+import (
+	"bytes"
+	"encoding/binary"
+	"sort"
+)
+
+var headerMagic = []byte{0x8E, 0xAD, 0xE8, 0x01, 0x00, 0x00, 0x00, 0x00}
+
+// identifies the data types of header entries
+const (
+	typeInt16       = 3
+	typeInt32       = 4
+	typeString      = 6
+	typeBinary      = 7
+	typeStringArray = 8
+	typeI18NString  = 9
+)
+
+const (
+	indexEntrySize = 16
+
+	// identifies the size of the trailer which terminates header regions
+	regionTrailerSize = 16
+)
+
+// header represents an RPM header structure (as used for both the signature and the main header)
+type header struct {
+	entries []headerEntry
+}
+
+type headerEntry struct {
+	tag   uint32
+	typ   uint32
+	count uint32
+	data  []byte
+}
+
+func (h *header) add(tag uint32, typ uint32, count uint32, data []byte) {
+	h.entries = append(h.entries, headerEntry{tag, typ, count, data})
+}
+
+func (h *header) addString(tag uint32, value string) {
+	h.add(tag, typeString, 1, append([]byte(value), 0))
+}
+
+func (h *header) addI18NString(tag uint32, value string) {
+	h.add(tag, typeI18NString, 1, append([]byte(value), 0))
+}
+
+func (h *header) addStringArray(tag uint32, values ...string) {
+	buf := &bytes.Buffer{}
+	for _, value := range values {
+		buf.WriteString(value)
+		buf.WriteByte(0)
+	}
+
+	h.add(tag, typeStringArray, uint32(len(values)), buf.Bytes())
+}
+
+func (h *header) addInt32(tag uint32, values ...uint32) {
+	data := make([]byte, 4*len(values))
+	for i, value := range values {
+		binary.BigEndian.PutUint32(data[4*i:], value)
+	}
+
+	h.add(tag, typeInt32, uint32(len(values)), data)
+}
+
+func (h *header) addInt16(tag uint32, values ...uint16) {
+	data := make([]byte, 2*len(values))
+	for i, value := range values {
+		binary.BigEndian.PutUint16(data[2*i:], value)
+	}
+
+	h.add(tag, typeInt16, uint32(len(values)), data)
+}
+
+func (h *header) addBinary(tag uint32, data []byte) {
+	h.add(tag, typeBinary, uint32(len(data)), data)
+}
+
+// encodes the header while enclosing all of its entries within a region of a given tag
+//
+// the region is described by its own index entry (which precedes all other entries) and a trailer
+// at the end of the data store which refers back to the beginning of the index
+func (h *header) encode(regionTag uint32) []byte {
+	entries := append([]headerEntry{}, h.entries...)
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].tag < entries[j].tag
+	})
+
+	indexCount := len(entries) + 1
+
+	index := &bytes.Buffer{}
+	store := &bytes.Buffer{}
+	writeIndexEntry := func(tag uint32, typ uint32, offset int32, count uint32) {
+		_ = binary.Write(index, binary.BigEndian, []uint32{tag, typ, uint32(offset), count})
+	}
+
+	// the region entry is written first although its trailer is appended to the data store last
+	regionOffset := 0
+	for _, entry := range entries {
+		regionOffset = align(regionOffset, entry.typ) + len(entry.data)
+	}
+	writeIndexEntry(regionTag, typeBinary, int32(regionOffset), regionTrailerSize)
+
+	for _, entry := range entries {
+		store.Write(make([]byte, align(store.Len(), entry.typ)-store.Len()))
+		writeIndexEntry(entry.tag, entry.typ, int32(store.Len()), entry.count)
+		store.Write(entry.data)
+	}
+
+	_ = binary.Write(store, binary.BigEndian, []uint32{regionTag, typeBinary, uint32(int32(-indexCount * indexEntrySize)), regionTrailerSize})
+
+	buf := &bytes.Buffer{}
+	buf.Write(headerMagic)
+	_ = binary.Write(buf, binary.BigEndian, []uint32{uint32(indexCount), uint32(store.Len())})
+	buf.Write(index.Bytes())
+	buf.Write(store.Bytes())
+
+	return buf.Bytes()
+}
+
+// aligns a given data store offset to the natural alignment of a given data type
+func align(offset int, typ uint32) int {
+	alignment := 1
+	switch typ {
+	case typeInt16:
+		alignment = 2
+	case typeInt32:
+		alignment = 4
+	}
+
+	return (offset + alignment - 1) &^ (alignment - 1)
+}
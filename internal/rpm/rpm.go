@@ -0,0 +1,487 @@
+/*
+ * Copyright 2021 Johannes Donath <johannesd@torchmind.com>
+ * and other copyright owners as documented in the project's IP log.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package rpm
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"time"
+)
+
+var leadMagic = []byte{0xED, 0xAB, 0xEE, 0xDB}
+
+const (
+	leadSize     = 96
+	leadNameSize = 66
+)
+
+// identifies the tags of the signature header
+const (
+	sigTagHeaderSignatures = 62
+	sigTagSHA1             = 269
+	sigTagSHA256           = 273
+	sigTagSize             = 1000
+	sigTagMD5              = 1004
+	sigTagPayloadSize      = 1007
+)
+
+// identifies the tags of the main header
+const (
+	tagHeaderImmutable   = 63
+	tagHeaderI18NTable   = 100
+	tagName              = 1000
+	tagVersion           = 1001
+	tagRelease           = 1002
+	tagSummary           = 1004
+	tagDescription       = 1005
+	tagBuildTime         = 1006
+	tagBuildHost         = 1007
+	tagSize              = 1009
+	tagVendor            = 1011
+	tagLicense           = 1014
+	tagGroup             = 1016
+	tagURL               = 1020
+	tagOS                = 1021
+	tagArch              = 1022
+	tagFileSizes         = 1028
+	tagFileModes         = 1030
+	tagFileRdevs         = 1033
+	tagFileMtimes        = 1034
+	tagFileDigests       = 1035
+	tagFileLinkTos       = 1036
+	tagFileFlags         = 1037
+	tagFileUserName      = 1039
+	tagFileGroupName     = 1040
+	tagSourceRPM         = 1044
+	tagFileVerifyFlags   = 1045
+	tagProvideName       = 1047
+	tagRequireFlags      = 1048
+	tagRequireName       = 1049
+	tagRequireVersion    = 1050
+	tagFileDevices       = 1095
+	tagFileInodes        = 1096
+	tagFileLangs         = 1097
+	tagProvideFlags      = 1112
+	tagProvideVersion    = 1113
+	tagDirIndexes        = 1116
+	tagBaseNames         = 1117
+	tagDirNames          = 1118
+	tagPayloadFormat     = 1124
+	tagPayloadCompressor = 1125
+	tagPayloadFlags      = 1126
+	tagFileDigestAlgo    = 5011
+	tagPayloadDigest     = 5092
+	tagPayloadDigestAlgo = 5093
+)
+
+// identifies the comparison flags of dependencies
+const (
+	senseLess    = 1 << 1
+	senseGreater = 1 << 2
+	senseEqual   = 1 << 3
+	senseRPMLib  = 1 << 24
+)
+
+// identifies the SHA-256 digest algorithm (as defined by OpenPGP)
+const digestAlgoSHA256 = 8
+
+var ErrInvalidPackage = errors.New("invalid package")
+
+// declares the rpmlib features on which generated packages rely
+var rpmlibRequirements = []struct {
+	Name    string
+	Version string
+}{
+	{"rpmlib(CompressedFileNames)", "3.0.4-1"},
+	{"rpmlib(FileDigests)", "4.6.0-1"},
+	{"rpmlib(PayloadFilesHavePrefix)", "4.0-1"},
+}
+
+var senseOperators = map[string]uint32{
+	"<":  senseLess,
+	"<=": senseLess | senseEqual,
+	"=":  senseEqual,
+	">=": senseGreater | senseEqual,
+	">":  senseGreater,
+}
+
+// Package describes the contents and metadata of an RPM binary package.
+type Package struct {
+	Name         string
+	Version      string
+	Release      string
+	Architecture string
+	Summary      string
+	Description  string
+	Vendor       string
+	URL          string
+	License      string
+
+	// Requires lists the dependencies of the package using the syntax of spec files (e.g. "foo" or
+	// "foo >= 1.0")
+	Requires []string
+
+	Files     []File
+	BuildTime time.Time
+}
+
+// File describes a single regular file which is installed by a package.
+type File struct {
+	// Path identifies the absolute installation path of the file (e.g. "/usr/bin/foo")
+	Path string
+	Mode os.FileMode
+	Data []byte
+}
+
+// maps Go architectures onto their RPM equivalents
+var architectures = map[string]string{
+	"386":     "i686",
+	"amd64":   "x86_64",
+	"arm":     "armv7hl",
+	"arm64":   "aarch64",
+	"ppc64":   "ppc64",
+	"ppc64le": "ppc64le",
+	"riscv64": "riscv64",
+	"s390x":   "s390x",
+}
+
+// Architecture translates a given Go architecture into its RPM equivalent.
+//
+// Returns an empty string when the architecture is not supported.
+func Architecture(arch string) string {
+	return architectures[arch]
+}
+
+// FileName computes the conventional file name of the package.
+func (p *Package) FileName() string {
+	return p.fullName() + "." + p.Architecture + ".rpm"
+}
+
+// computes the name of the package including its version and release
+func (p *Package) fullName() string {
+	return p.Name + "-" + p.Version + "-" + p.Release
+}
+
+// Write encodes the package and writes it to a given writer.
+func (p *Package) Write(w io.Writer) error {
+	if len(p.Name) == 0 || len(p.Version) == 0 || len(p.Release) == 0 || len(p.Architecture) == 0 || len(p.Summary) == 0 {
+		return fmt.Errorf("%w: name, version, release, architecture and summary are required", ErrInvalidPackage)
+	}
+	if strings.ContainsAny(p.Version+p.Release, "- ") {
+		return fmt.Errorf("%w: version and release must not contain dashes or spaces", ErrInvalidPackage)
+	}
+
+	archive, err := p.archive()
+	if err != nil {
+		return err
+	}
+
+	payload := &bytes.Buffer{}
+	gz, err := gzip.NewWriterLevel(payload, gzip.BestCompression)
+	if err != nil {
+		return err
+	}
+	if _, err := gz.Write(archive); err != nil {
+		return err
+	}
+	if err := gz.Close(); err != nil {
+		return err
+	}
+
+	main, err := p.header(payload.Bytes())
+	if err != nil {
+		return err
+	}
+	signature := signatureHeader(main, payload.Bytes(), len(archive))
+
+	buf := &bytes.Buffer{}
+	buf.Write(p.lead())
+	buf.Write(signature)
+	buf.Write(make([]byte, (8-len(signature)%8)%8))
+	buf.Write(main)
+	buf.Write(payload.Bytes())
+
+	_, err = w.Write(buf.Bytes())
+	return err
+}
+
+// encodes the legacy lead which precedes the signature header
+//
+// with the exception of its magic and signature type, the lead is ignored by current versions of
+// rpm
+func (p *Package) lead() []byte {
+	lead := make([]byte, leadSize)
+	copy(lead, leadMagic)
+	lead[4] = 3 // major version
+
+	name := p.fullName()
+	if len(name) >= leadNameSize {
+		name = name[:leadNameSize-1]
+	}
+	copy(lead[10:], name)
+
+	binary.BigEndian.PutUint16(lead[76:], 1) // operating system (Linux)
+	binary.BigEndian.PutUint16(lead[78:], 5) // signature type (header structure)
+
+	return lead
+}
+
+// encodes the signature header which carries the digests of a given main header and payload
+func signatureHeader(main []byte, payload []byte, archiveSize int) []byte {
+	md5Digest := md5.New()
+	md5Digest.Write(main)
+	md5Digest.Write(payload)
+
+	sha1Digest := sha1.Sum(main)
+	sha256Digest := sha256.Sum256(main)
+
+	h := &header{}
+	h.addString(sigTagSHA1, hex.EncodeToString(sha1Digest[:]))
+	h.addString(sigTagSHA256, hex.EncodeToString(sha256Digest[:]))
+	h.addInt32(sigTagSize, uint32(len(main)+len(payload)))
+	h.addBinary(sigTagMD5, md5Digest.Sum(nil))
+	h.addInt32(sigTagPayloadSize, uint32(archiveSize))
+
+	return h.encode(sigTagHeaderSignatures)
+}
+
+// encodes the main header which describes the package and its files
+func (p *Package) header(payload []byte) ([]byte, error) {
+	description := p.Description
+	if len(strings.TrimSpace(description)) == 0 {
+		description = p.Summary
+	}
+
+	h := &header{}
+	h.addStringArray(tagHeaderI18NTable, "C")
+	h.addString(tagName, p.Name)
+	h.addString(tagVersion, p.Version)
+	h.addString(tagRelease, p.Release)
+	h.addI18NString(tagSummary, strings.Join(strings.Fields(p.Summary), " "))
+	h.addI18NString(tagDescription, description)
+	h.addInt32(tagBuildTime, uint32(p.BuildTime.Unix()))
+	h.addString(tagBuildHost, "localhost")
+	if len(p.Vendor) != 0 {
+		h.addString(tagVendor, p.Vendor)
+	}
+	if len(p.License) != 0 {
+		h.addString(tagLicense, p.License)
+	}
+	h.addI18NString(tagGroup, "Unspecified")
+	if len(p.URL) != 0 {
+		h.addString(tagURL, p.URL)
+	}
+	h.addString(tagOS, "linux")
+	h.addString(tagArch, p.Architecture)
+
+	// rpm identifies source packages by the absence of this tag
+	h.addString(tagSourceRPM, p.fullName()+".src.rpm")
+
+	if err := p.addFiles(h); err != nil {
+		return nil, err
+	}
+	if err := p.addDependencies(h); err != nil {
+		return nil, err
+	}
+
+	payloadDigest := sha256.Sum256(payload)
+	h.addString(tagPayloadFormat, "cpio")
+	h.addString(tagPayloadCompressor, "gzip")
+	h.addString(tagPayloadFlags, "9")
+	h.addStringArray(tagPayloadDigest, hex.EncodeToString(payloadDigest[:]))
+	h.addInt32(tagPayloadDigestAlgo, digestAlgoSHA256)
+
+	return h.encode(tagHeaderImmutable), nil
+}
+
+// appends the file metadata of the package to a given header
+//
+// file paths are split into their directory and base names (as required by the
+// CompressedFileNames feature)
+func (p *Package) addFiles(h *header) error {
+	var directories []string
+	directoryIndexes := make(map[string]uint32)
+
+	var size uint32
+	count := len(p.Files)
+	sizes := make([]uint32, count)
+	modes := make([]uint16, count)
+	mtimes := make([]uint32, count)
+	digests := make([]string, count)
+	indexes := make([]uint32, count)
+	baseNames := make([]string, count)
+	inodes := make([]uint32, count)
+	for i, file := range p.Files {
+		if !path.IsAbs(file.Path) {
+			return fmt.Errorf("%w: file path must be absolute: %s", ErrInvalidPackage, file.Path)
+		}
+
+		dir, base := path.Split(path.Clean(file.Path))
+		index, ok := directoryIndexes[dir]
+		if !ok {
+			index = uint32(len(directories))
+			directoryIndexes[dir] = index
+			directories = append(directories, dir)
+		}
+
+		digest := sha256.Sum256(file.Data)
+
+		size += uint32(len(file.Data))
+		sizes[i] = uint32(len(file.Data))
+		modes[i] = uint16(0100000 | file.Mode.Perm())
+		mtimes[i] = uint32(p.BuildTime.Unix())
+		digests[i] = hex.EncodeToString(digest[:])
+		indexes[i] = index
+		baseNames[i] = base
+		inodes[i] = uint32(i + 1)
+	}
+
+	h.addInt32(tagSize, size)
+	if count == 0 {
+		return nil
+	}
+
+	h.addInt32(tagFileSizes, sizes...)
+	h.addInt16(tagFileModes, modes...)
+	h.addInt16(tagFileRdevs, make([]uint16, count)...)
+	h.addInt32(tagFileMtimes, mtimes...)
+	h.addStringArray(tagFileDigests, digests...)
+	h.addStringArray(tagFileLinkTos, make([]string, count)...)
+	h.addInt32(tagFileFlags, make([]uint32, count)...)
+	h.addStringArray(tagFileUserName, repeat("root", count)...)
+	h.addStringArray(tagFileGroupName, repeat("root", count)...)
+	h.addInt32(tagFileVerifyFlags, repeatInt32(0xFFFFFFFF, count)...)
+	h.addInt32(tagFileDevices, repeatInt32(1, count)...)
+	h.addInt32(tagFileInodes, inodes...)
+	h.addStringArray(tagFileLangs, make([]string, count)...)
+	h.addInt32(tagDirIndexes, indexes...)
+	h.addStringArray(tagBaseNames, baseNames...)
+	h.addStringArray(tagDirNames, directories...)
+	h.addInt32(tagFileDigestAlgo, digestAlgoSHA256)
+
+	return nil
+}
+
+// appends the capabilities provided and required by the package to a given header
+func (p *Package) addDependencies(h *header) error {
+	type requirement struct {
+		name    string
+		flags   uint32
+		version string
+	}
+
+	var requirements []requirement
+	for _, req := range p.Requires {
+		fields := strings.Fields(req)
+		switch len(fields) {
+		case 1:
+			requirements = append(requirements, requirement{fields[0], 0, ""})
+		case 3:
+			flags, ok := senseOperators[fields[1]]
+			if !ok {
+				return fmt.Errorf("%w: unknown operator in requirement %q", ErrInvalidPackage, req)
+			}
+			requirements = append(requirements, requirement{fields[0], flags, fields[2]})
+		default:
+			return fmt.Errorf("%w: malformed requirement %q: expected name [operator version]", ErrInvalidPackage, req)
+		}
+	}
+	for _, req := range rpmlibRequirements {
+		requirements = append(requirements, requirement{req.Name, senseRPMLib | senseLess | senseEqual, req.Version})
+	}
+
+	// rpm expects requirements to be ordered by name
+	sort.SliceStable(requirements, func(i, j int) bool {
+		return requirements[i].name < requirements[j].name
+	})
+
+	names := make([]string, len(requirements))
+	flags := make([]uint32, len(requirements))
+	versions := make([]string, len(requirements))
+	for i, req := range requirements {
+		names[i] = req.name
+		flags[i] = req.flags
+		versions[i] = req.version
+	}
+
+	h.addStringArray(tagProvideName, p.Name)
+	h.addInt32(tagProvideFlags, senseEqual)
+	h.addStringArray(tagProvideVersion, p.Version+"-"+p.Release)
+
+	h.addInt32(tagRequireFlags, flags...)
+	h.addStringArray(tagRequireName, names...)
+	h.addStringArray(tagRequireVersion, versions...)
+
+	return nil
+}
+
+// encodes the files of the package as a cpio archive (using the SVR4 "newc" format)
+func (p *Package) archive() ([]byte, error) {
+	buf := &bytes.Buffer{}
+	writeEntry := func(name string, inode int, mode uint32, data []byte) {
+		_, _ = fmt.Fprintf(buf, "070701%08X%08X%08X%08X%08X%08X%08X%08X%08X%08X%08X%08X%08X",
+			inode, mode, 0, 0, 1, uint32(p.BuildTime.Unix()), len(data), 0, 0, 0, 0, len(name)+1, 0)
+		buf.WriteString(name)
+		buf.WriteByte(0)
+		buf.Write(make([]byte, (4-buf.Len()%4)%4))
+		buf.Write(data)
+		buf.Write(make([]byte, (4-buf.Len()%4)%4))
+	}
+
+	for i, file := range p.Files {
+		if !path.IsAbs(file.Path) {
+			return nil, fmt.Errorf("%w: file path must be absolute: %s", ErrInvalidPackage, file.Path)
+		}
+
+		writeEntry("."+path.Clean(file.Path), i+1, uint32(0100000|file.Mode.Perm()), file.Data)
+	}
+	writeEntry("TRAILER!!!", 0, 0, nil)
+
+	return buf.Bytes(), nil
+}
+
+// creates a slice which repeats a given string
+func repeat(value string, count int) []string {
+	values := make([]string, count)
+	for i := range values {
+		values[i] = value
+	}
+
+	return values
+}
+
+// creates a slice which repeats a given integer
+func repeatInt32(value uint32, count int) []uint32 {
+	values := make([]uint32, count)
+	for i := range values {
+		values[i] = value
+	}
+
+	return values
+}
@@ -0,0 +1,211 @@
+/*
+ * Copyright 2021 Johannes Donath <johannesd@torchmind.com>
+ * and other copyright owners as documented in the project's IP log.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package rpm
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+func testPackage() *Package {
+	return &Package{
+		Name:         "foo",
+		Version:      "1.2.0",
+		Release:      "1",
+		Architecture: "x86_64",
+		Summary:      "Does foo",
+		Vendor:       "Example Inc.",
+		URL:          "https://example.org",
+		Requires:     []string{"java-17-openjdk-headless >= 17", "which"},
+		Files: []File{
+			{Path: "/usr/bin/foo", Mode: 0755, Data: []byte("#!/bin/sh\n")},
+			{Path: "/usr/share/foo/foo.txt", Mode: 0644, Data: []byte("foo")},
+		},
+		BuildTime: time.Unix(1600000000, 0),
+	}
+}
+
+// decodedHeader maps the tags of a decoded header onto their type and raw values
+type decodedHeader map[uint32]struct {
+	typ   uint32
+	count uint32
+	data  []byte
+}
+
+func (h decodedHeader) strings(tag uint32) []string {
+	entry := h[tag]
+	return strings.Split(string(entry.data), "\x00")[:entry.count]
+}
+
+func (h decodedHeader) int32s(tag uint32) []uint32 {
+	entry := h[tag]
+	values := make([]uint32, entry.count)
+	for i := range values {
+		values[i] = binary.BigEndian.Uint32(entry.data[4*i:])
+	}
+
+	return values
+}
+
+// decodes a header at the beginning of a given buffer and validates its region
+//
+// returns the decoded header along with its encoded length
+func decodeHeader(t *testing.T, data []byte, regionTag uint32) (decodedHeader, int) {
+	if !bytes.HasPrefix(data, headerMagic) {
+		t.Fatalf("missing header magic")
+	}
+
+	indexCount := int(binary.BigEndian.Uint32(data[8:]))
+	storeSize := int(binary.BigEndian.Uint32(data[12:]))
+	index := data[16 : 16+indexCount*indexEntrySize]
+	store := data[16+len(index) : 16+len(index)+storeSize]
+
+	h := make(decodedHeader)
+	for i := 0; i < indexCount; i++ {
+		entry := index[i*indexEntrySize:]
+		tag := binary.BigEndian.Uint32(entry)
+		typ := binary.BigEndian.Uint32(entry[4:])
+		offset := int(int32(binary.BigEndian.Uint32(entry[8:])))
+		count := binary.BigEndian.Uint32(entry[12:])
+
+		if i == 0 {
+			if tag != regionTag || typ != typeBinary || count != regionTrailerSize {
+				t.Fatalf("expected region %d but got tag %d", regionTag, tag)
+			}
+
+			trailer := store[offset:]
+			if binary.BigEndian.Uint32(trailer) != regionTag || int32(binary.BigEndian.Uint32(trailer[8:])) != int32(-indexCount*indexEntrySize) {
+				t.Fatalf("malformed region trailer")
+			}
+			if offset+regionTrailerSize != storeSize {
+				t.Errorf("expected region to span the entire data store")
+			}
+			continue
+		}
+
+		if offset != align(offset, typ) {
+			t.Errorf("misaligned value for tag %d", tag)
+		}
+
+		end := len(store)
+		if i+1 < indexCount {
+			end = int(binary.BigEndian.Uint32(index[(i+1)*indexEntrySize+8:]))
+		}
+		h[tag] = struct {
+			typ   uint32
+			count uint32
+			data  []byte
+		}{typ, count, store[offset:end]}
+	}
+
+	return h, 16 + len(index) + storeSize
+}
+
+func TestWrite(t *testing.T) {
+	buf := &bytes.Buffer{}
+	if err := testPackage().Write(buf); err != nil {
+		t.Fatalf("failed to write package: %s", err)
+	}
+	data := buf.Bytes()
+
+	if !bytes.HasPrefix(data, leadMagic) || binary.BigEndian.Uint16(data[78:]) != 5 {
+		t.Fatalf("malformed lead")
+	}
+	if name := string(bytes.TrimRight(data[10:10+leadNameSize], "\x00")); name != "foo-1.2.0-1" {
+		t.Errorf("expected lead name foo-1.2.0-1 but got %q", name)
+	}
+
+	signature, signatureLength := decodeHeader(t, data[leadSize:], sigTagHeaderSignatures)
+	mainOffset := leadSize + signatureLength + (8-signatureLength%8)%8
+	main, mainLength := decodeHeader(t, data[mainOffset:], tagHeaderImmutable)
+	payload := data[mainOffset+mainLength:]
+
+	if size := signature.int32s(sigTagSize)[0]; int(size) != len(data)-mainOffset {
+		t.Errorf("expected size %d but got %d", len(data)-mainOffset, size)
+	}
+	md5Digest := md5.Sum(data[mainOffset:])
+	if !bytes.Equal(signature[sigTagMD5].data, md5Digest[:]) {
+		t.Errorf("MD5 digest mismatch")
+	}
+	sha256Digest := sha256.Sum256(data[mainOffset : mainOffset+mainLength])
+	if digest := signature.strings(sigTagSHA256)[0]; digest != hex.EncodeToString(sha256Digest[:]) {
+		t.Errorf("SHA-256 header digest mismatch")
+	}
+
+	for tag, expected := range map[uint32]string{tagName: "foo", tagVersion: "1.2.0", tagRelease: "1", tagArch: "x86_64", tagOS: "linux", tagSourceRPM: "foo-1.2.0-1.src.rpm"} {
+		if actual := main.strings(tag)[0]; actual != expected {
+			t.Errorf("expected %q for tag %d but got %q", expected, tag, actual)
+		}
+	}
+	if dirs := main.strings(tagDirNames); len(dirs) != 2 || dirs[0] != "/usr/bin/" || dirs[1] != "/usr/share/foo/" {
+		t.Errorf("unexpected directories %v", dirs)
+	}
+	if names := main.strings(tagBaseNames); len(names) != 2 || names[0] != "foo" || names[1] != "foo.txt" {
+		t.Errorf("unexpected base names %v", names)
+	}
+
+	requires := main.strings(tagRequireName)
+	if len(requires) != 5 || requires[0] != "java-17-openjdk-headless" || !strings.HasPrefix(requires[1], "rpmlib(") || requires[4] != "which" {
+		t.Errorf("unexpected requirements %v", requires)
+	}
+	if flags := main.int32s(tagRequireFlags); flags[0] != senseGreater|senseEqual || flags[4] != 0 {
+		t.Errorf("unexpected requirement flags %v", flags)
+	}
+
+	gz, err := gzip.NewReader(bytes.NewReader(payload))
+	if err != nil {
+		t.Fatalf("failed to decompress payload: %s", err)
+	}
+	archive, err := io.ReadAll(gz)
+	if err != nil {
+		t.Fatalf("failed to decompress payload: %s", err)
+	}
+	if size := signature.int32s(sigTagPayloadSize)[0]; int(size) != len(archive) {
+		t.Errorf("expected payload size %d but got %d", len(archive), size)
+	}
+	for _, name := range []string{"./usr/bin/foo\x00", "./usr/share/foo/foo.txt\x00", "TRAILER!!!\x00"} {
+		if !bytes.Contains(archive, []byte(name)) {
+			t.Errorf("expected %q within payload", name)
+		}
+	}
+	if !bytes.HasPrefix(archive, []byte("070701")) || len(archive)%4 != 0 {
+		t.Errorf("malformed payload")
+	}
+}
+
+func TestWriteInvalid(t *testing.T) {
+	pkg := testPackage()
+	pkg.Version = "1.2.0-SNAPSHOT"
+	if err := pkg.Write(io.Discard); !errors.Is(err, ErrInvalidPackage) {
+		t.Errorf("expected ErrInvalidPackage but got %v", err)
+	}
+
+	pkg = testPackage()
+	pkg.Requires = []string{"foo ~ 1"}
+	if err := pkg.Write(io.Discard); !errors.Is(err, ErrInvalidPackage) {
+		t.Errorf("expected ErrInvalidPackage but got %v", err)
+	}
+}
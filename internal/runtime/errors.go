@@ -16,8 +16,44 @@
  */
 package runtime
 
-import "errors"
+import (
+	"errors"
+	"fmt"
+)
 
 var ErrNotFound = errors.New("runtime cannot be found")
 var ErrInvalidInstallation = errors.New("invalid runtime installation")
 var ErrUnsupported = errors.New("unsupported runtime version")
+
+// VersionError indicates that a located runtime does not satisfy the version constraints of an
+// application.
+type VersionError struct {
+	Minimum uint64
+	Maximum uint64
+	Found   uint64
+}
+
+func (e *VersionError) Error() string {
+	if e.Found < e.Minimum {
+		return fmt.Sprintf("%s: %d required (%d found)", ErrUnsupported, e.Minimum, e.Found)
+	}
+
+	return fmt.Sprintf("%s: %d and newer are unsupported (%d found)", ErrUnsupported, e.Maximum, e.Found)
+}
+
+func (e *VersionError) Unwrap() error {
+	return ErrUnsupported
+}
+
+// checks whether a given runtime version satisfies the passed constraints
+func checkVersion(version uint64, minimumVersion uint64, maximumVersion uint64) error {
+	if version < minimumVersion || (maximumVersion != 0 && version > maximumVersion) {
+		return &VersionError{
+			Minimum: minimumVersion,
+			Maximum: maximumVersion,
+			Found:   version,
+		}
+	}
+
+	return nil
+}
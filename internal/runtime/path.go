@@ -27,57 +27,70 @@ import (
 const cmdVersionPrefix = "version "
 
 // attempts to locate a given Java executable with the desired version number
-func FindInPath(executableName string, minimumVersion uint64, maximumVersion uint64) error {
+//
+// returns the version of the located runtime
+func FindInPath(executableName string, minimumVersion uint64, maximumVersion uint64) (uint64, error) {
+	if _, err := exec.LookPath(executableName); err != nil {
+		return 0, ErrNotFound
+	}
+
 	cmd := exec.Command(executableName, "-version")
 
 	pipe, err := cmd.StderrPipe()
 	if err != nil {
-		return ErrNotFound
+		return 0, ErrNotFound
 	}
 
 	scanner := bufio.NewScanner(pipe)
 
 	if err := cmd.Start(); err != nil {
-		return fmt.Errorf("%w: failed to launch Java process", ErrInvalidInstallation)
+		return 0, fmt.Errorf("%w: failed to launch Java process", ErrInvalidInstallation)
 	}
 
 	if !scanner.Scan() {
-		return fmt.Errorf("%w: Java process did not provide version information", ErrInvalidInstallation)
+		return 0, fmt.Errorf("%w: Java process did not provide version information", ErrInvalidInstallation)
 	}
 
 	versionLine := scanner.Text()
-	versionOffset := strings.Index(versionLine, cmdVersionPrefix)
 
 	if err := cmd.Wait(); err != nil {
-		return fmt.Errorf("%w: Java process terminated abnormally", ErrInvalidInstallation)
+		return 0, fmt.Errorf("%w: Java process terminated abnormally", ErrInvalidInstallation)
+	}
+
+	majorNumber, err := parseVersion(versionLine)
+	if err != nil {
+		return 0, err
 	}
 
+	return majorNumber, checkVersion(majorNumber, minimumVersion, maximumVersion)
+}
+
+// extracts the major version number from the first line of the runtime version output
+//
+// legacy runtimes report their version using a "1." prefix (e.g. "1.8.0_302") while GA releases
+// of modern runtimes may omit minor and patch versions entirely (e.g. "17")
+func parseVersion(versionLine string) (uint64, error) {
+	versionOffset := strings.Index(versionLine, cmdVersionPrefix)
 	if versionOffset == -1 {
-		return fmt.Errorf("%w: Java process did not provide valid version information: missing version number", ErrInvalidInstallation)
+		return 0, fmt.Errorf("%w: Java process did not provide valid version information: missing version number", ErrInvalidInstallation)
 	}
 
-	versionNumber := versionLine[(versionOffset + len(cmdVersionPrefix)):]
-	if versionNumber[0] == '"' {
-		versionNumber = versionNumber[1:]
+	versionNumber := strings.TrimPrefix(versionLine[(versionOffset+len(cmdVersionPrefix)):], "\"")
+	if strings.HasPrefix(versionNumber, "1.") {
+		versionNumber = versionNumber[2:]
 	}
 
-	majorSeparator := strings.IndexRune(versionNumber, '.')
+	majorSeparator := strings.IndexFunc(versionNumber, func(r rune) bool {
+		return r < '0' || r > '9'
+	})
 	if majorSeparator == -1 {
-		return fmt.Errorf("%w: Java process did not provide valid version information", ErrInvalidInstallation)
+		majorSeparator = len(versionNumber)
 	}
 
-	majorString := versionNumber[:majorSeparator]
-	majorNumber, err := strconv.ParseUint(majorString, 10, 32)
+	majorNumber, err := strconv.ParseUint(versionNumber[:majorSeparator], 10, 32)
 	if err != nil {
-		return fmt.Errorf("%w: Java process did not provide valid version information (%s)", ErrInvalidInstallation, err)
-	}
-
-	if majorNumber < minimumVersion {
-		return fmt.Errorf("%w: %d required (%d found)", ErrUnsupported, minimumVersion, majorNumber)
-	}
-	if maximumVersion != 0 && majorNumber > maximumVersion {
-		return fmt.Errorf("%w: %d and newer are unsupported (%d found)", ErrUnsupported, maximumVersion, majorNumber)
+		return 0, fmt.Errorf("%w: Java process did not provide valid version information (%s)", ErrInvalidInstallation, err)
 	}
 
-	return nil
+	return majorNumber, nil
 }
@@ -0,0 +1,47 @@
+/*
+ * Copyright 2021 Johannes Donath <johannesd@torchmind.com>
+ * and other copyright owners as documented in the project's IP log.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package runtime
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestParseVersion(t *testing.T) {
+	tests := map[string]uint64{
+		`java version "1.8.0_302"`:                   8,
+		`openjdk version "11.0.12" 2021-07-20`:       11,
+		`openjdk version "17" 2021-09-14`:            17,
+		`openjdk version "21-ea" 2023-09-19`:         21,
+		`openjdk version "17.0.1+12-LTS" 2021-10-19`: 17,
+	}
+
+	for line, expected := range tests {
+		version, err := parseVersion(line)
+		if err != nil {
+			t.Errorf("%s: failed to parse version: %s", line, err)
+			continue
+		}
+		if version != expected {
+			t.Errorf("%s: expected version %d but got %d", line, expected, version)
+		}
+	}
+
+	if _, err := parseVersion("Error: could not create the Java Virtual Machine."); !errors.Is(err, ErrInvalidInstallation) {
+		t.Errorf("expected invalid installation but got %v", err)
+	}
+}
@@ -21,8 +21,8 @@ package runtime
 const CliExecutableName = "java"
 const GuiExecutableName = "java"
 
-func Find(minimumVersion uint64, maximumVersion uint64) (string, error) {
+func Find(minimumVersion uint64, maximumVersion uint64) (string, uint64, error) {
 	// stub function which forces fallback to PATH search when no specific implementation applies to
 	// the current execution environment
-	return "", ErrNotFound
+	return "", 0, ErrNotFound
 }
@@ -87,23 +87,22 @@ func findRootForVersion(version uint64) (string, error) {
 }
 
 // locates a given minimum version of Java within the current execution environment
-func Find(minimumVersion uint64, maximumVersion uint64) (string, error) {
+//
+// returns the path to the binary directory of the runtime along with its version
+func Find(minimumVersion uint64, maximumVersion uint64) (string, uint64, error) {
 	latestVersion, err := getLatestVersion()
 	if err != nil {
-		return "", err
+		return "", 0, err
 	}
 
-	if latestVersion < minimumVersion {
-		return "", fmt.Errorf("%w: %d required (%d found)", ErrUnsupported, minimumVersion, latestVersion)
-	}
-	if maximumVersion != 0 && latestVersion > maximumVersion {
-		return "", fmt.Errorf("%w: %d and newer are unsupported (%d found)", ErrUnsupported, maximumVersion, latestVersion)
+	if err := checkVersion(latestVersion, minimumVersion, maximumVersion); err != nil {
+		return "", 0, err
 	}
 
 	root, err := findRootForVersion(latestVersion)
 	if err != nil {
-		return "", err
+		return "", 0, err
 	}
 
-	return path.Join(root, "bin"), nil
+	return path.Join(root, "bin"), latestVersion, nil
 }
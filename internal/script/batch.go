@@ -0,0 +1,167 @@
+/*
+ * Copyright 2021 Johannes Donath <johannesd@torchmind.com>
+ * and other copyright owners as documented in the project's IP log.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package script
+
+import (
+	"fmt"
+	"github.com/dotstart/canoe/internal"
+	"github.com/dotstart/canoe/internal/metadata"
+	"regexp"
+	"strings"
+)
+
+// matches absolute Windows paths (e.g. "C:\foo" or "\\server\foo")
+var batchAbsolutePathPattern = regexp.MustCompile(`^([A-Za-z]:)?[\\/]`)
+
+// identifies the characters which must be escaped within echo statements
+var batchEchoReplacer = strings.NewReplacer(
+	"%", "%%",
+	"^", "^^",
+	"&", "^&",
+	"|", "^|",
+	"<", "^<",
+	">", "^>",
+)
+
+var batchDialect = &dialect{
+	archive:       "%CANOE_ARCHIVE%",
+	directory:     "%CANOE_DIRECTORY%",
+	pathSeparator: `\`,
+	listSeparator: ";",
+	isAbsolute: func(path string) bool {
+		return batchAbsolutePathPattern.MatchString(path)
+	},
+	escape: func(value string) (string, error) {
+		// quotes cannot be escaped within quoted arguments
+		if strings.ContainsAny(value, "\"\r\n") {
+			return "", fmt.Errorf("%w: %q", ErrUnsupportedValue, value)
+		}
+
+		return strings.ReplaceAll(value, "%", "%%"), nil
+	},
+	word: func(parts ...string) string {
+		return `"` + strings.Join(parts, "") + `"`
+	},
+}
+
+// Batch generates a Windows batch script which launches the application described by the passed
+// metadata.
+//
+// The script locates a compatible runtime via JAVA_HOME or PATH and passes itself as the class path
+// of the application. As such, the payload and container metadata are expected to be appended to the
+// script.
+func Batch(cfg *metadata.ApplicationContainer) ([]byte, error) {
+	variants, err := launchVariants(cfg, batchDialect)
+	if err != nil {
+		return nil, err
+	}
+	messages := errorMessages(cfg)
+
+	lines := []string{
+		"rem",
+		"rem Locates a compatible Java runtime and launches the application archive which has been",
+		"rem appended to this script. Binary data follows the final line of this script.",
+		"setlocal",
+		"",
+		`set "CANOE_JAVA="`,
+		`set "CANOE_FOUND=none"`,
+		`set "CANOE_INVALID="`,
+		`if defined JAVA_HOME call :canoe_probe "%JAVA_HOME%\bin\java.exe"`,
+		`if not defined CANOE_JAVA for %%j in (java.exe) do if not "%%~$PATH:j" == "" call :canoe_probe "%%~$PATH:j"`,
+		"if defined CANOE_JAVA goto canoe_launch",
+		`if not "%CANOE_FOUND%" == "none" goto canoe_unsupported`,
+		"if defined CANOE_INVALID goto canoe_invalid",
+	}
+	lines = append(lines, batchMessage(messages[internal.RuntimeNotFoundError])...)
+	lines = append(lines, fmt.Sprintf("exit /b %d", runtimeErrorExitCode), "", ":canoe_unsupported")
+	lines = append(lines, batchMessage(messages[internal.RuntimeUnsupportedError])...)
+	lines = append(lines, fmt.Sprintf("exit /b %d", runtimeErrorExitCode), "", ":canoe_invalid")
+	lines = append(lines, batchMessage(messages[internal.RuntimeInvalidError])...)
+	lines = append(lines,
+		fmt.Sprintf("exit /b %d", runtimeErrorExitCode),
+		"",
+		":canoe_launch",
+		`set "CANOE_ARCHIVE=%~f0"`,
+		`set "CANOE_DIRECTORY=%~dp0"`,
+		`set "CANOE_DIRECTORY=%CANOE_DIRECTORY:~0,-1%"`,
+	)
+
+	// variants are selected via labels as arguments may contain characters which terminate
+	// parenthesized blocks
+	for i, variant := range variants[:len(variants)-1] {
+		lines = append(lines, fmt.Sprintf("if %%CANOE_VERSION%% GEQ %d goto canoe_launch_%d", variant.minimumVersion, i))
+	}
+	lines = append(lines, batchLaunch(variants[len(variants)-1])...)
+	for i, variant := range variants[:len(variants)-1] {
+		lines = append(lines, "", fmt.Sprintf(":canoe_launch_%d", i))
+		lines = append(lines, batchLaunch(variant)...)
+	}
+
+	minimumVersion := cfg.GetRuntime().GetMinimumVersion()
+	maximumVersion := cfg.GetRuntime().GetMaximumVersion()
+
+	// probes a given runtime executable and selects it if it satisfies the version constraints
+	lines = append(lines,
+		"",
+		":canoe_probe",
+		`if not exist "%~1" goto :eof`,
+		`set "CANOE_VERSION="`,
+		`for /f "tokens=3" %%v in ('""%~1" -version 2>&1 | findstr version"') do if not defined CANOE_VERSION set "CANOE_VERSION=%%~v"`,
+		`if not defined CANOE_VERSION goto canoe_probe_invalid`,
+		`for /f "tokens=1,2 delims=._-+" %%a in ("%CANOE_VERSION%") do if "%%a" == "1" (set "CANOE_VERSION=%%b") else set "CANOE_VERSION=%%a"`,
+		`for /f "delims=0123456789" %%c in ("%CANOE_VERSION%.") do if not "%%c" == "." set "CANOE_VERSION="`,
+		`if not defined CANOE_VERSION goto canoe_probe_invalid`,
+		`set "CANOE_FOUND=%CANOE_VERSION%"`,
+		fmt.Sprintf("if %%CANOE_VERSION%% LSS %d goto :eof", minimumVersion),
+	)
+	if maximumVersion != 0 {
+		lines = append(lines, fmt.Sprintf("if %%CANOE_VERSION%% GTR %d goto :eof", maximumVersion))
+	}
+	lines = append(lines,
+		`set "CANOE_JAVA=%~1"`,
+		"goto :eof",
+		"",
+		":canoe_probe_invalid",
+		`set "CANOE_INVALID=%~1"`,
+		"goto :eof",
+	)
+
+	return []byte(internal.BatchScriptHeader + strings.Join(lines, "\r\n") + "\r\n"), nil
+}
+
+// produces the statements which launch a given variant of the application
+func batchLaunch(variant *launchVariant) []string {
+	return []string{
+		fmt.Sprintf(`"%%CANOE_JAVA%%" %s %%*`, strings.Join(variant.arguments, " ")),
+		"exit /b %ERRORLEVEL%",
+	}
+}
+
+// produces a set of statements which print a given message to the standard error stream
+func batchMessage(lines []string) []string {
+	statements := make([]string, len(lines))
+	for i, line := range lines {
+		parts := strings.Split(line, foundPlaceholder)
+		for j, part := range parts {
+			parts[j] = batchEchoReplacer.Replace(part)
+		}
+
+		statements[i] = ">&2 echo(" + strings.Join(parts, "%CANOE_FOUND%")
+	}
+
+	return statements
+}
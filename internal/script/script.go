@@ -0,0 +1,207 @@
+/*
+ * Copyright 2021 Johannes Donath <johannesd@torchmind.com>
+ * and other copyright owners as documented in the project's IP log.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package script
+
+import (
+	"errors"
+	"fmt"
+	"github.com/dotstart/canoe/internal"
+	"github.com/dotstart/canoe/internal/metadata"
+	"github.com/dotstart/canoe/internal/runtime"
+	"strings"
+)
+
+// identifies the placeholder which refers to the version of the located runtime within error
+// messages
+const foundPlaceholder = "{found}"
+
+// identifies the exit code which is returned when no compatible runtime could be located
+// (equivalent to the wrapper executables)
+const runtimeErrorExitCode = 253
+
+var ErrUnsupportedValue = errors.New("value cannot be represented within script")
+
+// describes the syntax of a given script language
+type dialect struct {
+	// identify the expressions which refer to the launched archive and the directory in which it
+	// resides
+	archive   string
+	directory string
+
+	pathSeparator string
+	listSeparator string
+
+	// identifies whether a given class path entry refers to an absolute path
+	isAbsolute func(path string) bool
+
+	// escapes a given literal value for use within a command line argument
+	escape func(value string) (string, error)
+
+	// combines a set of escaped values and expressions into a single command line argument
+	word func(parts ...string) string
+}
+
+// collects the command line arguments of a given runtime invocation
+type argumentList struct {
+	dialect   *dialect
+	arguments []string
+	err       error
+}
+
+// escapes a given literal value
+func (l *argumentList) literal(value string) string {
+	escaped, err := l.dialect.escape(value)
+	if err != nil && l.err == nil {
+		l.err = err
+	}
+
+	return escaped
+}
+
+// appends an argument which consists of a given set of escaped values and expressions
+func (l *argumentList) add(parts ...string) {
+	l.arguments = append(l.arguments, l.dialect.word(parts...))
+}
+
+// appends a set of literal arguments
+func (l *argumentList) addLiterals(values ...string) {
+	for _, value := range values {
+		l.add(l.literal(value))
+	}
+}
+
+// computes the arguments passed to a runtime of a given version in order to launch the application
+// which has been appended to the script
+//
+// the arguments are equivalent to those passed by wrapper executables (refer to
+// internal.RuntimeArguments) with the exception of the runtime specific path syntax
+func runtimeArguments(cfg *metadata.ApplicationContainer, version uint64, d *dialect) ([]string, error) {
+	l := &argumentList{dialect: d}
+
+	if cfg.GetRuntime().GetInitialMemory() != 0 {
+		l.addLiterals("-Xms" + metadata.AppendByteSuffix(cfg.Runtime.InitialMemory))
+	}
+	if cfg.GetRuntime().GetMemoryLimit() != 0 {
+		l.addLiterals("-Xmx" + metadata.AppendByteSuffix(cfg.Runtime.MemoryLimit))
+	}
+
+	application := cfg.GetApplication()
+	if version >= internal.ModuleOptionsVersion {
+		for _, pkg := range application.GetAddOpens() {
+			l.addLiterals("--add-opens", pkg+"=ALL-UNNAMED")
+		}
+		for _, pkg := range application.GetAddExports() {
+			l.addLiterals("--add-exports", pkg+"=ALL-UNNAMED")
+		}
+	}
+	if version >= internal.NativeAccessOptionVersion && application.GetEnableNativeAccess() {
+		l.addLiterals("--enable-native-access=ALL-UNNAMED")
+	}
+	if len(application.GetLauncherAgentClass()) != 0 {
+		l.add(l.literal("-javaagent:"), d.archive)
+	}
+
+	if len(cfg.GetRuntime().GetAdditionalArguments()) != 0 {
+		l.addLiterals(strings.Split(cfg.Runtime.AdditionalArguments, " ")...)
+	}
+
+	classPath := []string{d.archive}
+	for _, entry := range application.GetClassPath() {
+		classPath = append(classPath, l.literal(d.listSeparator))
+		if d.isAbsolute(entry) {
+			classPath = append(classPath, l.literal(entry))
+			continue
+		}
+
+		entry = strings.ReplaceAll(entry, "/", d.pathSeparator)
+		classPath = append(classPath, d.directory, l.literal(d.pathSeparator+entry))
+	}
+
+	l.addLiterals("-cp")
+	l.add(classPath...)
+	l.addLiterals(application.GetMainClass())
+
+	return l.arguments, l.err
+}
+
+// describes a set of runtime arguments which is passed to runtimes of a given version or newer
+type launchVariant struct {
+	minimumVersion uint64
+	arguments      []string
+}
+
+// computes the distinct sets of runtime arguments which are passed to the runtime versions
+// permitted by the application
+//
+// variants are ordered by their minimum version in descending order
+func launchVariants(cfg *metadata.ApplicationContainer, d *dialect) ([]*launchVariant, error) {
+	minimumVersion := cfg.GetRuntime().GetMinimumVersion()
+	maximumVersion := cfg.GetRuntime().GetMaximumVersion()
+
+	arguments, err := runtimeArguments(cfg, minimumVersion, d)
+	if err != nil {
+		return nil, err
+	}
+	variants := []*launchVariant{{minimumVersion: minimumVersion, arguments: arguments}}
+
+	for _, version := range []uint64{internal.ModuleOptionsVersion, internal.NativeAccessOptionVersion} {
+		if version <= minimumVersion || (maximumVersion != 0 && version > maximumVersion) {
+			continue
+		}
+
+		arguments, err := runtimeArguments(cfg, version, d)
+		if err != nil {
+			return nil, err
+		}
+		if strings.Join(arguments, " ") == strings.Join(variants[0].arguments, " ") {
+			continue
+		}
+
+		variants = append([]*launchVariant{{minimumVersion: version, arguments: arguments}}, variants...)
+	}
+
+	return variants, nil
+}
+
+// produces the error messages which are displayed when no compatible runtime could be located
+//
+// the runtime version placeholder is retained within the messages
+func errorMessages(cfg *metadata.ApplicationContainer) map[internal.ErrorCategory][]string {
+	causes := map[internal.ErrorCategory]string{
+		internal.RuntimeNotFoundError:    runtime.ErrNotFound.Error(),
+		internal.RuntimeUnsupportedError: fmt.Sprintf("%s (%s found)", runtime.ErrUnsupported, foundPlaceholder),
+		internal.RuntimeInvalidError:     fmt.Sprintf("%s: Java process did not provide valid version information", runtime.ErrInvalidInstallation),
+	}
+
+	messages := make(map[internal.ErrorCategory][]string)
+	for category, cause := range causes {
+		report := internal.NewErrorReportTemplate(category, cfg, foundPlaceholder, cause)
+
+		// equivalent to the output of the CLI reporter
+		lines := strings.Split(report.Title+": "+report.Message, "\n")
+		if len(report.RuntimeDownloadURL) != 0 {
+			lines = append(lines, "", "A compatible Java Runtime may be downloaded from: "+report.RuntimeDownloadURL)
+		}
+		if len(report.SupportURL) != 0 {
+			lines = append(lines, "", "For further assistance, please visit: "+report.SupportURL)
+		}
+
+		messages[category] = lines
+	}
+
+	return messages
+}
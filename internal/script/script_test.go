@@ -0,0 +1,186 @@
+/*
+ * Copyright 2021 Johannes Donath <johannesd@torchmind.com>
+ * and other copyright owners as documented in the project's IP log.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package script
+
+import (
+	"bytes"
+	"errors"
+	"github.com/dotstart/canoe/internal"
+	"github.com/dotstart/canoe/internal/metadata"
+	"os"
+	"os/exec"
+	"path/filepath"
+	goruntime "runtime"
+	"strings"
+	"testing"
+)
+
+// produces a configuration which exercises all version specific launch options
+func testConfiguration() *metadata.ApplicationContainer {
+	return &metadata.ApplicationContainer{
+		Runtime: &metadata.RuntimeConfiguration{
+			MinimumVersion:      11,
+			MemoryLimit:         1024 * 1024 * 1024,
+			AdditionalArguments: "-Dfoo=bar",
+		},
+		Application: &metadata.ApplicationConfiguration{
+			MainClass:          "foo.Main",
+			AddOpens:           []string{"java.base/java.lang"},
+			EnableNativeAccess: true,
+			ClassPath:          []string{"lib/bar.jar"},
+		},
+		Identity: &metadata.ApplicationIdentity{
+			Name: "Foo's App",
+		},
+	}
+}
+
+// writes a fake runtime of a given version which prints its arguments to a temporary directory
+func writeFakeRuntime(t *testing.T, version string) string {
+	dir := t.TempDir()
+	runtime := "#!/bin/sh\nif [ \"$1\" = -version ]; then echo 'openjdk version \"" + version + "\" 2021-10-19' >&2; exit 0; fi\necho \"$@\"\n"
+	if err := os.WriteFile(filepath.Join(dir, "java"), []byte(runtime), 0755); err != nil {
+		t.Fatalf("failed to write fake runtime: %s", err)
+	}
+
+	return dir
+}
+
+// executes a given shell script with the passed search path
+func runShell(t *testing.T, script []byte, path string, args ...string) (string, string, int) {
+	if goruntime.GOOS == "windows" {
+		t.Skip("shell scripts are not supported on Windows")
+	}
+
+	executable := filepath.Join(t.TempDir(), "foo")
+	if err := os.WriteFile(executable, script, 0755); err != nil {
+		t.Fatalf("failed to write script: %s", err)
+	}
+
+	stdout := &bytes.Buffer{}
+	stderr := &bytes.Buffer{}
+	cmd := exec.Command(executable, args...)
+	cmd.Env = []string{"PATH=" + path}
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+
+	if err := cmd.Run(); err != nil {
+		exitErr, ok := err.(*exec.ExitError)
+		if !ok {
+			t.Fatalf("failed to execute script: %s", err)
+		}
+
+		return stdout.String(), stderr.String(), exitErr.ExitCode()
+	}
+
+	return stdout.String(), stderr.String(), 0
+}
+
+func TestShell(t *testing.T) {
+	script, err := Shell(testConfiguration())
+	if err != nil {
+		t.Fatalf("failed to generate script: %s", err)
+	}
+
+	detected, err := internal.DetectTarget(bytes.NewReader(script))
+	if err != nil || detected.Name() != "script" {
+		t.Errorf("expected script target but got %v (%v)", detected, err)
+	}
+
+	tests := map[string]string{
+		"17.0.1": "-Xmx1G --add-opens java.base/java.lang=ALL-UNNAMED --enable-native-access=ALL-UNNAMED -Dfoo=bar -cp",
+		"11":     "-Xmx1G --add-opens java.base/java.lang=ALL-UNNAMED -Dfoo=bar -cp",
+	}
+	for version, expected := range tests {
+		stdout, stderr, code := runShell(t, script, writeFakeRuntime(t, version)+":/usr/bin:/bin", "a", "b c")
+		if code != 0 {
+			t.Errorf("expected script to succeed with runtime %s but got exit code %d: %s", version, code, stderr)
+			continue
+		}
+
+		if !strings.HasPrefix(stdout, expected+" ") {
+			t.Errorf("expected arguments %q for runtime %s but got %q", expected, version, stdout)
+		}
+		if !strings.HasSuffix(stdout, "/lib/bar.jar foo.Main a b c\n") {
+			t.Errorf("expected class path, main class and arguments for runtime %s but got %q", version, stdout)
+		}
+	}
+}
+
+func TestShellRuntimeErrors(t *testing.T) {
+	script, err := Shell(testConfiguration())
+	if err != nil {
+		t.Fatalf("failed to generate script: %s", err)
+	}
+
+	_, stderr, code := runShell(t, script, "/usr/bin:/bin")
+	if code != runtimeErrorExitCode || !strings.Contains(stderr, "Foo's App requires Java 11 or newer but no Java Runtime could be located") {
+		t.Errorf("expected runtime not found error but got exit code %d: %s", code, stderr)
+	}
+
+	_, stderr, code = runShell(t, script, writeFakeRuntime(t, "1.8.0_302")+":/usr/bin:/bin")
+	if code != runtimeErrorExitCode || !strings.Contains(stderr, "only incompatible versions were found on this system (8)") {
+		t.Errorf("expected unsupported runtime error but got exit code %d: %s", code, stderr)
+	}
+}
+
+func TestBatch(t *testing.T) {
+	cfg := testConfiguration()
+	cfg.Runtime.AdditionalArguments = "-Dfoo=100%"
+
+	script, err := Batch(cfg)
+	if err != nil {
+		t.Fatalf("failed to generate script: %s", err)
+	}
+
+	detected, err := internal.DetectTarget(bytes.NewReader(script))
+	if err != nil || detected.Name() != "script-cmd" {
+		t.Errorf("expected script-cmd target but got %v (%v)", detected, err)
+	}
+
+	expected := `"%CANOE_JAVA%" "-Xmx1G" "--add-opens" "java.base/java.lang=ALL-UNNAMED" "-Dfoo=100%%" "-cp" "%CANOE_ARCHIVE%;%CANOE_DIRECTORY%\lib\bar.jar" "foo.Main" %*` + "\r\n"
+	if !bytes.Contains(script, []byte(expected)) {
+		t.Errorf("expected script to contain launch statement %q", expected)
+	}
+
+	cfg.Application.MainClass = `foo"Main`
+	if _, err := Batch(cfg); !errors.Is(err, ErrUnsupportedValue) {
+		t.Errorf("expected unsupported value error but got %v", err)
+	}
+}
+
+func TestLaunchVariants(t *testing.T) {
+	cfg := testConfiguration()
+
+	variants, err := launchVariants(cfg, shellDialect)
+	if err != nil {
+		t.Fatalf("failed to compute launch variants: %s", err)
+	}
+	if len(variants) != 2 || variants[0].minimumVersion != 17 || variants[1].minimumVersion != 11 {
+		t.Errorf("expected variants for versions 17 and 11 but got %d variants", len(variants))
+	}
+
+	// variants which are excluded by the version constraints or do not differ are omitted
+	cfg.Runtime.MaximumVersion = 16
+	variants, err = launchVariants(cfg, shellDialect)
+	if err != nil {
+		t.Fatalf("failed to compute launch variants: %s", err)
+	}
+	if len(variants) != 1 {
+		t.Errorf("expected a single variant but got %d", len(variants))
+	}
+}
@@ -0,0 +1,150 @@
+/*
+ * Copyright 2021 Johannes Donath <johannesd@torchmind.com>
+ * and other copyright owners as documented in the project's IP log.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package script
+
+import (
+	"fmt"
+	"github.com/dotstart/canoe/internal"
+	"github.com/dotstart/canoe/internal/metadata"
+	"regexp"
+	"strings"
+)
+
+// matches characters which require quoting within shell scripts
+var shellUnsafePattern = regexp.MustCompile(`[^A-Za-z0-9_@%+=:,./-]`)
+
+var shellDialect = &dialect{
+	archive:       `"$canoe_archive"`,
+	directory:     `"$canoe_directory"`,
+	pathSeparator: "/",
+	listSeparator: ":",
+	isAbsolute: func(path string) bool {
+		return strings.HasPrefix(path, "/")
+	},
+	escape: func(value string) (string, error) {
+		return shellQuote(value), nil
+	},
+	word: func(parts ...string) string {
+		return strings.Join(parts, "")
+	},
+}
+
+// extracts the major version number from the version information of a given runtime executable
+//
+// legacy runtimes report their version using a "1." prefix (e.g. "1.8.0_302") while GA releases
+// of modern runtimes may omit minor and patch versions entirely (e.g. "17")
+const shellVersionFunction = `canoe_version() {
+	"$1" -version 2>&1 | awk -F '"' '/ version "/ { split($2, v, "."); m = (v[1] == "1") ? v[2] : v[1]; sub(/[^0-9].*$/, "", m); print m; exit }'
+}
+`
+
+// Shell generates a POSIX shell script which launches the application described by the passed
+// metadata.
+//
+// The script locates a compatible runtime via JAVA_HOME or PATH and passes itself as the class path
+// of the application. As such, the payload and container metadata are expected to be appended to the
+// script.
+func Shell(cfg *metadata.ApplicationContainer) ([]byte, error) {
+	variants, err := launchVariants(cfg, shellDialect)
+	if err != nil {
+		return nil, err
+	}
+	messages := errorMessages(cfg)
+
+	minimumVersion := cfg.GetRuntime().GetMinimumVersion()
+	maximumVersion := cfg.GetRuntime().GetMaximumVersion()
+
+	condition := fmt.Sprintf(`[ "$canoe_version" -ge %d ]`, minimumVersion)
+	if maximumVersion != 0 {
+		condition += fmt.Sprintf(` && [ "$canoe_version" -le %d ]`, maximumVersion)
+	}
+
+	b := &strings.Builder{}
+	b.WriteString(internal.ShellScriptHeader)
+	b.WriteString(`#
+# Locates a compatible Java runtime and launches the application archive which has been appended to
+# this script. Binary data follows the final line of this script.
+
+`)
+	b.WriteString(shellVersionFunction)
+	b.WriteString(`
+canoe_java=
+canoe_found=none
+canoe_invalid=
+for canoe_candidate in ${JAVA_HOME:+"$JAVA_HOME/bin/java"} "$(command -v java)"; do
+	[ -n "$canoe_candidate" ] && [ -x "$canoe_candidate" ] || continue
+
+	canoe_version=$(canoe_version "$canoe_candidate")
+	case $canoe_version in
+	'' | *[!0-9]*)
+		canoe_invalid=$canoe_candidate
+		continue
+		;;
+	esac
+
+	canoe_found=$canoe_version
+	if ` + condition + `; then
+		canoe_java=$canoe_candidate
+		break
+	fi
+done
+
+if [ -z "$canoe_java" ]; then
+	if [ "$canoe_found" != none ]; then
+`)
+	writeShellMessage(b, messages[internal.RuntimeUnsupportedError], "\t\t")
+	b.WriteString("\telif [ -n \"$canoe_invalid\" ]; then\n")
+	writeShellMessage(b, messages[internal.RuntimeInvalidError], "\t\t")
+	b.WriteString("\telse\n")
+	writeShellMessage(b, messages[internal.RuntimeNotFoundError], "\t\t")
+	fmt.Fprintf(b, "\tfi\n\texit %d\nfi\n", runtimeErrorExitCode)
+
+	b.WriteString(`
+canoe_directory=$(CDPATH= cd -- "$(dirname -- "$0")" && pwd) || exit 1
+canoe_archive=$canoe_directory/$(basename -- "$0")
+
+`)
+	for _, variant := range variants[:len(variants)-1] {
+		fmt.Fprintf(b, "if [ \"$canoe_version\" -ge %d ]; then\n\texec \"$canoe_java\" %s \"$@\"\nfi\n", variant.minimumVersion, strings.Join(variant.arguments, " "))
+	}
+	fmt.Fprintf(b, "exec \"$canoe_java\" %s \"$@\"\n", strings.Join(variants[len(variants)-1].arguments, " "))
+
+	return []byte(b.String()), nil
+}
+
+// writes a set of statements which print a given message to the standard error stream
+func writeShellMessage(b *strings.Builder, lines []string, indent string) {
+	for _, line := range lines {
+		parts := strings.Split(line, foundPlaceholder)
+		for i, part := range parts {
+			parts[i] = shellQuote(part)
+		}
+
+		fmt.Fprintf(b, "%sprintf '%%s\\n' %s >&2\n", indent, strings.Join(parts, `"$canoe_found"`))
+	}
+}
+
+// quotes a given value for use within shell scripts
+//
+// values which consist of safe characters only are passed verbatim
+func shellQuote(value string) string {
+	if len(value) != 0 && !shellUnsafePattern.MatchString(value) {
+		return value
+	}
+
+	return "'" + strings.ReplaceAll(value, "'", `'\''`) + "'"
+}
@@ -0,0 +1,290 @@
+/*
+ * Copyright 2021 Johannes Donath <johannesd@torchmind.com>
+ * and other copyright owners as documented in the project's IP log.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package internal
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/x509"
+	"encoding/binary"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"github.com/dotstart/canoe/internal/metadata"
+	"github.com/golang/protobuf/proto"
+	"google.golang.org/protobuf/encoding/protowire"
+	"io"
+	"os"
+)
+
+const signatureFieldNumber = 25
+const signatureContext = "canoe-signature-v2\x00"
+
+const pinnedKeyMarkerLength = 16
+
+// pinnedPublicKey holds the public key which has been pinned into a wrapper executable at wrap time.
+// The key is prefixed with a marker which permits the generator to locate it within compiled
+// wrapper executables.
+var pinnedPublicKey = [pinnedKeyMarkerLength + ed25519.PublicKeySize]byte{
+	'c', 'a', 'n', 'o', 'e', '-', 'p', 'i', 'n', 'n', 'e', 'd', '-', 'k', 'e', 'y',
+}
+
+var ErrUnsigned = errors.New("executable is not signed")
+var ErrInvalidSignature = errors.New("invalid signature")
+var ErrPinningUnsupported = errors.New("wrapper does not support key pinning")
+
+// PinnedPublicKey retrieves the public key which has been pinned into the current executable.
+//
+// Returns nil when no key has been pinned.
+func PinnedPublicKey() ed25519.PublicKey {
+	key := pinnedPublicKey[pinnedKeyMarkerLength:]
+	if bytes.Equal(key, make([]byte, ed25519.PublicKeySize)) {
+		return nil
+	}
+
+	return key
+}
+
+// PinPublicKey creates a copy of a given wrapper executable which enforces signatures by the
+// passed public key.
+//
+// Compressed wrappers (e.g. as produced by UPX) cannot be pinned as their pinned key field cannot
+// be located.
+func PinPublicKey(wrapper []byte, key ed25519.PublicKey) ([]byte, error) {
+	field := make([]byte, len(pinnedPublicKey))
+	copy(field, pinnedPublicKey[:pinnedKeyMarkerLength])
+
+	offset := bytes.Index(wrapper, field)
+	if offset == -1 || bytes.Index(wrapper[offset+len(field):], field) != -1 {
+		return nil, ErrPinningUnsupported
+	}
+
+	pinned := make([]byte, len(wrapper))
+	copy(pinned, wrapper)
+	copy(pinned[offset+pinnedKeyMarkerLength:], key)
+
+	return pinned, nil
+}
+
+// FindPinnedPublicKey locates the public key which has been pinned into a given wrapper executable.
+//
+// Returns nil when no key has been pinned or the wrapper does not support pinning.
+func FindPinnedPublicKey(wrapper []byte) ed25519.PublicKey {
+	offset := bytes.Index(wrapper, pinnedPublicKey[:pinnedKeyMarkerLength])
+	if offset == -1 || offset+len(pinnedPublicKey) > len(wrapper) {
+		return nil
+	}
+
+	key := wrapper[offset+pinnedKeyMarkerLength : offset+len(pinnedPublicKey)]
+	if bytes.Equal(key, make([]byte, ed25519.PublicKeySize)) {
+		return nil
+	}
+
+	return key
+}
+
+// WriteSignedExecutableFooter encodes and signs the container metadata along with a footer
+// describing the layout of the executable.
+//
+// The signature covers the layout of the executable (as described by the footer) in addition to
+// the container metadata. Any signature present within the passed metadata is replaced.
+func WriteSignedExecutableFooter(writer io.Writer, footer *Footer, meta *metadata.ApplicationContainer, key ed25519.PrivateKey) (int, error) {
+	unsigned := proto.Clone(meta).(*metadata.ApplicationContainer)
+	unsigned.Signature = nil
+
+	encoded, err := proto.Marshal(unsigned)
+	if err != nil {
+		return 0, fmt.Errorf("failed to encode container metadata: %w", err)
+	}
+
+	signature := &metadata.ApplicationContainer{
+		Signature: &metadata.SignatureConfiguration{
+			PublicKey: key.Public().(ed25519.PublicKey),
+			Signature: make([]byte, ed25519.SignatureSize),
+		},
+	}
+
+	// signatures are of constant size thus permitting us to compute the final metadata length in
+	// advance
+	metadataLength := uint64(len(encoded) + proto.Size(signature))
+	signature.Signature.Signature = ed25519.Sign(key, signedMessage(footer.PayloadOffset, footer.PayloadLength, metadataLength, encoded))
+
+	encodedSignature, err := proto.Marshal(signature)
+	if err != nil {
+		return 0, fmt.Errorf("failed to encode signature: %w", err)
+	}
+
+	// protobuf decoders merge repeated occurrences of messages thus permitting us to simply append
+	// the signature to the signed data
+	return writeFooter(writer, footer, append(encoded, encodedSignature...))
+}
+
+// VerifySignature verifies the signature of a given executable against the passed public key.
+//
+// When no public key is given, the signature is verified against the public key embedded within
+// the signature itself (e.g. only its consistency is checked).
+func VerifySignature(r io.ReaderAt, footer *Footer, meta *metadata.ApplicationContainer, key ed25519.PublicKey) error {
+	signature := meta.GetSignature()
+	if signature == nil {
+		return ErrUnsigned
+	}
+
+	if key == nil {
+		key = signature.PublicKey
+	}
+	if len(key) != ed25519.PublicKeySize || !bytes.Equal(key, signature.PublicKey) {
+		return fmt.Errorf("%w: signed by unknown key", ErrInvalidSignature)
+	}
+
+	encoded := make([]byte, footer.MetadataLength)
+	if _, err := r.ReadAt(encoded, int64(footer.MetadataOffset)); err != nil {
+		return fmt.Errorf("failed to read container metadata: %w", err)
+	}
+
+	unsigned, err := stripSignature(encoded)
+	if err != nil {
+		return fmt.Errorf("%w: %s", ErrInvalidSignature, err)
+	}
+
+	// offsets within section footers are encoded relative to the beginning of their section
+	payloadOffset := footer.PayloadOffset - footer.SectionOffset
+	if !ed25519.Verify(key, signedMessage(payloadOffset, footer.PayloadLength, footer.MetadataLength, unsigned), signature.Signature) {
+		return fmt.Errorf("%w: signature mismatch", ErrInvalidSignature)
+	}
+
+	return nil
+}
+
+// VerifyExecutableSignature verifies the signature of a given executable against its pinned public
+// key (if any).
+//
+// Unsigned executables are accepted unless a public key has been pinned.
+func VerifyExecutableSignature(executable string, footer *Footer, meta *metadata.ApplicationContainer) error {
+	key := PinnedPublicKey()
+	if key == nil && meta.GetSignature() == nil {
+		return nil
+	}
+
+	// the wrapper digest is omitted by executables which embed their payload within an ELF section
+	// (the signature covers the metadata thus preventing its removal)
+	if len(meta.GetIntegrity().GetPayloadSha256()) == 0 {
+		return fmt.Errorf("%w: signed executable lacks digests", ErrInvalidSignature)
+	}
+
+	f, err := os.Open(executable)
+	if err != nil {
+		return fmt.Errorf("failed to open executable: %w", err)
+	}
+	defer f.Close()
+
+	return VerifySignature(f, footer, meta, key)
+}
+
+// removes the signature field from a given encoded container
+//
+// the signature is required to be encoded exactly once at the end of the container as additional
+// data would otherwise be merged into the decoded container without being covered by the signature
+func stripSignature(encoded []byte) ([]byte, error) {
+	for remaining := encoded; len(remaining) != 0; {
+		number, typ, n := protowire.ConsumeTag(remaining)
+		if n < 0 {
+			return nil, protowire.ParseError(n)
+		}
+
+		m := protowire.ConsumeFieldValue(number, typ, remaining[n:])
+		if m < 0 {
+			return nil, protowire.ParseError(m)
+		}
+
+		if number == signatureFieldNumber {
+			if len(remaining) != n+m {
+				return nil, errors.New("signature is followed by additional data")
+			}
+
+			return encoded[:len(encoded)-len(remaining)], nil
+		}
+		remaining = remaining[n+m:]
+	}
+
+	return nil, errors.New("signature field is missing")
+}
+
+// computes the message which is signed for a given set of encoded container metadata and the
+// layout of the executable which embeds it
+func signedMessage(payloadOffset uint64, payloadLength uint64, metadataLength uint64, encoded []byte) []byte {
+	message := &bytes.Buffer{}
+	message.WriteString(signatureContext)
+	_ = binary.Write(message, byteOrder, []uint64{payloadOffset, payloadLength, metadataLength})
+	message.Write(encoded)
+
+	return message.Bytes()
+}
+
+// ReadPrivateKey reads a PEM encoded PKCS #8 ed25519 private key from a given file.
+func ReadPrivateKey(path string) (ed25519.PrivateKey, error) {
+	block, err := readPEM(path, "PRIVATE KEY")
+	if err != nil {
+		return nil, err
+	}
+
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse private key: %w", err)
+	}
+
+	privateKey, ok := key.(ed25519.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("unsupported private key type %T: ed25519 key expected", key)
+	}
+
+	return privateKey, nil
+}
+
+// ReadPublicKey reads a PEM encoded PKIX ed25519 public key from a given file.
+func ReadPublicKey(path string) (ed25519.PublicKey, error) {
+	block, err := readPEM(path, "PUBLIC KEY")
+	if err != nil {
+		return nil, err
+	}
+
+	key, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse public key: %w", err)
+	}
+
+	publicKey, ok := key.(ed25519.PublicKey)
+	if !ok {
+		return nil, fmt.Errorf("unsupported public key type %T: ed25519 key expected", key)
+	}
+
+	return publicKey, nil
+}
+
+// reads a PEM block of a given type from a file
+func readPEM(path string, typ string) (*pem.Block, error) {
+	encoded, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read key: %w", err)
+	}
+
+	block, _ := pem.Decode(encoded)
+	if block == nil || block.Type != typ {
+		return nil, fmt.Errorf("failed to decode key: %s block expected", typ)
+	}
+
+	return block, nil
+}
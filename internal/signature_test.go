@@ -0,0 +1,170 @@
+/*
+ * Copyright 2021 Johannes Donath <johannesd@torchmind.com>
+ * and other copyright owners as documented in the project's IP log.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package internal
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"errors"
+	"github.com/dotstart/canoe/internal/metadata"
+	"github.com/golang/protobuf/proto"
+	"testing"
+)
+
+// writes a signed test executable to memory
+func writeSignedTestExecutable(t *testing.T, key ed25519.PrivateKey) ([]byte, *Footer) {
+	buf := &bytes.Buffer{}
+	buf.Write(testWrapper)
+	buf.Write(testPayload)
+
+	footer := &Footer{
+		PayloadOffset: uint64(len(testWrapper)),
+		PayloadLength: uint64(len(testPayload)),
+	}
+	if _, err := WriteSignedExecutableFooter(buf, footer, testMetadata(), key); err != nil {
+		t.Fatalf("failed to write signed footer: %s", err)
+	}
+
+	return buf.Bytes(), footer
+}
+
+func TestVerifySignature(t *testing.T) {
+	publicKey, privateKey, _ := ed25519.GenerateKey(nil)
+	otherKey, _, _ := ed25519.GenerateKey(nil)
+
+	executable, _ := writeSignedTestExecutable(t, privateKey)
+
+	footer, meta, err := DecodeExecutableFooter(bytes.NewReader(executable), int64(len(executable)))
+	if err != nil {
+		t.Fatalf("failed to decode signed footer: %s", err)
+	}
+	if meta.Application.MainClass != "foo.Main" {
+		t.Errorf("expected main class foo.Main but got %q", meta.Application.MainClass)
+	}
+
+	if err := VerifySignature(bytes.NewReader(executable), footer, meta, publicKey); err != nil {
+		t.Errorf("expected valid signature but got %s", err)
+	}
+	if err := VerifySignature(bytes.NewReader(executable), footer, meta, nil); err != nil {
+		t.Errorf("expected consistent signature but got %s", err)
+	}
+	if err := VerifySignature(bytes.NewReader(executable), footer, meta, otherKey); !errors.Is(err, ErrInvalidSignature) {
+		t.Errorf("expected invalid signature error for unknown key but got %v", err)
+	}
+
+	// flip a bit within the main class name
+	offset := bytes.Index(executable, []byte("foo.Main"))
+	executable[offset] ^= 1
+
+	footer, meta, err = DecodeExecutableFooter(bytes.NewReader(executable), int64(len(executable)))
+	if err != nil {
+		t.Fatalf("failed to decode modified footer: %s", err)
+	}
+	if err := VerifySignature(bytes.NewReader(executable), footer, meta, publicKey); !errors.Is(err, ErrInvalidSignature) {
+		t.Errorf("expected invalid signature error for modified metadata but got %v", err)
+	}
+}
+
+func TestVerifySignatureLayout(t *testing.T) {
+	publicKey, privateKey, _ := ed25519.GenerateKey(nil)
+
+	executable, _ := writeSignedTestExecutable(t, privateKey)
+	footer, meta, err := DecodeExecutableFooter(bytes.NewReader(executable), int64(len(executable)))
+	if err != nil {
+		t.Fatalf("failed to decode signed footer: %s", err)
+	}
+
+	// shifting the payload boundary does not affect the metadata itself
+	footer.PayloadOffset--
+	footer.PayloadLength++
+	if err := VerifySignature(bytes.NewReader(executable), footer, meta, publicKey); !errors.Is(err, ErrInvalidSignature) {
+		t.Errorf("expected invalid signature error for modified layout but got %v", err)
+	}
+}
+
+func TestStripSignature(t *testing.T) {
+	encoded, err := proto.Marshal(testMetadata())
+	if err != nil {
+		t.Fatalf("failed to encode metadata: %s", err)
+	}
+	signature, err := proto.Marshal(&metadata.ApplicationContainer{
+		Signature: &metadata.SignatureConfiguration{Signature: []byte("signature")},
+	})
+	if err != nil {
+		t.Fatalf("failed to encode signature: %s", err)
+	}
+
+	unsigned, err := stripSignature(append(append([]byte{}, encoded...), signature...))
+	if err != nil {
+		t.Fatalf("failed to strip signature: %s", err)
+	}
+	if !bytes.Equal(unsigned, encoded) {
+		t.Errorf("expected unsigned container to be retained")
+	}
+
+	for name, data := range map[string][]byte{
+		"missing":   encoded,
+		"duplicate": append(append(append([]byte{}, encoded...), signature...), signature...),
+		"trailing":  append(append(append([]byte{}, encoded...), signature...), encoded...),
+	} {
+		if _, err := stripSignature(data); err == nil {
+			t.Errorf("%s: expected signature to be rejected", name)
+		}
+	}
+}
+
+func TestVerifySignatureUnsigned(t *testing.T) {
+	buf := &bytes.Buffer{}
+	footer := &Footer{}
+	if _, err := WriteExecutableFooter(buf, footer, testMetadata()); err != nil {
+		t.Fatalf("failed to write footer: %s", err)
+	}
+
+	footer, meta, err := DecodeExecutableFooter(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatalf("failed to decode footer: %s", err)
+	}
+	if err := VerifySignature(bytes.NewReader(buf.Bytes()), footer, meta, nil); !errors.Is(err, ErrUnsigned) {
+		t.Errorf("expected unsigned error but got %v", err)
+	}
+}
+
+func TestPinPublicKey(t *testing.T) {
+	publicKey, _, _ := ed25519.GenerateKey(nil)
+
+	wrapper := append([]byte("prefix"), pinnedPublicKey[:]...)
+	wrapper = append(wrapper, []byte("suffix")...)
+
+	if key := FindPinnedPublicKey(wrapper); key != nil {
+		t.Errorf("expected no pinned key but got %x", key)
+	}
+
+	pinned, err := PinPublicKey(wrapper, publicKey)
+	if err != nil {
+		t.Fatalf("failed to pin key: %s", err)
+	}
+	if len(pinned) != len(wrapper) {
+		t.Errorf("expected pinned wrapper of %d bytes but got %d", len(wrapper), len(pinned))
+	}
+	if key := FindPinnedPublicKey(pinned); !bytes.Equal(key, publicKey) {
+		t.Errorf("expected pinned key %x but got %x", publicKey, key)
+	}
+
+	if _, err := PinPublicKey([]byte("compressed"), publicKey); !errors.Is(err, ErrPinningUnsupported) {
+		t.Errorf("expected pinning unsupported error but got %v", err)
+	}
+}
@@ -0,0 +1,66 @@
+/*
+ * Copyright 2021 Johannes Donath <johannesd@torchmind.com>
+ * and other copyright owners as documented in the project's IP log.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package internal
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// IsGuiExecutable identifies whether the running executable has been built for the Windows GUI
+// subsystem or resides within a Mac OS application bundle.
+//
+// The subsystem is selected by the generator when the wrapper is embedded within an executable.
+// Always evaluates to false for executables outside of application bundles on platforms other than
+// Windows.
+func IsGuiExecutable() bool {
+	executable, err := os.Executable()
+	if err != nil {
+		return false
+	}
+
+	// applications launched from within bundles are typically started via Finder and thus lack a
+	// terminal
+	if IsBundleExecutable(executable) {
+		return true
+	}
+
+	f, err := os.Open(executable)
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+
+	target, err := DetectTarget(f)
+	if err != nil {
+		return false
+	}
+
+	return target.Gui
+}
+
+// IsBundleExecutable identifies whether a given executable resides within a Mac OS application
+// bundle (e.g. within the "Contents/MacOS" directory of a ".app" directory).
+func IsBundleExecutable(executable string) bool {
+	macOS := filepath.Dir(executable)
+	contents := filepath.Dir(macOS)
+
+	return filepath.Base(macOS) == "MacOS" &&
+		filepath.Base(contents) == "Contents" &&
+		strings.HasSuffix(filepath.Dir(contents), ".app")
+}
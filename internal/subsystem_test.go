@@ -0,0 +1,40 @@
+/*
+ * Copyright 2021 Johannes Donath <johannesd@torchmind.com>
+ * and other copyright owners as documented in the project's IP log.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package internal
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestIsBundleExecutable(t *testing.T) {
+	cases := map[string]bool{
+		filepath.Join("Applications", "Foo.app", "Contents", "MacOS", "foo"): true,
+		filepath.Join("Foo.app", "Contents", "MacOS", "foo"):                 true,
+		filepath.Join("Foo", "Contents", "MacOS", "foo"):                     false,
+		filepath.Join("Foo.app", "Contents", "Resources", "foo"):             false,
+		filepath.Join("Foo.app", "MacOS", "foo"):                             false,
+		filepath.Join("bin", "foo"):                                          false,
+		"foo":                                                                false,
+	}
+
+	for path, expected := range cases {
+		if actual := IsBundleExecutable(path); actual != expected {
+			t.Errorf("expected %v for %s but got %v", expected, path, actual)
+		}
+	}
+}
@@ -0,0 +1,180 @@
+/*
+ * Copyright 2021 Johannes Donath <johannesd@torchmind.com>
+ * and other copyright owners as documented in the project's IP log.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package internal
+
+import (
+	"bytes"
+	"debug/elf"
+	"debug/macho"
+	"debug/pe"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// identifies the architecture of universal Mach-O executables which combine multiple
+// architectures
+const UniversalArch = "universal"
+
+// identifies the launch scripts which are generated for platforms without a prebuilt wrapper
+//
+// POSIX shell scripts carry no architecture while Windows batch scripts are identified by their
+// file extension
+const ScriptOS = "script"
+const BatchScriptArch = "cmd"
+
+// identify launch scripts based on their leading lines
+//
+// plain scripts which lack these markers are not considered to be wrappers
+const ShellScriptHeader = "#!/bin/sh\n# canoe launch script\n"
+const BatchScriptHeader = "@echo off\r\nrem canoe launch script\r\n"
+
+var ErrUnknownTarget = errors.New("unknown target")
+
+// Target describes the platform for which a given wrapper executable has been built.
+type Target struct {
+	OS   string
+	Arch string
+
+	// identifies whether the wrapper has been built for the GUI subsystem (only applies to Windows)
+	Gui bool
+}
+
+// Name returns the name of the target as used by the generator (e.g. "linux-amd64" or "script").
+func (t *Target) Name() string {
+	if len(t.Arch) == 0 {
+		return t.OS
+	}
+
+	return t.OS + "-" + t.Arch
+}
+
+var elfArchitectures = map[elf.Machine]string{
+	elf.EM_386:     "386",
+	elf.EM_X86_64:  "amd64",
+	elf.EM_ARM:     "arm",
+	elf.EM_AARCH64: "arm64",
+	elf.EM_RISCV:   "riscv64",
+	elf.EM_PPC64:   "ppc64",
+	elf.EM_S390:    "s390x",
+	elf.EM_MIPS:    "mips",
+}
+
+var machoArchitectures = map[macho.Cpu]string{
+	macho.Cpu386:   "386",
+	macho.CpuAmd64: "amd64",
+	macho.CpuArm64: "arm64",
+}
+
+var peArchitectures = map[uint16]string{
+	pe.IMAGE_FILE_MACHINE_I386:  "386",
+	pe.IMAGE_FILE_MACHINE_AMD64: "amd64",
+	pe.IMAGE_FILE_MACHINE_ARM64: "arm64",
+	pe.IMAGE_FILE_MACHINE_ARMNT: "arm",
+}
+
+// DetectTarget identifies the target platform of a given wrapper executable based on its
+// executable header.
+//
+// Launch scripts are identified by their leading lines (refer to ShellScriptHeader and
+// BatchScriptHeader).
+func DetectTarget(r io.ReaderAt) (*Target, error) {
+	if target, ok := detectScriptTarget(r); ok {
+		return target, nil
+	}
+	if f, err := elf.NewFile(r); err == nil {
+		return detectElfTarget(f)
+	}
+	if f, err := macho.NewFile(r); err == nil {
+		arch, ok := machoArchitectures[f.Cpu]
+		if !ok {
+			return nil, fmt.Errorf("%w: unsupported Mach-O architecture %s", ErrUnknownTarget, f.Cpu)
+		}
+
+		return &Target{OS: "darwin", Arch: arch}, nil
+	}
+	if _, err := macho.NewFatFile(r); err == nil {
+		return &Target{OS: "darwin", Arch: UniversalArch}, nil
+	}
+	if f, err := pe.NewFile(r); err == nil {
+		return detectPeTarget(f)
+	}
+
+	return nil, fmt.Errorf("%w: unsupported executable format", ErrUnknownTarget)
+}
+
+// identifies the target platform of a given ELF executable
+func detectElfTarget(f *elf.File) (*Target, error) {
+	arch, ok := elfArchitectures[f.Machine]
+	if !ok {
+		return nil, fmt.Errorf("%w: unsupported ELF architecture %s", ErrUnknownTarget, f.Machine)
+	}
+	if f.ByteOrder == binary.LittleEndian && (f.Machine == elf.EM_PPC64 || f.Machine == elf.EM_MIPS) {
+		arch += "le"
+	}
+
+	os := "linux"
+	switch f.OSABI {
+	case elf.ELFOSABI_FREEBSD:
+		os = "freebsd"
+	case elf.ELFOSABI_NETBSD:
+		os = "netbsd"
+	case elf.ELFOSABI_OPENBSD:
+		os = "openbsd"
+	}
+
+	return &Target{OS: os, Arch: arch}, nil
+}
+
+// identifies the target platform of a given PE executable
+func detectPeTarget(f *pe.File) (*Target, error) {
+	arch, ok := peArchitectures[f.Machine]
+	if !ok {
+		return nil, fmt.Errorf("%w: unsupported PE architecture 0x%04x", ErrUnknownTarget, f.Machine)
+	}
+
+	var subsystem uint16
+	switch header := f.OptionalHeader.(type) {
+	case *pe.OptionalHeader32:
+		subsystem = header.Subsystem
+	case *pe.OptionalHeader64:
+		subsystem = header.Subsystem
+	}
+
+	return &Target{
+		OS:   "windows",
+		Arch: arch,
+		Gui:  subsystem == pe.IMAGE_SUBSYSTEM_WINDOWS_GUI,
+	}, nil
+}
+
+// identifies the target of a given launch script
+func detectScriptTarget(r io.ReaderAt) (*Target, bool) {
+	header := make([]byte, len(BatchScriptHeader))
+	n, _ := r.ReadAt(header, 0)
+	header = header[:n]
+
+	if bytes.HasPrefix(header, []byte(ShellScriptHeader)) {
+		return &Target{OS: ScriptOS}, true
+	}
+	if bytes.HasPrefix(header, []byte(BatchScriptHeader)) {
+		return &Target{OS: ScriptOS, Arch: BatchScriptArch}, true
+	}
+
+	return nil, false
+}
@@ -0,0 +1,73 @@
+/*
+ * Copyright 2021 Johannes Donath <johannesd@torchmind.com>
+ * and other copyright owners as documented in the project's IP log.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package internal
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	goruntime "runtime"
+	"testing"
+)
+
+func TestDetectTarget(t *testing.T) {
+	executable, err := os.Executable()
+	if err != nil {
+		t.Fatalf("failed to locate test executable: %s", err)
+	}
+
+	f, err := os.Open(executable)
+	if err != nil {
+		t.Fatalf("failed to open test executable: %s", err)
+	}
+	defer f.Close()
+
+	target, err := DetectTarget(f)
+	if err != nil {
+		t.Fatalf("failed to detect target of test executable: %s", err)
+	}
+
+	expected := goruntime.GOOS + "-" + goruntime.GOARCH
+	if target.Name() != expected {
+		t.Errorf("expected target %s but got %s", expected, target.Name())
+	}
+}
+
+func TestDetectTargetUnknown(t *testing.T) {
+	if _, err := DetectTarget(bytes.NewReader([]byte("#!/bin/sh\necho hello\n"))); !errors.Is(err, ErrUnknownTarget) {
+		t.Errorf("expected unknown target error but got %v", err)
+	}
+}
+
+func TestDetectTargetScript(t *testing.T) {
+	tests := map[string]string{
+		ShellScriptHeader + "exit 0\n":    "script",
+		BatchScriptHeader + "exit /b\r\n": "script-cmd",
+	}
+
+	for script, expected := range tests {
+		target, err := DetectTarget(bytes.NewReader([]byte(script)))
+		if err != nil {
+			t.Errorf("failed to detect target of %s launch script: %s", expected, err)
+			continue
+		}
+
+		if target.Name() != expected {
+			t.Errorf("expected target %s but got %s", expected, target.Name())
+		}
+	}
+}